@@ -0,0 +1,884 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command jsonhelper-gen writes MarshalJSONObject and UnmarshalJSONObject
+// methods for structs, so that jsonhelper.Marshal and jsonhelper.Unmarshal
+// convert them without reflection. It is meant to be run by go generate:
+//
+//	//go:generate jsonhelper-gen
+//
+//	//jsonhelper:generate
+//	type User struct {
+//	    Name    string    `json:"name,omitempty"`
+//	    Age     int       `json:"age,string"`
+//	    Created time.Time `json:"created,unix"`
+//	}
+//
+// Structs are selected by a //jsonhelper:generate line in their doc comment
+// or by the -type flag, and the methods are written to jsonhelper_gen.go in
+// the package directory.
+//
+// The methods follow the same tag rules as reflection. Fields holding
+// booleans, strings, numbers, time.Time and time.Duration, or pointers to
+// them, are converted inline; other fields are passed to the MarshalField
+// method of jsonhelper.EncodeContext and to jsonhelper.UnmarshalField,
+// which still use the generated methods of the types they hold. Marshal
+// passes its state to the generated MarshalJSONObjectWith method, so
+// cycles and the MaxDepth option are checked as with reflection. Untagged
+// embedded fields must be structs declared in the same package. Their
+// fields are merged into the object, leaving out those that Go's embedding
+// rules hide, as reflection does.
+package main
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "go/ast"
+    "go/format"
+    "go/parser"
+    "go/token"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+const annotation = "//jsonhelper:generate"
+
+const importPath = "github.com/pomack/jsonhelper.go/jsonhelper"
+
+var (
+    typeNames = flag.String("type", "", "comma-separated list of struct types; defaults to those annotated with "+annotation)
+    output    = flag.String("output", "jsonhelper_gen.go", "name of the file to write in the package directory")
+)
+
+func usage() {
+    fmt.Fprintf(os.Stderr, "usage: jsonhelper-gen [flags] [directory]\n")
+    flag.PrintDefaults()
+}
+
+func main() {
+    flag.Usage = usage
+    flag.Parse()
+    dir := "."
+    switch flag.NArg() {
+    case 0:
+    case 1:
+        dir = flag.Arg(0)
+    default:
+        usage()
+        os.Exit(2)
+    }
+    if err := run(dir); err != nil {
+        fmt.Fprintf(os.Stderr, "jsonhelper-gen: %v\n", err)
+        os.Exit(1)
+    }
+}
+
+func run(dir string) error {
+    pkg, err := parsePackage(dir)
+    if err != nil {
+        return err
+    }
+    var names []string
+    if *typeNames != "" {
+        for _, name := range strings.Split(*typeNames, ",") {
+            names = append(names, strings.TrimSpace(name))
+        }
+    } else {
+        names = pkg.annotated
+    }
+    if len(names) == 0 {
+        return fmt.Errorf("no types annotated with %s in %s", annotation, dir)
+    }
+    g := &generator{pkg: pkg, imports: map[string]bool{importPath: true}}
+    for _, name := range names {
+        if err := g.generate(name); err != nil {
+            return err
+        }
+    }
+    src, err := format.Source(g.file())
+    if err != nil {
+        return fmt.Errorf("formatting generated code: %v", err)
+    }
+    return ioutil.WriteFile(filepath.Join(dir, *output), src, 0644)
+}
+
+// pkgInfo holds the struct types of the package being processed.
+type pkgInfo struct {
+    name      string
+    structs   map[string]*ast.StructType
+    types     map[string]bool
+    annotated []string
+    // timeNames are the names the files of the package import "time" as.
+    timeNames map[string]bool
+}
+
+func parsePackage(dir string) (*pkgInfo, error) {
+    fset := token.NewFileSet()
+    filter := func(fi os.FileInfo) bool {
+        return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != *output
+    }
+    pkgs, err := parser.ParseDir(fset, dir, filter, parser.ParseComments)
+    if err != nil {
+        return nil, err
+    }
+    if len(pkgs) != 1 {
+        return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+    }
+    pkg := &pkgInfo{
+        structs:   make(map[string]*ast.StructType),
+        types:     make(map[string]bool),
+        timeNames: make(map[string]bool),
+    }
+    for name, p := range pkgs {
+        pkg.name = name
+        filenames := make([]string, 0, len(p.Files))
+        for filename := range p.Files {
+            filenames = append(filenames, filename)
+        }
+        sort.Strings(filenames)
+        for _, filename := range filenames {
+            pkg.addFile(p.Files[filename])
+        }
+    }
+    return pkg, nil
+}
+
+func (pkg *pkgInfo) addFile(f *ast.File) {
+    for _, imp := range f.Imports {
+        if path, _ := strconv.Unquote(imp.Path.Value); path == "time" {
+            if imp.Name != nil {
+                pkg.timeNames[imp.Name.Name] = true
+            } else {
+                pkg.timeNames["time"] = true
+            }
+        }
+    }
+    for _, decl := range f.Decls {
+        gd, ok := decl.(*ast.GenDecl)
+        if !ok || gd.Tok != token.TYPE {
+            continue
+        }
+        for _, spec := range gd.Specs {
+            ts := spec.(*ast.TypeSpec)
+            pkg.types[ts.Name.Name] = true
+            st, ok := ts.Type.(*ast.StructType)
+            if !ok || ts.TypeParams != nil {
+                continue
+            }
+            pkg.structs[ts.Name.Name] = st
+            doc := ts.Doc
+            if doc == nil && len(gd.Specs) == 1 {
+                doc = gd.Doc
+            }
+            if isAnnotated(doc) {
+                pkg.annotated = append(pkg.annotated, ts.Name.Name)
+            }
+        }
+    }
+}
+
+func isAnnotated(doc *ast.CommentGroup) bool {
+    if doc == nil {
+        return false
+    }
+    for _, c := range doc.List {
+        if strings.TrimSpace(c.Text) == annotation {
+            return true
+        }
+    }
+    return false
+}
+
+// A kind is how the value of a field is converted.
+type kind int
+
+const (
+    // kindOther fields are passed to MarshalField and UnmarshalField.
+    kindOther kind = iota
+    kindBool
+    kindString
+    kindInt
+    kindUint
+    kindFloat
+    kindTime
+    kindDuration
+)
+
+var basicKinds = map[string]kind{
+    "bool":    kindBool,
+    "string":  kindString,
+    "int":     kindInt,
+    "int8":    kindInt,
+    "int16":   kindInt,
+    "int32":   kindInt,
+    "int64":   kindInt,
+    "rune":    kindInt,
+    "uint":    kindUint,
+    "uint8":   kindUint,
+    "uint16":  kindUint,
+    "uint32":  kindUint,
+    "uint64":  kindUint,
+    "uintptr": kindUint,
+    "byte":    kindUint,
+    "float32": kindFloat,
+    "float64": kindFloat,
+}
+
+// genField describes how one struct field is generated.
+type genField struct {
+    goName    string
+    key       string
+    tagged    bool
+    typ       ast.Expr
+    kind      kind
+    basic     string
+    ptr       bool
+    opts      string
+    omitEmpty bool
+    omitZero  bool
+    stringify bool
+    collapse  bool
+    sensitive bool
+    embedded  bool
+    format    string
+    // hidden are the keys of an embedded struct that other fields hide.
+    hidden []string
+}
+
+type generator struct {
+    pkg     *pkgInfo
+    imports map[string]bool
+    buf     bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+    fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) file() []byte {
+    var out bytes.Buffer
+    fmt.Fprintf(&out, "// Code generated by jsonhelper-gen; DO NOT EDIT.\n\n")
+    fmt.Fprintf(&out, "package %s\n\nimport (\n", g.pkg.name)
+    paths := make([]string, 0, len(g.imports))
+    for path := range g.imports {
+        paths = append(paths, path)
+    }
+    sort.Strings(paths)
+    for _, path := range paths {
+        fmt.Fprintf(&out, "\t%q\n", path)
+    }
+    fmt.Fprintf(&out, ")\n")
+    out.Write(g.buf.Bytes())
+    return out.Bytes()
+}
+
+func (g *generator) generate(name string) error {
+    st, ok := g.pkg.structs[name]
+    if !ok {
+        return fmt.Errorf("%s is not a non-generic struct type in package %s", name, g.pkg.name)
+    }
+    fields, err := g.fields(name, st)
+    if err != nil {
+        return err
+    }
+    g.generateMarshal(name, fields)
+    g.generateUnmarshal(name, fields)
+    return nil
+}
+
+// fields lists the fields of st that are converted, following the rules
+// of jsonhelper's typeFields for a single level of the struct.
+func (g *generator) fields(name string, st *ast.StructType) ([]*genField, error) {
+    var fields []*genField
+    for _, f := range st.Fields.List {
+        key, opts, ok, err := fieldTag(f)
+        if err != nil {
+            return nil, err
+        }
+        if !ok {
+            continue
+        }
+        if len(f.Names) == 0 {
+            embedded, err := g.embeddedField(name, f, key, opts)
+            if err != nil {
+                return nil, err
+            }
+            if embedded != nil {
+                fields = append(fields, embedded)
+            }
+            continue
+        }
+        for _, ident := range f.Names {
+            if !ident.IsExported() {
+                continue
+            }
+            fields = append(fields, g.newField(ident.Name, key, opts, f.Type))
+        }
+    }
+    return g.dominantFields(fields), nil
+}
+
+// fieldTag returns the key and options in the json tag of f, and false if
+// the tag skips the field.
+func fieldTag(f *ast.Field) (key, opts string, ok bool, err error) {
+    tag := ""
+    if f.Tag != nil {
+        s, err := strconv.Unquote(f.Tag.Value)
+        if err != nil {
+            return "", "", false, err
+        }
+        tag = reflect.StructTag(s).Get("json")
+    }
+    if tag == "-" {
+        return "", "", false, nil
+    }
+    key = tag
+    if i := strings.Index(tag, ","); i >= 0 {
+        key, opts = tag[:i], tag[i+1:]
+    }
+    if !isValidTag(key) {
+        key = ""
+    }
+    return key, opts, true, nil
+}
+
+// embeddedType returns the name of the type of an embedded field, whether
+// it is exported, and whether it is a struct declared in the package.
+func (g *generator) embeddedType(typ ast.Expr) (typeName string, exported, local, ok bool) {
+    if star, isStar := typ.(*ast.StarExpr); isStar {
+        typ = star.X
+    }
+    switch t := typ.(type) {
+    case *ast.Ident:
+        return t.Name, t.IsExported(), g.pkg.structs[t.Name] != nil, true
+    case *ast.SelectorExpr:
+        return t.Sel.Name, t.Sel.IsExported(), false, true
+    }
+    return "", false, false, false
+}
+
+func (g *generator) embeddedField(name string, f *ast.Field, key, opts string) (*genField, error) {
+    typeName, exported, local, ok := g.embeddedType(f.Type)
+    if !ok {
+        return nil, fmt.Errorf("%s: unsupported embedded field type", name)
+    }
+    if key != "" {
+        if !exported {
+            return nil, nil
+        }
+        return g.newField(typeName, key, opts, f.Type), nil
+    }
+    if !local {
+        if !exported {
+            return nil, nil
+        }
+        return nil, fmt.Errorf("%s: embedded field %s must be a struct declared in package %s or have a json tag name", name, typeName, g.pkg.name)
+    }
+    field := g.newField(typeName, "", opts, f.Type)
+    field.kind = kindOther
+    field.collapse = true
+    field.embedded = true
+    return field, nil
+}
+
+func (g *generator) newField(goName, key, opts string, typ ast.Expr) *genField {
+    f := &genField{
+        goName:    goName,
+        key:       key,
+        tagged:    key != "",
+        typ:       typ,
+        opts:      opts,
+        omitEmpty: hasOption(opts, "omitempty"),
+        omitZero:  hasOption(opts, "omitzero"),
+        stringify: hasOption(opts, "string"),
+        collapse:  hasOption(opts, "collapse"),
+        sensitive: hasOption(opts, "sensitive"),
+    }
+    if key == "" {
+        f.key = goName
+    }
+    elem := typ
+    if star, ok := typ.(*ast.StarExpr); ok {
+        elem, f.ptr = star.X, true
+    }
+    switch t := elem.(type) {
+    case *ast.Ident:
+        if k, ok := basicKinds[t.Name]; ok && !g.pkg.types[t.Name] {
+            f.kind, f.basic = k, t.Name
+        }
+    case *ast.SelectorExpr:
+        if x, ok := t.X.(*ast.Ident); ok && g.pkg.timeNames[x.Name] {
+            switch t.Sel.Name {
+            case "Time":
+                f.kind = kindTime
+            case "Duration":
+                f.kind = kindDuration
+            }
+        }
+    }
+    switch f.kind {
+    case kindTime:
+        f.format = "2006-01-02T15:04:05.999999999Z07:00"
+        if format, ok := getOption(opts, "format"); ok && format != "" {
+            f.format = format
+        }
+        if hasOption(opts, "unix") {
+            f.format = "unix"
+        }
+        if hasOption(opts, "unixmilli") {
+            f.format = "unixmilli"
+        }
+    case kindDuration:
+        if _, ok := getOption(opts, "duration"); ok {
+            f.kind = kindOther
+        }
+    }
+    if f.collapse {
+        f.kind = kindOther
+    }
+    return f
+}
+
+// A promotedKey is a key that a field of a struct, or of a struct it
+// embeds, is converted under.
+type promotedKey struct {
+    key    string
+    depth  int
+    tagged bool
+    // field is the field of the generated struct that the key comes from.
+    field *genField
+}
+
+// promotedKeys lists the keys of the struct embedded by f, searching the
+// structs it embeds in turn breadth first, as typeFields does.
+func (g *generator) promotedKeys(f *genField) []promotedKey {
+    var keys []promotedKey
+    typeName, _, _, _ := g.embeddedType(f.typ)
+    next := []string{typeName}
+    nextCount := map[string]int{typeName: 1}
+    visited := make(map[string]bool)
+    for depth := 1; len(next) > 0; depth++ {
+        current, count := next, nextCount
+        next, nextCount = nil, make(map[string]int)
+        for _, name := range current {
+            if visited[name] {
+                continue
+            }
+            visited[name] = true
+            for _, sf := range g.pkg.structs[name].Fields.List {
+                key, _, ok, err := fieldTag(sf)
+                if !ok || err != nil {
+                    continue
+                }
+                var names []string
+                if len(sf.Names) == 0 {
+                    embedded, exported, local, ok := g.embeddedType(sf.Type)
+                    if ok && key == "" && local {
+                        nextCount[embedded]++
+                        if nextCount[embedded] == 1 {
+                            next = append(next, embedded)
+                        }
+                        continue
+                    }
+                    // The fields of other embedded structs are not known
+                    // here.
+                    if ok && exported && (key != "" || g.pkg.types[embedded]) {
+                        names = append(names, embedded)
+                    }
+                }
+                for _, ident := range sf.Names {
+                    if ident.IsExported() {
+                        names = append(names, ident.Name)
+                    }
+                }
+                for _, n := range names {
+                    k := promotedKey{key: key, depth: depth, tagged: key != "", field: f}
+                    if key == "" {
+                        k.key = n
+                    }
+                    keys = append(keys, k)
+                    if count[name] > 1 {
+                        // A struct embedded twice at this depth hides its
+                        // own fields.
+                        keys = append(keys, k)
+                    }
+                }
+            }
+        }
+    }
+    return keys
+}
+
+// dominantFields applies Go's embedding rules, modified by json tags as in
+// typeFields, to the keys of fields and of the structs they embed. It drops
+// the fields whose keys are hidden and records on each embedded field the
+// keys of its struct that are.
+func (g *generator) dominantFields(fields []*genField) []*genField {
+    var keys []promotedKey
+    for _, f := range fields {
+        if f.embedded {
+            keys = append(keys, g.promotedKeys(f)...)
+        } else {
+            keys = append(keys, promotedKey{key: f.key, tagged: f.tagged, field: f})
+        }
+    }
+    sort.SliceStable(keys, func(i, j int) bool {
+        if keys[i].key != keys[j].key {
+            return keys[i].key < keys[j].key
+        }
+        if keys[i].depth != keys[j].depth {
+            return keys[i].depth < keys[j].depth
+        }
+        return keys[i].tagged && !keys[j].tagged
+    })
+    dominant := make(map[string]*genField)
+    for i, j := 0, 0; i < len(keys); i = j {
+        for j = i + 1; j < len(keys) && keys[j].key == keys[i].key; j++ {
+        }
+        if j == i+1 || keys[i].depth != keys[i+1].depth || keys[i].tagged != keys[i+1].tagged {
+            dominant[keys[i].key] = keys[i].field
+        }
+    }
+    for _, k := range keys {
+        f := k.field
+        if f.embedded && dominant[k.key] != f && (len(f.hidden) == 0 || f.hidden[len(f.hidden)-1] != k.key) {
+            f.hidden = append(f.hidden, k.key)
+        }
+    }
+    var out []*genField
+    for _, f := range fields {
+        if f.embedded || dominant[f.key] == f {
+            out = append(out, f)
+        }
+    }
+    return out
+}
+
+func (g *generator) generateMarshal(name string, fields []*genField) {
+    g.printf("\n// MarshalJSONObject implements jsonhelper.JSONObjectMarshaler.\n")
+    g.printf("func (v %s) MarshalJSONObject() (jsonhelper.JSONObject, error) {\n", name)
+    g.printf("return v.MarshalJSONObjectWith(nil)\n}\n")
+    g.printf("\n// MarshalJSONObjectWith is called by jsonhelper.Marshal, which passes the\n")
+    g.printf("// state of the call on to the fields not converted inline.\n")
+    g.printf("func (v %s) MarshalJSONObjectWith(ctx *jsonhelper.EncodeContext) (jsonhelper.JSONObject, error) {\n", name)
+    g.printf("obj := jsonhelper.NewJSONObject()\n")
+    hasCollapse := false
+    for _, f := range fields {
+        if f.collapse && !f.embedded {
+            hasCollapse = true
+        }
+    }
+    if hasCollapse {
+        g.printf("var collapsed []jsonhelper.JSONObject\n")
+    }
+    for _, f := range fields {
+        if f.kind == kindOther || f.sensitive {
+            g.marshalOther(f)
+        } else {
+            g.marshalInline(f)
+        }
+    }
+    if hasCollapse {
+        g.printf("for _, sub := range collapsed {\n")
+        g.printf("for k, item := range sub {\n")
+        g.printf("if _, exists := obj[k]; !exists {\nobj[k] = item\n}\n")
+        g.printf("}\n}\n")
+    }
+    g.printf("return obj, nil\n}\n")
+}
+
+// omitCondition is the Go condition under which the field is left out,
+// or "" if it never is.
+func (g *generator) omitCondition(f *genField) string {
+    x := "v." + f.goName
+    var conds []string
+    if f.ptr {
+        if f.omitEmpty || f.omitZero {
+            conds = append(conds, x+" == nil")
+        }
+        if f.omitZero && f.kind == kindTime {
+            conds = append(conds, x+".IsZero()")
+        }
+        return strings.Join(conds, " || ")
+    }
+    if f.omitEmpty {
+        if cond := emptyCondition(f, x); cond != "" {
+            conds = append(conds, cond)
+        }
+    }
+    if f.omitZero {
+        switch f.kind {
+        case kindTime:
+            conds = append(conds, x+".IsZero()")
+        case kindOther:
+            conds = append(conds, "jsonhelper.IsZero("+x+")")
+        default:
+            conds = append(conds, emptyCondition(f, x))
+        }
+    }
+    return strings.Join(conds, " || ")
+}
+
+// emptyCondition tests x as the omitempty option does.
+func emptyCondition(f *genField, x string) string {
+    switch f.kind {
+    case kindBool:
+        return "!" + x
+    case kindString:
+        return x + ` == ""`
+    case kindInt, kindUint, kindFloat, kindDuration:
+        return x + " == 0"
+    case kindTime:
+        return ""
+    }
+    switch f.typ.(type) {
+    case *ast.StarExpr, *ast.InterfaceType, *ast.FuncType, *ast.ChanType:
+        return x + " == nil"
+    case *ast.MapType, *ast.ArrayType:
+        return "len(" + x + ") == 0"
+    case *ast.StructType:
+        return ""
+    }
+    return "jsonhelper.IsEmpty(" + x + ")"
+}
+
+func (g *generator) marshalInline(f *genField) {
+    x := "v." + f.goName
+    cond := g.omitCondition(f)
+    if cond != "" {
+        g.printf("if !(%s) {\n", cond)
+    }
+    if f.ptr {
+        g.printf("if %s == nil {\nobj[%q] = nil\n} else {\n", x, f.key)
+        x = "*" + x
+    }
+    g.printf("obj[%q] = %s\n", f.key, g.valueExpr(f, x))
+    if f.ptr {
+        g.printf("}\n")
+    }
+    if cond != "" {
+        g.printf("}\n")
+    }
+}
+
+// valueExpr converts x into the JSON value Marshal would produce.
+func (g *generator) valueExpr(f *genField, x string) string {
+    switch f.kind {
+    case kindBool:
+        if f.stringify {
+            g.imports["strconv"] = true
+            return "strconv.FormatBool(" + x + ")"
+        }
+        return x
+    case kindString:
+        return x
+    case kindInt, kindDuration:
+        if f.stringify {
+            g.imports["strconv"] = true
+            return "strconv.FormatInt(int64(" + x + "), 10)"
+        }
+        return "int64(" + x + ")"
+    case kindUint:
+        if f.stringify {
+            g.imports["strconv"] = true
+            return "strconv.FormatUint(uint64(" + x + "), 10)"
+        }
+        return "uint64(" + x + ")"
+    case kindFloat:
+        if f.stringify {
+            g.imports["strconv"] = true
+            bits := "64"
+            if f.basic == "float32" {
+                bits = "32"
+            }
+            return "strconv.FormatFloat(float64(" + x + "), 'g', -1, " + bits + ")"
+        }
+        return "float64(" + x + ")"
+    case kindTime:
+        expr := "jsonhelper.FormatTime(" + x + ", " + strconv.Quote(f.format) + ")"
+        if f.stringify {
+            return "jsonhelper.JSONValueToString(" + expr + ")"
+        }
+        return expr
+    }
+    panic("unreachable")
+}
+
+func (g *generator) marshalOther(f *genField) {
+    x := "v." + f.goName
+    if cond := g.omitCondition(f); cond != "" {
+        g.printf("if !(%s) {\n", cond)
+    } else {
+        g.printf("{\n")
+    }
+    g.printf("value, err := ctx.MarshalField(%q, %s, %q)\n", f.key, x, f.opts)
+    g.printf("if err != nil {\nreturn nil, err\n}\n")
+    if !f.omitEmpty && !f.collapse {
+        g.printf("obj[%q] = value\n}\n", f.key)
+        return
+    }
+    if f.embedded {
+        // The keys of an embedded struct rank with the struct's own fields,
+        // ahead of those of collapse fields.
+        g.printf("if sub, ok := value.(jsonhelper.JSONObject); ok {\n")
+        g.printf("for k, item := range sub {\n")
+        if len(f.hidden) > 0 {
+            g.printf("switch k {\ncase %s:\ncontinue\n}\n", quoteList(f.hidden))
+        }
+        g.printf("if _, exists := obj[k]; !exists {\nobj[k] = item\n}\n")
+        g.printf("}\n}\n}\n")
+        return
+    }
+    g.printf("sub, isObject := value.(jsonhelper.JSONObject)\n")
+    g.printf("switch {\n")
+    if f.omitEmpty {
+        g.printf("case value == nil || isObject && sub.Len() == 0:\n")
+    }
+    if f.collapse {
+        g.printf("case isObject:\ncollapsed = append(collapsed, sub)\n")
+    }
+    g.printf("default:\nobj[%q] = value\n}\n}\n", f.key)
+}
+
+func (g *generator) generateUnmarshal(name string, fields []*genField) {
+    g.printf("\n// UnmarshalJSONObject implements jsonhelper.JSONObjectUnmarshaler.\n")
+    g.printf("func (v *%s) UnmarshalJSONObject(obj jsonhelper.JSONObject) error {\n", name)
+    for _, f := range fields {
+        if f.embedded && len(f.hidden) > 0 {
+            g.imports["strings"] = true
+            g.printf("{\nsub := jsonhelper.NewJSONObject()\n")
+            g.printf("for k, item := range obj {\n")
+            var conds []string
+            for _, key := range f.hidden {
+                conds = append(conds, fmt.Sprintf("!strings.EqualFold(k, %q)", key))
+            }
+            g.printf("if %s {\nsub[k] = item\n}\n}\n", strings.Join(conds, " && "))
+            g.printf("if err := jsonhelper.UnmarshalField(sub, &v.%s, %q); err != nil {\nreturn err\n}\n}\n", f.goName, f.opts)
+            continue
+        }
+        if f.collapse {
+            g.printf("if err := jsonhelper.UnmarshalField(obj, &v.%s, %q); err != nil {\nreturn err\n}\n", f.goName, f.opts)
+            continue
+        }
+        g.printf("if item, ok := jsonhelper.LookupKey(obj, %q); ok {\n", f.key)
+        if f.kind == kindOther {
+            g.printf("if err := jsonhelper.UnmarshalField(item, &v.%s, %q); err != nil {\nreturn err\n}\n", f.goName, f.opts)
+        } else {
+            g.unmarshalInline(f)
+        }
+        g.printf("}\n")
+    }
+    g.printf("return nil\n}\n")
+}
+
+func (g *generator) unmarshalInline(f *genField) {
+    x := "v." + f.goName
+    if f.ptr {
+        g.printf("if item == nil {\n%s = nil\n} else {\n", x)
+    } else {
+        g.printf("if item != nil {\n")
+    }
+    switch f.kind {
+    case kindBool:
+        g.printf("b, err := jsonhelper.JSONValueToBoolStrict(item)\n")
+        g.printf("if err != nil {\nreturn err\n}\n")
+        g.assign(f, x, "b")
+    case kindString:
+        g.printf("s, err := jsonhelper.JSONValueToStringStrict(item)\n")
+        g.printf("if err != nil {\nreturn err\n}\n")
+        g.assign(f, x, "s")
+    case kindInt:
+        g.printf("n, err := jsonhelper.JSONValueToInt64Strict(item)\n")
+        cond := "err != nil"
+        if f.basic != "int64" {
+            cond += " || int64(" + f.basic + "(n)) != n"
+        }
+        g.printf("if %s {\nreturn jsonhelper.NewUnmarshalTypeError(item, %s(0))\n}\n", cond, f.basic)
+        g.assign(f, x, f.basic+"(n)")
+    case kindUint:
+        g.printf("n, err := jsonhelper.JSONValueToUint64Strict(item)\n")
+        cond := "err != nil"
+        if f.basic != "uint64" {
+            cond += " || uint64(" + f.basic + "(n)) != n"
+        }
+        g.printf("if %s {\nreturn jsonhelper.NewUnmarshalTypeError(item, %s(0))\n}\n", cond, f.basic)
+        g.assign(f, x, f.basic+"(n)")
+    case kindFloat:
+        g.printf("n, err := jsonhelper.JSONValueToFloat64Strict(item)\n")
+        cond := "err != nil"
+        if f.basic == "float32" {
+            g.imports["math"] = true
+            cond += " || math.IsInf(float64(float32(n)), 0) && !math.IsInf(n, 0)"
+        }
+        g.printf("if %s {\nreturn jsonhelper.NewUnmarshalTypeError(item, %s(0))\n}\n", cond, f.basic)
+        g.assign(f, x, f.basic+"(n)")
+    case kindTime:
+        g.printf("t, err := jsonhelper.JSONValueToTimeStrict(item, %q)\n", f.format)
+        g.printf("if err != nil {\nreturn err\n}\n")
+        g.assign(f, x, "t")
+    case kindDuration:
+        g.printf("d, err := jsonhelper.JSONValueToDurationStrict(item, 1)\n")
+        g.printf("if err != nil {\nreturn err\n}\n")
+        g.assign(f, x, "d")
+    }
+    g.printf("}\n")
+}
+
+// assign stores value in the field x, allocating it first if it is a nil
+// pointer.
+func (g *generator) assign(f *genField, x, value string) {
+    if f.ptr {
+        g.printf("val := %s\n", value)
+        g.printf("if %s == nil {\n%s = &val\n} else {\n*%s = val\n}\n", x, x, x)
+        return
+    }
+    g.printf("%s = %s\n", x, value)
+}
+
+// quoteList quotes keys for a case clause.
+func quoteList(keys []string) string {
+    quoted := make([]string, len(keys))
+    for i, key := range keys {
+        quoted[i] = strconv.Quote(key)
+    }
+    return strings.Join(quoted, ", ")
+}
+
+func hasOption(opts, name string) bool {
+    _, ok := getOption(opts, name)
+    return ok
+}
+
+// getOption returns the value of a "name=value" option, and whether the
+// option was present at all.
+func getOption(opts, name string) (string, bool) {
+    for _, opt := range strings.Split(opts, ",") {
+        if opt == name {
+            return "", true
+        }
+        if strings.HasPrefix(opt, name+"=") {
+            return opt[len(name)+1:], true
+        }
+    }
+    return "", false
+}
+
+func isValidTag(s string) bool {
+    if s == "" {
+        return false
+    }
+    for _, c := range s {
+        if c != '$' && c != '-' && c != '_' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+            return false
+        }
+    }
+    return true
+}
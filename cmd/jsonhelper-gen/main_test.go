@@ -0,0 +1,255 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+    "go/ast"
+    "go/importer"
+    "go/parser"
+    "go/token"
+    "go/types"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// generateSource runs the generator on a package made of src and returns
+// the file it writes.
+func generateSource(t *testing.T, src string) (string, string, error) {
+    dir, err := ioutil.TempDir("", "jsonhelper-gen")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { os.RemoveAll(dir) })
+    if err := ioutil.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err := run(dir); err != nil {
+        return dir, "", err
+    }
+    out, err := ioutil.ReadFile(filepath.Join(dir, *output))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return dir, string(out), nil
+}
+
+func TestGenerate(t *testing.T) {
+    tests := []struct {
+        name    string
+        fields  string
+        want    []string
+        notWant []string
+    }{
+        {"omitempty", "Name string `json:\"name,omitempty\"`",
+            []string{`if !(v.Name == "") {`, `obj["name"] = v.Name`}, nil},
+        {"string option", "Age int `json:\"age,string\"`",
+            []string{`strconv.FormatInt(int64(v.Age), 10)`, `"strconv"`}, nil},
+        {"unix time", "Created time.Time `json:\"created,unix\"`",
+            []string{`jsonhelper.FormatTime(v.Created, "unix")`, `JSONValueToTimeStrict(item, "unix")`}, nil},
+        {"format time", "Day time.Time `json:\"day,format=2006-01-02\"`",
+            []string{`jsonhelper.FormatTime(v.Day, "2006-01-02")`}, nil},
+        {"pointer omitzero", "Updated *time.Time `json:\"updated,omitzero\"`",
+            []string{`if !(v.Updated == nil || v.Updated.IsZero()) {`, `v.Updated = &val`}, nil},
+        {"untagged", "Score *float64",
+            []string{`obj["Score"] = float64(*v.Score)`}, nil},
+        {"skipped", "Skip string `json:\"-\"`\n\tprivate int",
+            nil, []string{"Skip", "private"}},
+        {"dash key", "Dash string `json:\"-,\"`",
+            []string{`obj["-"] = v.Dash`}, nil},
+        {"sensitive", "Password string `json:\"password,sensitive\"`",
+            []string{`ctx.MarshalField("password", v.Password, "sensitive")`}, []string{`obj["password"] = v.Password`}},
+        {"other types", "Friends []*T `json:\"friends\"`",
+            []string{`ctx.MarshalField("friends", v.Friends, "")`, `jsonhelper.UnmarshalField(item, &v.Friends, "")`}, nil},
+        {"collapse", "Attrs map[string]string `json:\"attrs,collapse\"`",
+            []string{`collapsed = append(collapsed, sub)`, `if _, exists := obj[k]; !exists {`}, nil},
+        {"embedded", "Base",
+            []string{`ctx.MarshalField("Base", v.Base, "")`, `jsonhelper.UnmarshalField(obj, &v.Base, "")`}, nil},
+    }
+    // The importer is shared so that jsonhelper is only loaded once.
+    fset := token.NewFileSet()
+    imp := importer.ForCompiler(fset, "source", nil)
+    for _, tt := range tests {
+        src := "package sample\n\nimport \"time\"\n\nvar _ time.Time\n\ntype Base struct {\n\tID int `json:\"id\"`\n}\n\n" +
+            "//jsonhelper:generate\ntype T struct {\n\t" + tt.fields + "\n}\n"
+        dir, out, err := generateSource(t, src)
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        for _, w := range tt.want {
+            if !strings.Contains(out, w) {
+                t.Errorf("%s: output lacks %s:\n%s", tt.name, w, out)
+            }
+        }
+        for _, w := range tt.notWant {
+            if strings.Contains(out, w) {
+                t.Errorf("%s: output has %s:\n%s", tt.name, w, out)
+            }
+        }
+        typeCheck(t, tt.name, fset, imp, dir)
+    }
+}
+
+// typeCheck compiles the package in dir, with its generated file, against
+// the jsonhelper sources.
+func typeCheck(t *testing.T, name string, fset *token.FileSet, imp types.Importer, dir string) {
+    pkgs, err := parser.ParseDir(fset, dir, nil, 0)
+    if err != nil {
+        t.Errorf("%s: %v", name, err)
+        return
+    }
+    var files []*ast.File
+    for _, p := range pkgs {
+        for _, f := range p.Files {
+            files = append(files, f)
+        }
+    }
+    conf := types.Config{Importer: imp}
+    if _, err := conf.Check("sample", fset, files, nil); err != nil {
+        if strings.Contains(err.Error(), "could not import "+importPath) {
+            t.Skipf("cannot load %s to type-check: %v", importPath, err)
+        }
+        t.Errorf("%s: generated code does not compile: %v", name, err)
+    }
+}
+
+func TestGenerateErrors(t *testing.T) {
+    tests := []struct {
+        name string
+        src  string
+        want string
+    }{
+        {"no annotated types", "package sample\n\ntype T struct{}\n", "no types annotated"},
+        {"not a struct", "package sample\n\n//jsonhelper:generate\ntype T int\n", "no types annotated"},
+        {"foreign embedded struct", "package sample\n\nimport \"time\"\n\n//jsonhelper:generate\ntype T struct {\n\ttime.Time\n}\n", "embedded"},
+    }
+    for _, tt := range tests {
+        _, _, err := generateSource(t, tt.src)
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.want)
+        }
+    }
+}
+
+// compareProgram checks, for each of values, that the generated methods
+// give what reflection does for the same struct without them.
+const compareProgram = `package main
+
+import (
+    "fmt"
+    "os"
+    "reflect"
+
+    "github.com/pomack/jsonhelper.go/jsonhelper"
+)
+
+type plain T
+
+func main() {
+    failed := false
+    for i, v := range values {
+        want, err := jsonhelper.Marshal(plain(v))
+        if err != nil {
+            panic(err)
+        }
+        got, err := v.MarshalJSONObjectWith(nil)
+        if err != nil {
+            panic(err)
+        }
+        if !jsonhelper.EqualJSONValues(got, want) {
+            fmt.Printf("value %d: generated %v, reflection %v\n", i, got, want)
+            failed = true
+        }
+    }
+    var decoded T
+    var plainDecoded plain
+    if err := jsonhelper.Unmarshal(input, &decoded); err != nil {
+        panic(err)
+    }
+    if err := jsonhelper.Unmarshal(input, &plainDecoded); err != nil {
+        panic(err)
+    }
+    if !reflect.DeepEqual(plain(decoded), plainDecoded) {
+        fmt.Printf("input: generated %+v, reflection %+v\n", decoded, plainDecoded)
+        failed = true
+    }
+    if failed {
+        os.Exit(1)
+    }
+}
+`
+
+func TestGenerateEmbeddedMatchesReflection(t *testing.T) {
+    goTool, err := exec.LookPath("go")
+    if err != nil {
+        t.Skip("no go command to build generated code with")
+    }
+    tests := []struct {
+        name   string
+        types  string
+        values string
+        input  string
+    }{
+        {"same depth hides both",
+            "type A struct {\n\tX int\n\tY int `json:\"y\"`\n}\n\ntype B struct {\n\tX int\n\tY string `json:\"y\"`\n}\n\n" +
+                "//jsonhelper:generate\ntype T struct {\n\tA\n\tB\n\tZ int\n}\n",
+            `[]T{{A{1, 2}, B{3, "b"}, 4}, {}}`,
+            `jsonhelper.JSONObject{"X": 1.0, "y": 2.0, "x": 5.0, "Z": 3.0}`},
+        {"tagged key wins",
+            "type A struct {\n\tX int\n}\n\ntype B struct {\n\tY int `json:\"X\"`\n}\n\n" +
+                "//jsonhelper:generate\ntype T struct {\n\tA\n\tB\n}\n",
+            `[]T{{A{1}, B{2}}}`,
+            `jsonhelper.JSONObject{"X": 1.0}`},
+        {"shallower key wins",
+            "type A struct {\n\tX int\n}\n\ntype B struct {\n\tC\n}\n\ntype C struct {\n\tX int\n\tW int\n}\n\n" +
+                "//jsonhelper:generate\ntype T struct {\n\tB\n\tA\n}\n",
+            `[]T{{B{C{1, 2}}, A{3}}}`,
+            `jsonhelper.JSONObject{"X": 1.0, "W": 2.0}`},
+        {"struct embedded twice",
+            "type A struct {\n\tC\n}\n\ntype B struct {\n\tC\n}\n\ntype C struct {\n\tX int\n}\n\n" +
+                "//jsonhelper:generate\ntype T struct {\n\tA\n\tB\n}\n",
+            `[]T{{A{C{1}}, B{C{2}}}}`,
+            `jsonhelper.JSONObject{"X": 1.0}`},
+        {"own field wins",
+            "type A struct {\n\tX int\n\tY int\n}\n\n" +
+                "//jsonhelper:generate\ntype T struct {\n\t*A\n\tX string `json:\",omitempty\"`\n\tM map[string]interface{} `json:\"m,collapse\"`\n}\n",
+            `[]T{{&A{1, 2}, "x", map[string]interface{}{"Y": 3, "Q": 4}}, {&A{1, 2}, "", nil}, {}}`,
+            `jsonhelper.JSONObject{"X": "a", "Y": 2.0}`},
+    }
+    fset := token.NewFileSet()
+    imp := importer.ForCompiler(fset, "source", nil)
+    for _, tt := range tests {
+        src := "package sample\n\n" + tt.types
+        dir, _, err := generateSource(t, src)
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        main := compareProgram + "\nvar values = " + tt.values + "\n\nvar input = " + tt.input + "\n"
+        for _, name := range []string{"types.go", *output} {
+            b, err := ioutil.ReadFile(filepath.Join(dir, name))
+            if err != nil {
+                t.Fatal(err)
+            }
+            b = []byte(strings.Replace(string(b), "package sample", "package main", 1))
+            if err := ioutil.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+                t.Fatal(err)
+            }
+        }
+        if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(main), 0644); err != nil {
+            t.Fatal(err)
+        }
+        typeCheck(t, tt.name, fset, imp, dir)
+        cmd := exec.Command(goTool, "run", "main.go", "types.go", *output)
+        cmd.Dir = dir
+        if out, err := cmd.CombinedOutput(); err != nil {
+            t.Errorf("%s: %v\n%s", tt.name, err, out)
+        }
+    }
+}
@@ -0,0 +1,396 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command jsonhelper-structgen writes Go struct definitions matching sample
+// JSON documents, as a starting point for types used with
+// jsonhelper.Marshal and jsonhelper.Unmarshal:
+//
+//	jsonhelper-structgen -type Order orders/*.json > order.go
+//
+// Each file may hold several documents, one after another as in NDJSON,
+// and standard input is read when no files are given. The samples are
+// summarized with jsonhelper.SchemaInferrer, so keys missing from some
+// samples become omitempty fields, nested objects become named struct
+// types, strings in date-time or date format become time.Time, integers
+// too large for an int64 become uint64, values that are sometimes null
+// become pointers, and arrays or keys holding values of several types
+// become interface{}.
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "go/format"
+    "io"
+    "io/ioutil"
+    "math"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "unicode"
+
+    "github.com/pomack/jsonhelper.go/jsonhelper"
+)
+
+var (
+    typeName    = flag.String("type", "Root", "name of the type describing the samples")
+    packageName = flag.String("package", "main", "package clause of the generated file")
+    output      = flag.String("output", "", "file to write; defaults to standard output")
+    elements    = flag.Bool("elements", false, "treat the elements of top-level arrays as samples")
+    required    = flag.Float64("required", jsonhelper.DefaultSchemaRequiredRatio, "share of samples a key must appear in to not be omitempty")
+)
+
+func usage() {
+    fmt.Fprintf(os.Stderr, "usage: jsonhelper-structgen [flags] [file ...]\n")
+    flag.PrintDefaults()
+}
+
+func main() {
+    flag.Usage = usage
+    flag.Parse()
+    if err := run(flag.Args()); err != nil {
+        fmt.Fprintf(os.Stderr, "jsonhelper-structgen: %v\n", err)
+        os.Exit(1)
+    }
+}
+
+func run(files []string) error {
+    inferrer := jsonhelper.NewSchemaInferrer(jsonhelper.SchemaOptions{RequiredRatio: *required, MaxEnumValues: -1})
+    source := "standard input"
+    if len(files) == 0 {
+        if err := addSamples(inferrer, os.Stdin); err != nil {
+            return fmt.Errorf("standard input: %v", err)
+        }
+    } else {
+        source = strings.Join(files, ", ")
+        for _, name := range files {
+            f, err := os.Open(name)
+            if err != nil {
+                return err
+            }
+            err = addSamples(inferrer, f)
+            f.Close()
+            if err != nil {
+                return fmt.Errorf("%s: %v", name, err)
+            }
+        }
+    }
+    if inferrer.Count() == 0 {
+        return fmt.Errorf("no samples in %s", source)
+    }
+    g := &generator{names: make(map[string]bool), bodies: make(map[string]string), imports: make(map[string]bool)}
+    g.root(*typeName, inferrer.Schema())
+    src, err := format.Source(g.file(source))
+    if err != nil {
+        return fmt.Errorf("formatting generated code: %v", err)
+    }
+    if *output == "" {
+        _, err = os.Stdout.Write(src)
+        return err
+    }
+    return ioutil.WriteFile(*output, src, 0644)
+}
+
+// addSamples adds every document in r to inferrer.
+func addSamples(inferrer *jsonhelper.SchemaInferrer, r io.Reader) error {
+    dec := json.NewDecoder(r)
+    dec.UseNumber()
+    for {
+        var value interface{}
+        if err := dec.Decode(&value); err == io.EOF {
+            return nil
+        } else if err != nil {
+            return err
+        }
+        if arr, ok := value.([]interface{}); ok && *elements {
+            for _, item := range arr {
+                inferrer.Add(item)
+            }
+            continue
+        }
+        inferrer.Add(value)
+    }
+}
+
+type typeDef struct {
+    name string
+    decl string
+}
+
+type generator struct {
+    types []typeDef
+    // names holds the type names in use, and bodies the name given to
+    // each struct body, so that objects of the same shape share a type.
+    names   map[string]bool
+    bodies  map[string]string
+    imports map[string]bool
+}
+
+func (g *generator) file(source string) []byte {
+    var out bytes.Buffer
+    fmt.Fprintf(&out, "// Generated by jsonhelper-structgen from %s.\n\n", source)
+    fmt.Fprintf(&out, "package %s\n\n", *packageName)
+    if g.imports["time"] {
+        fmt.Fprintf(&out, "import \"time\"\n\n")
+    }
+    for _, t := range g.types {
+        fmt.Fprintf(&out, "type %s %s\n\n", t.name, t.decl)
+    }
+    return out.Bytes()
+}
+
+// root declares name as the type of the samples, placing it first.
+func (g *generator) root(name string, schema jsonhelper.JSONObject) {
+    g.names[name] = true
+    if types := schemaTypes(schema); len(types) == 1 && types[0] == "object" && hasProperties(schema) {
+        g.structType(name, schema, true)
+    } else {
+        g.types = append(g.types, typeDef{name, g.goType(schema, name, false)})
+    }
+    // The root was declared after the types it uses; move it first.
+    n := len(g.types) - 1
+    for i := range g.types {
+        if g.types[i].name == name {
+            n = i
+        }
+    }
+    rootDef := g.types[n]
+    copy(g.types[1:n+1], g.types[:n])
+    g.types[0] = rootDef
+}
+
+func schemaTypes(schema jsonhelper.JSONObject) []string {
+    if t, ok := schema["type"].(string); ok {
+        return []string{t}
+    }
+    var types []string
+    for _, t := range schema.GetAsArray("type") {
+        types = append(types, jsonhelper.JSONValueToString(t))
+    }
+    return types
+}
+
+func hasProperties(schema jsonhelper.JSONObject) bool {
+    return schema.GetAsObject("properties").Len() > 0
+}
+
+// goType returns the Go type of values described by schema, declaring the
+// struct types it needs under names derived from hint. Optional values
+// that cannot be omitted when zero are made pointers.
+func (g *generator) goType(schema jsonhelper.JSONObject, hint string, optional bool) string {
+    var types []string
+    nullable := false
+    for _, t := range schemaTypes(schema) {
+        if t == "null" {
+            nullable = true
+        } else {
+            types = append(types, t)
+        }
+    }
+    if len(types) != 1 {
+        return "interface{}"
+    }
+    var typ string
+    switch types[0] {
+    case "object":
+        if !hasProperties(schema) {
+            return "map[string]interface{}"
+        }
+        typ = g.structType(hint, schema, false)
+        nullable = nullable || optional
+    case "array":
+        items := schema.GetAsObject("items")
+        if items.Len() == 0 {
+            return "[]interface{}"
+        }
+        return "[]" + g.goType(items, singular(hint), false)
+    case "string":
+        typ = "string"
+        if f := schema.GetAsString("format"); f == "date-time" || f == "date" {
+            g.imports["time"] = true
+            typ = "time.Time"
+        }
+    case "integer":
+        typ = integerType(schema)
+    case "number":
+        typ = "float64"
+    case "boolean":
+        typ = "bool"
+    default:
+        return "interface{}"
+    }
+    if nullable {
+        return "*" + typ
+    }
+    return typ
+}
+
+// integerType returns int64, or uint64 for non-negative integers too large
+// for an int64, or float64 when neither holds every value.
+func integerType(schema jsonhelper.JSONObject) string {
+    var big bool
+    switch max := schema["maximum"].(type) {
+    case uint64:
+        big = max > math.MaxInt64
+    case float64:
+        // math.MaxInt64 rounds up to 2^63, so only larger values count.
+        big = max > -math.MinInt64
+    }
+    if !big {
+        return "int64"
+    }
+    if min, ok := schema["minimum"].(float64); ok && min < 0 {
+        return "float64"
+    }
+    return "uint64"
+}
+
+// structType declares a struct type for an object schema and returns its
+// name, reusing the type of an object of the same shape.
+func (g *generator) structType(hint string, schema jsonhelper.JSONObject, isRoot bool) string {
+    props := schema.GetAsObject("properties")
+    requiredKeys := make(map[string]bool)
+    for _, k := range schema.GetAsArray("required") {
+        requiredKeys[jsonhelper.JSONValueToString(k)] = true
+    }
+    keys := make([]string, 0, len(props))
+    for k := range props {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    var body bytes.Buffer
+    body.WriteString("struct {\n")
+    fieldNames := make(map[string]bool)
+    for _, k := range keys {
+        if !isValidTagName(k) {
+            fmt.Fprintf(&body, "\t// Key %s cannot be written in a json tag.\n", strconv.Quote(k))
+            continue
+        }
+        name := uniqueName(goName(k), fieldNames)
+        prop := props.GetAsObject(k)
+        optional := !requiredKeys[k]
+        typ := g.goType(prop, goName(k), optional)
+        tag := k
+        if optional {
+            if typ == "time.Time" {
+                tag += ",omitzero"
+            } else {
+                tag += ",omitempty"
+            }
+        }
+        if prop.GetAsString("format") == "date" && strings.HasSuffix(typ, "time.Time") {
+            tag += ",format=2006-01-02"
+        }
+        fmt.Fprintf(&body, "\t%s %s `json:%s`\n", name, typ, strconv.Quote(tag))
+    }
+    body.WriteString("}")
+    decl := body.String()
+    if name, ok := g.bodies[decl]; ok && !isRoot {
+        return name
+    }
+    name := hint
+    if !isRoot {
+        name = uniqueName(hint, g.names)
+    }
+    g.bodies[decl] = name
+    g.types = append(g.types, typeDef{name, decl})
+    return name
+}
+
+// uniqueName returns name, or name with the first number from 2 that makes
+// it unused, and marks it used.
+func uniqueName(name string, used map[string]bool) string {
+    unique := name
+    for i := 2; used[unique]; i++ {
+        unique = name + strconv.Itoa(i)
+    }
+    used[unique] = true
+    return unique
+}
+
+// isValidTagName reports whether key can be the name in a json tag.
+func isValidTagName(key string) bool {
+    if key == "" || key == "-" {
+        return false
+    }
+    for _, c := range key {
+        switch {
+        case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+        case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+            return false
+        }
+    }
+    return true
+}
+
+var initialisms = map[string]bool{
+    "API": true, "CPU": true, "CSS": true, "DNS": true, "HTML": true,
+    "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+    "SQL": true, "TCP": true, "TLS": true, "TTL": true, "UDP": true,
+    "UI": true, "URI": true, "URL": true, "UTC": true, "UUID": true,
+    "XML": true,
+}
+
+// goName turns a JSON key such as "user_id" or "createdAt" into an
+// exported Go name such as "UserID" or "CreatedAt".
+func goName(key string) string {
+    var words []string
+    var word []rune
+    prev := rune(0)
+    for _, r := range key {
+        if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+            if len(word) > 0 {
+                words = append(words, string(word))
+            }
+            word, prev = nil, r
+            continue
+        }
+        if unicode.IsUpper(r) && unicode.IsLower(prev) && len(word) > 0 {
+            words = append(words, string(word))
+            word = nil
+        }
+        word = append(word, r)
+        prev = r
+    }
+    if len(word) > 0 {
+        words = append(words, string(word))
+    }
+    var b strings.Builder
+    for _, w := range words {
+        if upper := strings.ToUpper(w); initialisms[upper] {
+            b.WriteString(upper)
+            continue
+        }
+        r := []rune(w)
+        r[0] = unicode.ToUpper(r[0])
+        b.WriteString(string(r))
+    }
+    name := b.String()
+    if name == "" {
+        return "Field"
+    }
+    // Letters without case, as in most scripts of East Asia, cannot start
+    // an exported name.
+    switch first := []rune(name)[0]; {
+    case unicode.IsDigit(first):
+        return "F" + name
+    case !unicode.IsUpper(first):
+        return "X" + name
+    }
+    return name
+}
+
+// singular names the elements of an array named name.
+func singular(name string) string {
+    switch {
+    case strings.HasSuffix(name, "ies") && len(name) > 3:
+        return name[:len(name)-3] + "y"
+    case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 1:
+        return name[:len(name)-1]
+    }
+    return name + "Item"
+}
@@ -0,0 +1,185 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+    "go/ast"
+    "go/importer"
+    "go/parser"
+    "go/token"
+    "go/types"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "testing"
+)
+
+// generateStructs runs the command on a file holding samples and returns
+// the file it writes.
+func generateStructs(t *testing.T, samples string, elems bool) (string, error) {
+    dir, err := ioutil.TempDir("", "jsonhelper-structgen")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { os.RemoveAll(dir) })
+    in := filepath.Join(dir, "samples.json")
+    if err := ioutil.WriteFile(in, []byte(samples), 0644); err != nil {
+        t.Fatal(err)
+    }
+    defer func(o string, e bool) { *output, *elements = o, e }(*output, *elements)
+    *output, *elements = filepath.Join(dir, "types.go"), elems
+    if err := run([]string{in}); err != nil {
+        return "", err
+    }
+    out, err := ioutil.ReadFile(*output)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return string(out), nil
+}
+
+var spaces = regexp.MustCompile(`[ \t]+`)
+
+func TestGenerateStructs(t *testing.T) {
+    tests := []struct {
+        name    string
+        samples string
+        elems   bool
+        want    []string
+        notWant []string
+    }{
+        {"scalars", `{"user_id":1,"name":"a","score":1.5,"ok":true,"createdAt":"2012-01-02T03:04:05Z"}`, false,
+            []string{"type Root struct {", "UserID int64 `json:\"user_id\"`", "Name string `json:\"name\"`", "Score float64 `json:\"score\"`",
+                "Ok bool `json:\"ok\"`", "CreatedAt time.Time `json:\"createdAt\"`", `import "time"`}, nil},
+        {"optional keys", `{"a":"x","t":"2012-01-02T03:04:05Z","d":"2012-01-02","o":{"k":1}} {"b":2}`, false,
+            []string{"A string `json:\"a,omitempty\"`", "B int64 `json:\"b,omitempty\"`", "T time.Time `json:\"t,omitzero\"`",
+                "D time.Time `json:\"d,omitzero,format=2006-01-02\"`", "O *O `json:\"o,omitempty\"`"}, nil},
+        {"nullable", `{"a":"x","b":null} {"a":null,"b":null}`, false,
+            []string{"A *string `json:\"a\"`", "B interface{} `json:\"b\"`"}, nil},
+        {"same shapes share a type", `{"home":{"city":"x"},"work":{"city":"y"},"billing":{"city":1}}`, false,
+            []string{"Home Home `json:\"home\"`", "Work Home `json:\"work\"`", "Billing Billing `json:\"billing\"`"}, []string{"type Work "}},
+        {"arrays", `{"items":[{"id":1}],"categories":[{"id":"c"}],"tags":["a"],"empty":[],"mixed":[1,"a"],"grid":[[1]]}`, false,
+            []string{"Items []Item `json:\"items\"`", "Categories []Category `json:\"categories\"`", "Tags []string `json:\"tags\"`",
+                "Empty []interface{} `json:\"empty\"`", "Mixed []interface{} `json:\"mixed\"`", "Grid [][]int64 `json:\"grid\"`",
+                "type Item struct {", "type Category struct {"}, nil},
+        {"empty object", `{"meta":{}}`, false,
+            []string{"Meta map[string]interface{} `json:\"meta\"`"}, nil},
+        {"large integers", `{"big":18446744073709551615,"neg":-1} {"big":1,"neg":18446744073709551615}`, false,
+            []string{"Big uint64 `json:\"big\"`", "Neg float64 `json:\"neg\"`"}, nil},
+        {"names", `{"2fa":1,"a_b":1,"aB":1,"-x-":1,"html_url":"u"}`, false,
+            []string{"F2fa int64 `json:\"2fa\"`", "AB int64 `json:\"aB\"`", "AB2 int64 `json:\"a_b\"`", "X int64 `json:\"-x-\"`", "HTMLURL string `json:\"html_url\"`"}, nil},
+        {"keys that cannot be tags", `{"":1,"-":2,"a\"b":3,"ok":4}`, false,
+            []string{`// Key "" cannot be written in a json tag.`, `// Key "-" cannot be written in a json tag.`, `// Key "a\"b" cannot be written in a json tag.`,
+                "Ok int64 `json:\"ok\"`"}, nil},
+        {"top-level array", `[1,2]`, false,
+            []string{"type Root []int64"}, nil},
+        {"elements", `[{"a":1},{"a":2,"b":true}]`, true,
+            []string{"type Root struct {", "A int64 `json:\"a\"`", "B bool `json:\"b,omitempty\"`"}, nil},
+        {"uncased key", `{"\u540d\u524d":"x"}`, false,
+            []string{"X\u540d\u524d string `json:\"\u540d\u524d\"`"}, nil},
+        {"root named like a nested type", `{"root":{"a":1}}`, false,
+            []string{"type Root struct {", "Root Root2 `json:\"root\"`", "type Root2 struct {"}, nil},
+    }
+    fset := token.NewFileSet()
+    imp := importer.ForCompiler(fset, "source", nil)
+    for _, tt := range tests {
+        out, err := generateStructs(t, tt.samples, tt.elems)
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        flat := spaces.ReplaceAllString(out, " ")
+        for _, w := range tt.want {
+            if !strings.Contains(flat, w) {
+                t.Errorf("%s: output lacks %s:\n%s", tt.name, w, out)
+            }
+        }
+        for _, w := range tt.notWant {
+            if strings.Contains(flat, w) {
+                t.Errorf("%s: output has %s:\n%s", tt.name, w, out)
+            }
+        }
+        if !strings.HasPrefix(out, "// Generated by jsonhelper-structgen from ") || !strings.Contains(out, "\npackage main\n") {
+            t.Errorf("%s: output lacks its header:\n%s", tt.name, out)
+        }
+        typeCheck(t, tt.name, fset, imp, out)
+    }
+}
+
+// typeCheck compiles a generated file on its own.
+func typeCheck(t *testing.T, name string, fset *token.FileSet, imp types.Importer, src string) {
+    f, err := parser.ParseFile(fset, "types.go", src, 0)
+    if err != nil {
+        t.Errorf("%s: %v", name, err)
+        return
+    }
+    conf := types.Config{Importer: imp}
+    if _, err := conf.Check("main", fset, []*ast.File{f}, nil); err != nil {
+        t.Errorf("%s: generated code does not compile: %v\n%s", name, err, src)
+    }
+}
+
+func TestGenerateStructsErrors(t *testing.T) {
+    tests := []struct {
+        name    string
+        samples string
+        want    string
+    }{
+        {"no samples", " \n", "no samples in "},
+        {"empty array of elements", "[]", "no samples in "},
+        {"invalid JSON", `{"a":}`, "samples.json: invalid character"},
+    }
+    for _, tt := range tests {
+        _, err := generateStructs(t, tt.samples, true)
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.want)
+        }
+    }
+    if err := run([]string{filepath.Join(os.TempDir(), "jsonhelper-structgen-missing.json")}); err == nil {
+        t.Error("a missing file succeeded")
+    }
+}
+
+func TestGoName(t *testing.T) {
+    tests := []struct {
+        key, want string
+    }{
+        {"name", "Name"},
+        {"user_id", "UserID"},
+        {"createdAt", "CreatedAt"},
+        {"HTTPStatus", "HTTPStatus"},
+        {"api-key", "APIKey"},
+        {"x.y z", "XYZ"},
+        {"9lives", "F9lives"},
+        {"\u00e9t\u00e9", "\u00c9t\u00e9"},
+        {"__", "Field"},
+        {"\u540d\u524d", "X\u540d\u524d"},
+        {"user_\u540d\u524d", "User\u540d\u524d"},
+    }
+    for _, tt := range tests {
+        if got := goName(tt.key); got != tt.want {
+            t.Errorf("goName(%q) = %q, want %q", tt.key, got, tt.want)
+        }
+    }
+}
+
+func TestSingular(t *testing.T) {
+    tests := []struct {
+        name, want string
+    }{
+        {"Items", "Item"},
+        {"Categories", "Category"},
+        {"Address", "AddressItem"},
+        {"Data", "DataItem"},
+        {"S", "SItem"},
+    }
+    for _, tt := range tests {
+        if got := singular(tt.name); got != tt.want {
+            t.Errorf("singular(%q) = %q, want %q", tt.name, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,442 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command jsonhelper inspects and transforms JSON documents with the same
+// semantics as the jsonhelper package:
+//
+//	jsonhelper get /users/0/name users.json
+//	jsonhelper query '$.users[?(@.age > 30)].name' users.json
+//	jsonhelper compact -empty-strings -zero < config.json
+//	jsonhelper diff old.json new.json > changes.json
+//	jsonhelper patch changes.json old.json
+//	jsonhelper convert -from ndjson -to csv events.ndjson
+//
+// Documents are read from the file named last on the command line, or from
+// standard input when it is missing or "-", and results are written to
+// standard output as JSON indented by -indent. Object keys keep the order
+// they were read in. diff exits with status 1 when the documents differ
+// and validate when the document is invalid.
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strings"
+
+    "github.com/pomack/jsonhelper.go/jsonhelper"
+)
+
+type command struct {
+    args string
+    help string
+    run  func(c *context) error
+}
+
+var commands = map[string]*command{
+    "get":          {"POINTER [file]", "print the value at a JSON Pointer", runGet},
+    "query":        {"PATH [file]", "print the values a JSONPath expression selects", runQuery},
+    "compact":      {"[file]", "remove nulls, and the values chosen by flags, from objects and arrays", runCompact},
+    "canonicalize": {"[file]", "print the RFC 8785 canonical form", runCanonicalize},
+    "diff":         {"FILE1 FILE2", "print the JSON Patch turning FILE1 into FILE2", runDiff},
+    "patch":        {"PATCH [file]", "apply a JSON Patch", runPatch},
+    "merge":        {"PATCH [file]", "apply a JSON Merge Patch", runMerge},
+    "flatten":      {"[file]", "flatten nested keys into paths, or the reverse with -unflatten", runFlatten},
+    "validate":     {"SCHEMA [file]", "check the document against a JSON Schema", runValidate},
+    "convert":      {"[file]", "convert between json, ndjson, csv and yaml", runConvert},
+}
+
+// errFailed reports a failure the command has already described.
+var errFailed = errors.New("failed")
+
+func usage() {
+    fmt.Fprintf(os.Stderr, "usage: jsonhelper command [flags] [args]\n\ncommands:\n")
+    names := make([]string, 0, len(commands))
+    for name := range commands {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        fmt.Fprintf(os.Stderr, "  %-13s %s\n", name, commands[name].help)
+    }
+    fmt.Fprintf(os.Stderr, "\nRun \"jsonhelper command -h\" for the flags of a command.\n")
+}
+
+func main() {
+    if len(os.Args) < 2 {
+        usage()
+        os.Exit(2)
+    }
+    name := os.Args[1]
+    cmd, ok := commands[name]
+    if !ok {
+        if name != "-h" && name != "-help" && name != "help" {
+            fmt.Fprintf(os.Stderr, "jsonhelper: unknown command %q\n", name)
+        }
+        usage()
+        os.Exit(2)
+    }
+    if err := cmd.run(newContext(name, cmd)); err == errFailed {
+        os.Exit(1)
+    } else if err != nil {
+        fmt.Fprintf(os.Stderr, "jsonhelper %s: %s\n", name, strings.Replace(err.Error(), "jsonhelper: ", "", -1))
+        os.Exit(1)
+    }
+}
+
+// context holds the flags shared by all commands.
+type context struct {
+    flags   *flag.FlagSet
+    indent  *string
+    relaxed *bool
+}
+
+func newContext(name string, cmd *command) *context {
+    flags := flag.NewFlagSet(name, flag.ExitOnError)
+    flags.Usage = func() {
+        fmt.Fprintf(os.Stderr, "usage: jsonhelper %s [flags] %s\n", name, cmd.args)
+        flags.PrintDefaults()
+    }
+    return &context{
+        flags:   flags,
+        indent:  flags.String("indent", "  ", "indentation of the JSON written; empty for one line"),
+        relaxed: flags.Bool("relaxed", false, "accept comments, trailing commas and other JSON5 syntax"),
+    }
+}
+
+// args parses the command line, once the command has defined its flags,
+// and returns the arguments after the flags, which must number between min
+// and max.
+func (c *context) args(min, max int) ([]string, error) {
+    c.flags.Parse(os.Args[2:])
+    args := c.flags.Args()
+    if len(args) < min || len(args) > max {
+        c.flags.Usage()
+        return nil, errFailed
+    }
+    return args, nil
+}
+
+// parseOptions reads documents of any size, keeping the order of keys and
+// the digits of numbers.
+func (c *context) parseOptions() jsonhelper.ParseOptions {
+    return jsonhelper.ParseOptions{
+        MaxBytes:        -1,
+        MaxDepth:        -1,
+        MaxStringLength: -1,
+        MaxObjectKeys:   -1,
+        MaxArrayLength:  -1,
+        MaxNodes:        -1,
+        DuplicateKeys:   jsonhelper.DuplicateKeyLast,
+        UseNumber:       true,
+        Relaxed:         *c.relaxed,
+        Ordered:         true,
+    }
+}
+
+// open returns the named file, or standard input for "" and "-".
+func open(name string) (io.ReadCloser, error) {
+    if name == "" || name == "-" {
+        return os.Stdin, nil
+    }
+    return os.Open(name)
+}
+
+// read parses the single document in the named file.
+func (c *context) read(name string) (interface{}, error) {
+    r, err := open(name)
+    if err != nil {
+        return nil, err
+    }
+    defer r.Close()
+    value, err := jsonhelper.ParseReader(r, c.parseOptions())
+    if err != nil && name != "" && name != "-" {
+        return nil, fmt.Errorf("%s: %v", name, err)
+    }
+    return value, err
+}
+
+// optional returns the argument at i, or "" when there is none.
+func optional(args []string, i int) string {
+    if i < len(args) {
+        return args[i]
+    }
+    return ""
+}
+
+func (c *context) write(value interface{}) error {
+    enc := json.NewEncoder(os.Stdout)
+    enc.SetEscapeHTML(false)
+    enc.SetIndent("", *c.indent)
+    return enc.Encode(value)
+}
+
+func runGet(c *context) error {
+    raw := c.flags.Bool("raw", false, "print strings without quotes")
+    args, err := c.args(1, 2)
+    if err != nil {
+        return err
+    }
+    doc, err := c.read(optional(args, 1))
+    if err != nil {
+        return err
+    }
+    value, err := jsonhelper.GetPointer(doc, args[0])
+    if err != nil {
+        return err
+    }
+    if s, ok := value.(string); ok && *raw {
+        _, err = fmt.Println(s)
+        return err
+    }
+    return c.write(value)
+}
+
+func runQuery(c *context) error {
+    args, err := c.args(1, 2)
+    if err != nil {
+        return err
+    }
+    doc, err := c.read(optional(args, 1))
+    if err != nil {
+        return err
+    }
+    values, err := jsonhelper.QueryJSONPath(doc, args[0])
+    if err != nil {
+        return err
+    }
+    return c.write(values)
+}
+
+func runCompact(c *context) error {
+    removeFalse := c.flags.Bool("false", false, "remove false values")
+    removeEmptyStrings := c.flags.Bool("empty-strings", false, "remove empty strings")
+    removeZero := c.flags.Bool("zero", false, "remove zero numbers")
+    removeEmptyArrays := c.flags.Bool("empty-arrays", false, "remove empty arrays")
+    removeEmptyObjects := c.flags.Bool("empty-objects", false, "remove empty objects")
+    args, err := c.args(0, 1)
+    if err != nil {
+        return err
+    }
+    doc, err := c.read(optional(args, 0))
+    if err != nil {
+        return err
+    }
+    switch v := doc.(type) {
+    case *jsonhelper.OrderedJSONObject:
+        doc = v.Compact(*removeFalse, *removeEmptyStrings, *removeZero, *removeEmptyArrays, *removeEmptyObjects)
+    case jsonhelper.JSONArray:
+        doc = v.Compact(*removeFalse, *removeEmptyStrings, *removeZero, *removeEmptyArrays, *removeEmptyObjects)
+    }
+    return c.write(doc)
+}
+
+func runCanonicalize(c *context) error {
+    args, err := c.args(0, 1)
+    if err != nil {
+        return err
+    }
+    doc, err := c.read(optional(args, 0))
+    if err != nil {
+        return err
+    }
+    b, err := jsonhelper.Canonicalize(doc)
+    if err != nil {
+        return err
+    }
+    _, err = fmt.Printf("%s\n", b)
+    return err
+}
+
+func runDiff(c *context) error {
+    args, err := c.args(2, 2)
+    if err != nil {
+        return err
+    }
+    a, err := c.read(args[0])
+    if err != nil {
+        return err
+    }
+    b, err := c.read(args[1])
+    if err != nil {
+        return err
+    }
+    patch := jsonhelper.Diff(a, b)
+    if err := c.write(patch); err != nil {
+        return err
+    }
+    if len(patch) > 0 {
+        return errFailed
+    }
+    return nil
+}
+
+func runPatch(c *context) error {
+    args, err := c.args(1, 2)
+    if err != nil {
+        return err
+    }
+    patch, err := c.read(args[0])
+    if err != nil {
+        return err
+    }
+    ops, ok := patch.(jsonhelper.JSONArray)
+    if !ok {
+        return fmt.Errorf("%s: a JSON Patch must be an array", args[0])
+    }
+    doc, err := c.read(optional(args, 1))
+    if err != nil {
+        return err
+    }
+    if doc, err = jsonhelper.ApplyPatch(doc, ops); err != nil {
+        return err
+    }
+    return c.write(doc)
+}
+
+func runMerge(c *context) error {
+    args, err := c.args(1, 2)
+    if err != nil {
+        return err
+    }
+    patch, err := c.read(args[0])
+    if err != nil {
+        return err
+    }
+    doc, err := c.read(optional(args, 1))
+    if err != nil {
+        return err
+    }
+    return c.write(jsonhelper.MergePatch(doc, patch))
+}
+
+func runFlatten(c *context) error {
+    unflatten := c.flags.Bool("unflatten", false, "rebuild nested objects from flattened keys")
+    separator := c.flags.String("separator", jsonhelper.DefaultFlattenSeparator, "separator between key segments")
+    brackets := c.flags.Bool("brackets", false, "write array indices as [0] instead of .0")
+    args, err := c.args(0, 1)
+    if err != nil {
+        return err
+    }
+    doc, err := c.read(optional(args, 0))
+    if err != nil {
+        return err
+    }
+    opts := jsonhelper.FlattenOptions{Separator: *separator}
+    if *brackets {
+        opts.IndexStyle = jsonhelper.IndexBracketed
+    }
+    if *unflatten {
+        obj, err := jsonhelper.Unflatten(jsonhelper.JSONValueToObject(doc), opts)
+        if err != nil {
+            return err
+        }
+        return c.write(obj)
+    }
+    return c.write(jsonhelper.Flatten(jsonhelper.JSONValueToObject(doc), opts))
+}
+
+func runValidate(c *context) error {
+    args, err := c.args(1, 2)
+    if err != nil {
+        return err
+    }
+    schema, err := c.read(args[0])
+    if err != nil {
+        return err
+    }
+    doc, err := c.read(optional(args, 1))
+    if err != nil {
+        return err
+    }
+    errs := jsonhelper.ValidateSchema(doc, schema)
+    for _, e := range errs {
+        location := e.Path
+        switch {
+        case e.Schema:
+            location = "schema#" + location
+        case location == "":
+            location = "document"
+        }
+        fmt.Printf("%s: %s\n", location, e.Msg)
+    }
+    if len(errs) > 0 {
+        return errFailed
+    }
+    return nil
+}
+
+var formats = []string{"json", "ndjson", "csv", "yaml"}
+
+func runConvert(c *context) error {
+    from := c.flags.String("from", "json", "input format: "+strings.Join(formats, ", "))
+    to := c.flags.String("to", "json", "output format: "+strings.Join(formats, ", "))
+    args, err := c.args(0, 1)
+    if err != nil {
+        return err
+    }
+    name := optional(args, 0)
+    var doc interface{}
+    switch *from {
+    case "json":
+        doc, err = c.read(name)
+    case "ndjson", "csv", "yaml":
+        doc, err = c.readFormat(name, *from)
+    default:
+        return fmt.Errorf("unknown input format %q", *from)
+    }
+    if err != nil {
+        return err
+    }
+    switch *to {
+    case "json":
+        return c.write(doc)
+    case "ndjson":
+        return jsonhelper.WriteNDJSON(os.Stdout, rows(doc))
+    case "csv":
+        return jsonhelper.WriteCSV(os.Stdout, rows(doc), jsonhelper.CSVOptions{})
+    case "yaml":
+        return jsonhelper.WriteYAML(os.Stdout, doc, jsonhelper.YAMLOptions{})
+    }
+    return fmt.Errorf("unknown output format %q", *to)
+}
+
+// readFormat reads the named file in an input format other than JSON.
+func (c *context) readFormat(name, format string) (interface{}, error) {
+    r, err := open(name)
+    if err != nil {
+        return nil, err
+    }
+    defer r.Close()
+    var doc interface{}
+    switch format {
+    case "ndjson":
+        doc, err = jsonhelper.ReadNDJSON(r, c.parseOptions())
+    case "csv":
+        doc, err = jsonhelper.ReadCSV(r, jsonhelper.CSVOptions{InferTypes: true, Nested: true})
+    case "yaml":
+        var docs []interface{}
+        if docs, err = jsonhelper.ReadYAMLStream(r); len(docs) == 1 {
+            doc = docs[0]
+        } else if err == nil {
+            doc = jsonhelper.NewJSONArrayFromArray(docs)
+        }
+    }
+    if err != nil && name != "" && name != "-" {
+        return nil, fmt.Errorf("%s: %v", name, err)
+    }
+    return doc, err
+}
+
+// rows returns the elements of an array, or a single value as one row.
+func rows(doc interface{}) jsonhelper.JSONArray {
+    if arr, ok := doc.(jsonhelper.JSONArray); ok {
+        return arr
+    }
+    return jsonhelper.JSONArray{doc}
+}
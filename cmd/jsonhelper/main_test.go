@@ -0,0 +1,141 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// runCommand runs a command with args and stdin in dir and returns what it
+// writes to standard output.
+func runCommand(t *testing.T, dir, stdin string, args ...string) (string, error) {
+    in := filepath.Join(dir, "stdin")
+    if err := ioutil.WriteFile(in, []byte(stdin), 0644); err != nil {
+        t.Fatal(err)
+    }
+    inFile, err := os.Open(in)
+    if err != nil {
+        t.Fatal(err)
+    }
+    outFile, err := ioutil.TempFile(dir, "stdout")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer outFile.Close()
+    devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer devNull.Close()
+    defer func(args []string, stdin, stdout, stderr *os.File) {
+        os.Args, os.Stdin, os.Stdout, os.Stderr = args, stdin, stdout, stderr
+    }(os.Args, os.Stdin, os.Stdout, os.Stderr)
+    os.Args = append([]string{"jsonhelper"}, args...)
+    os.Stdin, os.Stdout, os.Stderr = inFile, outFile, devNull
+
+    cmd := commands[args[0]]
+    runErr := cmd.run(newContext(args[0], cmd))
+    out, err := ioutil.ReadFile(outFile.Name())
+    if err != nil {
+        t.Fatal(err)
+    }
+    return string(out), runErr
+}
+
+func TestCommands(t *testing.T) {
+    dir, err := ioutil.TempDir("", "jsonhelper")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    files := map[string]string{
+        "a.json":      `{"name":"x","tags":["a","b"],"n":1}`,
+        "b.json":      `{"name":"y","tags":["a"],"n":1,"new":true}`,
+        "patch.json":  `[{"op":"replace","path":"/name","value":"z"},{"op":"add","path":"/tags/-","value":"c"}]`,
+        "bad.json":    `[{"op":"test","path":"/name","value":"nope"}]`,
+        "object.json": `{"op":"add"}`,
+        "merge.json":  `{"name":null,"extra":{"k":1}}`,
+        "schema.json": `{"type":"object","required":["id"],"properties":{"id":{"type":"integer"}}}`,
+        "broken.json": `{"a":`,
+    }
+    for name, content := range files {
+        if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    path := func(name string) string { return filepath.Join(dir, name) }
+
+    tests := []struct {
+        name    string
+        args    []string
+        stdin   string
+        want    string
+        wantErr string
+    }{
+        {"get", []string{"get", "-indent=", "/tags/1"}, files["a.json"], "\"b\"\n", ""},
+        {"get raw", []string{"get", "-raw", "/name", path("a.json")}, "", "x\n", ""},
+        {"get raw non-string", []string{"get", "-raw", "/tags"}, files["a.json"], "[\n  \"a\",\n  \"b\"\n]\n", ""},
+        {"get keeps digits", []string{"get", "/n"}, `{"n":12345678901234567890.0}`, "12345678901234567890.0\n", ""},
+        {"get keeps order", []string{"get", "-indent=", ""}, `{"z":1,"a":{"y":2,"b":3}}`, "{\"z\":1,\"a\":{\"y\":2,\"b\":3}}\n", ""},
+        {"get relaxed", []string{"get", "-relaxed", "/a"}, "{a: 1, // c\n}", "1\n", ""},
+        {"get strict", []string{"get", "/a"}, "{a: 1}", "", "unexpected character 'a'"},
+        {"get missing member", []string{"get", "/nope"}, files["a.json"], "", `no member "nope"`},
+        {"get too many args", []string{"get", "/a", "x", "y"}, "", "", "failed"},
+        {"get missing file", []string{"get", "/a", path("missing.json")}, "", "", "missing.json"},
+        {"get broken file", []string{"get", "/a", path("broken.json")}, "", "", "line 1 column 6: unexpected end of input"},
+        {"query", []string{"query", "-indent=", "$.tags[*]"}, files["a.json"], "[\"a\",\"b\"]\n", ""},
+        {"query nothing", []string{"query", "-indent=", "$.nope"}, files["a.json"], "[]\n", ""},
+        {"query error", []string{"query", "tags"}, files["a.json"], "", "must start with $"},
+        {"compact", []string{"compact", "-indent=", "-zero", "-empty-strings"}, `{"a":null,"b":0,"c":"","d":[null,1,{}],"e":"x"}`, "{\"d\":[1,{}],\"e\":\"x\"}\n", ""},
+        {"compact empty", []string{"compact", "-indent=", "-empty-arrays", "-empty-objects", "-false"}, `[{},[],false,true]`, "[null,true]\n", ""},
+        {"compact scalar", []string{"compact", "-indent="}, `null`, "null\n", ""},
+        {"canonicalize", []string{"canonicalize"}, `{"b":[1e2,"\u00e9"],"a":true}`, "{\"a\":true,\"b\":[100,\"\u00e9\"]}\n", ""},
+        {"diff", []string{"diff", "-indent=", path("a.json"), path("b.json")}, "",
+            "[{\"op\":\"replace\",\"path\":\"/name\",\"value\":\"y\"},{\"op\":\"remove\",\"path\":\"/tags/1\"},{\"op\":\"add\",\"path\":\"/new\",\"value\":true}]\n", "failed"},
+        {"diff equal", []string{"diff", "-indent=", path("a.json"), path("a.json")}, "", "[]\n", ""},
+        {"diff one file", []string{"diff", path("a.json")}, "", "", "failed"},
+        {"patch", []string{"patch", "-indent=", path("patch.json")}, files["a.json"], "{\"name\":\"z\",\"tags\":[\"a\",\"b\",\"c\"],\"n\":1}\n", ""},
+        {"patch fails", []string{"patch", path("bad.json"), path("a.json")}, "", "", `patch operation 0 (test): value at "/name" is not equal`},
+        {"patch not an array", []string{"patch", path("object.json")}, "{}", "", "a JSON Patch must be an array"},
+        {"merge", []string{"merge", "-indent=", path("merge.json")}, files["a.json"], "{\"tags\":[\"a\",\"b\"],\"n\":1,\"extra\":{\"k\":1}}\n", ""},
+        {"flatten", []string{"flatten", "-indent="}, `{"a":{"b":[1,{"c":2}]}}`, "{\"a.b.0\":1,\"a.b.1.c\":2}\n", ""},
+        {"flatten brackets", []string{"flatten", "-indent=", "-brackets", "-separator=/"}, `{"a":{"b":[1]}}`, "{\"a/b[0]\":1}\n", ""},
+        {"unflatten", []string{"flatten", "-indent=", "-unflatten"}, `{"a.b.0":1,"a.b.1.c":2}`, "{\"a\":{\"b\":[1,{\"c\":2}]}}\n", ""},
+        {"validate", []string{"validate", path("schema.json")}, `{"id":1}`, "", ""},
+        {"validate invalid", []string{"validate", path("schema.json")}, `{"id":"x"}`, "/id: ", "failed"},
+        {"validate missing key", []string{"validate", path("schema.json")}, `{}`, "document: ", "failed"},
+        {"convert ndjson to csv", []string{"convert", "-from", "ndjson", "-to", "csv"}, "{\"a\":1,\"b\":\"x\"}\n\n{\"a\":2,\"b\":\"y\"}\n", "a,b\n1,x\n2,y\n", ""},
+        {"convert csv to json", []string{"convert", "-indent=", "-from", "csv"}, "a,b.c\n1,x\n", "[{\"a\":1,\"b\":{\"c\":\"x\"}}]\n", ""},
+        {"convert json to ndjson", []string{"convert", "-to", "ndjson"}, `[{"z":1,"a":2},3]`, "{\"z\":1,\"a\":2}\n3\n", ""},
+        {"convert object to ndjson", []string{"convert", "-to", "ndjson"}, `{"a":1}`, "{\"a\":1}\n", ""},
+        {"convert json to yaml", []string{"convert", "-to", "yaml"}, `{"z":[1,"yes"],"a":null}`, "z:\n  - 1\n  - \"yes\"\na: null\n", ""},
+        {"convert empty object to yaml", []string{"convert", "-to", "yaml"}, `{}`, "{}\n", ""},
+        {"convert yaml to json", []string{"convert", "-indent=", "-from", "yaml"}, "a: [1, x]\n", "{\"a\":[1,\"x\"]}\n", ""},
+        {"convert yaml stream", []string{"convert", "-indent=", "-from", "yaml"}, "a: 1\n---\nb: 2\n", "[{\"a\":1},{\"b\":2}]\n", ""},
+        {"convert bad ndjson", []string{"convert", "-from", "ndjson"}, "1\n{\n", "", "line 2"},
+        {"convert unknown input", []string{"convert", "-from", "xml"}, "{}", "", `unknown input format "xml"`},
+        {"convert unknown output", []string{"convert", "-to", "xml"}, "{}", "", `unknown output format "xml"`},
+    }
+    for _, tt := range tests {
+        out, err := runCommand(t, dir, tt.stdin, tt.args...)
+        switch {
+        case tt.wantErr == "" && err != nil:
+            t.Errorf("%s: %v", tt.name, err)
+        case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+            t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+        }
+        if strings.HasSuffix(tt.want, ": ") {
+            if !strings.HasPrefix(out, tt.want) {
+                t.Errorf("%s: got %q, want a line starting %q", tt.name, out, tt.want)
+            }
+        } else if out != tt.want {
+            t.Errorf("%s: got %q, want %q", tt.name, out, tt.want)
+        }
+    }
+}
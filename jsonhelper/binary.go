@@ -0,0 +1,82 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bufio"
+    "io"
+)
+
+// binaryError carries decoding errors up through the panics the binary
+// decoders use to unwind.
+type binaryError struct {
+    err error
+}
+
+// recoverBinaryError stores the error of a binaryError panic in err.
+func recoverBinaryError(err *error) {
+    if r := recover(); r != nil {
+        if e, ok := r.(binaryError); ok {
+            *err = e.err
+            return
+        }
+        panic(r)
+    }
+}
+
+// binaryReader reads the bytes and big-endian integers of the binary
+// formats, panicking with a binaryError when the input fails.
+type binaryReader struct {
+    r io.ByteReader
+}
+
+func newBinaryReader(r io.Reader) binaryReader {
+    br, ok := r.(io.ByteReader)
+    if !ok {
+        br = bufio.NewReader(r)
+    }
+    return binaryReader{br}
+}
+
+func (b *binaryReader) error(err error) {
+    if err == io.EOF {
+        err = io.ErrUnexpectedEOF
+    }
+    panic(binaryError{err})
+}
+
+func (b *binaryReader) byte() byte {
+    c, err := b.r.ReadByte()
+    if err != nil {
+        b.error(err)
+    }
+    return c
+}
+
+func (b *binaryReader) uint(size int) uint64 {
+    var n uint64
+    for i := 0; i < size; i++ {
+        n = n<<8 | uint64(b.byte())
+    }
+    return n
+}
+
+// bytes reads n bytes, growing the result as data arrives so that a bogus
+// length cannot force a large allocation.
+func (b *binaryReader) bytes(n uint64) []byte {
+    const chunk = 64 << 10
+    p := make([]byte, 0, minUint64(n, chunk))
+    for uint64(len(p)) < n {
+        p = append(p, b.byte())
+    }
+    return p
+}
+
+func minUint64(a, b uint64) uint64 {
+    if a < b {
+        return a
+    }
+    return b
+}
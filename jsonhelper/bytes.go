@@ -0,0 +1,117 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "reflect"
+)
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// isByteSequence reports whether t is a []byte or [N]byte, including named
+// types such as a [32]byte hash, whose elements have no JSON encoding of
+// their own. Types with text methods, such as net.IP, are not byte
+// sequences and are written as their text.
+func isByteSequence(t reflect.Type) bool {
+    if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+        return false
+    }
+    for _, tt := range []reflect.Type{t, reflect.PtrTo(t)} {
+        if tt.Implements(textMarshalerType) || tt.Implements(textUnmarshalerType) {
+            return false
+        }
+    }
+    elem := t.Elem()
+    if elem.Kind() != reflect.Uint8 {
+        return false
+    }
+    p := reflect.PtrTo(elem)
+    return !p.Implements(jsonMarshalerType) && !p.Implements(textMarshalerType)
+}
+
+// byteSequence copies the bytes out of a value for which isByteSequence
+// is true.
+func byteSequence(v reflect.Value) []byte {
+    b := make([]byte, v.Len())
+    for i := range b {
+        b[i] = byte(v.Index(i).Uint())
+    }
+    return b
+}
+
+// setByteSequence stores b into v, a value for which isByteSequence is
+// true and whose length is len(b).
+func setByteSequence(v reflect.Value, b []byte) {
+    for i, c := range b {
+        v.Index(i).SetUint(uint64(c))
+    }
+}
+
+// encodeBytes converts b into the JSON value described by enc.
+func encodeBytes(b []byte, enc BytesEncoding) (interface{}, error) {
+    switch enc {
+    case BytesBase64:
+        return base64.StdEncoding.EncodeToString(b), nil
+    case BytesBase64URL:
+        return base64.URLEncoding.EncodeToString(b), nil
+    case BytesBase64Raw:
+        return base64.RawStdEncoding.EncodeToString(b), nil
+    case BytesBase64RawURL:
+        return base64.RawURLEncoding.EncodeToString(b), nil
+    case BytesHex:
+        return hex.EncodeToString(b), nil
+    case BytesArray:
+        arr := make([]interface{}, len(b))
+        for i, c := range b {
+            arr[i] = uint64(c)
+        }
+        return NewJSONArrayFromArray(arr), nil
+    }
+    return nil, fmt.Errorf("jsonhelper: unknown bytes encoding %q", string(enc))
+}
+
+// decodeBytes reverses the string forms of BytesEncoding.
+func decodeBytes(s string, enc BytesEncoding) ([]byte, error) {
+    switch enc {
+    case BytesBase64:
+        return base64.StdEncoding.DecodeString(s)
+    case BytesBase64URL:
+        return base64.URLEncoding.DecodeString(s)
+    case BytesBase64Raw:
+        return base64.RawStdEncoding.DecodeString(s)
+    case BytesBase64RawURL:
+        return base64.RawURLEncoding.DecodeString(s)
+    case BytesHex:
+        return hex.DecodeString(s)
+    }
+    return nil, fmt.Errorf("jsonhelper: cannot decode %q bytes from a string", string(enc))
+}
+
+// JSONValueToBytes decodes a value produced by Marshal for a []byte: a
+// string in the given encoding or an array of numbers.
+func JSONValueToBytes(value interface{}, enc BytesEncoding) []byte {
+    switch v := value.(type) {
+    case nil, bool, JSONObject, map[string]interface{}:
+        return nil
+    case string:
+        b, _ := decodeBytes(v, enc)
+        return b
+    case []byte:
+        return v
+    case JSONArray:
+        b := make([]byte, len(v))
+        for i, item := range v {
+            b[i] = byte(JSONValueToInt(item))
+        }
+        return b
+    case []interface{}:
+        return JSONValueToBytes(NewJSONArrayFromArray(v), enc)
+    }
+    return nil
+}
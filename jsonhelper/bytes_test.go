@@ -0,0 +1,161 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/hex"
+    "fmt"
+    "net"
+    "reflect"
+    "testing"
+)
+
+type namedByte byte
+
+type namedBytes []namedByte
+
+type namedHash [4]namedByte
+
+type bytesHolder struct {
+    Slice  namedBytes `json:"slice"`
+    Array  namedHash  `json:"array"`
+    Hex    []byte     `json:"hex,bytes=hex"`
+    Plain  []byte     `json:"plain"`
+    Nested [2]byte    `json:"nested,bytes=array"`
+}
+
+func TestBytesEncodings(t *testing.T) {
+    b := []byte{0xde, 0xad, 0xbe, 0xef}
+    tests := []struct {
+        enc  BytesEncoding
+        want interface{}
+    }{
+        {BytesBase64, "3q2+7w=="},
+        {BytesBase64URL, "3q2-7w=="},
+        {BytesBase64Raw, "3q2+7w"},
+        {BytesBase64RawURL, "3q2-7w"},
+        {BytesHex, "deadbeef"},
+        {BytesArray, JSONArray{uint64(0xde), uint64(0xad), uint64(0xbe), uint64(0xef)}},
+    }
+    for _, tt := range tests {
+        got, err := MarshalWithOptions(b, MarshalOptions{BytesEncoding: tt.enc})
+        if err != nil {
+            t.Errorf("%q: %v", tt.enc, err)
+            continue
+        }
+        if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%q: got %#v, want %#v", tt.enc, got, tt.want)
+        }
+        if tt.enc == BytesArray {
+            continue
+        }
+        var back []byte
+        if err := UnmarshalWithOptions(got, &back, UnmarshalOptions{BytesEncoding: tt.enc}); err != nil {
+            t.Errorf("%q: unmarshal: %v", tt.enc, err)
+        } else if !reflect.DeepEqual(back, b) {
+            t.Errorf("%q: unmarshal gave %v, want %v", tt.enc, back, b)
+        }
+    }
+}
+
+func TestNamedByteTypes(t *testing.T) {
+    in := bytesHolder{
+        Slice:  namedBytes{1, 2, 3},
+        Array:  namedHash{0xca, 0xfe, 0xba, 0xbe},
+        Hex:    []byte{0xff},
+        Plain:  []byte("hi"),
+        Nested: [2]byte{7, 8},
+    }
+    v, err := MarshalWithOptions(in, MarshalOptions{BytesEncoding: BytesHex})
+    if err != nil {
+        t.Fatal(err)
+    }
+    obj := v.(JSONObject)
+    want := JSONObject{
+        "slice":  "010203",
+        "array":  "cafebabe",
+        "hex":    "ff",
+        "plain":  "6869",
+        "nested": JSONArray{uint64(7), uint64(8)},
+    }
+    if !reflect.DeepEqual(obj, want) {
+        t.Fatalf("got %#v, want %#v", obj, want)
+    }
+    var out bytesHolder
+    if err := UnmarshalWithOptions(obj, &out, UnmarshalOptions{BytesEncoding: BytesHex}); err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(out, in) {
+        t.Errorf("round trip gave %#v, want %#v", out, in)
+    }
+}
+
+func TestNamedByteArrayLength(t *testing.T) {
+    var h namedHash
+    if err := UnmarshalWithOptions("0102", &h, UnmarshalOptions{BytesEncoding: BytesHex}); err == nil {
+        t.Error("decoding 2 bytes into a 4 byte array succeeded")
+    }
+}
+
+type textUUID [16]byte
+
+func (u textUUID) MarshalText() ([]byte, error) {
+    return []byte(hex.EncodeToString(u[:])), nil
+}
+
+func (u *textUUID) UnmarshalText(b []byte) error {
+    if len(b) != 32 {
+        return fmt.Errorf("bad uuid %q", b)
+    }
+    _, err := hex.Decode(u[:], b)
+    return err
+}
+
+type textHolder struct {
+    IP   net.IP            `json:"ip"`
+    Ptr  *net.IP           `json:"ptr"`
+    ID   textUUID          `json:"id"`
+    IDs  []textUUID        `json:"ids"`
+    Keys map[textUUID]bool `json:"keys"`
+}
+
+func TestTextMarshalerByteTypes(t *testing.T) {
+    ip := net.IPv4(10, 0, 0, 1)
+    id := textUUID{0: 0x12, 15: 0xff}
+    in := textHolder{
+        IP:   net.IP{1, 2, 3, 4},
+        Ptr:  &ip,
+        ID:   id,
+        IDs:  []textUUID{id},
+        Keys: map[textUUID]bool{id: true},
+    }
+    idText := "120000000000000000000000000000ff"
+    want := JSONObject{
+        "ip":   "1.2.3.4",
+        "ptr":  "10.0.0.1",
+        "id":   idText,
+        "ids":  JSONArray{idText},
+        "keys": JSONObject{idText: true},
+    }
+    for _, enc := range []BytesEncoding{BytesBase64, BytesHex, BytesArray} {
+        v, err := MarshalWithOptions(in, MarshalOptions{BytesEncoding: enc})
+        if err != nil {
+            t.Fatalf("%q: %v", enc, err)
+        }
+        if !reflect.DeepEqual(v, want) {
+            t.Errorf("%q: got %#v, want %#v", enc, v, want)
+        }
+        var out textHolder
+        if err := UnmarshalWithOptions(v, &out, UnmarshalOptions{BytesEncoding: enc}); err != nil {
+            t.Fatalf("%q: unmarshal: %v", enc, err)
+        }
+        if !out.IP.Equal(in.IP) || !out.Ptr.Equal(ip) || out.ID != id || !reflect.DeepEqual(out.IDs, in.IDs) || !reflect.DeepEqual(out.Keys, in.Keys) {
+            t.Errorf("%q: round trip gave %#v", enc, out)
+        }
+    }
+    if v, err := Marshal(textHolder{}); err != nil || v.(JSONObject)["ip"] != "" || v.(JSONObject)["ptr"] != nil {
+        t.Errorf("zero values gave %#v, %v", v, err)
+    }
+}
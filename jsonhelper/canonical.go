@@ -0,0 +1,161 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bytes"
+    "fmt"
+    "math"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+    "unicode"
+    "unicode/utf16"
+    "unicode/utf8"
+)
+
+// Canonicalize returns the JSON Canonicalization Scheme (RFC 8785) form of
+// value: no whitespace, object keys sorted by their UTF-16 code units,
+// numbers written as ECMAScript does and strings escaped only where JSON
+// requires it. Equal documents canonicalize to the same bytes, which makes
+// the output suitable for hashing and signing.
+func Canonicalize(value interface{}) ([]byte, error) {
+    var buf bytes.Buffer
+    if err := canonicalize(&buf, value); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func canonicalize(buf *bytes.Buffer, value interface{}) error {
+    if obj, ok := jsonObjectValue(value); ok {
+        keys := make([]string, 0, len(obj))
+        for k := range obj {
+            keys = append(keys, k)
+        }
+        sort.Slice(keys, func(i, j int) bool { return utf16Less(keys[i], keys[j]) })
+        buf.WriteByte('{')
+        for i, k := range keys {
+            if i > 0 {
+                buf.WriteByte(',')
+            }
+            canonicalString(buf, k)
+            buf.WriteByte(':')
+            if err := canonicalize(buf, obj[k]); err != nil {
+                return err
+            }
+        }
+        buf.WriteByte('}')
+        return nil
+    }
+    if arr, ok := jsonArrayValue(value); ok {
+        buf.WriteByte('[')
+        for i, item := range arr {
+            if i > 0 {
+                buf.WriteByte(',')
+            }
+            if err := canonicalize(buf, item); err != nil {
+                return err
+            }
+        }
+        buf.WriteByte(']')
+        return nil
+    }
+    if f, ok := jsonNumberValue(value); ok {
+        s, err := canonicalNumber(f)
+        if err != nil {
+            return err
+        }
+        buf.WriteString(s)
+        return nil
+    }
+    switch v := value.(type) {
+    case nil:
+        buf.WriteString("null")
+    case bool:
+        buf.WriteString(strconv.FormatBool(v))
+    case string:
+        canonicalString(buf, v)
+    case time.Time:
+        canonicalString(buf, v.Format(time.RFC3339Nano))
+    default:
+        return fmt.Errorf("jsonhelper: cannot canonicalize %s", describeJSONValue(value))
+    }
+    return nil
+}
+
+// canonicalNumber formats f as ECMAScript's Number.prototype.toString.
+func canonicalNumber(f float64) (string, error) {
+    if math.IsNaN(f) || math.IsInf(f, 0) {
+        return "", fmt.Errorf("jsonhelper: cannot canonicalize %v", f)
+    }
+    if f == 0 {
+        return "0", nil
+    }
+    if abs := math.Abs(f); abs >= 1e-6 && abs < 1e21 {
+        return strconv.FormatFloat(f, 'f', -1, 64), nil
+    }
+    s := strconv.FormatFloat(f, 'e', -1, 64)
+    // Go writes exponents with at least two digits, ECMAScript without
+    // leading zeros.
+    mantissa, exp := s[:strings.IndexByte(s, 'e')+2], s[strings.IndexByte(s, 'e')+2:]
+    return mantissa + strings.TrimLeft(exp, "0"), nil
+}
+
+// canonicalString writes s quoted, escaping only quotes, backslashes and
+// control characters.
+func canonicalString(buf *bytes.Buffer, s string) {
+    buf.WriteByte('"')
+    for _, r := range s {
+        switch r {
+        case '"':
+            buf.WriteString(`\"`)
+        case '\\':
+            buf.WriteString(`\\`)
+        case '\b':
+            buf.WriteString(`\b`)
+        case '\f':
+            buf.WriteString(`\f`)
+        case '\n':
+            buf.WriteString(`\n`)
+        case '\r':
+            buf.WriteString(`\r`)
+        case '\t':
+            buf.WriteString(`\t`)
+        default:
+            if r < 0x20 {
+                fmt.Fprintf(buf, `\u%04x`, r)
+            } else {
+                buf.WriteRune(r)
+            }
+        }
+    }
+    buf.WriteByte('"')
+}
+
+// utf16Less orders strings by their UTF-16 code units.
+func utf16Less(a, b string) bool {
+    for a != "" && b != "" {
+        ra, na := utf8.DecodeRuneInString(a)
+        rb, nb := utf8.DecodeRuneInString(b)
+        if ra != rb {
+            if ua, ub := utf16Unit(ra), utf16Unit(rb); ua != ub {
+                return ua < ub
+            }
+            return ra < rb
+        }
+        a, b = a[na:], b[nb:]
+    }
+    return a == "" && b != ""
+}
+
+// utf16Unit returns the first UTF-16 code unit of r.
+func utf16Unit(r rune) rune {
+    if r1, _ := utf16.EncodeRune(r); r1 != unicode.ReplacementChar {
+        return r1
+    }
+    return r
+}
@@ -0,0 +1,91 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "math"
+    "testing"
+    "time"
+)
+
+func TestCanonicalize(t *testing.T) {
+    tests := []struct {
+        value interface{}
+        want  string
+    }{
+        {nil, `null`},
+        {true, `true`},
+        {0.0, `0`},
+        {math.Copysign(0, -1), `0`},
+        {1.0, `1`},
+        {-1.5, `-1.5`},
+        {1e21, `1e+21`},
+        {1e20, `100000000000000000000`},
+        {1e-7, `1e-7`},
+        {1e-6, `0.000001`},
+        {123456789012345680000.0, `123456789012345680000`},
+        {4.50, `4.5`},
+        {2e-3, `0.002`},
+        {0.000001234, `0.000001234`},
+        {1.7976931348623157e308, `1.7976931348623157e+308`},
+        {5e-324, `5e-324`},
+        {int64(-42), `-42`},
+        {json.Number("1E3"), `1000`},
+        {"\u20ac$\u000f\nA'B\"\\\\\"/", `"` + "\u20ac" + `$\u000f\nA'B\"\\\\\"/"`},
+        {"<&>\u2028", "\"<&>\u2028\""},
+        {"\x7f\b\f\r\t", "\"\x7f\\b\\f\\r\\t\""},
+        {time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC), `"2020-01-02T03:04:05.0000006Z"`},
+        {JSONArray{}, `[]`},
+        {JSONObject{}, `{}`},
+        {JSONArray{56, JSONObject{"d": true, "10": nil, "1": JSONArray{}}}, `[56,{"1":[],"10":null,"d":true}]`},
+        {mustParseOrdered(t, `{"b":1,"a":{"d":2,"c":3}}`), `{"a":{"c":3,"d":2},"b":1}`},
+    }
+    for _, tt := range tests {
+        got, err := Canonicalize(tt.value)
+        if err != nil {
+            t.Errorf("Canonicalize(%#v): %v", tt.value, err)
+        } else if string(got) != tt.want {
+            t.Errorf("Canonicalize(%#v) = %s, want %s", tt.value, got, tt.want)
+        }
+    }
+}
+
+// The keys are the sorting example of RFC 8785 section 3.2.3, where U+1F600
+// sorts before U+FB33 because its first UTF-16 code unit is a surrogate.
+func TestCanonicalizeKeyOrder(t *testing.T) {
+    value := JSONObject{
+        "\u20ac":     "Euro Sign",
+        "\r":         "Carriage Return",
+        "\ufb33":     "Hebrew Letter Dalet With Dagesh",
+        "1":          "One",
+        "\U0001f600": "Emoji: Grinning Face",
+        "\u0080":     "Control",
+        "\u00f6":     "Latin Small Letter O With Diaeresis",
+    }
+    want := "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\"," +
+        "\"\u20ac\":\"Euro Sign\",\"\U0001f600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}"
+    got, err := Canonicalize(value)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if string(got) != want {
+        t.Errorf("got %s, want %s", got, want)
+    }
+}
+
+func TestCanonicalizeErrors(t *testing.T) {
+    for _, value := range []interface{}{
+        math.NaN(),
+        math.Inf(1),
+        JSONArray{math.Inf(-1)},
+        JSONObject{"a": make(chan int)},
+        struct{}{},
+    } {
+        if got, err := Canonicalize(value); err == nil {
+            t.Errorf("Canonicalize(%#v) = %s, want an error", value, got)
+        }
+    }
+}
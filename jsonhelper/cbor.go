@@ -0,0 +1,662 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bytes"
+    "encoding/binary"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math"
+    "math/big"
+    "sort"
+    "strconv"
+    "time"
+    "unicode/utf8"
+)
+
+// CBORTimeMode selects how time.Time values are written as CBOR.
+type CBORTimeMode int
+
+const (
+    // CBORTimeString writes times as RFC 3339 strings with tag 0.
+    CBORTimeString CBORTimeMode = iota
+    // CBORTimeEpoch writes times as seconds since the Unix epoch with tag
+    // 1: integers for whole seconds and floats otherwise.
+    CBORTimeEpoch
+)
+
+// CBORTag is a tagged CBOR data item whose tag this package does not
+// interpret. It is returned by the decoder and written back by the encoder.
+type CBORTag struct {
+    Number  uint64
+    Content interface{}
+}
+
+// Defaults for the decoding limits of CBOROptions.
+const (
+    DefaultCBORMaxDepth          = 1000
+    DefaultCBORMaxCollectionSize = 1 << 20
+)
+
+// CBOROptions controls the CBOR encoder and decoder.
+type CBOROptions struct {
+    // Deterministic applies the core deterministic encoding requirements
+    // of RFC 8949 section 4.2.1, so that equal values always encode to
+    // the same bytes: map keys are sorted by their encoded bytes. The
+    // encoder otherwise already uses the shortest forms of integers,
+    // lengths and floats and never writes indefinite lengths.
+    Deterministic bool
+    // Time selects how time.Time values are written.
+    Time CBORTimeMode
+    // IntKeys writes object keys that are decimal integers, such as "1"
+    // or "-7", as CBOR integers, as COSE and CWT maps use them. Integer
+    // keys are always read as their decimal text.
+    IntKeys bool
+    // MaxDepth limits the nesting of arrays, maps and tags the decoder
+    // accepts. Zero means DefaultCBORMaxDepth and a negative value means
+    // no limit.
+    MaxDepth int
+    // MaxCollectionSize limits the number of elements of an array, or of
+    // pairs of a map, the decoder accepts. Zero means
+    // DefaultCBORMaxCollectionSize and a negative value means no limit.
+    MaxCollectionSize int
+}
+
+func (o *CBOROptions) maxDepth() int {
+    if o.MaxDepth == 0 {
+        return DefaultCBORMaxDepth
+    }
+    return o.MaxDepth
+}
+
+func (o *CBOROptions) maxCollectionSize() int {
+    if o.MaxCollectionSize == 0 {
+        return DefaultCBORMaxCollectionSize
+    }
+    return o.MaxCollectionSize
+}
+
+// CBOR major types.
+const (
+    cborUint   = 0
+    cborNegInt = 1
+    cborBytes  = 2
+    cborText   = 3
+    cborArray  = 4
+    cborMap    = 5
+    cborTag    = 6
+    cborSimple = 7
+)
+
+// Tags with a meaning in this package.
+const (
+    cborTagTime      = 0
+    cborTagEpoch     = 1
+    cborTagPosBignum = 2
+    cborTagNegBignum = 3
+)
+
+const cborBreak = 0xff
+
+// EncodeCBOR encodes value as CBOR. It accepts nil, bools, strings,
+// []byte, integers, floats, *big.Int, time.Time, json.Number, CBORTag, and
+// JSONObject and JSONArray values holding them, along with their
+// map[string]interface{} and []interface{} forms. Big integers that fit
+// in 64 bits are written as integers and others as bignums.
+func EncodeCBOR(value interface{}, opts CBOROptions) ([]byte, error) {
+    e := &cborEncoder{opts: &opts}
+    if err := e.value(value); err != nil {
+        return nil, err
+    }
+    return e.buf.Bytes(), nil
+}
+
+// WriteCBOR writes value to w as CBOR, as EncodeCBOR does.
+func WriteCBOR(w io.Writer, value interface{}, opts CBOROptions) error {
+    b, err := EncodeCBOR(value, opts)
+    if err != nil {
+        return err
+    }
+    _, err = w.Write(b)
+    return err
+}
+
+type cborEncoder struct {
+    buf  bytes.Buffer
+    opts *CBOROptions
+}
+
+// head writes the initial bytes of a data item of the given major type
+// with argument n in its shortest form.
+func (e *cborEncoder) head(major byte, n uint64) {
+    major <<= 5
+    switch {
+    case n < 24:
+        e.buf.WriteByte(major | byte(n))
+    case n <= math.MaxUint8:
+        e.buf.WriteByte(major | 24)
+        e.buf.WriteByte(byte(n))
+    case n <= math.MaxUint16:
+        e.buf.WriteByte(major | 25)
+        binary.Write(&e.buf, binary.BigEndian, uint16(n))
+    case n <= math.MaxUint32:
+        e.buf.WriteByte(major | 26)
+        binary.Write(&e.buf, binary.BigEndian, uint32(n))
+    default:
+        e.buf.WriteByte(major | 27)
+        binary.Write(&e.buf, binary.BigEndian, n)
+    }
+}
+
+func (e *cborEncoder) int(n int64) {
+    if n < 0 {
+        e.head(cborNegInt, uint64(-1-n))
+    } else {
+        e.head(cborUint, uint64(n))
+    }
+}
+
+func (e *cborEncoder) value(value interface{}) error {
+    switch v := value.(type) {
+    case nil:
+        e.buf.WriteByte(0xf6)
+    case bool:
+        if v {
+            e.buf.WriteByte(0xf5)
+        } else {
+            e.buf.WriteByte(0xf4)
+        }
+    case string:
+        if !utf8.ValidString(v) {
+            return fmt.Errorf("jsonhelper: cannot encode invalid UTF-8 string %q as CBOR", v)
+        }
+        e.head(cborText, uint64(len(v)))
+        e.buf.WriteString(v)
+    case []byte:
+        e.head(cborBytes, uint64(len(v)))
+        e.buf.Write(v)
+    case int:
+        e.int(int64(v))
+    case int8:
+        e.int(int64(v))
+    case int16:
+        e.int(int64(v))
+    case int32:
+        e.int(int64(v))
+    case int64:
+        e.int(v)
+    case uint:
+        e.head(cborUint, uint64(v))
+    case uint8:
+        e.head(cborUint, uint64(v))
+    case uint16:
+        e.head(cborUint, uint64(v))
+    case uint32:
+        e.head(cborUint, uint64(v))
+    case uint64:
+        e.head(cborUint, v)
+    case float32:
+        e.float(float64(v))
+    case float64:
+        e.float(v)
+    case json.Number:
+        if n, err := v.Int64(); err == nil {
+            e.int(n)
+        } else if f, err := v.Float64(); err == nil {
+            e.float(f)
+        } else {
+            return fmt.Errorf("jsonhelper: cannot encode number %q as CBOR", string(v))
+        }
+    case *big.Int:
+        if v == nil {
+            e.buf.WriteByte(0xf6)
+        } else {
+            e.bigInt(v)
+        }
+    case big.Int:
+        e.bigInt(&v)
+    case time.Time:
+        e.time(v)
+    case CBORTag:
+        e.head(cborTag, v.Number)
+        return e.value(v.Content)
+    case JSONObject:
+        return e.mapValue(v)
+    case *OrderedJSONObject:
+        if v == nil {
+            e.buf.WriteByte(0xf6)
+            break
+        }
+        return e.orderedMapValue(v)
+    case map[string]interface{}:
+        return e.mapValue(v)
+    case JSONArray:
+        return e.array(v)
+    case []interface{}:
+        return e.array(v)
+    default:
+        return fmt.Errorf("jsonhelper: cannot encode %T as CBOR", value)
+    }
+    return nil
+}
+
+// float writes f in the shortest of the half, single and double precision
+// forms that holds it exactly, with NaN always written as 0xf97e00.
+func (e *cborEncoder) float(f float64) {
+    if h, ok := cborFloat16(f); ok {
+        e.buf.WriteByte(0xf9)
+        binary.Write(&e.buf, binary.BigEndian, h)
+    } else if float64(float32(f)) == f {
+        e.buf.WriteByte(0xfa)
+        binary.Write(&e.buf, binary.BigEndian, math.Float32bits(float32(f)))
+    } else {
+        e.buf.WriteByte(0xfb)
+        binary.Write(&e.buf, binary.BigEndian, math.Float64bits(f))
+    }
+}
+
+// cborFloat16 returns the half precision bits of f, if f has them.
+func cborFloat16(f float64) (uint16, bool) {
+    if f != f {
+        return 0x7e00, true
+    }
+    f32 := float32(f)
+    if float64(f32) != f {
+        return 0, false
+    }
+    bits := math.Float32bits(f32)
+    sign := uint16(bits>>16) & 0x8000
+    exp := int(bits>>23) & 0xff
+    mant := bits & 0x7fffff
+    switch {
+    case exp == 0xff:
+        return sign | 0x7c00, true
+    case exp == 0 && mant == 0:
+        return sign, true
+    case exp >= 143:
+        return 0, false
+    case exp >= 113:
+        if mant&0x1fff != 0 {
+            return 0, false
+        }
+        return sign | uint16(exp-112)<<10 | uint16(mant>>13), true
+    case exp >= 103:
+        full := mant | 0x800000
+        shift := uint(126 - exp)
+        if full&(1<<shift-1) != 0 {
+            return 0, false
+        }
+        return sign | uint16(full>>shift), true
+    }
+    return 0, false
+}
+
+func cborHalf(h uint16) float64 {
+    exp := int(h>>10) & 0x1f
+    mant := float64(h & 0x3ff)
+    var f float64
+    switch exp {
+    case 0:
+        f = math.Ldexp(mant, -24)
+    case 31:
+        if mant != 0 {
+            return math.NaN()
+        }
+        f = math.Inf(1)
+    default:
+        f = math.Ldexp(mant+1024, exp-25)
+    }
+    if h&0x8000 != 0 {
+        return -f
+    }
+    return f
+}
+
+func (e *cborEncoder) bigInt(n *big.Int) {
+    if n.IsUint64() {
+        e.head(cborUint, n.Uint64())
+        return
+    }
+    tag := uint64(cborTagPosBignum)
+    mag := n
+    if n.Sign() < 0 {
+        // Negative bignums hold -1-n.
+        mag = new(big.Int).Neg(n)
+        mag.Sub(mag, big.NewInt(1))
+        if mag.IsUint64() {
+            e.head(cborNegInt, mag.Uint64())
+            return
+        }
+        tag = cborTagNegBignum
+    }
+    e.head(cborTag, tag)
+    b := mag.Bytes()
+    e.head(cborBytes, uint64(len(b)))
+    e.buf.Write(b)
+}
+
+func (e *cborEncoder) time(t time.Time) {
+    if e.opts.Time == CBORTimeEpoch {
+        e.head(cborTag, cborTagEpoch)
+        if t.Nanosecond() == 0 {
+            e.int(t.Unix())
+        } else {
+            e.float(float64(t.Unix()) + float64(t.Nanosecond())/1e9)
+        }
+        return
+    }
+    e.head(cborTag, cborTagTime)
+    s := t.Format(time.RFC3339Nano)
+    e.head(cborText, uint64(len(s)))
+    e.buf.WriteString(s)
+}
+
+// key writes an object key, as an integer when IntKeys allows it.
+func (e *cborEncoder) key(k string) {
+    if e.opts.IntKeys {
+        if n, err := strconv.ParseInt(k, 10, 64); err == nil && strconv.FormatInt(n, 10) == k {
+            e.int(n)
+            return
+        }
+    }
+    e.head(cborText, uint64(len(k)))
+    e.buf.WriteString(k)
+}
+
+func (e *cborEncoder) mapValue(m map[string]interface{}) error {
+    e.head(cborMap, uint64(len(m)))
+    if !e.opts.Deterministic {
+        for k, v := range m {
+            if !utf8.ValidString(k) {
+                return fmt.Errorf("jsonhelper: cannot encode invalid UTF-8 key %q as CBOR", k)
+            }
+            e.key(k)
+            if err := e.value(v); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    type pair struct {
+        key   []byte
+        value interface{}
+    }
+    pairs := make([]pair, 0, len(m))
+    for k, v := range m {
+        if !utf8.ValidString(k) {
+            return fmt.Errorf("jsonhelper: cannot encode invalid UTF-8 key %q as CBOR", k)
+        }
+        start := e.buf.Len()
+        e.key(k)
+        pairs = append(pairs, pair{append([]byte(nil), e.buf.Bytes()[start:]...), v})
+        e.buf.Truncate(start)
+    }
+    sort.Slice(pairs, func(i, j int) bool { return bytes.Compare(pairs[i].key, pairs[j].key) < 0 })
+    for _, p := range pairs {
+        e.buf.Write(p.key)
+        if err := e.value(p.value); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// orderedMapValue writes the members of p in their order, unless the
+// Deterministic option requires them sorted.
+func (e *cborEncoder) orderedMapValue(p *OrderedJSONObject) error {
+    if e.opts.Deterministic {
+        return e.mapValue(p.values)
+    }
+    e.head(cborMap, uint64(len(p.keys)))
+    for _, k := range p.keys {
+        if !utf8.ValidString(k) {
+            return fmt.Errorf("jsonhelper: cannot encode invalid UTF-8 key %q as CBOR", k)
+        }
+        e.key(k)
+        if err := e.value(p.values[k]); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (e *cborEncoder) array(arr []interface{}) error {
+    e.head(cborArray, uint64(len(arr)))
+    for _, item := range arr {
+        if err := e.value(item); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// DecodeCBOR decodes the single CBOR data item in data. Maps become
+// JSONObject values, arrays become JSONArray values, integers become
+// int64, or uint64 when too large for int64, and *big.Int when too
+// negative, floats become float64, byte strings become []byte, tags 0
+// and 1 become time.Time values and bignums become *big.Int values.
+// Undefined is read as nil, and other tags are returned as CBORTag.
+// Indefinite lengths are accepted.
+func DecodeCBOR(data []byte, opts CBOROptions) (interface{}, error) {
+    r := bytes.NewReader(data)
+    value, err := ReadCBOR(r, opts)
+    if err != nil {
+        return nil, err
+    }
+    if r.Len() > 0 {
+        return nil, fmt.Errorf("jsonhelper: %d bytes of data after CBOR data item", r.Len())
+    }
+    return value, nil
+}
+
+// ReadCBOR reads one CBOR data item from r, as DecodeCBOR does. Unless r
+// is an io.ByteReader it is buffered, and may be read past the end of the
+// item.
+func ReadCBOR(r io.Reader, opts CBOROptions) (value interface{}, err error) {
+    d := &cborDecoder{newBinaryReader(r), &opts}
+    defer recoverBinaryError(&err)
+    return d.value(d.byte(), 0), nil
+}
+
+type cborDecoder struct {
+    binaryReader
+    opts *CBOROptions
+}
+
+// argument reads the argument of the data item whose initial byte is c,
+// reporting false for an indefinite length.
+func (d *cborDecoder) argument(c byte) (uint64, bool) {
+    switch info := c & 0x1f; {
+    case info < 24:
+        return uint64(info), true
+    case info <= 27:
+        return d.uint(1 << (info - 24)), true
+    case info == 31:
+        return 0, false
+    }
+    d.error(fmt.Errorf("jsonhelper: invalid CBOR initial byte 0x%02x", c))
+    return 0, false
+}
+
+func (d *cborDecoder) enter(depth int) {
+    if max := d.opts.maxDepth(); max > 0 && depth >= max {
+        d.error(fmt.Errorf("jsonhelper: CBOR data nested deeper than %d", max))
+    }
+}
+
+func (d *cborDecoder) checkSize(n uint64) {
+    if max := d.opts.maxCollectionSize(); max > 0 && n > uint64(max) {
+        d.error(fmt.Errorf("jsonhelper: CBOR collection of %d elements exceeds the limit of %d", n, max))
+    }
+}
+
+// value reads the data item whose initial byte c has already been read.
+func (d *cborDecoder) value(c byte, depth int) interface{} {
+    major := c >> 5
+    if major == cborSimple {
+        return d.simple(c)
+    }
+    n, definite := d.argument(c)
+    if !definite && major < cborBytes || major == cborTag && !definite {
+        d.error(fmt.Errorf("jsonhelper: invalid CBOR initial byte 0x%02x", c))
+    }
+    switch major {
+    case cborUint:
+        if n > math.MaxInt64 {
+            return n
+        }
+        return int64(n)
+    case cborNegInt:
+        if n > math.MaxInt64 {
+            b := new(big.Int).SetUint64(n)
+            return b.Neg(b).Sub(b, big.NewInt(1))
+        }
+        return -1 - int64(n)
+    case cborBytes:
+        return d.string(major, n, definite)
+    case cborText:
+        b := d.string(major, n, definite)
+        if !utf8.Valid(b) {
+            d.error(fmt.Errorf("jsonhelper: CBOR text string is not valid UTF-8"))
+        }
+        return string(b)
+    case cborArray:
+        return d.array(n, definite, depth)
+    case cborMap:
+        return d.mapValue(n, definite, depth)
+    }
+    d.enter(depth)
+    return d.tag(n, d.value(d.byte(), depth+1))
+}
+
+// string reads the contents of a byte or text string, joining the chunks
+// of indefinite length strings.
+func (d *cborDecoder) string(major byte, n uint64, definite bool) []byte {
+    if definite {
+        return d.bytes(n)
+    }
+    var b []byte
+    for {
+        c := d.byte()
+        if c == cborBreak {
+            return b
+        }
+        n, definite := d.argument(c)
+        if c>>5 != major || !definite {
+            d.error(fmt.Errorf("jsonhelper: invalid chunk in indefinite length CBOR string"))
+        }
+        b = append(b, d.bytes(n)...)
+    }
+}
+
+func (d *cborDecoder) array(n uint64, definite bool, depth int) JSONArray {
+    d.enter(depth)
+    if definite {
+        d.checkSize(n)
+    }
+    arr := make([]interface{}, 0, minUint64(n, 1024))
+    for i := uint64(0); !definite || i < n; i++ {
+        c := d.byte()
+        if !definite {
+            if c == cborBreak {
+                break
+            }
+            d.checkSize(i + 1)
+        }
+        arr = append(arr, d.value(c, depth+1))
+    }
+    return NewJSONArrayFromArray(arr)
+}
+
+func (d *cborDecoder) mapValue(n uint64, definite bool, depth int) JSONObject {
+    d.enter(depth)
+    if definite {
+        d.checkSize(n)
+    }
+    obj := NewJSONObject()
+    for i := uint64(0); !definite || i < n; i++ {
+        c := d.byte()
+        if !definite {
+            if c == cborBreak {
+                break
+            }
+            d.checkSize(i + 1)
+        }
+        var k string
+        switch key := d.value(c, depth+1).(type) {
+        case string:
+            k = key
+        case int64:
+            k = strconv.FormatInt(key, 10)
+        case uint64:
+            k = strconv.FormatUint(key, 10)
+        default:
+            d.error(fmt.Errorf("jsonhelper: CBOR map key of type %T is not supported", key))
+        }
+        obj[k] = d.value(d.byte(), depth+1)
+    }
+    return obj
+}
+
+func (d *cborDecoder) tag(number uint64, content interface{}) interface{} {
+    switch number {
+    case cborTagTime:
+        s, ok := content.(string)
+        if !ok {
+            d.error(fmt.Errorf("jsonhelper: CBOR time tag holds %T, not a string", content))
+        }
+        t, err := time.Parse(time.RFC3339Nano, s)
+        if err != nil {
+            d.error(err)
+        }
+        return t
+    case cborTagEpoch:
+        switch v := content.(type) {
+        case int64:
+            return time.Unix(v, 0).UTC()
+        case float64:
+            if math.IsNaN(v) || math.IsInf(v, 0) {
+                d.error(fmt.Errorf("jsonhelper: CBOR epoch time %v is not finite", v))
+            }
+            sec, frac := math.Modf(v)
+            return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+        }
+        d.error(fmt.Errorf("jsonhelper: CBOR epoch time tag holds %T, not a number", content))
+    case cborTagPosBignum, cborTagNegBignum:
+        b, ok := content.([]byte)
+        if !ok {
+            d.error(fmt.Errorf("jsonhelper: CBOR bignum tag holds %T, not a byte string", content))
+        }
+        n := new(big.Int).SetBytes(b)
+        if number == cborTagNegBignum {
+            n.Neg(n).Sub(n, big.NewInt(1))
+        }
+        return n
+    }
+    return CBORTag{Number: number, Content: content}
+}
+
+func (d *cborDecoder) simple(c byte) interface{} {
+    switch c & 0x1f {
+    case 20:
+        return false
+    case 21:
+        return true
+    case 22, 23:
+        return nil
+    case 25:
+        return cborHalf(uint16(d.uint(2)))
+    case 26:
+        return float64(math.Float32frombits(uint32(d.uint(4))))
+    case 27:
+        return math.Float64frombits(d.uint(8))
+    case 31:
+        d.error(fmt.Errorf("jsonhelper: unexpected CBOR break"))
+    }
+    d.error(fmt.Errorf("jsonhelper: unsupported CBOR simple value 0x%02x", c))
+    return nil
+}
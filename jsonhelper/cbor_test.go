@@ -0,0 +1,245 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bytes"
+    "encoding/json"
+    "math"
+    "math/big"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func mustBig(t *testing.T, s string) *big.Int {
+    n, ok := new(big.Int).SetString(s, 10)
+    if !ok {
+        t.Fatalf("bad big integer %s", s)
+    }
+    return n
+}
+
+// The expected encodings are from RFC 8949 appendix A where it has them.
+func TestEncodeCBOR(t *testing.T) {
+    tests := []struct {
+        value interface{}
+        opts  CBOROptions
+        want  string
+    }{
+        {0, CBOROptions{}, "00"},
+        {23, CBOROptions{}, "17"},
+        {24, CBOROptions{}, "18 18"},
+        {uint16(1000), CBOROptions{}, "19 03e8"},
+        {int64(1000000), CBOROptions{}, "1a 000f4240"},
+        {uint64(math.MaxUint64), CBOROptions{}, "1b ffffffffffffffff"},
+        {-1, CBOROptions{}, "20"},
+        {-1000, CBOROptions{}, "39 03e7"},
+        {int64(math.MinInt64), CBOROptions{}, "3b 7fffffffffffffff"},
+        {mustBig(t, "18446744073709551616"), CBOROptions{}, "c2 49 010000000000000000"},
+        {mustBig(t, "-18446744073709551616"), CBOROptions{}, "3b ffffffffffffffff"},
+        {mustBig(t, "-18446744073709551617"), CBOROptions{}, "c3 49 010000000000000000"},
+        {big.NewInt(5), CBOROptions{}, "05"},
+        {(*big.Int)(nil), CBOROptions{}, "f6"},
+        {0.0, CBOROptions{}, "f9 0000"},
+        {math.Copysign(0, -1), CBOROptions{}, "f9 8000"},
+        {1.5, CBOROptions{}, "f9 3e00"},
+        {65504.0, CBOROptions{}, "f9 7bff"},
+        {5.960464477539063e-8, CBOROptions{}, "f9 0001"},
+        {0.00006103515625, CBOROptions{}, "f9 0400"},
+        {100000.0, CBOROptions{}, "fa 47c35000"},
+        {float32(3.4028234663852886e+38), CBOROptions{}, "fa 7f7fffff"},
+        {1.1, CBOROptions{}, "fb 3ff199999999999a"},
+        {math.Inf(1), CBOROptions{}, "f9 7c00"},
+        {math.Inf(-1), CBOROptions{}, "f9 fc00"},
+        {math.NaN(), CBOROptions{}, "f9 7e00"},
+        {json.Number("-10"), CBOROptions{}, "29"},
+        {json.Number("1.5"), CBOROptions{}, "f9 3e00"},
+        {false, CBOROptions{}, "f4"},
+        {true, CBOROptions{}, "f5"},
+        {nil, CBOROptions{}, "f6"},
+        {"", CBOROptions{}, "60"},
+        {"IETF", CBOROptions{}, "64 49455446"},
+        {"\u00fc", CBOROptions{}, "62 c3bc"},
+        {[]byte{1, 2, 3, 4}, CBOROptions{}, "44 01020304"},
+        {JSONArray{}, CBOROptions{}, "80"},
+        {[]interface{}{1, JSONArray{2, 3}}, CBOROptions{}, "82 01 82 02 03"},
+        {JSONObject{}, CBOROptions{}, "a0"},
+        {JSONObject{"a": 1}, CBOROptions{}, "a1 61 61 01"},
+        {CBORTag{Number: 32, Content: "http://www.example.com"}, CBOROptions{}, "d8 20 76 687474703a2f2f7777772e6578616d706c652e636f6d"},
+        {time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC), CBOROptions{}, "c0 74 323031332d30332d32315432303a30343a30305a"},
+        {time.Unix(1363896240, 0), CBOROptions{Time: CBORTimeEpoch}, "c1 1a 514b67b0"},
+        {time.Unix(1363896240, 5e8), CBOROptions{Time: CBORTimeEpoch}, "c1 fb 41d452d9ec200000"},
+        {JSONObject{"1": "x", "-2": "y", "01": "z"}, CBOROptions{IntKeys: true, Deterministic: true}, "a3 01 61 78 21 61 79 62 3031 61 7a"},
+    }
+    for _, tt := range tests {
+        got, err := EncodeCBOR(tt.value, tt.opts)
+        if err != nil {
+            t.Errorf("EncodeCBOR(%#v): %v", tt.value, err)
+        } else if want := mustHex(t, tt.want); !bytes.Equal(got, want) {
+            t.Errorf("EncodeCBOR(%#v) = % x, want % x", tt.value, got, want)
+        }
+    }
+}
+
+func TestEncodeCBORDeterministic(t *testing.T) {
+    // Keys sort by their encoded bytes, so shorter keys come first.
+    in := JSONObject{
+        "bb":  1,
+        "a":   JSONObject{"z": true, "y": false},
+        "c":   JSONArray{JSONObject{"e": 1, "d": 2}},
+        "aaa": nil,
+    }
+    want := mustHex(t, "a4 61 61 a2 61 79 f4 61 7a f5 61 63 81 a2 61 64 02 61 65 01 62 6262 01 63 616161 f6")
+    for i := 0; i < 10; i++ {
+        got, err := EncodeCBOR(in, CBOROptions{Deterministic: true})
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !bytes.Equal(got, want) {
+            t.Fatalf("got % x, want % x", got, want)
+        }
+    }
+    const doc = `{"bb":1,"a":{"z":true,"y":false},"c":[{"e":1,"d":2}],"aaa":null}`
+    want, _ = EncodeCBOR(mustParse(t, doc), CBOROptions{Deterministic: true})
+    if got, err := EncodeCBOR(mustParseOrdered(t, doc), CBOROptions{Deterministic: true}); err != nil || !bytes.Equal(got, want) {
+        t.Errorf("ordered object gave % x, %v, want % x", got, err, want)
+    }
+}
+
+func TestEncodeCBORErrors(t *testing.T) {
+    for _, value := range []interface{}{
+        "\xff",
+        JSONObject{"\xff": 1},
+        JSONObject{"a": JSONArray{[]string{"x"}}},
+        JSONArray{struct{}{}},
+        json.Number("x"),
+    } {
+        if _, err := EncodeCBOR(value, CBOROptions{}); err == nil {
+            t.Errorf("EncodeCBOR(%#v) succeeded", value)
+        }
+    }
+    if _, err := EncodeCBOR(JSONObject{"\xff": 1}, CBOROptions{Deterministic: true}); err == nil {
+        t.Error("deterministic EncodeCBOR of an invalid key succeeded")
+    }
+}
+
+func TestDecodeCBOR(t *testing.T) {
+    tests := []struct {
+        in   string
+        want interface{}
+    }{
+        {"00", int64(0)},
+        {"1b 7fffffffffffffff", int64(math.MaxInt64)},
+        {"1b ffffffffffffffff", uint64(math.MaxUint64)},
+        {"3b 7fffffffffffffff", int64(math.MinInt64)},
+        {"3b ffffffffffffffff", mustBig(t, "-18446744073709551616")},
+        {"c2 49 010000000000000000", mustBig(t, "18446744073709551616")},
+        {"c3 49 010000000000000000", mustBig(t, "-18446744073709551617")},
+        {"f9 3c00", 1.0},
+        {"f9 0001", 5.960464477539063e-8},
+        {"f9 7c00", math.Inf(1)},
+        {"fa 47c35000", 100000.0},
+        {"fb 3ff199999999999a", 1.1},
+        {"f4", false},
+        {"f6", nil},
+        {"f7", nil},
+        {"62 c3bc", "\u00fc"},
+        {"7f 657374726561 646d696e67 ff", "streaming"},
+        {"5f 42 0102 43 030405 ff", []byte{1, 2, 3, 4, 5}},
+        {"9f 01 82 02 03 9f 04 05 ff ff", JSONArray{int64(1), JSONArray{int64(2), int64(3)}, JSONArray{int64(4), int64(5)}}},
+        {"bf 61 61 01 61 62 9f 02 03 ff ff", JSONObject{"a": int64(1), "b": JSONArray{int64(2), int64(3)}}},
+        {"a2 01 02 20 03", JSONObject{"1": int64(2), "-1": int64(3)}},
+        {"c0 74 323031332d30332d32315432303a30343a30305a", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+        {"c1 1a 514b67b0", time.Unix(1363896240, 0).UTC()},
+        {"c1 fb 41d452d9ec200000", time.Unix(1363896240, 5e8).UTC()},
+        {"d8 20 63 616263", CBORTag{Number: 32, Content: "abc"}},
+    }
+    for _, tt := range tests {
+        got, err := DecodeCBOR(mustHex(t, tt.in), CBOROptions{})
+        if err != nil {
+            t.Errorf("DecodeCBOR(%s): %v", tt.in, err)
+            continue
+        }
+        if want, ok := tt.want.(*big.Int); ok {
+            if n, ok := got.(*big.Int); !ok || n.Cmp(want) != 0 {
+                t.Errorf("DecodeCBOR(%s) = %v, want %v", tt.in, got, want)
+            }
+        } else if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("DecodeCBOR(%s) = %#v, want %#v", tt.in, got, tt.want)
+        }
+    }
+    got, err := DecodeCBOR(mustHex(t, "f9 7e00"), CBOROptions{})
+    if f, ok := got.(float64); err != nil || !ok || !math.IsNaN(f) {
+        t.Errorf("DecodeCBOR(f97e00) = %v, %v, want NaN", got, err)
+    }
+}
+
+func TestDecodeCBORErrors(t *testing.T) {
+    tests := []struct {
+        in   string
+        opts CBOROptions
+        want string
+    }{
+        {"", CBOROptions{}, "unexpected EOF"},
+        {"64 4945", CBOROptions{}, "unexpected EOF"},
+        {"5b ffffffffffffffff", CBOROptions{}, "unexpected EOF"},
+        {"1c", CBOROptions{}, "invalid CBOR initial byte 0x1c"},
+        {"1f", CBOROptions{}, "invalid CBOR initial byte 0x1f"},
+        {"df", CBOROptions{}, "invalid CBOR initial byte 0xdf"},
+        {"ff", CBOROptions{}, "unexpected CBOR break"},
+        {"f0", CBOROptions{}, "unsupported CBOR simple value 0xf0"},
+        {"00 00", CBOROptions{}, "1 bytes of data after"},
+        {"62 c328", CBOROptions{}, "not valid UTF-8"},
+        {"7f 41 61 ff", CBOROptions{}, "invalid chunk"},
+        {"a1 f5 01", CBOROptions{}, "map key of type bool"},
+        {"c0 01", CBOROptions{}, "time tag holds int64"},
+        {"c0 63 616263", CBOROptions{}, "cannot parse"},
+        {"c1 61 61", CBOROptions{}, "epoch time tag holds string"},
+        {"c1 f9 7c00", CBOROptions{}, "not finite"},
+        {"c2 01", CBOROptions{}, "bignum tag holds int64"},
+        {"83 01 02 03", CBOROptions{MaxCollectionSize: 2}, "collection of 3 elements exceeds the limit of 2"},
+        {"a3 01 01 02 02 03 03", CBOROptions{MaxCollectionSize: 2}, "exceeds the limit of 2"},
+        {"9f 01 02 03 ff", CBOROptions{MaxCollectionSize: 2}, "collection of 3 elements"},
+        {"9b 00000000ffffffff", CBOROptions{}, "exceeds the limit of 1048576"},
+        {"81 81 81 00", CBOROptions{MaxDepth: 2}, "nested deeper than 2"},
+        {"c1 c1 c1 00", CBOROptions{MaxDepth: 2}, "nested deeper than 2"},
+        {strings.Repeat("81", DefaultCBORMaxDepth+1) + "00", CBOROptions{}, "nested deeper than 1000"},
+    }
+    for _, tt := range tests {
+        _, err := DecodeCBOR(mustHex(t, tt.in), tt.opts)
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("DecodeCBOR(%.20s) error = %v, want %q", tt.in, err, tt.want)
+        }
+    }
+}
+
+func TestDecodeCBORNoLimits(t *testing.T) {
+    deep := strings.Repeat("81", DefaultCBORMaxDepth+1) + "00"
+    if _, err := DecodeCBOR(mustHex(t, deep), CBOROptions{MaxDepth: -1}); err != nil {
+        t.Errorf("unlimited depth: %v", err)
+    }
+    if _, err := DecodeCBOR(mustHex(t, "83 01 02 03"), CBOROptions{MaxCollectionSize: -1}); err != nil {
+        t.Errorf("unlimited size: %v", err)
+    }
+}
+
+func TestCBORRoundTrip(t *testing.T) {
+    in := mustParse(t, `{"s":"x","n":[1,-1,300,-300,70000,1e100,0.5,-0.25],"o":{"b":true,"z":null},"e":[],"m":{}}`)
+    var buf bytes.Buffer
+    for _, opts := range []CBOROptions{{}, {Deterministic: true}, {IntKeys: true}} {
+        buf.Reset()
+        if err := WriteCBOR(&buf, in, opts); err != nil {
+            t.Fatal(err)
+        }
+        got, err := ReadCBOR(&buf, opts)
+        if err != nil {
+            t.Errorf("%+v: %v", opts, err)
+        } else if !EqualJSONValues(got, in) {
+            t.Errorf("%+v: round trip gave %v, want %v", opts, got, in)
+        }
+    }
+}
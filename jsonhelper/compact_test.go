@@ -0,0 +1,62 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+// compactEqual compares got, by way of encoding/json, which writes the nil
+// objects and arrays Compact leaves as null, with the JSON want.
+func compactEqual(t *testing.T, got interface{}, want string) bool {
+    b, err := json.Marshal(got)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return EqualJSONValues(mustParse(t, string(b)), mustParse(t, want))
+}
+
+// Objects and arrays that compact away are kept as null, except for
+// OrderedJSONObject values, which are left out.
+func TestCompact(t *testing.T) {
+    const in = `{"s":"","f":false,"z":0,"n":null,"a":[],"o":{},"nested":{"a":[],"o":{"e":""}},"list":[[],{},"",0,false,null,1]}`
+    tests := []struct {
+        name                                            string
+        noFalse, noStrings, noZero, noArrays, noObjects bool
+        want, wantOrdered                               string
+    }{
+        {"nothing", false, false, false, false, false,
+            `{"s":"","f":false,"z":0,"a":[],"o":{},"nested":{"a":[],"o":{"e":""}},"list":[[],{},"",0,false,1]}`,
+            `{"s":"","f":false,"z":0,"a":[],"o":{},"nested":{"a":[],"o":{"e":""}},"list":[[],{},"",0,false,1]}`},
+        {"empty arrays", false, false, false, true, false,
+            `{"s":"","f":false,"z":0,"a":null,"o":{},"nested":{"a":null,"o":{"e":""}},"list":[null,{},"",0,false,1]}`,
+            `{"s":"","f":false,"z":0,"a":null,"o":{},"nested":{"a":null,"o":{"e":""}},"list":[null,{},"",0,false,1]}`},
+        {"empty objects", false, false, false, false, true,
+            `{"s":"","f":false,"z":0,"a":[],"o":null,"nested":{"a":[],"o":{"e":""}},"list":[[],null,"",0,false,1]}`,
+            `{"s":"","f":false,"z":0,"a":[],"nested":{"a":[],"o":{"e":""}},"list":[[],"",0,false,1]}`},
+        {"everything", true, true, true, true, true,
+            `{"a":null,"o":null,"nested":{"a":null,"o":null},"list":[null,null,1]}`,
+            `{"a":null,"nested":{"a":null},"list":[null,1]}`},
+    }
+    for _, tt := range tests {
+        obj, _ := ParseObject([]byte(in), ParseOptions{})
+        got := obj.Compact(tt.noFalse, tt.noStrings, tt.noZero, tt.noArrays, tt.noObjects)
+        if !compactEqual(t, got, tt.want) {
+            b, _ := json.Marshal(got)
+            t.Errorf("JSONObject %s: got %s, want %s", tt.name, b, tt.want)
+        }
+        arr := JSONArray{obj}.Compact(tt.noFalse, tt.noStrings, tt.noZero, tt.noArrays, tt.noObjects)
+        if len(arr) != 1 || !compactEqual(t, arr[0], tt.want) {
+            b, _ := json.Marshal(arr)
+            t.Errorf("JSONArray %s: got %s, want [%s]", tt.name, b, tt.want)
+        }
+        ordered := mustParseOrdered(t, in).Compact(tt.noFalse, tt.noStrings, tt.noZero, tt.noArrays, tt.noObjects)
+        if !compactEqual(t, ordered, tt.wantOrdered) {
+            b, _ := json.Marshal(ordered)
+            t.Errorf("OrderedJSONObject %s: got %s, want %s", tt.name, b, tt.wantOrdered)
+        }
+    }
+}
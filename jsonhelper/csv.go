@@ -0,0 +1,262 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+)
+
+// CSVArrayMode selects how WriteCSV writes arrays.
+type CSVArrayMode int
+
+const (
+    // CSVArrayFlatten gives every element its own column, such as
+    // "items.0.sku".
+    CSVArrayFlatten CSVArrayMode = iota
+    // CSVArrayJSON writes the whole array as JSON text in one cell.
+    CSVArrayJSON
+    // CSVArrayJoin joins arrays of scalars with ArraySeparator in one cell.
+    // Arrays holding objects or arrays are written as JSON text.
+    CSVArrayJoin
+)
+
+// DefaultCSVArraySeparator joins array elements under CSVArrayJoin when
+// CSVOptions.ArraySeparator is empty.
+const DefaultCSVArraySeparator = ";"
+
+// CSVOptions controls WriteCSV and ReadCSV.
+type CSVOptions struct {
+    // Comma is the field delimiter. It defaults to ','.
+    Comma rune
+    // Columns selects and orders the columns written by WriteCSV. By
+    // default every flattened key of every row is written, in sorted
+    // order with array indices compared as numbers.
+    Columns []string
+    // Flatten controls how nested keys become column names, and how
+    // ReadCSV turns them back into paths when Nested is set.
+    Flatten FlattenOptions
+    // ArrayMode selects how WriteCSV writes arrays.
+    ArrayMode CSVArrayMode
+    // ArraySeparator joins array elements under CSVArrayJoin.
+    ArraySeparator string
+    // InferTypes makes ReadCSV convert "true", "false", "null" and JSON
+    // numbers into values of those types, empty cells into nil, and cells
+    // holding JSON arrays or objects into JSONArray and JSONObject values.
+    InferTypes bool
+    // Nested makes ReadCSV rebuild nested objects and arrays from the
+    // column names, as Unflatten does. Empty cells are then left out, so
+    // that rows of different shapes can share columns. An array index may
+    // be at most the number of columns.
+    Nested bool
+    // HeaderPaths maps column names to the flattened paths ReadCSV stores
+    // their cells at, such as "City" to "address.city". Columns not listed
+    // keep their names.
+    HeaderPaths map[string]string
+}
+
+func (o *CSVOptions) comma() rune {
+    if o.Comma == 0 {
+        return ','
+    }
+    return o.Comma
+}
+
+// WriteCSV writes rows, which must all be objects, to w as CSV with a
+// header line. Nested objects become flattened columns, and cells that are
+// missing from a row are left empty.
+func WriteCSV(w io.Writer, rows JSONArray, opts CSVOptions) error {
+    flat := make([]JSONObject, len(rows))
+    seen := make(map[string]bool)
+    var discovered []string
+    for i, row := range rows {
+        obj, ok := jsonObjectValue(row)
+        if !ok {
+            return fmt.Errorf("jsonhelper: CSV row %d is %s, not an object", i, describeJSONValue(row))
+        }
+        if opts.ArrayMode != CSVArrayFlatten {
+            obj = collapseArrays(obj, &opts).(JSONObject)
+        }
+        flat[i] = Flatten(obj, opts.Flatten)
+        for k := range flat[i] {
+            if !seen[k] {
+                seen[k] = true
+                discovered = append(discovered, k)
+            }
+        }
+    }
+    columns := opts.Columns
+    if columns == nil {
+        sort.Sort(naturalStrings(discovered))
+        columns = discovered
+    }
+    cw := csv.NewWriter(w)
+    cw.Comma = opts.comma()
+    if err := cw.Write(columns); err != nil {
+        return err
+    }
+    record := make([]string, len(columns))
+    for _, row := range flat {
+        for i, col := range columns {
+            record[i] = csvCell(row[col])
+        }
+        if err := cw.Write(record); err != nil {
+            return err
+        }
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// collapseArrays replaces every array in value with the single cell text
+// ArrayMode asks for.
+func collapseArrays(value interface{}, opts *CSVOptions) interface{} {
+    if obj, ok := jsonObjectValue(value); ok {
+        m := NewJSONObject()
+        for k, v := range obj {
+            m[k] = collapseArrays(v, opts)
+        }
+        return m
+    }
+    arr, ok := jsonArrayValue(value)
+    if !ok {
+        return value
+    }
+    if opts.ArrayMode == CSVArrayJoin {
+        cells := make([]string, len(arr))
+        for i, item := range arr {
+            if isCompositeJSONValue(item) {
+                return csvCell(arr)
+            }
+            cells[i] = csvCell(item)
+        }
+        sep := opts.ArraySeparator
+        if sep == "" {
+            sep = DefaultCSVArraySeparator
+        }
+        return strings.Join(cells, sep)
+    }
+    return csvCell(arr)
+}
+
+// csvCell is the text of a value in a CSV cell: scalars as they print and
+// objects and arrays as JSON.
+func csvCell(value interface{}) string {
+    if isCompositeJSONValue(value) {
+        b, _ := json.Marshal(value)
+        return string(b)
+    }
+    return JSONValueToString(value)
+}
+
+// ReadCSV reads CSV with a header line from r, returning one JSONObject per
+// record keyed by the column names.
+func ReadCSV(r io.Reader, opts CSVOptions) (JSONArray, error) {
+    cr := csv.NewReader(r)
+    cr.Comma = opts.comma()
+    cr.FieldsPerRecord = -1
+    header, err := cr.Read()
+    if err == io.EOF {
+        return NewJSONArray(), nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    paths := make([]string, len(header))
+    for i, name := range header {
+        paths[i] = name
+        if path, ok := opts.HeaderPaths[name]; ok {
+            paths[i] = path
+        }
+    }
+    var rows []interface{}
+    for {
+        record, err := cr.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+        obj := NewJSONObject()
+        for i, cell := range record {
+            if i >= len(paths) {
+                break
+            }
+            if cell == "" && opts.Nested {
+                continue
+            }
+            obj[paths[i]] = opts.cellValue(cell)
+        }
+        if opts.Nested {
+            // Empty cells are left out, so the columns rather than the
+            // cells bound the array indices.
+            if obj, err = unflatten(obj, opts.Flatten, len(paths)); err != nil {
+                line, _ := cr.FieldPos(0)
+                return nil, fmt.Errorf("jsonhelper: CSV line %d: %v", line, err)
+            }
+        }
+        rows = append(rows, obj)
+    }
+    return NewJSONArrayFromArray(rows), nil
+}
+
+func (o *CSVOptions) cellValue(cell string) interface{} {
+    if !o.InferTypes {
+        return cell
+    }
+    if cell == "" {
+        return nil
+    }
+    if cell[0] == '[' || cell[0] == '{' {
+        var value interface{}
+        if err := json.Unmarshal([]byte(cell), &value); err == nil {
+            return normalizeJSONValue(value)
+        }
+    }
+    return inferScalar(cell)
+}
+
+// naturalStrings sorts column names comparing runs of digits as numbers,
+// so that "items.2" comes before "items.10".
+type naturalStrings []string
+
+func (x naturalStrings) Len() int           { return len(x) }
+func (x naturalStrings) Swap(i, j int)      { x[i], x[j] = x[j], x[i] }
+func (x naturalStrings) Less(i, j int) bool { return naturalLess(x[i], x[j]) }
+
+func naturalLess(a, b string) bool {
+    for a != "" && b != "" {
+        da, db := digitPrefix(a), digitPrefix(b)
+        if da > 0 && db > 0 {
+            na, nb := strings.TrimLeft(a[:da], "0"), strings.TrimLeft(b[:db], "0")
+            if len(na) != len(nb) {
+                return len(na) < len(nb)
+            }
+            if na != nb {
+                return na < nb
+            }
+            a, b = a[da:], b[db:]
+            continue
+        }
+        if a[0] != b[0] {
+            return a[0] < b[0]
+        }
+        a, b = a[1:], b[1:]
+    }
+    return len(a) < len(b)
+}
+
+func digitPrefix(s string) int {
+    i := 0
+    for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+        i++
+    }
+    return i
+}
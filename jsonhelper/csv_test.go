@@ -0,0 +1,109 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bytes"
+    "encoding/json"
+    "strings"
+    "testing"
+)
+
+const csvRows = `[{"id":1,"name":"a, b","tags":["x","y"],"addr":{"city":"c"}},{"id":2,"items":[1,2,3,4,5,6,7,8,9,10,11],"flag":false}]`
+
+func TestWriteCSV(t *testing.T) {
+    tests := []struct {
+        name string
+        opts CSVOptions
+        want string
+    }{
+        {"flatten", CSVOptions{Columns: []string{"id", "name", "tags.1", "addr.city", "items.10", "missing"}},
+            "id,name,tags.1,addr.city,items.10,missing\n1,\"a, b\",y,c,,\n2,,,,11,\n"},
+        {"json arrays", CSVOptions{ArrayMode: CSVArrayJSON, Columns: []string{"id", "tags"}},
+            "id,tags\n1,\"[\"\"x\"\",\"\"y\"\"]\"\n2,\n"},
+        {"joined arrays", CSVOptions{ArrayMode: CSVArrayJoin, ArraySeparator: "|", Columns: []string{"tags", "flag"}},
+            "tags,flag\nx|y,\n,false\n"},
+        {"tabs", CSVOptions{Comma: '\t', Columns: []string{"id", "name"}},
+            "id\tname\n1\ta, b\n2\t\n"},
+    }
+    rows, _ := Parse([]byte(csvRows), ParseOptions{})
+    for _, tt := range tests {
+        var buf bytes.Buffer
+        if err := WriteCSV(&buf, rows.(JSONArray), tt.opts); err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+        } else if buf.String() != tt.want {
+            t.Errorf("%s: got\n%s\nwant\n%s", tt.name, buf.String(), tt.want)
+        }
+    }
+    var buf bytes.Buffer
+    if err := WriteCSV(&buf, rows.(JSONArray), CSVOptions{}); err != nil {
+        t.Fatal(err)
+    }
+    header := strings.SplitN(buf.String(), "\n", 2)[0]
+    if want := "addr.city,flag,id,items.0,items.1,items.2,items.3,items.4,items.5,items.6,items.7,items.8,items.9,items.10,name,tags.0,tags.1"; header != want {
+        t.Errorf("header %s, want %s", header, want)
+    }
+    if err := WriteCSV(&buf, JSONArray{"row"}, CSVOptions{}); err == nil {
+        t.Error("a row that is not an object succeeded")
+    }
+}
+
+func TestReadCSV(t *testing.T) {
+    const in = "id,Name,tags.0,tags.1,addr.city,extra\n1,a,x,y,c,\"{\"\"k\"\":[1]}\"\n2,,,,,null\n"
+    tests := []struct {
+        name string
+        opts CSVOptions
+        want string
+    }{
+        {"strings", CSVOptions{},
+            `[{"id":"1","Name":"a","tags.0":"x","tags.1":"y","addr.city":"c","extra":"{\"k\":[1]}"},{"id":"2","Name":"","tags.0":"","tags.1":"","addr.city":"","extra":"null"}]`},
+        {"inferred", CSVOptions{InferTypes: true},
+            `[{"id":1,"Name":"a","tags.0":"x","tags.1":"y","addr.city":"c","extra":{"k":[1]}},{"id":2,"Name":null,"tags.0":null,"tags.1":null,"addr.city":null,"extra":null}]`},
+        {"nested", CSVOptions{InferTypes: true, Nested: true, HeaderPaths: map[string]string{"Name": "user.name"}},
+            `[{"id":1,"user":{"name":"a"},"tags":["x","y"],"addr":{"city":"c"},"extra":{"k":[1]}},{"id":2,"extra":null}]`},
+    }
+    for _, tt := range tests {
+        rows, err := ReadCSV(strings.NewReader(in), tt.opts)
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        want, _ := Parse([]byte(tt.want), ParseOptions{})
+        if !EqualJSONValues(rows, want) {
+            b, _ := json.Marshal(rows)
+            t.Errorf("%s: got %s, want %s", tt.name, b, tt.want)
+        }
+    }
+    if rows, err := ReadCSV(strings.NewReader(""), CSVOptions{}); err != nil || len(rows) != 0 {
+        t.Errorf("empty input: got %v, %v", rows, err)
+    }
+    if _, err := ReadCSV(strings.NewReader("a,a.b\n1,2\n"), CSVOptions{Nested: true}); err == nil {
+        t.Error("conflicting nested columns succeeded")
+    }
+    rows, err := ReadCSV(strings.NewReader("a.0,a.1\n,x\n"), CSVOptions{Nested: true})
+    if want := mustParse(t, `[{"a":[null,"x"]}]`); err != nil || !EqualJSONValues(rows, want) {
+        t.Errorf("empty first element: got %v, %v", rows, err)
+    }
+    huge := "a.1048575\n" + strings.Repeat("1\n", 200)
+    if _, err := ReadCSV(strings.NewReader(huge), CSVOptions{Nested: true}); err == nil || !strings.Contains(err.Error(), "line 2: ") {
+        t.Errorf("sparse huge index: got error %v", err)
+    }
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+    rows, _ := Parse([]byte(csvRows), ParseOptions{})
+    var buf bytes.Buffer
+    if err := WriteCSV(&buf, rows.(JSONArray), CSVOptions{}); err != nil {
+        t.Fatal(err)
+    }
+    back, err := ReadCSV(&buf, CSVOptions{InferTypes: true, Nested: true})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !EqualJSONValues(back, rows) {
+        b, _ := json.Marshal(back)
+        t.Errorf("got %s, want %s", b, csvRows)
+    }
+}
@@ -0,0 +1,511 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding"
+    "encoding/json"
+    "fmt"
+    "math"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// UnmarshalOptions controls UnmarshalWithOptions.
+type UnmarshalOptions struct {
+    // TimeFormat is the layout used to parse time.Time strings, or one of
+    // TimeFormatUnix and TimeFormatUnixMilli. It defaults to time.RFC3339Nano.
+    TimeFormat string
+    // DurationUnit is the unit of numeric time.Duration values. It defaults
+    // to nanoseconds.
+    DurationUnit time.Duration
+    // BytesEncoding is the encoding of []byte strings.
+    BytesEncoding BytesEncoding
+    // FieldNaming is the strategy the values were marshalled with. It is
+    // used to find the key of struct fields that have no name in their tag.
+    FieldNaming NamingStrategy
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// Unmarshal stores a JSON value, such as a JSONObject produced by Marshal or
+// by encoding/json, into the value pointed to by v, following the same tag
+// rules as Marshal. Scalar conversions are as lenient as the JSONValueTo*
+// helpers, so "12" can populate an int field.
+func Unmarshal(value interface{}, v interface{}) error {
+    return UnmarshalWithOptions(value, v, UnmarshalOptions{})
+}
+
+func UnmarshalWithOptions(value interface{}, v interface{}, opts UnmarshalOptions) error {
+    return newDecodeState(opts).unmarshal(value, v)
+}
+
+func newDecodeState(opts UnmarshalOptions) *decodeState {
+    if opts.TimeFormat == "" {
+        opts.TimeFormat = time.RFC3339Nano
+    }
+    if opts.DurationUnit <= 0 {
+        opts.DurationUnit = time.Nanosecond
+    }
+    return &decodeState{
+        opts:          &opts,
+        timeFormat:    opts.TimeFormat,
+        durationUnit:  opts.DurationUnit,
+        bytesEncoding: opts.BytesEncoding,
+    }
+}
+
+func (d *decodeState) unmarshal(value interface{}, v interface{}) (err error) {
+    rv := reflect.ValueOf(v)
+    if rv.Kind() != reflect.Ptr || rv.IsNil() {
+        return &json.InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+    }
+    defer func() {
+        if r := recover(); r != nil {
+            err = recoveredError(r)
+        }
+    }()
+    d.value(value, rv.Elem())
+    return nil
+}
+
+type decodeState struct {
+    opts          *UnmarshalOptions
+    timeFormat    string
+    durationUnit  time.Duration
+    bytesEncoding BytesEncoding
+    // scalarAsArray lets a single value fill a slice or array, as form
+    // values do.
+    scalarAsArray bool
+}
+
+// applyFieldOptions applies the tag options of a struct field, mirroring
+// fieldEncoder.setOptions.
+func (d *decodeState) applyFieldOptions(opts tagOptions) {
+    if format, ok := opts.Get("format"); ok && format != "" {
+        d.timeFormat = format
+    }
+    if opts.Contains(TimeFormatUnix) {
+        d.timeFormat = TimeFormatUnix
+    }
+    if opts.Contains(TimeFormatUnixMilli) {
+        d.timeFormat = TimeFormatUnixMilli
+    }
+    if format, ok := opts.Get("duration"); ok {
+        if unit, ok := DurationUnit(format); ok {
+            d.durationUnit = unit
+        }
+    }
+    if enc, ok := opts.Get("bytes"); ok {
+        d.bytesEncoding = BytesEncoding(enc)
+    }
+}
+
+// isDefault reports whether values are being decoded with the default
+// options, so that an UnmarshalJSONObject method gives the same result as
+// reflection.
+func (d *decodeState) isDefault() bool {
+    return d.opts.FieldNaming == nil && d.timeFormat == time.RFC3339Nano &&
+        d.durationUnit == time.Nanosecond && d.bytesEncoding == BytesBase64
+}
+
+func (d *decodeState) bytes(s string) []byte {
+    b, err := decodeBytes(s, d.bytesEncoding)
+    if err != nil {
+        d.error(err)
+    }
+    return b
+}
+
+func (d *decodeState) error(err error) {
+    panic(err)
+}
+
+func (d *decodeState) typeError(value interface{}, t reflect.Type) {
+    d.error(&json.UnmarshalTypeError{Value: describeJSONValue(value), Type: t})
+}
+
+// describeJSONValue names the JSON type of a value for error messages.
+func describeJSONValue(value interface{}) string {
+    switch value.(type) {
+    case nil:
+        return "null"
+    case bool:
+        return "bool"
+    case string:
+        return "string"
+    case JSONObject, map[string]interface{}, *OrderedJSONObject:
+        return "object"
+    case JSONArray, []interface{}:
+        return "array"
+    }
+    return "number"
+}
+
+func isCompositeJSONValue(value interface{}) bool {
+    switch value.(type) {
+    case JSONObject, map[string]interface{}, *OrderedJSONObject, JSONArray, []interface{}:
+        return true
+    }
+    return false
+}
+
+func (d *decodeState) value(value interface{}, v reflect.Value) {
+    if value == nil {
+        switch v.Kind() {
+        case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+            v.Set(reflect.Zero(v.Type()))
+        }
+        return
+    }
+    if v.Kind() == reflect.Ptr {
+        if v.IsNil() {
+            v.Set(reflect.New(v.Type().Elem()))
+        }
+        d.value(value, v.Elem())
+        return
+    }
+    switch v.Type() {
+    case timeType:
+        d.time(value, v)
+        return
+    case durationType:
+        d.duration(value, v)
+        return
+    }
+    if v.CanAddr() {
+        pv := v.Addr()
+        if pv.Type().Implements(jsonObjectUnmarshalerType) && d.isDefault() {
+            if obj, ok := jsonObjectValue(value); ok {
+                if err := pv.Interface().(JSONObjectUnmarshaler).UnmarshalJSONObject(obj); err != nil {
+                    d.error(err)
+                }
+                return
+            }
+        }
+        if pv.Type().Implements(jsonUnmarshalerType) {
+            b, err := json.Marshal(value)
+            if err == nil {
+                err = pv.Interface().(json.Unmarshaler).UnmarshalJSON(b)
+            }
+            if err != nil {
+                d.error(err)
+            }
+            return
+        }
+        if s, ok := value.(string); ok && pv.Type().Implements(textUnmarshalerType) {
+            if err := pv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+                d.error(err)
+            }
+            return
+        }
+    }
+    switch v.Kind() {
+    case reflect.Interface:
+        if v.NumMethod() != 0 {
+            d.typeError(value, v.Type())
+        }
+        v.Set(reflect.ValueOf(normalizeJSONValue(value)))
+    case reflect.Bool:
+        if isCompositeJSONValue(value) {
+            d.typeError(value, v.Type())
+        }
+        v.SetBool(JSONValueToBool(value))
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        n, err := JSONValueToInt64Strict(value)
+        if err != nil || v.OverflowInt(n) {
+            d.typeError(value, v.Type())
+        }
+        v.SetInt(n)
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        n, err := JSONValueToUint64Strict(value)
+        if err != nil || v.OverflowUint(n) {
+            d.typeError(value, v.Type())
+        }
+        v.SetUint(n)
+    case reflect.Float32, reflect.Float64:
+        f, err := JSONValueToFloat64Strict(value)
+        if err != nil || v.OverflowFloat(f) {
+            d.typeError(value, v.Type())
+        }
+        v.SetFloat(f)
+    case reflect.String:
+        if isCompositeJSONValue(value) {
+            d.typeError(value, v.Type())
+        }
+        v.SetString(JSONValueToString(value))
+    case reflect.Struct:
+        obj, ok := jsonObjectValue(value)
+        if !ok {
+            d.typeError(value, v.Type())
+        }
+        d.object(obj, v)
+    case reflect.Map:
+        obj, ok := jsonObjectValue(value)
+        if !ok {
+            d.typeError(value, v.Type())
+        }
+        t := v.Type()
+        if !isSupportedMapKey(t.Key()) && !reflect.PtrTo(t.Key()).Implements(textUnmarshalerType) {
+            d.error(&json.UnsupportedTypeError{Type: t})
+        }
+        if v.IsNil() {
+            v.Set(reflect.MakeMap(t))
+        }
+        for k, item := range obj {
+            kv, err := parseMapKey(k, t.Key())
+            if err != nil {
+                d.error(err)
+            }
+            elem := reflect.New(t.Elem()).Elem()
+            d.value(item, elem)
+            v.SetMapIndex(kv, elem)
+        }
+    case reflect.Slice:
+        if s, ok := value.(string); ok && isByteSequence(v.Type()) {
+            v.SetBytes(d.bytes(s))
+            return
+        }
+        arr, ok := d.arrayValue(value)
+        if !ok {
+            d.typeError(value, v.Type())
+        }
+        s := reflect.MakeSlice(v.Type(), len(arr), len(arr))
+        for i, item := range arr {
+            d.value(item, s.Index(i))
+        }
+        v.Set(s)
+    case reflect.Array:
+        if s, ok := value.(string); ok && isByteSequence(v.Type()) {
+            b := d.bytes(s)
+            if len(b) != v.Len() {
+                d.error(fmt.Errorf("jsonhelper: cannot decode %d bytes into %v", len(b), v.Type()))
+            }
+            setByteSequence(v, b)
+            return
+        }
+        arr, ok := d.arrayValue(value)
+        if !ok {
+            d.typeError(value, v.Type())
+        }
+        n := v.Len()
+        for i := 0; i < n; i++ {
+            if i < len(arr) {
+                d.value(arr[i], v.Index(i))
+            } else {
+                v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+            }
+        }
+    default:
+        d.error(&json.UnsupportedTypeError{Type: v.Type()})
+    }
+}
+
+func (d *decodeState) object(obj JSONObject, v reflect.Value) {
+    for _, f := range cachedTypeFields(v.Type()) {
+        key := f.name
+        if !f.tag && d.opts.FieldNaming != nil {
+            key = d.opts.FieldNaming(f.name)
+        }
+        var item interface{}
+        if f.collapse {
+            item = obj
+        } else if found, ok := LookupKey(obj, key); ok {
+            item = found
+        } else {
+            continue
+        }
+        fieldValue, ok := fieldByIndexAlloc(v, f.index)
+        if !ok {
+            d.error(fmt.Errorf("jsonhelper: cannot set embedded pointer to unexported struct: %v", v.Type().FieldByIndex(f.index[:1]).Type))
+        }
+        saved := *d
+        d.applyFieldOptions(f.opts)
+        d.value(item, fieldValue)
+        *d = saved
+    }
+}
+
+// LookupKey finds key in obj, preferring an exact match but accepting a
+// case-insensitive one like encoding/json.
+func LookupKey(obj JSONObject, key string) (interface{}, bool) {
+    if item, ok := obj[key]; ok {
+        return item, true
+    }
+    for k, item := range obj {
+        if strings.EqualFold(k, key) {
+            return item, true
+        }
+    }
+    return nil, false
+}
+
+func (d *decodeState) time(value interface{}, v reflect.Value) {
+    t, err := JSONValueToTimeStrict(value, d.timeFormat)
+    if err != nil {
+        d.error(err)
+    }
+    v.Set(reflect.ValueOf(t))
+}
+
+func (d *decodeState) duration(value interface{}, v reflect.Value) {
+    dur, err := JSONValueToDurationStrict(value, d.durationUnit)
+    if err != nil {
+        d.error(err)
+    }
+    v.SetInt(int64(dur))
+}
+
+func jsonObjectValue(value interface{}) (JSONObject, bool) {
+    switch v := value.(type) {
+    case JSONObject:
+        return v, true
+    case map[string]interface{}:
+        return NewJSONObjectFromMap(v), true
+    case *OrderedJSONObject:
+        if v != nil {
+            return v.values, true
+        }
+    }
+    return nil, false
+}
+
+// arrayValue is jsonArrayValue, also wrapping single values when
+// scalarAsArray is set.
+func (d *decodeState) arrayValue(value interface{}) (JSONArray, bool) {
+    arr, ok := jsonArrayValue(value)
+    if !ok && d.scalarAsArray && !isCompositeJSONValue(value) {
+        return JSONArray{value}, true
+    }
+    return arr, ok
+}
+
+func jsonArrayValue(value interface{}) (JSONArray, bool) {
+    switch v := value.(type) {
+    case JSONArray:
+        return v, true
+    case []interface{}:
+        return NewJSONArrayFromArray(v), true
+    }
+    return nil, false
+}
+
+// JSONValueToInt64Strict is like JSONValueToInt64 but fails on values that
+// are not integers, such as objects, 1.5 or "abc", instead of returning 0.
+func JSONValueToInt64Strict(value interface{}) (int64, error) {
+    switch v := value.(type) {
+    case string:
+        n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+        if err != nil {
+            return 0, err
+        }
+        return n, nil
+    case float64:
+        return floatToInt64(v)
+    case float32:
+        return floatToInt64(float64(v))
+    case uint64:
+        if v > math.MaxInt64 {
+            return 0, fmt.Errorf("jsonhelper: %d overflows int64", v)
+        }
+        return int64(v), nil
+    case uint:
+        if uint64(v) > math.MaxInt64 {
+            return 0, fmt.Errorf("jsonhelper: %d overflows int64", v)
+        }
+        return int64(v), nil
+    case json.Number:
+        n, err := v.Int64()
+        if err != nil {
+            return 0, err
+        }
+        return n, nil
+    }
+    if isCompositeJSONValue(value) {
+        return 0, fmt.Errorf("jsonhelper: %s is not a number", describeJSONValue(value))
+    }
+    return JSONValueToInt64(value), nil
+}
+
+// JSONValueToUint64Strict is the unsigned counterpart of
+// JSONValueToInt64Strict, also failing on negative values.
+func JSONValueToUint64Strict(value interface{}) (uint64, error) {
+    switch v := value.(type) {
+    case string:
+        n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+        if err != nil {
+            return 0, err
+        }
+        return n, nil
+    case uint64:
+        return v, nil
+    case uint:
+        return uint64(v), nil
+    case float64:
+        return floatToUint64(v)
+    case float32:
+        return floatToUint64(float64(v))
+    case json.Number:
+        n, err := strconv.ParseUint(string(v), 10, 64)
+        if err != nil {
+            return 0, err
+        }
+        return n, nil
+    }
+    n, err := JSONValueToInt64Strict(value)
+    if err != nil {
+        return 0, err
+    }
+    if n < 0 {
+        return 0, fmt.Errorf("jsonhelper: %d is negative", n)
+    }
+    return uint64(n), nil
+}
+
+// floatToInt64 converts f to an int64, failing if f has a fraction or is
+// out of range.
+func floatToInt64(f float64) (int64, error) {
+    if f != math.Trunc(f) {
+        return 0, fmt.Errorf("jsonhelper: %v is not an integer", f)
+    }
+    // -2^63 is exact as a float64; 2^63 is the first value too large.
+    if f < math.MinInt64 || f >= -math.MinInt64 {
+        return 0, fmt.Errorf("jsonhelper: %v overflows int64", f)
+    }
+    return int64(f), nil
+}
+
+// floatToUint64 converts f to a uint64, failing if f has a fraction, is
+// negative or is out of range.
+func floatToUint64(f float64) (uint64, error) {
+    if f != math.Trunc(f) {
+        return 0, fmt.Errorf("jsonhelper: %v is not an integer", f)
+    }
+    if f < 0 {
+        return 0, fmt.Errorf("jsonhelper: %v is negative", f)
+    }
+    if f >= 1<<64 {
+        return 0, fmt.Errorf("jsonhelper: %v overflows uint64", f)
+    }
+    return uint64(f), nil
+}
+
+// JSONValueToFloat64Strict is like JSONValueToFloat64 but fails on values
+// that are not numbers.
+func JSONValueToFloat64Strict(value interface{}) (float64, error) {
+    switch v := value.(type) {
+    case string:
+        return strconv.ParseFloat(strings.TrimSpace(v), 64)
+    case json.Number:
+        return v.Float64()
+    }
+    if isCompositeJSONValue(value) {
+        return 0, fmt.Errorf("jsonhelper: %s is not a number", describeJSONValue(value))
+    }
+    return JSONValueToFloat64(value), nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "math"
+    "testing"
+)
+
+func TestJSONValueToInt64Strict(t *testing.T) {
+    tests := []struct {
+        value interface{}
+        want  int64
+        ok    bool
+    }{
+        {int64(-5), -5, true},
+        {float64(42), 42, true},
+        {float64(1.5), 0, false},
+        {float64(-9223372036854775808), math.MinInt64, true},
+        {float64(9223372036854775808), 0, false},
+        {float64(1e19), 0, false},
+        {math.Inf(1), 0, false},
+        {math.NaN(), 0, false},
+        {float32(7), 7, true},
+        {uint64(math.MaxInt64), math.MaxInt64, true},
+        {uint64(math.MaxInt64 + 1), 0, false},
+        {uint(3), 3, true},
+        {" 12 ", 12, true},
+        {"abc", 0, false},
+        {"9223372036854775808", 0, false},
+        {json.Number("-7"), -7, true},
+        {json.Number("1.5"), 0, false},
+        {json.Number("9223372036854775808"), 0, false},
+        {JSONObject{}, 0, false},
+        {JSONArray{}, 0, false},
+    }
+    for _, tt := range tests {
+        got, err := JSONValueToInt64Strict(tt.value)
+        if (err == nil) != tt.ok || got != tt.want {
+            t.Errorf("JSONValueToInt64Strict(%T %v) = %d, %v", tt.value, tt.value, got, err)
+        }
+    }
+}
+
+func TestJSONValueToUint64Strict(t *testing.T) {
+    tests := []struct {
+        value interface{}
+        want  uint64
+        ok    bool
+    }{
+        {int64(5), 5, true},
+        {int64(-1), 0, false},
+        {uint64(math.MaxUint64), math.MaxUint64, true},
+        {float64(1e19), 10000000000000000000, true},
+        {float64(18446744073709551616), 0, false},
+        {float64(-1), 0, false},
+        {float64(2.5), 0, false},
+        {math.Inf(1), 0, false},
+        {"18446744073709551615", math.MaxUint64, true},
+        {"-1", 0, false},
+        {json.Number("12345678901234567890"), 12345678901234567890, true},
+        {JSONObject{}, 0, false},
+    }
+    for _, tt := range tests {
+        got, err := JSONValueToUint64Strict(tt.value)
+        if (err == nil) != tt.ok || got != tt.want {
+            t.Errorf("JSONValueToUint64Strict(%T %v) = %d, %v", tt.value, tt.value, got, err)
+        }
+    }
+}
+
+func TestUnmarshalIntegerOverflow(t *testing.T) {
+    var v struct {
+        I8  int8   `json:"i8"`
+        U64 uint64 `json:"u64"`
+        I64 int64  `json:"i64"`
+    }
+    if err := Unmarshal(JSONObject{"u64": float64(1e19)}, &v); err != nil || v.U64 != 1e19 {
+        t.Errorf("u64 = %d, %v", v.U64, err)
+    }
+    for _, obj := range []JSONObject{
+        {"i8": int64(200)},
+        {"i64": uint64(math.MaxUint64)},
+        {"i64": float64(1e19)},
+        {"u64": int64(-1)},
+    } {
+        if err := Unmarshal(obj, &v); err == nil {
+            t.Errorf("Unmarshal(%v) succeeded", obj)
+        }
+    }
+}
@@ -0,0 +1,224 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Duration formats understood by Marshal when encoding time.Duration values.
+// In addition to these, any unit accepted by DurationUnit ("ns", "us", "ms",
+// "s", "m", "h") may be used to emit the duration as a number of that unit.
+const (
+    // DurationFormatNanoseconds emits the raw nanosecond count, matching encoding/json.
+    DurationFormatNanoseconds = ""
+    // DurationFormatString emits time.Duration.String(), e.g. "1m30s".
+    DurationFormatString = "string"
+    // DurationFormatISO8601 emits an ISO 8601 duration, e.g. "PT1M30S".
+    DurationFormatISO8601 = "iso8601"
+)
+
+var (
+    errInvalidISO8601Duration = errors.New("jsonhelper: invalid ISO 8601 duration")
+    errDurationRange          = errors.New("jsonhelper: duration is out of range")
+)
+
+// DurationUnit returns the time.Duration represented by a unit name such as
+// "ms" or "s".
+func DurationUnit(name string) (time.Duration, bool) {
+    switch name {
+    case "ns", "nanoseconds":
+        return time.Nanosecond, true
+    case "us", "µs", "microseconds":
+        return time.Microsecond, true
+    case "ms", "milliseconds":
+        return time.Millisecond, true
+    case "s", "seconds":
+        return time.Second, true
+    case "m", "minutes":
+        return time.Minute, true
+    case "h", "hours":
+        return time.Hour, true
+    }
+    return 0, false
+}
+
+// ParseISO8601Duration parses durations of the form PnYnMnWnDTnHnMnS.
+// Years and months have no fixed length, so they are approximated as 365 and
+// 30 days respectively.
+func ParseISO8601Duration(s string) (time.Duration, error) {
+    neg := false
+    if strings.HasPrefix(s, "-") {
+        neg = true
+        s = s[1:]
+    } else if strings.HasPrefix(s, "+") {
+        s = s[1:]
+    }
+    if len(s) < 2 || (s[0] != 'P' && s[0] != 'p') {
+        return 0, errInvalidISO8601Duration
+    }
+    s = s[1:]
+    var total float64
+    inTime := false
+    seen := false
+    for len(s) > 0 {
+        if s[0] == 'T' || s[0] == 't' {
+            if inTime {
+                return 0, errInvalidISO8601Duration
+            }
+            inTime = true
+            s = s[1:]
+            continue
+        }
+        i := 0
+        for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.' || s[i] == ',') {
+            i++
+        }
+        if i == 0 || i == len(s) {
+            return 0, errInvalidISO8601Duration
+        }
+        n, err := strconv.ParseFloat(strings.Replace(s[:i], ",", ".", 1), 64)
+        if err != nil {
+            return 0, errInvalidISO8601Duration
+        }
+        var unit time.Duration
+        switch s[i] {
+        case 'Y', 'y':
+            unit = 365 * 24 * time.Hour
+        case 'M', 'm':
+            if inTime {
+                unit = time.Minute
+            } else {
+                unit = 30 * 24 * time.Hour
+            }
+        case 'W', 'w':
+            unit = 7 * 24 * time.Hour
+        case 'D', 'd':
+            unit = 24 * time.Hour
+        case 'H', 'h':
+            unit = time.Hour
+        case 'S', 's':
+            unit = time.Second
+        default:
+            return 0, errInvalidISO8601Duration
+        }
+        if inTime != (unit <= time.Hour) {
+            return 0, errInvalidISO8601Duration
+        }
+        total += n * float64(unit)
+        if total >= math.MaxInt64 {
+            return 0, errInvalidISO8601Duration
+        }
+        seen = true
+        s = s[i+1:]
+    }
+    if !seen {
+        return 0, errInvalidISO8601Duration
+    }
+    if neg {
+        total = -total
+    }
+    return time.Duration(total), nil
+}
+
+// FormatISO8601Duration formats d as an ISO 8601 duration using hours,
+// minutes and seconds only, e.g. 36h0m1.5s is written as "PT36H1.5S".
+func FormatISO8601Duration(d time.Duration) string {
+    if d == 0 {
+        return "PT0S"
+    }
+    buf := make([]byte, 0, 24)
+    u := uint64(d)
+    if d < 0 {
+        buf = append(buf, '-')
+        u = -u
+    }
+    buf = append(buf, 'P', 'T')
+    hours := u / uint64(time.Hour)
+    u -= hours * uint64(time.Hour)
+    minutes := u / uint64(time.Minute)
+    u -= minutes * uint64(time.Minute)
+    if hours > 0 {
+        buf = strconv.AppendUint(buf, hours, 10)
+        buf = append(buf, 'H')
+    }
+    if minutes > 0 {
+        buf = strconv.AppendUint(buf, minutes, 10)
+        buf = append(buf, 'M')
+    }
+    if u > 0 {
+        secs := u / uint64(time.Second)
+        frac := u - secs*uint64(time.Second)
+        buf = strconv.AppendUint(buf, secs, 10)
+        if frac > 0 {
+            f := strconv.FormatUint(frac+uint64(time.Second), 10)[1:]
+            buf = append(buf, '.')
+            buf = append(buf, strings.TrimRight(f, "0")...)
+        }
+        buf = append(buf, 'S')
+    }
+    return string(buf)
+}
+
+// formatDuration converts d into the JSON value described by format.
+func formatDuration(d time.Duration, format string) (interface{}, error) {
+    switch format {
+    case DurationFormatNanoseconds:
+        return int64(d), nil
+    case DurationFormatString:
+        return d.String(), nil
+    case DurationFormatISO8601:
+        return FormatISO8601Duration(d), nil
+    }
+    unit, ok := DurationUnit(format)
+    if !ok {
+        return nil, fmt.Errorf("jsonhelper: unknown duration format %q", format)
+    }
+    if d%unit == 0 {
+        return int64(d / unit), nil
+    }
+    return float64(d) / float64(unit), nil
+}
+
+// parseDurationString accepts Go duration strings, ISO 8601 durations and
+// plain numbers, which are interpreted in the given unit.
+func parseDurationString(s string, unit time.Duration) (time.Duration, error) {
+    s = strings.TrimSpace(s)
+    if s == "" {
+        return 0, nil
+    }
+    if c := strings.TrimLeft(s, "+-"); len(c) > 0 && (c[0] == 'P' || c[0] == 'p') {
+        return ParseISO8601Duration(s)
+    }
+    if f, err := strconv.ParseFloat(s, 64); err == nil {
+        return durationFromFloat(f, unit)
+    }
+    return time.ParseDuration(s)
+}
+
+// durationFromFloat returns f units as a Duration, rejecting values that
+// are not finite or do not fit.
+func durationFromFloat(f float64, unit time.Duration) (time.Duration, error) {
+    // float64(math.MaxInt64) rounds up to 2^63, which does not fit.
+    d := f * float64(unit)
+    if math.IsNaN(d) || d >= math.MaxInt64 || d < math.MinInt64 {
+        return 0, errDurationRange
+    }
+    return time.Duration(d), nil
+}
+
+// durationFromInt returns n units as a Duration, rejecting values that do
+// not fit.
+func durationFromInt(n int64, unit time.Duration) (time.Duration, error) {
+    if n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+        return 0, errDurationRange
+    }
+    return time.Duration(n) * unit, nil
+}
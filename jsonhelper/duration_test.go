@@ -0,0 +1,179 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "math"
+    "reflect"
+    "testing"
+    "time"
+)
+
+func TestParseISO8601Duration(t *testing.T) {
+    tests := []struct {
+        in   string
+        want time.Duration
+        ok   bool
+    }{
+        {"PT0S", 0, true},
+        {"PT1M30S", 90 * time.Second, true},
+        {"PT36H1.5S", 36*time.Hour + 1500*time.Millisecond, true},
+        {"PT0,5S", 500 * time.Millisecond, true},
+        {"P1DT2H", 26 * time.Hour, true},
+        {"P2W", 14 * 24 * time.Hour, true},
+        {"P1M", 30 * 24 * time.Hour, true},
+        {"P1Y", 365 * 24 * time.Hour, true},
+        {"-PT5M", -5 * time.Minute, true},
+        {"pt5m", 5 * time.Minute, true},
+        {"P", 0, false},
+        {"PT", 0, false},
+        {"P5H", 0, false},
+        {"PT5D", 0, false},
+        {"PTT5S", 0, false},
+        {"PT5", 0, false},
+        {"1M", 0, false},
+        {"P999999999Y", 0, false},
+        {"PT2562047H47M16.854775807S", 0, false},
+        {"PT2562047H47M17S", 0, false},
+        {"P106752D", 0, false},
+        {"PT9223372036.854775807S", 0, false},
+        {"PT2562047H", 2562047 * time.Hour, true},
+    }
+    for _, tt := range tests {
+        got, err := ParseISO8601Duration(tt.in)
+        if (err == nil) != tt.ok || got != tt.want {
+            t.Errorf("ParseISO8601Duration(%q) = %v, %v", tt.in, got, err)
+        }
+    }
+}
+
+func TestFormatISO8601Duration(t *testing.T) {
+    tests := []struct {
+        in   time.Duration
+        want string
+    }{
+        {0, "PT0S"},
+        {90 * time.Second, "PT1M30S"},
+        {36*time.Hour + 1500*time.Millisecond, "PT36H1.5S"},
+        {-time.Hour, "-PT1H"},
+        {time.Nanosecond, "PT0.000000001S"},
+    }
+    for _, tt := range tests {
+        got := FormatISO8601Duration(tt.in)
+        if got != tt.want {
+            t.Errorf("FormatISO8601Duration(%v) = %q, want %q", tt.in, got, tt.want)
+        }
+        if back, err := ParseISO8601Duration(got); err != nil || back != tt.in {
+            t.Errorf("ParseISO8601Duration(%q) = %v, %v, want %v", got, back, err, tt.in)
+        }
+    }
+}
+
+func TestJSONValueToDuration(t *testing.T) {
+    tests := []struct {
+        value interface{}
+        unit  time.Duration
+        want  time.Duration
+    }{
+        {"1m30s", 0, 90 * time.Second},
+        {"PT1M", 0, time.Minute},
+        {"250", time.Millisecond, 250 * time.Millisecond},
+        {" ", time.Second, 0},
+        {"bogus", time.Second, 0},
+        {float64(1.5), time.Second, 1500 * time.Millisecond},
+        {int64(3), time.Minute, 3 * time.Minute},
+        {uint8(2), 0, 2},
+        {time.Hour, time.Second, time.Hour},
+        {true, time.Second, 0},
+        {JSONObject{}, time.Second, 0},
+        {"1e30", 0, 0},
+        {"NaN", time.Second, 0},
+        {"-Inf", time.Second, 0},
+        {math.NaN(), time.Second, 0},
+        {math.Inf(1), time.Second, 0},
+        {float64(1e19), 0, 0},
+        {float64(-1e19), 0, 0},
+        {float64(9.3e9), time.Second, 0},
+        {int64(math.MaxInt64), time.Millisecond, 0},
+        {int64(math.MinInt64 / int64(time.Millisecond)), time.Millisecond, math.MinInt64 / time.Millisecond * time.Millisecond},
+        {uint64(math.MaxUint64), 0, 0},
+        {float64(-9.2e18), 0, time.Duration(-9.2e18)},
+    }
+    for _, tt := range tests {
+        if got := JSONValueToDuration(tt.value, tt.unit); got != tt.want {
+            t.Errorf("JSONValueToDuration(%#v, %v) = %v, want %v", tt.value, tt.unit, got, tt.want)
+        }
+    }
+    obj := JSONObject{"timeout": "2s", "huge": "1e30"}
+    if got := obj.GetAsDuration("timeout", 0); got != 2*time.Second {
+        t.Errorf("GetAsDuration = %v", got)
+    }
+    if got := obj.GetAsDuration("huge", 0); got != 0 {
+        t.Errorf("GetAsDuration of 1e30 = %v", got)
+    }
+}
+
+func TestJSONValueToDurationStrict(t *testing.T) {
+    tests := []struct {
+        value interface{}
+        unit  time.Duration
+        want  time.Duration
+        ok    bool
+    }{
+        {"1m30s", 0, 90 * time.Second, true},
+        {"250", time.Millisecond, 250 * time.Millisecond, true},
+        {"250", 0, 250, true},
+        {int64(2), time.Hour, 2 * time.Hour, true},
+        {nil, time.Second, 0, true},
+        {"bogus", time.Second, 0, false},
+        {"1e30", 0, 0, false},
+        {"NaN", time.Second, 0, false},
+        {"Inf", time.Second, 0, false},
+        {math.Inf(-1), time.Second, 0, false},
+        {float64(1e19), 0, 0, false},
+        {int64(math.MaxInt64 / 1000), time.Second, 0, false},
+        {uint64(1 << 63), 0, 0, false},
+        {"P999999999Y", 0, 0, false},
+        {JSONArray{}, time.Second, 0, false},
+    }
+    for _, tt := range tests {
+        got, err := JSONValueToDurationStrict(tt.value, tt.unit)
+        if (err == nil) != tt.ok || got != tt.want {
+            t.Errorf("JSONValueToDurationStrict(%#v, %v) = %v, %v", tt.value, tt.unit, got, err)
+        }
+    }
+}
+
+func TestMarshalDuration(t *testing.T) {
+    d := 1500 * time.Millisecond
+    tests := []struct {
+        format string
+        want   interface{}
+    }{
+        {DurationFormatNanoseconds, int64(1500000000)},
+        {DurationFormatString, "1.5s"},
+        {DurationFormatISO8601, "PT1.5S"},
+        {"ms", int64(1500)},
+        {"s", float64(1.5)},
+    }
+    for _, tt := range tests {
+        got, err := MarshalWithOptions(d, MarshalOptions{DurationFormat: tt.format})
+        if err != nil || !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("format %q: got %#v, %v, want %#v", tt.format, got, err, tt.want)
+        }
+    }
+    if _, err := MarshalWithOptions(d, MarshalOptions{DurationFormat: "fortnights"}); err == nil {
+        t.Error("unknown duration format succeeded")
+    }
+    v := struct {
+        Timeout time.Duration `json:"timeout,duration=ms"`
+        Wait    time.Duration `json:"wait"`
+    }{2 * time.Second, time.Minute}
+    got, err := MarshalWithFormats(v, "", DurationFormatString)
+    want := JSONObject{"timeout": int64(2000), "wait": "1m0s"}
+    if err != nil || !reflect.DeepEqual(got, want) {
+        t.Errorf("tagged struct: got %#v, %v, want %#v", got, err, want)
+    }
+}
@@ -7,79 +7,218 @@
 package jsonhelper
 
 import (
-    "encoding/base64"
+    "encoding"
     "encoding/json"
+    "fmt"
+    "math"
     "reflect"
     "runtime"
     "sort"
     "strconv"
+    "sync"
     "time"
     "unicode"
 )
 
-var byteSliceType = reflect.TypeOf([]byte(nil))
 var timeType = reflect.TypeOf(time.Time{})
+var durationType = reflect.TypeOf(time.Duration(0))
+var orderedJSONObjectType = reflect.TypeOf(OrderedJSONObject{})
 
 func Marshal(v interface{}) (retval interface{}, err error) {
+    return MarshalWithOptions(v, MarshalOptions{})
+}
+
+// MarshalWithTimeFormat formats time.Time values using timeFormat.
+func MarshalWithTimeFormat(v interface{}, timeFormat string) (retval interface{}, err error) {
+    return MarshalWithOptions(v, MarshalOptions{TimeFormat: timeFormat})
+}
+
+// MarshalWithFormats is like MarshalWithTimeFormat, but also controls how
+// time.Duration values are emitted. See the DurationFormat constants.
+func MarshalWithFormats(v interface{}, timeFormat, durationFormat string) (retval interface{}, err error) {
+    return MarshalWithOptions(v, MarshalOptions{TimeFormat: timeFormat, DurationFormat: durationFormat})
+}
+
+func MarshalWithOptions(v interface{}, opts MarshalOptions) (retval interface{}, err error) {
     defer func() {
         if r := recover(); r != nil {
-            if _, ok := r.(runtime.Error); ok {
-                panic(r)
-            }
-            err = r.(error)
+            err = recoveredError(r)
         }
     }()
     if v == nil {
         return nil, nil
     }
-    e := &encodeState{}
-    retval = e.reflectValue(reflect.ValueOf(v), false)
+    e := &encodeState{
+        opts:      &opts,
+        selector:  newFieldSelector(opts.Include, opts.Exclude),
+        isDefault: opts.isDefault(),
+    }
+    retval = e.encode(reflect.ValueOf(v), encOpts{
+        timeFormat:     opts.TimeFormat,
+        durationFormat: opts.DurationFormat,
+        bytesEncoding:  opts.BytesEncoding,
+    })
     return
 }
 
-func MarshalWithOptions(v interface{}, timeFormat string) (retval interface{}, err error) {
-    defer func() {
-        if r := recover(); r != nil {
-            if _, ok := r.(runtime.Error); ok {
-                panic(r)
-            }
-            err = r.(error)
-        }
-    }()
-    if v == nil {
-        return nil, nil
+// recoveredError converts a value recovered from the panics Marshal and
+// Unmarshal unwind with into their error. Runtime errors are re-panicked,
+// and other values, such as the strings reflect panics with, are wrapped.
+func recoveredError(r interface{}) error {
+    if _, ok := r.(runtime.Error); ok {
+        panic(r)
     }
-    e := &encodeState{timeFormat: timeFormat}
-    retval = e.reflectValue(reflect.ValueOf(v), false)
-    return
+    if err, ok := r.(error); ok {
+        return err
+    }
+    return fmt.Errorf("jsonhelper: %v", r)
 }
 
-// stringValues is a slice of reflect.Value holding *reflect.StringValue.
-// It implements the methods to sort by string.
-type stringValues []reflect.Value
+// encodeState holds the state of a single Marshal call. The options that
+// struct tags can override for a single field travel separately, by value,
+// as encOpts.
+type encodeState struct {
+    opts      *MarshalOptions
+    depth     int
+    path      []pathSegment
+    visiting  []visit
+    selector  *fieldSelector
+    fieldPath []string
+    skipped   bool
+    isDefault bool
+}
 
-func (sv stringValues) Len() int           { return len(sv) }
-func (sv stringValues) Swap(i, j int)      { sv[i], sv[j] = sv[j], sv[i] }
-func (sv stringValues) Less(i, j int) bool { return sv.get(i) < sv.get(j) }
-func (sv stringValues) get(i int) string   { return sv[i].String() }
+// encOpts are the options in effect for the value being encoded.
+type encOpts struct {
+    stringify      bool
+    timeFormat     string
+    durationFormat string
+    bytesEncoding  BytesEncoding
+}
 
-type encodeState struct {
-    isObject   bool
-    isArray    bool
-    isFloat    bool
-    isInt      bool
-    isUint     bool
-    isString   bool
-    isBool     bool
-    isNull     bool
-    obj        JSONObject
-    arr        JSONArray
-    fValue     float64
-    iValue     int64
-    uValue     uint64
-    sValue     string
-    bValue     bool
-    timeFormat string
+// pathSegment is a struct field or map key, or an index into an array.
+type pathSegment struct {
+    key   string
+    index int
+}
+
+// An encoderFunc converts v, whose type it was built for, into a JSON value.
+type encoderFunc func(e *encodeState, v reflect.Value, opts encOpts) interface{}
+
+var encoderCache struct {
+    sync.RWMutex
+    m map[reflect.Type]encoderFunc
+}
+
+// typeEncoder returns the cached encoder for t, building it on first use.
+func typeEncoder(t reflect.Type) encoderFunc {
+    encoderCache.RLock()
+    f := encoderCache.m[t]
+    encoderCache.RUnlock()
+    if f != nil {
+        return f
+    }
+
+    // To deal with recursive types, populate the map with an
+    // indirect func before we build it. This type waits on the
+    // real func (f) to be ready and then calls it. This indirect
+    // func is only used for recursive types.
+    encoderCache.Lock()
+    if encoderCache.m == nil {
+        encoderCache.m = make(map[reflect.Type]encoderFunc)
+    }
+    if f = encoderCache.m[t]; f != nil {
+        encoderCache.Unlock()
+        return f
+    }
+    var wg sync.WaitGroup
+    wg.Add(1)
+    encoderCache.m[t] = func(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+        wg.Wait()
+        return f(e, v, opts)
+    }
+    encoderCache.Unlock()
+
+    // Compute the real encoder and replace the indirect func with it.
+    f = newTypeEncoder(t, true)
+    wg.Done()
+    encoderCache.Lock()
+    encoderCache.m[t] = f
+    encoderCache.Unlock()
+    return f
+}
+
+// newTypeEncoder constructs an encoderFunc for a type. Types implementing
+// JSONObjectMarshaler get an encoder that falls back to the one built with
+// allowObjectMarshaler false when their method cannot be used.
+func newTypeEncoder(t reflect.Type, allowObjectMarshaler bool) encoderFunc {
+    if allowObjectMarshaler && t.Implements(jsonObjectMarshalerType) {
+        return newObjectMarshalerEncoder(t)
+    }
+    switch t {
+    case timeType:
+        return timeEncoder
+    case reflect.PtrTo(timeType):
+        // *time.Time is a json.Marshaler, which would ignore the time
+        // format of the field.
+        return newPtrEncoder(t)
+    case durationType:
+        return durationEncoder
+    case orderedJSONObjectType, reflect.PtrTo(orderedJSONObjectType):
+        return orderedObjectEncoder
+    }
+    if t.Implements(jsonMarshalerType) {
+        return marshalerEncoder
+    }
+    if t.Implements(textMarshalerType) {
+        return textMarshalerEncoder
+    }
+    switch t.Kind() {
+    case reflect.Bool:
+        return boolEncoder
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return intEncoder
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return uintEncoder
+    case reflect.Float32, reflect.Float64:
+        return floatEncoder
+    case reflect.String:
+        return stringEncoder
+    case reflect.Interface:
+        return interfaceEncoder
+    case reflect.Struct:
+        return newStructEncoder(t)
+    case reflect.Map:
+        return newMapEncoder(t)
+    case reflect.Slice, reflect.Array:
+        return newArrayEncoder(t)
+    case reflect.Ptr:
+        return newPtrEncoder(t)
+    }
+    return unsupportedEncoder
+}
+
+// encode converts v, honoring the per-call Encoders overrides.
+func (e *encodeState) encode(v reflect.Value, opts encOpts) interface{} {
+    if !v.IsValid() {
+        return nil
+    }
+    return e.encodeWith(typeEncoder(v.Type()), v, opts)
+}
+
+// encodeWith converts v with the encoder cached for its type, unless the
+// caller registered an EncoderFunc for that type.
+func (e *encodeState) encodeWith(f encoderFunc, v reflect.Value, opts encOpts) interface{} {
+    if e.opts.Encoders != nil && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+        if enc, ok := e.opts.Encoders[v.Type()]; ok {
+            value, err := enc(v.Interface())
+            if err != nil {
+                e.error(&json.MarshalerError{Type: v.Type(), Err: err})
+            }
+            return value
+        }
+    }
+    return f(e, v, opts)
 }
 
 func isValidTag(s string) bool {
@@ -112,202 +251,649 @@ func isEmptyValue(v reflect.Value) bool {
     return false
 }
 
-func (e *encodeState) newWithSameOptions() *encodeState {
-    o := new(encodeState)
-    o.timeFormat = e.timeFormat
-    return o
+type isZeroer interface {
+    IsZero() bool
 }
 
-func (e *encodeState) Value() interface{} {
-    if e.isObject {
-        return e.obj
+var isZeroerType = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// isZeroValue reports whether v should be dropped by the omitzero option:
+// its IsZero method returns true or, lacking one, it is the zero value.
+func isZeroValue(v reflect.Value) bool {
+    if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+        return true
     }
-    if e.isArray {
-        return e.arr
+    if v.Type().Implements(isZeroerType) {
+        return v.Interface().(isZeroer).IsZero()
     }
-    if e.isFloat {
-        return e.fValue
+    if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(isZeroerType) {
+        return v.Addr().Interface().(isZeroer).IsZero()
     }
-    if e.isInt {
-        return e.iValue
+    return v.IsZero()
+}
+
+func (e *encodeState) error(err error) {
+    panic(err)
+}
+
+// unsupported fails on a value that cannot be represented, or marks it to
+// be dropped by its container when the SkipUnsupported option is set.
+func (e *encodeState) unsupported(t reflect.Type) interface{} {
+    if e.opts.SkipUnsupported {
+        e.skipped = true
+        return nil
     }
-    if e.isUint {
-        return e.uValue
+    e.error(&json.UnsupportedTypeError{Type: t})
+    return nil
+}
+
+// takeSkipped reports whether the value just encoded must be dropped.
+func (e *encodeState) takeSkipped() bool {
+    skipped := e.skipped
+    e.skipped = false
+    return skipped
+}
+
+// enter checks that descending into another object or array stays within
+// the MaxDepth option. Each call must be paired with a call to leave.
+func (e *encodeState) enter() {
+    if e.opts.MaxDepth > 0 && e.depth >= e.opts.MaxDepth {
+        e.error(&MaxDepthError{Depth: e.opts.MaxDepth, Path: e.pathString()})
     }
-    if e.isString {
-        return e.sValue
+    e.depth++
+}
+
+func (e *encodeState) leave() {
+    e.depth--
+}
+
+func (e *encodeState) pushKey(key string) {
+    e.path = append(e.path, pathSegment{key: key, index: -1})
+}
+
+func (e *encodeState) pushIndex(i int) {
+    e.path = append(e.path, pathSegment{index: i})
+}
+
+func (e *encodeState) pop() {
+    e.path = e.path[:len(e.path)-1]
+}
+
+// pathString describes the location being encoded, such as
+// "$.users[3].friend".
+func (e *encodeState) pathString() string {
+    s := "$"
+    for _, seg := range e.path {
+        if seg.index >= 0 {
+            s += "[" + strconv.Itoa(seg.index) + "]"
+        } else {
+            s += "." + seg.key
+        }
     }
-    if e.isBool {
-        return e.bValue
+    return s
+}
+
+// selectKey checks the object member key against the Include and Exclude
+// options, and on success makes it the current field path. The returned
+// path must be restored with restoreFieldPath. The value of a partial key
+// must be passed through pruneAncestor.
+func (e *encodeState) selectKey(key string) (saved []string, partial, ok bool) {
+    saved = e.fieldPath
+    if e.selector == nil {
+        return saved, false, true
     }
-    if e.isNull {
-        return nil
+    path := make([]string, len(saved)+1)
+    copy(path, saved)
+    path[len(saved)] = key
+    if ok, partial = e.selector.allows(path); !ok {
+        return saved, false, false
     }
-    return nil
+    e.fieldPath = path
+    return saved, partial, true
 }
 
-func (e *encodeState) error(err error) {
-    panic(err)
+func (e *encodeState) restoreFieldPath(saved []string) {
+    e.fieldPath = saved
 }
 
-func (e *encodeState) reflectValue(v reflect.Value, stringify bool) (retval interface{}) {
-    if !v.IsValid() {
-        e.isNull = true
-        return
-    }
-    if j, ok := v.Interface().(json.Marshaler); ok {
-        b, err := j.MarshalJSON()
-        if err == nil {
-            var value interface{}
-            err = json.Unmarshal(b, &value)
+// visit identifies a pointer, map or slice being encoded.
+type visit struct {
+    ptr uintptr
+    typ reflect.Type
+    n   int
+}
+
+// startVisit records that the value behind v is being encoded, failing if
+// it is already being encoded further up the tree. Each call must be paired
+// with a call to endVisit.
+func (e *encodeState) startVisit(v reflect.Value) {
+    key := visit{ptr: v.Pointer(), typ: v.Type()}
+    if v.Kind() == reflect.Slice {
+        key.n = v.Len()
+    }
+    for _, seen := range e.visiting {
+        if seen == key {
+            e.error(&CycleError{Type: v.Type(), Path: e.pathString()})
         }
-        if err != nil {
-            e.error(&json.MarshalerError{Type:v.Type(), Err:err})
+    }
+    e.visiting = append(e.visiting, key)
+}
+
+func (e *encodeState) endVisit() {
+    e.visiting = e.visiting[:len(e.visiting)-1]
+}
+
+// MaxDepthError is returned by Marshal when a value nests deeper than the
+// MaxDepth option allows.
+type MaxDepthError struct {
+    Depth int
+    Path  string
+}
+
+func (e *MaxDepthError) Error() string {
+    return "jsonhelper: exceeded maximum nesting depth of " + strconv.Itoa(e.Depth) + " at " + e.Path
+}
+
+// CycleError is returned by Marshal when a value refers back to itself.
+type CycleError struct {
+    Type reflect.Type
+    Path string
+}
+
+func (e *CycleError) Error() string {
+    return "jsonhelper: encountered a cycle via " + e.Type.String() + " at " + e.Path
+}
+
+func stringifyValue(value interface{}, opts encOpts) interface{} {
+    if opts.stringify {
+        return JSONValueToString(value)
+    }
+    return value
+}
+
+func timeEncoder(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if opts.timeFormat == "" {
+        return marshalerEncoder(e, v, opts)
+    }
+    return stringifyValue(FormatTime(v.Interface().(time.Time), opts.timeFormat), opts)
+}
+
+func durationEncoder(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if opts.durationFormat == DurationFormatNanoseconds {
+        return intEncoder(e, v, opts)
+    }
+    value, err := formatDuration(time.Duration(v.Int()), opts.durationFormat)
+    if err != nil {
+        e.error(err)
+    }
+    return stringifyValue(value, opts)
+}
+
+func marshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if v.Kind() == reflect.Ptr && v.IsNil() {
+        return nil
+    }
+    b, err := v.Interface().(json.Marshaler).MarshalJSON()
+    var value interface{}
+    if err == nil {
+        err = json.Unmarshal(b, &value)
+    }
+    if err != nil {
+        e.error(&json.MarshalerError{Type: v.Type(), Err: err})
+    }
+    return normalizeJSONValue(value)
+}
+
+func textMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if v.Kind() == reflect.Ptr && v.IsNil() {
+        return nil
+    }
+    b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+    if err != nil {
+        e.error(&json.MarshalerError{Type: v.Type(), Err: err})
+    }
+    return string(b)
+}
+
+type objectMarshalerEncoder struct {
+    fallback encoderFunc
+}
+
+func newObjectMarshalerEncoder(t reflect.Type) encoderFunc {
+    oe := &objectMarshalerEncoder{newTypeEncoder(t, false)}
+    return oe.encode
+}
+
+// encode calls MarshalJSONObject when nothing in the call or the enclosing
+// field tags changes the result from the default, which is all that
+// generated methods produce. Generated methods are given the encodeState
+// through MarshalJSONObjectWith, so that cycles and depth are tracked
+// across them.
+func (oe *objectMarshalerEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if v.Kind() == reflect.Ptr && v.IsNil() {
+        return nil
+    }
+    if !e.isDefault || opts != (encOpts{}) {
+        return oe.fallback(e, v, opts)
+    }
+    e.enter()
+    if v.Kind() == reflect.Ptr {
+        e.startVisit(v)
+    }
+    var obj JSONObject
+    var err error
+    if m, ok := v.Interface().(jsonObjectContextMarshaler); ok {
+        obj, err = m.MarshalJSONObjectWith(&EncodeContext{e})
+    } else {
+        obj, err = v.Interface().(JSONObjectMarshaler).MarshalJSONObject()
+    }
+    if v.Kind() == reflect.Ptr {
+        e.endVisit()
+    }
+    e.leave()
+    if err != nil {
+        e.error(&json.MarshalerError{Type: v.Type(), Err: err})
+    }
+    if obj == nil {
+        return nil
+    }
+    return obj
+}
+
+func boolEncoder(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if opts.stringify {
+        if v.Bool() {
+            return "true"
         }
-        return retval
+        return "false"
     }
-    switch v.Kind() {
-    case reflect.Bool:
-        if stringify {
-            x := v.Bool()
-            if x {
-                e.sValue = "true"
-            } else {
-                e.sValue = "false"
+    return v.Bool()
+}
+
+func intEncoder(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if opts.stringify {
+        return strconv.FormatInt(v.Int(), 10)
+    }
+    return v.Int()
+}
+
+func uintEncoder(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if opts.stringify {
+        return strconv.FormatUint(v.Uint(), 10)
+    }
+    return v.Uint()
+}
+
+func floatEncoder(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    f := v.Float()
+    if math.IsNaN(f) || math.IsInf(f, 0) {
+        switch e.opts.FloatPolicy {
+        case FloatError:
+            e.error(&json.UnsupportedValueError{Value: v, Str: strconv.FormatFloat(f, 'g', -1, 64)})
+        case FloatNull:
+            return nil
+        case FloatString:
+            if math.IsInf(f, 1) {
+                return "+Inf"
+            } else if math.IsInf(f, -1) {
+                return "-Inf"
             }
-            e.isString = true
-            retval = e.sValue
-        } else {
-            e.bValue = v.Bool()
-            e.isBool = true
-            retval = e.bValue
+            return "NaN"
         }
-    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-        if stringify {
-            e.sValue = strconv.FormatInt(v.Int(), 10)
-            e.isString = true
-            retval = e.sValue
-        } else {
-            e.iValue = v.Int()
-            e.isInt = true
-            retval = e.iValue
+    }
+    if opts.stringify {
+        return strconv.FormatFloat(f, 'g', -1, v.Type().Bits())
+    }
+    return f
+}
+
+func stringEncoder(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    return v.String()
+}
+
+func interfaceEncoder(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if v.IsNil() {
+        return nil
+    }
+    opts.stringify = false
+    return e.encode(v.Elem(), opts)
+}
+
+func unsupportedEncoder(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    return e.unsupported(v.Type())
+}
+
+// fieldEncoder is the cached plan for one struct field: where it lives,
+// what its key is, how to encode its type and which options its tag
+// overrides.
+type fieldEncoder struct {
+    field
+    enc               encoderFunc
+    timeFormat        string
+    durationFormat    string
+    hasDurationFormat bool
+    bytesEncoding     BytesEncoding
+    hasBytesEncoding  bool
+}
+
+// setOptions records the options of the field's tag that override those
+// of the struct containing it.
+func (f *fieldEncoder) setOptions(opts tagOptions) {
+    if format, ok := opts.Get("format"); ok && format != "" {
+        f.timeFormat = format
+    }
+    if opts.Contains(TimeFormatUnix) {
+        f.timeFormat = TimeFormatUnix
+    }
+    if opts.Contains(TimeFormatUnixMilli) {
+        f.timeFormat = TimeFormatUnixMilli
+    }
+    if f.durationFormat, f.hasDurationFormat = opts.Get("duration"); f.hasDurationFormat && f.durationFormat == "" {
+        f.durationFormat = DurationFormatString
+    }
+    var enc string
+    enc, f.hasBytesEncoding = opts.Get("bytes")
+    f.bytesEncoding = BytesEncoding(enc)
+}
+
+// fieldOpts derives the options for the field's value from those of the
+// struct containing it.
+func (f *fieldEncoder) fieldOpts(opts encOpts) encOpts {
+    opts.stringify = f.stringify
+    if f.timeFormat != "" {
+        opts.timeFormat = f.timeFormat
+    }
+    if f.hasDurationFormat {
+        opts.durationFormat = f.durationFormat
+    }
+    if f.hasBytesEncoding {
+        opts.bytesEncoding = f.bytesEncoding
+    }
+    return opts
+}
+
+type structEncoder struct {
+    fields []fieldEncoder
+}
+
+func newStructEncoder(t reflect.Type) encoderFunc {
+    fields := cachedTypeFields(t)
+    se := &structEncoder{fields: make([]fieldEncoder, len(fields))}
+    for i, f := range fields {
+        fe := &se.fields[i]
+        fe.field = f
+        fe.enc = typeEncoder(typeByIndex(t, f.index))
+        fe.setOptions(f.opts)
+    }
+    return se.encode
+}
+
+func (se *structEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    e.enter()
+    obj := NewJSONObject()
+    // order keeps the keys of obj in field order under OrderedObjects.
+    var order []string
+    var collapsed []*OrderedJSONObject
+    for i := range se.fields {
+        f := &se.fields[i]
+        name := f.name
+        if !f.tag && e.opts.FieldNaming != nil {
+            name = e.opts.FieldNaming(name)
         }
-    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-        if stringify {
-            e.sValue = strconv.FormatUint(v.Uint(), 10)
-            e.isString = true
-            retval = e.sValue
-        } else {
-            e.uValue = v.Uint()
-            e.isUint = true
-            retval = e.uValue
+        if f.view != "" && !inView(f.view, e.opts.View) {
+            continue
         }
-    case reflect.Float32, reflect.Float64:
-        if stringify {
-            e.sValue = strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
-            e.isString = true
-            retval = e.sValue
-        } else {
-            e.fValue = v.Float()
-            e.isFloat = true
-            retval = e.fValue
+        fieldValue, ok := fieldByIndex(v, f.index)
+        if !ok {
+            continue
         }
-    case reflect.String:
-        e.sValue = v.String()
-        e.isString = true
-        retval = e.sValue
-    case reflect.Struct:
-        t := v.Type()
-        if t == timeType && e.timeFormat != "" {
-            s := v.Interface().(time.Time)
-            e.sValue = s.Format(e.timeFormat)
-            e.isString = true
-            retval = e.sValue
-            break
+        if f.omitEmpty && isEmptyValue(fieldValue) {
+            continue
         }
-        n := v.NumField()
-        e.obj = NewJSONObject()
-        e.isObject = true
-        for i := 0; i < n; i++ {
-            f := t.Field(i)
-            if f.PkgPath != "" {
+        if f.omitZero && isZeroValue(fieldValue) {
+            continue
+        }
+        savedPath, partial := e.fieldPath, false
+        if !f.collapse {
+            if savedPath, partial, ok = e.selectKey(name); !ok {
                 continue
             }
-            tag, omitEmpty, collapse, stringify := f.Name, false, false, false
-            if tv := f.Tag.Get("json"); tv != "" {
-                name, opts := parseTag(tv)
-                if isValidTag(name) {
-                    tag = name
-                }
-                omitEmpty = opts.Contains("omitempty")
-                stringify = opts.Contains("string")
-                collapse = opts.Contains("collapse")
+        }
+        e.pushKey(name)
+        value := e.encodeWith(f.enc, fieldValue, f.fieldOpts(opts))
+        e.pop()
+        e.restoreFieldPath(savedPath)
+        if e.takeSkipped() {
+            continue
+        }
+        if partial {
+            if value, ok = pruneAncestor(value); !ok {
+                continue
             }
-            fieldValue := v.Field(i)
-            if omitEmpty && isEmptyValue(fieldValue) {
+        }
+        if f.sensitive {
+            redacted, keep := redactValue(value, e.opts.Redaction, e.opts.RedactionMask)
+            if !keep {
                 continue
             }
-            subvalue := e.newWithSameOptions().reflectValue(fieldValue, stringify)
-            if subvalue != nil {
-                if subobj, ok := subvalue.(JSONObject); ok {
-                    if omitEmpty && subobj.Len() == 0 {
-                        continue
-                    }
-                    if collapse {
-                        for k, v := range subobj {
-                            e.obj[k] = v
-                        }
-                        continue
-                    }
+            value = redacted
+        }
+        if value != nil {
+            switch value.(type) {
+            case JSONObject, *OrderedJSONObject:
+                if subobj, _ := jsonObjectValue(value); f.omitEmpty && len(subobj) == 0 {
+                    continue
+                }
+                if f.collapse {
+                    collapsed = append(collapsed, JSONValueToOrderedObject(value))
+                    continue
                 }
-            } else if omitEmpty {
+            }
+        } else if f.omitEmpty {
+            continue
+        }
+        obj[name] = value
+        if e.opts.OrderedObjects {
+            order = append(order, name)
+        }
+    }
+    // Collapsed objects never override the struct's own fields.
+    for _, subobj := range collapsed {
+        for _, k := range subobj.keys {
+            if _, exists := obj[k]; !exists {
+                obj[k] = subobj.values[k]
+                order = append(order, k)
+            }
+        }
+    }
+    e.leave()
+    if e.opts.OrderedObjects {
+        return &OrderedJSONObject{keys: order, values: obj}
+    }
+    return obj
+}
+
+type mapEncoder struct {
+    elemEnc encoderFunc
+}
+
+func newMapEncoder(t reflect.Type) encoderFunc {
+    if !isSupportedMapKey(t.Key()) {
+        return unsupportedEncoder
+    }
+    me := &mapEncoder{typeEncoder(t.Elem())}
+    return me.encode
+}
+
+func (me *mapEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if v.IsNil() && !e.opts.NilMapAsEmpty {
+        return nil
+    }
+    e.enter()
+    if !v.IsNil() {
+        e.startVisit(v)
+    }
+    keys := v.MapKeys()
+    sv := make(mapKeys, len(keys))
+    for i, k := range keys {
+        s, err := mapKeyString(k)
+        if err != nil {
+            e.error(&json.MarshalerError{Type: k.Type(), Err: err})
+        }
+        sv[i] = mapKey{s, k}
+    }
+    sort.Sort(sv)
+    obj := make(JSONObject, len(sv))
+    var order []string
+    elemOpts := opts
+    elemOpts.stringify = false
+    for _, k := range sv {
+        savedPath, partial, ok := e.selectKey(k.s)
+        if !ok {
+            continue
+        }
+        e.pushKey(k.s)
+        value := e.encodeWith(me.elemEnc, v.MapIndex(k.v), elemOpts)
+        e.pop()
+        e.restoreFieldPath(savedPath)
+        if e.takeSkipped() {
+            continue
+        }
+        if partial {
+            if value, ok = pruneAncestor(value); !ok {
                 continue
             }
-            e.obj[tag] = subvalue
         }
-        retval = e.obj
-    case reflect.Map:
-        if v.Type().Key().Kind() != reflect.String {
-            e.error(&json.UnsupportedTypeError{Type:v.Type()})
+        obj[k.s] = value
+        if e.opts.OrderedObjects {
+            order = append(order, k.s)
         }
-        if v.IsNil() {
-            e.isNull = true
-            break
+    }
+    if !v.IsNil() {
+        e.endVisit()
+    }
+    e.leave()
+    if e.opts.OrderedObjects {
+        return &OrderedJSONObject{keys: order, values: obj}
+    }
+    return obj
+}
+
+// orderedObjectEncoder encodes the values of an OrderedJSONObject, keeping
+// its key order whatever the options.
+func orderedObjectEncoder(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if v.Kind() != reflect.Ptr {
+        p := v.Interface().(OrderedJSONObject)
+        v = reflect.ValueOf(&p)
+    } else if v.IsNil() {
+        return nil
+    }
+    p := v.Interface().(*OrderedJSONObject)
+    e.enter()
+    e.startVisit(v)
+    obj := NewOrderedJSONObject()
+    for _, k := range p.keys {
+        savedPath, partial, ok := e.selectKey(k)
+        if !ok {
+            continue
         }
-        e.isObject = true
-        e.obj = NewJSONObject()
-        var sv stringValues = v.MapKeys()
-        sort.Sort(sv)
-        for _, k := range sv {
-            e.obj[k.String()] = e.newWithSameOptions().reflectValue(v.MapIndex(k), false)
+        e.pushKey(k)
+        value := e.encode(reflect.ValueOf(p.values[k]), encOpts{})
+        e.pop()
+        e.restoreFieldPath(savedPath)
+        if e.takeSkipped() {
+            continue
         }
-        retval = e.obj
-    case reflect.Array, reflect.Slice:
-        if v.Type() == byteSliceType {
-            s := v.Interface().([]byte)
-            dst := make([]byte, base64.StdEncoding.EncodedLen(len(s)))
-            base64.StdEncoding.Encode(dst, s)
-            e.isString = true
-            e.sValue = string(dst)
-            break
+        if partial {
+            if value, ok = pruneAncestor(value); !ok {
+                continue
+            }
         }
-        n := v.Len()
-        arr := make([]interface{}, n)
-        for i := 0; i < n; i++ {
-            arr[i] = e.newWithSameOptions().reflectValue(v.Index(i), false)
+        obj.Set(k, value)
+    }
+    e.endVisit()
+    e.leave()
+    return obj
+}
+
+type arrayEncoder struct {
+    elemEnc encoderFunc
+}
+
+func newArrayEncoder(t reflect.Type) encoderFunc {
+    if isByteSequence(t) {
+        return bytesEncoder
+    }
+    ae := &arrayEncoder{typeEncoder(t.Elem())}
+    return ae.encode
+}
+
+func (ae *arrayEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if v.Kind() == reflect.Slice && v.IsNil() && e.opts.NilSliceAsNull {
+        return nil
+    }
+    e.enter()
+    n := v.Len()
+    visiting := v.Kind() == reflect.Slice && n > 0
+    if visiting {
+        e.startVisit(v)
+    }
+    arr := make([]interface{}, 0, n)
+    elemOpts := opts
+    elemOpts.stringify = false
+    for i := 0; i < n; i++ {
+        e.pushIndex(i)
+        value := e.encodeWith(ae.elemEnc, v.Index(i), elemOpts)
+        e.pop()
+        if e.takeSkipped() {
+            continue
         }
-        e.arr = NewJSONArrayFromArray(arr)
-        e.isArray = true
-        retval = e.arr
-    case reflect.Interface, reflect.Ptr:
-        if v.IsNil() {
-            e.isNull = true
-            retval = nil
-            return
+        arr = append(arr, value)
+    }
+    if visiting {
+        e.endVisit()
+    }
+    e.leave()
+    return NewJSONArrayFromArray(arr)
+}
+
+func bytesEncoder(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if v.Kind() == reflect.Slice && v.IsNil() && e.opts.NilSliceAsNull {
+        return nil
+    }
+    value, err := encodeBytes(byteSequence(v), opts.bytesEncoding)
+    if err != nil {
+        e.error(err)
+    }
+    return value
+}
+
+type ptrEncoder struct {
+    elemEnc encoderFunc
+}
+
+func newPtrEncoder(t reflect.Type) encoderFunc {
+    pe := &ptrEncoder{typeEncoder(t.Elem())}
+    return pe.encode
+}
+
+func (pe *ptrEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) interface{} {
+    if v.IsNil() {
+        return nil
+    }
+    e.startVisit(v)
+    value := e.encodeWith(pe.elemEnc, v.Elem(), opts)
+    e.endVisit()
+    return value
+}
+
+// typeByIndex returns the type of the nested field of t at index.
+func typeByIndex(t reflect.Type, index []int) reflect.Type {
+    for _, i := range index {
+        if t.Kind() == reflect.Ptr {
+            t = t.Elem()
         }
-        retval = e.reflectValue(v.Elem(), false)
-    default:
-        e.error(&json.UnsupportedTypeError{Type:v.Type()})
+        t = t.Field(i).Type
     }
-    return
+    return t
 }
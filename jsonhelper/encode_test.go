@@ -0,0 +1,238 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "fmt"
+    "sync"
+    "testing"
+    "time"
+)
+
+type cycleNode struct {
+    Name     string       `json:"name"`
+    Next     *cycleNode   `json:"next,omitempty"`
+    Children []*cycleNode `json:"children,omitempty"`
+}
+
+func TestMarshalCycles(t *testing.T) {
+    self := &cycleNode{Name: "self"}
+    self.Next = self
+    loop := &cycleNode{Name: "a", Next: &cycleNode{Name: "b"}}
+    loop.Next.Next = loop
+    child := &cycleNode{Name: "parent"}
+    child.Children = []*cycleNode{{Name: "c"}, child}
+    m := map[string]interface{}{}
+    m["m"] = m
+    s := []interface{}{nil}
+    s[0] = s
+    tests := []struct {
+        name string
+        v    interface{}
+        path string
+    }{
+        {"pointer to itself", self, "$.next"},
+        {"two node loop", loop, "$.next.next"},
+        {"through a slice", child, "$.children[1]"},
+        {"map", m, "$.m"},
+        {"slice", s, "$[0]"},
+    }
+    for _, tt := range tests {
+        _, err := Marshal(tt.v)
+        ce, ok := err.(*CycleError)
+        if !ok {
+            t.Errorf("%s: got error %v, want a *CycleError", tt.name, err)
+        } else if ce.Path != tt.path {
+            t.Errorf("%s: cycle at %s, want %s", tt.name, ce.Path, tt.path)
+        }
+    }
+}
+
+func TestMarshalSharedValuesAreNotCycles(t *testing.T) {
+    shared := &cycleNode{Name: "shared"}
+    v := []*cycleNode{shared, shared, {Name: "x", Next: shared}}
+    if _, err := Marshal(v); err != nil {
+        t.Errorf("shared pointers: %v", err)
+    }
+    backing := []int{1, 2, 3}
+    if _, err := Marshal([][]int{backing, backing[:2]}); err != nil {
+        t.Errorf("shared slices: %v", err)
+    }
+}
+
+func TestMarshalMaxDepth(t *testing.T) {
+    v := map[string]interface{}{"a": []interface{}{map[string]interface{}{"b": 1}}}
+    tests := []struct {
+        depth int
+        path  string
+    }{
+        {0, ""},
+        {3, ""},
+        {2, "$.a[0]"},
+        {1, "$.a"},
+    }
+    for _, tt := range tests {
+        _, err := MarshalWithOptions(v, MarshalOptions{MaxDepth: tt.depth})
+        if tt.path == "" {
+            if err != nil {
+                t.Errorf("depth %d: %v", tt.depth, err)
+            }
+            continue
+        }
+        de, ok := err.(*MaxDepthError)
+        if !ok || de.Path != tt.path || de.Depth != tt.depth {
+            t.Errorf("depth %d: got error %v, want a *MaxDepthError at %s", tt.depth, err, tt.path)
+        }
+    }
+}
+
+type benchAddress struct {
+    Street  string `json:"street"`
+    City    string `json:"city"`
+    Zip     string `json:"zip,omitempty"`
+    Country string `json:"country"`
+}
+
+type benchUser struct {
+    ID        int64             `json:"id"`
+    Name      string            `json:"name"`
+    Email     string            `json:"email,omitempty"`
+    Active    bool              `json:"active"`
+    Score     float64           `json:"score"`
+    Tags      []string          `json:"tags"`
+    Created   time.Time         `json:"created"`
+    Address   benchAddress      `json:"address"`
+    Previous  []benchAddress    `json:"previous,omitempty"`
+    Attrs     map[string]string `json:"attrs"`
+    Manager   *benchUser        `json:"manager,omitempty"`
+    SessionID string            `json:"session,sensitive"`
+}
+
+func newBenchUser() *benchUser {
+    a := benchAddress{"1 Main St", "Springfield", "12345", "US"}
+    return &benchUser{
+        ID:       42,
+        Name:     "Ann Example",
+        Email:    "ann@example.com",
+        Active:   true,
+        Score:    97.5,
+        Tags:     []string{"admin", "beta", "staff"},
+        Created:  time.Date(2012, 3, 4, 5, 6, 7, 0, time.UTC),
+        Address:  a,
+        Previous: []benchAddress{a, a},
+        Attrs:    map[string]string{"team": "core", "tz": "UTC"},
+        Manager:  &benchUser{ID: 1, Name: "Boss", Address: a},
+    }
+}
+
+// resetEncoderCaches forgets every encoder and struct field list, so that
+// the next Marshal builds them again.
+func resetEncoderCaches() {
+    encoderCache.Lock()
+    encoderCache.m = nil
+    encoderCache.Unlock()
+    fieldCache.Lock()
+    fieldCache.m = nil
+    fieldCache.Unlock()
+}
+
+func TestMarshalCacheConsistency(t *testing.T) {
+    u := newBenchUser()
+    resetEncoderCaches()
+    cold, err := Marshal(u)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var wg sync.WaitGroup
+    errs := make(chan error, 8)
+    for i := 0; i < 8; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for j := 0; j < 50; j++ {
+                warm, err := Marshal(u)
+                if err != nil {
+                    errs <- err
+                    return
+                }
+                if !EqualJSONValues(warm, cold) {
+                    errs <- fmt.Errorf("got %v, want %v", warm, cold)
+                    return
+                }
+            }
+        }()
+    }
+    wg.Wait()
+    close(errs)
+    for err := range errs {
+        t.Error(err)
+    }
+}
+
+func BenchmarkMarshalCached(b *testing.B) {
+    u := newBenchUser()
+    Marshal(u)
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        if _, err := Marshal(u); err != nil {
+            b.Fatal(err)
+        }
+    }
+}
+
+// BenchmarkMarshalColdCache measures the first Marshal of a type, which
+// builds its encoders and field lists into empty caches. It is not the cost
+// of Marshal before the caches existed, which did less work up front.
+func BenchmarkMarshalColdCache(b *testing.B) {
+    u := newBenchUser()
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        resetEncoderCaches()
+        if _, err := Marshal(u); err != nil {
+            b.Fatal(err)
+        }
+    }
+}
+
+func BenchmarkMarshalCachedParallel(b *testing.B) {
+    u := newBenchUser()
+    Marshal(u)
+    b.ReportAllocs()
+    b.ResetTimer()
+    b.RunParallel(func(pb *testing.PB) {
+        for pb.Next() {
+            if _, err := Marshal(u); err != nil {
+                b.Fatal(err)
+            }
+        }
+    })
+}
+
+func BenchmarkMarshalWithOptions(b *testing.B) {
+    u := newBenchUser()
+    opts := MarshalOptions{FieldNaming: SnakeCase, TimeFormat: TimeFormatUnix, Exclude: []string{"manager"}}
+    MarshalWithOptions(u, opts)
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        if _, err := MarshalWithOptions(u, opts); err != nil {
+            b.Fatal(err)
+        }
+    }
+}
+
+// BenchmarkEncodingJSON is a reference point; it produces bytes rather
+// than a tree.
+func BenchmarkEncodingJSON(b *testing.B) {
+    u := newBenchUser()
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        if _, err := json.Marshal(u); err != nil {
+            b.Fatal(err)
+        }
+    }
+}
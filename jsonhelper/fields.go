@@ -0,0 +1,276 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// adapted from typeFields in json/encode.go in the official Go source code
+
+package jsonhelper
+
+import (
+    "reflect"
+    "sort"
+    "sync"
+)
+
+// A field represents a single field found in a struct, either directly or
+// promoted from an embedded struct.
+type field struct {
+    name      string
+    tag       bool
+    index     []int
+    typ       reflect.Type
+    omitEmpty bool
+    omitZero  bool
+    stringify bool
+    collapse  bool
+    sensitive bool
+    opts      tagOptions
+    view      string
+}
+
+// byName sorts field by name, breaking ties with depth, then breaking ties
+// with "name came from json tag", then breaking ties with index sequence.
+type byName []field
+
+func (x byName) Len() int      { return len(x) }
+func (x byName) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x byName) Less(i, j int) bool {
+    if x[i].name != x[j].name {
+        return x[i].name < x[j].name
+    }
+    if len(x[i].index) != len(x[j].index) {
+        return len(x[i].index) < len(x[j].index)
+    }
+    if x[i].tag != x[j].tag {
+        return x[i].tag
+    }
+    return byIndex(x).Less(i, j)
+}
+
+// byIndex sorts field by index sequence.
+type byIndex []field
+
+func (x byIndex) Len() int      { return len(x) }
+func (x byIndex) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x byIndex) Less(i, j int) bool {
+    for k, xik := range x[i].index {
+        if k >= len(x[j].index) {
+            return false
+        }
+        if xik != x[j].index[k] {
+            return xik < x[j].index[k]
+        }
+    }
+    return len(x[i].index) < len(x[j].index)
+}
+
+// typeFields returns a list of fields that Marshal and Unmarshal should
+// recognize for the given type. The algorithm is breadth-first search over
+// the set of structs to include - the top struct and then any reachable
+// anonymous structs, following the rules of encoding/json.
+func typeFields(t reflect.Type) []field {
+    // Anonymous fields to explore at the current level and the next.
+    current := []field{}
+    next := []field{{typ: t}}
+
+    // Count of queued names for current level and the next.
+    count := map[reflect.Type]int{}
+    nextCount := map[reflect.Type]int{}
+
+    // Types already visited at an earlier level.
+    visited := map[reflect.Type]bool{}
+
+    // Fields found.
+    var fields []field
+
+    for len(next) > 0 {
+        current, next = next, current[:0]
+        count, nextCount = nextCount, map[reflect.Type]int{}
+
+        for _, f := range current {
+            if visited[f.typ] {
+                continue
+            }
+            visited[f.typ] = true
+
+            // Scan f.typ for fields to include.
+            for i := 0; i < f.typ.NumField(); i++ {
+                sf := f.typ.Field(i)
+                if sf.Anonymous {
+                    t := sf.Type
+                    if t.Kind() == reflect.Ptr {
+                        t = t.Elem()
+                    }
+                    // Unexported embedded structs still promote their
+                    // exported fields.
+                    if sf.PkgPath != "" && t.Kind() != reflect.Struct {
+                        continue
+                    }
+                } else if sf.PkgPath != "" {
+                    continue
+                }
+                tv := sf.Tag.Get("json")
+                if tv == "-" {
+                    continue
+                }
+                name, opts := parseTag(tv)
+                if !isValidTag(name) {
+                    name = ""
+                }
+                index := make([]int, len(f.index)+1)
+                copy(index, f.index)
+                index[len(f.index)] = i
+
+                ft := sf.Type
+                if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+                    ft = ft.Elem()
+                }
+
+                // Record found field and index sequence.
+                if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+                    tagged := name != ""
+                    if name == "" {
+                        name = sf.Name
+                    }
+                    fields = append(fields, field{
+                        name:      name,
+                        tag:       tagged,
+                        index:     index,
+                        typ:       ft,
+                        omitEmpty: opts.Contains("omitempty"),
+                        omitZero:  opts.Contains("omitzero"),
+                        stringify: opts.Contains("string"),
+                        collapse:  opts.Contains("collapse"),
+                        sensitive: opts.Contains("sensitive"),
+                        opts:      opts,
+                        view:      sf.Tag.Get("jsonview"),
+                    })
+                    if count[f.typ] > 1 {
+                        // If there were multiple instances, add a second,
+                        // so that the annihilation code will see a duplicate.
+                        // It only cares about the distinction between 1 or 2,
+                        // so don't bother generating any more copies.
+                        fields = append(fields, fields[len(fields)-1])
+                    }
+                    continue
+                }
+
+                // Record new anonymous struct to explore in next round.
+                nextCount[ft]++
+                if nextCount[ft] == 1 {
+                    next = append(next, field{name: ft.Name(), index: index, typ: ft})
+                }
+            }
+        }
+    }
+
+    sort.Sort(byName(fields))
+
+    // Delete all fields that are hidden by the Go rules for embedded fields,
+    // except that fields with JSON tags are promoted.
+    out := fields[:0]
+    for advance, i := 0, 0; i < len(fields); i += advance {
+        // One iteration per name.
+        // Find the sequence of fields with the name of this first field.
+        fi := fields[i]
+        name := fi.name
+        for advance = 1; i+advance < len(fields); advance++ {
+            fj := fields[i+advance]
+            if fj.name != name {
+                break
+            }
+        }
+        if advance == 1 { // Only one field with this name
+            out = append(out, fi)
+            continue
+        }
+        dominant, ok := dominantField(fields[i : i+advance])
+        if ok {
+            out = append(out, dominant)
+        }
+    }
+
+    fields = out
+    sort.Sort(byIndex(fields))
+    return fields
+}
+
+var fieldCache struct {
+    sync.RWMutex
+    m map[reflect.Type][]field
+}
+
+// cachedTypeFields is like typeFields but uses a cache to avoid repeated work.
+func cachedTypeFields(t reflect.Type) []field {
+    fieldCache.RLock()
+    f := fieldCache.m[t]
+    fieldCache.RUnlock()
+    if f != nil {
+        return f
+    }
+
+    // Compute fields without lock.
+    // Might duplicate effort but won't hold other computations back.
+    f = typeFields(t)
+    if f == nil {
+        f = []field{}
+    }
+
+    fieldCache.Lock()
+    if fieldCache.m == nil {
+        fieldCache.m = map[reflect.Type][]field{}
+    }
+    fieldCache.m[t] = f
+    fieldCache.Unlock()
+    return f
+}
+
+// dominantField looks through the fields, all of which are known to have
+// the same name, to find the single field that dominates the others using
+// Go's embedding rules, modified by the presence of JSON tags. If there are
+// multiple top-level fields, the boolean will be false: This condition is an
+// error in Go and we skip all the fields.
+func dominantField(fields []field) (field, bool) {
+    // The fields are sorted in increasing index-length order, then by
+    // presence of tag. That means that the first field is the dominant one.
+    // We need only check for error cases: two fields at top level, either
+    // both tagged or neither tagged.
+    if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tag == fields[1].tag {
+        return field{}, false
+    }
+    return fields[0], true
+}
+
+// fieldByIndex returns the nested field of v at index, or false if an
+// embedded pointer along the way is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+    for i, x := range index {
+        if i > 0 && v.Kind() == reflect.Ptr {
+            if v.IsNil() {
+                return reflect.Value{}, false
+            }
+            v = v.Elem()
+        }
+        v = v.Field(x)
+    }
+    return v, true
+}
+
+// fieldByIndexAlloc is like fieldByIndex but allocates nil embedded
+// pointers. It returns false if such a pointer cannot be set because its
+// embedded type is unexported.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, bool) {
+    for i, x := range index {
+        if i > 0 && v.Kind() == reflect.Ptr {
+            if v.IsNil() {
+                if !v.CanSet() {
+                    return reflect.Value{}, false
+                }
+                v.Set(reflect.New(v.Type().Elem()))
+            }
+            v = v.Elem()
+        }
+        v = v.Field(x)
+    }
+    return v, true
+}
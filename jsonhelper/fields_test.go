@@ -0,0 +1,116 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "reflect"
+    "testing"
+)
+
+// Names are left untagged where they collide, as go vet reports repeated
+// json tags.
+type embedBase struct {
+    ID   int `json:"id"`
+    Name string
+}
+
+type embedOther struct {
+    Name  string
+    Extra string `json:"extra"`
+}
+
+type embedTagged struct {
+    Label string
+}
+
+type embedInt int
+
+type embedPromoted struct {
+    embedBase
+    Own string `json:"own"`
+}
+
+type embedShadowed struct {
+    embedBase
+    Name string
+}
+
+type embedAmbiguous struct {
+    embedBase
+    embedOther
+}
+
+type embedPointer struct {
+    *embedBase
+    Own string `json:"own"`
+}
+
+type embedMixed struct {
+    embedTagged `json:"tagged"`
+    embedInt
+    Skip string `json:"-"`
+    Dash string `json:"-,"`
+}
+
+func TestEmbeddedFieldsMatchEncodingJSON(t *testing.T) {
+    tests := []interface{}{
+        embedPromoted{embedBase{1, "a"}, "o"},
+        embedShadowed{embedBase{1, "inner"}, "outer"},
+        embedAmbiguous{embedBase{1, "a"}, embedOther{"b", "x"}},
+        embedPointer{&embedBase{2, "p"}, "o"},
+        embedPointer{nil, "o"},
+        embedMixed{embedTagged{"l"}, 7, "s", "d"},
+    }
+    for _, v := range tests {
+        got, err := Marshal(v)
+        if err != nil {
+            t.Errorf("%T: %v", v, err)
+            continue
+        }
+        b, _ := json.Marshal(v)
+        want, _ := Parse(b, ParseOptions{})
+        if !EqualJSONValues(got, want) {
+            t.Errorf("%T: got %v, want %s", v, got, b)
+        }
+    }
+}
+
+func TestEmbeddedFieldsUnmarshal(t *testing.T) {
+    obj := JSONObject{"id": int64(3), "Name": "n", "own": "o"}
+    var p embedPointer
+    if err := Unmarshal(obj, &p); err == nil {
+        t.Error("allocating an unexported embedded pointer succeeded")
+    }
+    p = embedPointer{embedBase: &embedBase{}}
+    if err := Unmarshal(obj, &p); err != nil {
+        t.Fatal(err)
+    }
+    if p.embedBase == nil || p.ID != 3 || p.Name != "n" || p.Own != "o" {
+        t.Errorf("got %+v", p)
+    }
+    var s embedShadowed
+    if err := Unmarshal(obj, &s); err != nil {
+        t.Fatal(err)
+    }
+    if s.Name != "n" || s.embedBase.Name != "" || s.ID != 3 {
+        t.Errorf("got %+v", s)
+    }
+}
+
+func TestCollapse(t *testing.T) {
+    v := struct {
+        Name  string                 `json:"name"`
+        Attrs map[string]interface{} `json:"attrs,collapse"`
+    }{"n", map[string]interface{}{"color": "red", "name": "ignored"}}
+    got, err := Marshal(v)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := JSONObject{"name": "n", "color": "red"}
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("got %#v, want %#v", got, want)
+    }
+}
@@ -0,0 +1,350 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+// IndexStyle selects how Flatten writes array indices.
+type IndexStyle int
+
+const (
+    // IndexDotted writes indices as ordinary segments: "items.0.sku".
+    IndexDotted IndexStyle = iota
+    // IndexBracketed writes indices in brackets: "items[0].sku".
+    IndexBracketed
+)
+
+// DefaultFlattenSeparator joins the segments of flattened keys when
+// FlattenOptions.Separator is empty.
+const DefaultFlattenSeparator = "."
+
+// maxUnflattenIndex bounds the arrays Unflatten is willing to allocate.
+const maxUnflattenIndex = 1 << 20
+
+// FlattenOptions controls Flatten and Unflatten. Unflatten must be given the
+// options the keys were flattened with.
+type FlattenOptions struct {
+    // Separator joins key segments. It defaults to DefaultFlattenSeparator.
+    Separator string
+    // IndexStyle selects how array indices are written.
+    IndexStyle IndexStyle
+    // MaxDepth limits the number of segments in a key. Objects and arrays
+    // below that depth are kept whole as values. Zero means no limit.
+    MaxDepth int
+    // Escape precedes separators and escape characters that occur inside
+    // keys, and, with IndexDotted, keys that consist only of digits. It
+    // defaults to a backslash.
+    Escape rune
+    // NoEscape writes keys as they are, which makes keys containing the
+    // separator ambiguous.
+    NoEscape bool
+}
+
+func (o *FlattenOptions) separator() string {
+    if o.Separator == "" {
+        return DefaultFlattenSeparator
+    }
+    return o.Separator
+}
+
+func (o *FlattenOptions) escape() rune {
+    if o.Escape == 0 {
+        return '\\'
+    }
+    return o.Escape
+}
+
+// Flatten converts obj into a single-level JSONObject whose keys are the
+// paths of its leaves, such as "user.address.city" or "items.0.sku". Empty
+// objects and arrays are kept as values so that Unflatten can restore them.
+func Flatten(obj JSONObject, opts FlattenOptions) JSONObject {
+    flat := NewJSONObject()
+    flattenObject(flat, "", obj, 0, &opts)
+    return flat
+}
+
+func (p JSONObject) Flatten(opts FlattenOptions) JSONObject {
+    return Flatten(p, opts)
+}
+
+func flattenValue(flat JSONObject, prefix string, value interface{}, depth int, opts *FlattenOptions) {
+    if opts.MaxDepth <= 0 || depth < opts.MaxDepth {
+        if obj, ok := jsonObjectValue(value); ok && len(obj) > 0 {
+            flattenObject(flat, prefix, obj, depth, opts)
+            return
+        }
+        if arr, ok := jsonArrayValue(value); ok && len(arr) > 0 {
+            for i, item := range arr {
+                flattenValue(flat, joinFlattenIndex(prefix, i, opts), item, depth+1, opts)
+            }
+            return
+        }
+    }
+    flat[prefix] = normalizeJSONValue(value)
+}
+
+func flattenObject(flat JSONObject, prefix string, obj JSONObject, depth int, opts *FlattenOptions) {
+    for k, v := range obj {
+        key := escapeFlattenKey(k, opts)
+        if depth > 0 {
+            key = prefix + opts.separator() + key
+        }
+        flattenValue(flat, key, v, depth+1, opts)
+    }
+}
+
+func joinFlattenIndex(prefix string, i int, opts *FlattenOptions) string {
+    if opts.IndexStyle == IndexBracketed {
+        return prefix + "[" + strconv.Itoa(i) + "]"
+    }
+    return prefix + opts.separator() + strconv.Itoa(i)
+}
+
+func isDigits(s string) bool {
+    if s == "" {
+        return false
+    }
+    for i := 0; i < len(s); i++ {
+        if s[i] < '0' || s[i] > '9' {
+            return false
+        }
+    }
+    return true
+}
+
+// escapeFlattenKey escapes the characters of an object key that Unflatten
+// would otherwise read as structure.
+func escapeFlattenKey(key string, opts *FlattenOptions) string {
+    if opts.NoEscape {
+        return key
+    }
+    esc := string(opts.escape())
+    sep := opts.separator()
+    var b strings.Builder
+    if opts.IndexStyle == IndexDotted && isDigits(key) {
+        b.WriteString(esc)
+    }
+    for i := 0; i < len(key); {
+        switch {
+        case strings.HasPrefix(key[i:], sep):
+            b.WriteString(esc)
+            b.WriteString(sep)
+            i += len(sep)
+            continue
+        case strings.HasPrefix(key[i:], esc):
+            b.WriteString(esc)
+        case opts.IndexStyle == IndexBracketed && key[i] == '[':
+            b.WriteString(esc)
+        }
+        _, size := utf8.DecodeRuneInString(key[i:])
+        b.WriteString(key[i : i+size])
+        i += size
+    }
+    return b.String()
+}
+
+// flatSegment is one step of a flattened key: an object key or, when
+// isIndex is set, an array index. A negative index appends to the array.
+type flatSegment struct {
+    key     string
+    index   int
+    isIndex bool
+}
+
+// parseFlatKey splits a flattened key into its segments.
+func parseFlatKey(key string, opts *FlattenOptions) ([]flatSegment, error) {
+    esc := opts.escape()
+    sep := opts.separator()
+    var segments []flatSegment
+    var b strings.Builder
+    escaped := false
+    pending := true
+    flush := func() {
+        if !pending {
+            return
+        }
+        s := b.String()
+        if opts.IndexStyle == IndexDotted && len(segments) > 0 && !escaped && isDigits(s) {
+            n, err := strconv.Atoi(s)
+            if err == nil {
+                segments = append(segments, flatSegment{index: n, isIndex: true})
+                b.Reset()
+                return
+            }
+        }
+        segments = append(segments, flatSegment{key: s})
+        b.Reset()
+        escaped = false
+    }
+    for i := 0; i < len(key); {
+        r, size := utf8.DecodeRuneInString(key[i:])
+        switch {
+        case !opts.NoEscape && r == esc:
+            i += size
+            if i >= len(key) {
+                return nil, fmt.Errorf("jsonhelper: flattened key %q ends with an escape", key)
+            }
+            if strings.HasPrefix(key[i:], sep) {
+                b.WriteString(sep)
+                i += len(sep)
+            } else {
+                _, size = utf8.DecodeRuneInString(key[i:])
+                b.WriteString(key[i : i+size])
+                i += size
+            }
+            escaped = true
+            pending = true
+        case strings.HasPrefix(key[i:], sep):
+            flush()
+            i += len(sep)
+            pending = true
+        case opts.IndexStyle == IndexBracketed && r == '[' && len(segments)+b.Len() > 0:
+            flush()
+            end := strings.IndexByte(key[i:], ']')
+            if end < 0 {
+                return nil, fmt.Errorf("jsonhelper: unterminated index in flattened key %q", key)
+            }
+            n, err := strconv.Atoi(key[i+1 : i+end])
+            if err != nil || n < 0 {
+                return nil, fmt.Errorf("jsonhelper: invalid index in flattened key %q", key)
+            }
+            segments = append(segments, flatSegment{index: n, isIndex: true})
+            i += end + 1
+            pending = false
+        default:
+            b.WriteString(key[i : i+size])
+            i += size
+            pending = true
+        }
+    }
+    flush()
+    return segments, nil
+}
+
+// flatNode is a value being rebuilt by Unflatten.
+type flatNode struct {
+    leaf     bool
+    value    interface{}
+    isArray  bool
+    children map[string]*flatNode
+    items    map[int]*flatNode
+    length   int
+}
+
+func (n *flatNode) child(seg flatSegment, path string) (*flatNode, error) {
+    if n.children == nil && n.items == nil {
+        n.isArray = seg.isIndex
+        if seg.isIndex {
+            n.items = make(map[int]*flatNode)
+        } else {
+            n.children = make(map[string]*flatNode)
+        }
+    }
+    if n.isArray != seg.isIndex {
+        return nil, fmt.Errorf("jsonhelper: %q is used as both an object and an array", path)
+    }
+    if seg.isIndex {
+        if seg.index < 0 {
+            seg.index = n.length
+        }
+        if seg.index >= maxUnflattenIndex {
+            return nil, fmt.Errorf("jsonhelper: array index %d in %q is too large", seg.index, path)
+        }
+        c, ok := n.items[seg.index]
+        if !ok {
+            c = &flatNode{}
+            n.items[seg.index] = c
+            if seg.index >= n.length {
+                n.length = seg.index + 1
+            }
+        }
+        return c, nil
+    }
+    c, ok := n.children[seg.key]
+    if !ok {
+        c = &flatNode{}
+        n.children[seg.key] = c
+    }
+    return c, nil
+}
+
+func (n *flatNode) build() interface{} {
+    if n.leaf {
+        return n.value
+    }
+    if n.isArray {
+        arr := make([]interface{}, n.length)
+        for i, c := range n.items {
+            arr[i] = c.build()
+        }
+        return NewJSONArrayFromArray(arr)
+    }
+    obj := NewJSONObject()
+    for k, c := range n.children {
+        obj[k] = c.build()
+    }
+    return obj
+}
+
+// insert stores value at the path given by segments, which were parsed
+// from key.
+func (n *flatNode) insert(segments []flatSegment, value interface{}, key string) error {
+    node := n
+    for i, seg := range segments {
+        var err error
+        if node, err = node.child(seg, key); err != nil {
+            return err
+        }
+        if i < len(segments)-1 && node.leaf {
+            return fmt.Errorf("jsonhelper: key %q conflicts with a value at one of its prefixes", key)
+        }
+    }
+    if node.leaf || node.children != nil || node.items != nil {
+        return fmt.Errorf("jsonhelper: key %q conflicts with another key", key)
+    }
+    node.leaf = true
+    node.value = normalizeJSONValue(value)
+    return nil
+}
+
+// Unflatten rebuilds the nested JSONObject that Flatten produced flat
+// from. Keys whose paths conflict, such as "a" and "a.b", or "a.0" and
+// "a.b" with IndexDotted, are an error. Missing array elements are null,
+// but an index may be at most the number of keys in flat, as Flatten
+// never writes a larger one.
+func Unflatten(flat JSONObject, opts FlattenOptions) (JSONObject, error) {
+    return unflatten(flat, opts, len(flat))
+}
+
+// unflatten is Unflatten with array indices limited to maxIndex.
+func unflatten(flat JSONObject, opts FlattenOptions, maxIndex int) (JSONObject, error) {
+    keys := make([]string, 0, len(flat))
+    for k := range flat {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    root := &flatNode{children: make(map[string]*flatNode)}
+    for _, k := range keys {
+        segments, err := parseFlatKey(k, &opts)
+        if err != nil {
+            return nil, err
+        }
+        for _, seg := range segments {
+            if seg.isIndex && seg.index > maxIndex {
+                return nil, fmt.Errorf("jsonhelper: array index %d in %q is larger than the input allows", seg.index, k)
+            }
+        }
+        if err := root.insert(segments, flat[k], k); err != nil {
+            return nil, err
+        }
+    }
+    return root.build().(JSONObject), nil
+}
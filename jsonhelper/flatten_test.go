@@ -0,0 +1,90 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+func TestFlatten(t *testing.T) {
+    const doc = `{"user":{"name":"a","tags":["x","y"],"empty":{},"none":[]},"items":[{"sku":1}],"a.b":1,"7":{"c\\d":2}}`
+    tests := []struct {
+        name string
+        opts FlattenOptions
+        want string
+    }{
+        {"dotted", FlattenOptions{},
+            `{"user.name":"a","user.tags.0":"x","user.tags.1":"y","user.empty":{},"user.none":[],"items.0.sku":1,"a\\.b":1,"\\7.c\\\\d":2}`},
+        {"bracketed", FlattenOptions{IndexStyle: IndexBracketed},
+            `{"user.name":"a","user.tags[0]":"x","user.tags[1]":"y","user.empty":{},"user.none":[],"items[0].sku":1,"a\\.b":1,"7.c\\\\d":2}`},
+        {"separator", FlattenOptions{Separator: "/", Escape: '~'},
+            `{"user/name":"a","user/tags/0":"x","user/tags/1":"y","user/empty":{},"user/none":[],"items/0/sku":1,"a.b":1,"~7/c\\d":2}`},
+        {"max depth", FlattenOptions{MaxDepth: 2},
+            `{"user.name":"a","user.tags":["x","y"],"user.empty":{},"user.none":[],"items.0":{"sku":1},"a\\.b":1,"\\7.c\\\\d":2}`},
+        {"no escape", FlattenOptions{NoEscape: true, IndexStyle: IndexBracketed},
+            `{"user.name":"a","user.tags[0]":"x","user.tags[1]":"y","user.empty":{},"user.none":[],"items[0].sku":1,"a.b":1,"7.c\\d":2}`},
+    }
+    for _, tt := range tests {
+        obj, _ := ParseObject([]byte(doc), ParseOptions{})
+        flat := Flatten(obj, tt.opts)
+        want, _ := ParseObject([]byte(tt.want), ParseOptions{})
+        if !EqualJSONValues(flat, want) {
+            b, _ := json.Marshal(flat)
+            t.Errorf("%s: got %s, want %s", tt.name, b, tt.want)
+            continue
+        }
+        if tt.opts.NoEscape {
+            continue
+        }
+        back, err := Unflatten(flat, tt.opts)
+        if err != nil {
+            t.Errorf("%s: Unflatten: %v", tt.name, err)
+        } else if !EqualJSONValues(back, obj) {
+            b, _ := json.Marshal(back)
+            t.Errorf("%s: round trip gave %s", tt.name, b)
+        }
+    }
+}
+
+func TestUnflatten(t *testing.T) {
+    tests := []struct {
+        flat string
+        opts FlattenOptions
+        want string
+    }{
+        {`{"a.2":"z","a.0":"x"}`, FlattenOptions{}, `{"a":["x",null,"z"]}`},
+        {`{"a[1][0]":true}`, FlattenOptions{IndexStyle: IndexBracketed}, `{"a":[null,[true]]}`},
+        {`{"0":1}`, FlattenOptions{}, `{"0":1}`},
+        {`{"a.\\0":1}`, FlattenOptions{}, `{"a":{"0":1}}`},
+    }
+    for _, tt := range tests {
+        flat, _ := ParseObject([]byte(tt.flat), ParseOptions{})
+        got, err := Unflatten(flat, tt.opts)
+        want, _ := ParseObject([]byte(tt.want), ParseOptions{})
+        if err != nil || !EqualJSONValues(got, want) {
+            t.Errorf("Unflatten(%s) = %v, %v, want %s", tt.flat, got, err, tt.want)
+        }
+    }
+    errors := []struct {
+        flat string
+        opts FlattenOptions
+    }{
+        {`{"a":1,"a.b":2}`, FlattenOptions{}},
+        {`{"a.0":1,"a.b":2}`, FlattenOptions{}},
+        {`{"a\\":1}`, FlattenOptions{}},
+        {`{"a[x]":1}`, FlattenOptions{IndexStyle: IndexBracketed}},
+        {`{"a[1":1}`, FlattenOptions{IndexStyle: IndexBracketed}},
+        {`{"a.1048576":1}`, FlattenOptions{}},
+        {`{"a.1048575":1}`, FlattenOptions{}},
+        {`{"a[3]":1,"b":2}`, FlattenOptions{IndexStyle: IndexBracketed}},
+    }
+    for _, tt := range errors {
+        flat, _ := ParseObject([]byte(tt.flat), ParseOptions{})
+        if got, err := Unflatten(flat, tt.opts); err == nil {
+            t.Errorf("Unflatten(%s) = %v, want an error", tt.flat, got)
+        }
+    }
+}
@@ -0,0 +1,269 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// FormArrayStyle selects how JSONObjectToValues writes arrays of scalars.
+// Arrays holding objects or arrays are always written with indices.
+type FormArrayStyle int
+
+const (
+    // FormArrayIndexed writes "a[0]=x&a[1]=y".
+    FormArrayIndexed FormArrayStyle = iota
+    // FormArrayBrackets writes "a[]=x&a[]=y".
+    FormArrayBrackets
+    // FormArrayRepeat writes "a=x&a=y".
+    FormArrayRepeat
+)
+
+// FormOptions controls the conversions between url.Values and JSONObject.
+type FormOptions struct {
+    // InferTypes converts "true" and "false" into booleans, "null" into
+    // nil and JSON numbers into int64 or float64 when reading url.Values.
+    // Numbers with leading zeros, such as zip codes, stay strings.
+    InferTypes bool
+    // ArrayStyle selects how arrays are written by JSONObjectToValues.
+    ArrayStyle FormArrayStyle
+}
+
+// ValuesToJSONObject converts form values into a JSONObject. Bracket
+// notation builds nested values: "a[b]=x" becomes {"a": {"b": "x"}},
+// "a[0]=x" and "a[]=x" build arrays, and a key given several values, such
+// as "a=x&a=y", becomes an array. Keys whose paths conflict, such as "a=x"
+// and "a[b]=y", are an error.
+//
+// An index may be at most the length of the array built so far, so a form
+// cannot make the server allocate a large array. Larger indices are object
+// keys: "a[5]=x" on its own becomes {"a": {"5": "x"}}.
+func ValuesToJSONObject(values url.Values, opts FormOptions) (JSONObject, error) {
+    keys := make([]string, 0, len(values))
+    for k := range values {
+        keys = append(keys, k)
+    }
+    sort.Sort(naturalStrings(keys))
+    root := &flatNode{children: make(map[string]*flatNode)}
+    for _, k := range keys {
+        vs := values[k]
+        if len(vs) == 0 {
+            continue
+        }
+        segments := root.denseSegments(parseFormKey(k))
+        if last := segments[len(segments)-1]; last.isIndex && last.index < 0 {
+            for _, s := range vs {
+                if err := root.insert(segments, opts.formValue(s), k); err != nil {
+                    return nil, err
+                }
+            }
+            continue
+        }
+        var value interface{}
+        if len(vs) == 1 {
+            value = opts.formValue(vs[0])
+        } else {
+            arr := make([]interface{}, len(vs))
+            for i, s := range vs {
+                arr[i] = opts.formValue(s)
+            }
+            value = NewJSONArrayFromArray(arr)
+        }
+        if err := root.insert(segments, value, k); err != nil {
+            return nil, err
+        }
+    }
+    return root.build().(JSONObject), nil
+}
+
+// parseFormKey splits "a[b][0][]" into its segments. Keys that are not
+// well-formed bracket notation are a single segment.
+func parseFormKey(key string) []flatSegment {
+    open := strings.IndexByte(key, '[')
+    if open <= 0 || !strings.HasSuffix(key, "]") {
+        return []flatSegment{{key: key}}
+    }
+    segments := []flatSegment{{key: key[:open]}}
+    rest := key[open:]
+    for rest != "" {
+        end := strings.IndexByte(rest, ']')
+        if rest[0] != '[' || end < 0 {
+            return []flatSegment{{key: key}}
+        }
+        s := rest[1:end]
+        switch n, err := strconv.Atoi(s); {
+        case s == "":
+            segments = append(segments, flatSegment{index: -1, isIndex: true})
+        case err == nil && n >= 0 && strconv.Itoa(n) == s:
+            segments = append(segments, flatSegment{index: n, isIndex: true})
+        default:
+            segments = append(segments, flatSegment{key: s})
+        }
+        rest = rest[end+1:]
+    }
+    return segments
+}
+
+// denseSegments turns the indices in segments that lie past the end of the
+// arrays already built under n into object keys.
+func (n *flatNode) denseSegments(segments []flatSegment) []flatSegment {
+    node := n
+    for i, seg := range segments {
+        if seg.isIndex && seg.index >= 0 {
+            length := 0
+            if node != nil && node.isArray {
+                length = node.length
+            }
+            if seg.index > length {
+                seg = flatSegment{key: strconv.Itoa(seg.index)}
+                segments[i] = seg
+            }
+        }
+        if node == nil {
+            continue
+        }
+        switch {
+        case seg.isIndex && seg.index >= 0:
+            node = node.items[seg.index]
+        case seg.isIndex:
+            node = nil
+        default:
+            node = node.children[seg.key]
+        }
+    }
+    return segments
+}
+
+func (o *FormOptions) formValue(s string) interface{} {
+    if !o.InferTypes {
+        return s
+    }
+    return inferScalar(s)
+}
+
+// inferScalar converts the text of a form value or CSV cell into the
+// boolean, nil or number it spells, or returns it unchanged.
+func inferScalar(s string) interface{} {
+    switch s {
+    case "true":
+        return true
+    case "false":
+        return false
+    case "null":
+        return nil
+    }
+    if !isJSONNumber(s) {
+        return s
+    }
+    if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+        return n
+    }
+    if f, err := strconv.ParseFloat(s, 64); err == nil {
+        return f
+    }
+    return s
+}
+
+// isJSONNumber reports whether s is a number in JSON syntax.
+func isJSONNumber(s string) bool {
+    i := 0
+    if i < len(s) && s[i] == '-' {
+        i++
+    }
+    switch {
+    case i < len(s) && s[i] == '0':
+        i++
+    case i < len(s) && s[i] >= '1' && s[i] <= '9':
+        for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+            i++
+        }
+    default:
+        return false
+    }
+    if i < len(s) && s[i] == '.' {
+        i++
+        start := i
+        for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+            i++
+        }
+        if i == start {
+            return false
+        }
+    }
+    if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+        i++
+        if i < len(s) && (s[i] == '+' || s[i] == '-') {
+            i++
+        }
+        start := i
+        for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+            i++
+        }
+        if i == start {
+            return false
+        }
+    }
+    return i == len(s)
+}
+
+// JSONObjectToValues converts obj into form values using bracket notation
+// for nested objects and arrays. Empty objects and arrays have no form
+// representation and are left out.
+func JSONObjectToValues(obj JSONObject, opts FormOptions) url.Values {
+    values := make(url.Values)
+    for k, v := range obj {
+        addFormValue(values, k, v, &opts)
+    }
+    return values
+}
+
+func (p JSONObject) ToValues(opts FormOptions) url.Values {
+    return JSONObjectToValues(p, opts)
+}
+
+func addFormValue(values url.Values, key string, value interface{}, opts *FormOptions) {
+    if obj, ok := jsonObjectValue(value); ok {
+        for k, v := range obj {
+            addFormValue(values, key+"["+k+"]", v, opts)
+        }
+        return
+    }
+    if arr, ok := jsonArrayValue(value); ok {
+        style := opts.ArrayStyle
+        for _, item := range arr {
+            if isCompositeJSONValue(item) {
+                style = FormArrayIndexed
+            }
+        }
+        for i, item := range arr {
+            switch style {
+            case FormArrayBrackets:
+                values.Add(key+"[]", JSONValueToString(item))
+            case FormArrayRepeat:
+                values.Add(key, JSONValueToString(item))
+            default:
+                addFormValue(values, key+"["+strconv.Itoa(i)+"]", item, opts)
+            }
+        }
+        return
+    }
+    values.Add(key, JSONValueToString(value))
+}
+
+// UnmarshalValues stores form values into the struct pointed to by v,
+// following the same tag rules as Unmarshal. Strings are converted to the
+// types of the fields, and a single value can fill a slice field.
+func UnmarshalValues(values url.Values, v interface{}, opts FormOptions) error {
+    obj, err := ValuesToJSONObject(values, opts)
+    if err != nil {
+        return err
+    }
+    d := newDecodeState(UnmarshalOptions{})
+    d.scalarAsArray = true
+    return d.unmarshal(obj, v)
+}
@@ -0,0 +1,96 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "net/url"
+    "testing"
+)
+
+func TestValuesToJSONObject(t *testing.T) {
+    tests := []struct {
+        form  string
+        infer bool
+        want  string
+    }{
+        {"a=x", false, `{"a":"x"}`},
+        {"a=x&a=y", false, `{"a":["x","y"]}`},
+        {"a[b]=x&a[c][d]=y", false, `{"a":{"b":"x","c":{"d":"y"}}}`},
+        {"a[]=x&a[]=y", false, `{"a":["x","y"]}`},
+        {"a[0]=x&a[1]=y", false, `{"a":["x","y"]}`},
+        {"a[0][b]=x&a[1][b]=y", false, `{"a":[{"b":"x"},{"b":"y"}]}`},
+        {"a[0]=0&a[1]=1&a[2]=2&a[3]=3&a[4]=4&a[5]=5&a[6]=6&a[7]=7&a[8]=8&a[9]=9&a[10]=10", false,
+            `{"a":["0","1","2","3","4","5","6","7","8","9","10"]}`},
+        {"a[5]=x", false, `{"a":{"5":"x"}}`},
+        {"a[1048575]=x", false, `{"a":{"1048575":"x"}}`},
+        {"a[01]=x", false, `{"a":{"01":"x"}}`},
+        {"a]=x&b[=y", false, `{"a]":"x","b[":"y"}`},
+        {"n=1&f=1.5&b=true&z=null&zip=02134", true, `{"b":true,"f":1.5,"n":1,"z":null,"zip":"02134"}`},
+        {"n=1&b=true", false, `{"b":"true","n":"1"}`},
+    }
+    for _, tt := range tests {
+        values, err := url.ParseQuery(tt.form)
+        if err != nil {
+            t.Fatal(err)
+        }
+        obj, err := ValuesToJSONObject(values, FormOptions{InferTypes: tt.infer})
+        if err != nil {
+            t.Errorf("%s: %v", tt.form, err)
+            continue
+        }
+        want, _ := ParseObject([]byte(tt.want), ParseOptions{})
+        if !EqualJSONValues(obj, want) {
+            t.Errorf("%s: got %v, want %s", tt.form, obj, tt.want)
+        }
+    }
+}
+
+func TestValuesToJSONObjectConflicts(t *testing.T) {
+    for _, form := range []string{"a=x&a[b]=y", "a[0]=x&a[b]=y", "a[0]=x&a[2]=y", "a[b]=x&a[b][c]=y"} {
+        values, _ := url.ParseQuery(form)
+        if _, err := ValuesToJSONObject(values, FormOptions{}); err == nil {
+            t.Errorf("%s: expected an error", form)
+        }
+    }
+}
+
+func TestJSONObjectToValues(t *testing.T) {
+    obj := JSONObject{"a": JSONArray{"x", "y"}, "o": JSONObject{"k": "v"}, "e": JSONArray{}}
+    tests := []struct {
+        style FormArrayStyle
+        want  string
+    }{
+        {FormArrayIndexed, "a%5B0%5D=x&a%5B1%5D=y&o%5Bk%5D=v"},
+        {FormArrayBrackets, "a%5B%5D=x&a%5B%5D=y&o%5Bk%5D=v"},
+        {FormArrayRepeat, "a=x&a=y&o%5Bk%5D=v"},
+    }
+    for _, tt := range tests {
+        values := JSONObjectToValues(obj, FormOptions{ArrayStyle: tt.style})
+        if got := values.Encode(); got != tt.want {
+            t.Errorf("style %d: got %s, want %s", tt.style, got, tt.want)
+        }
+        back, err := ValuesToJSONObject(values, FormOptions{})
+        if err != nil {
+            t.Errorf("style %d: %v", tt.style, err)
+        } else if !EqualJSONValues(back, JSONObject{"a": JSONArray{"x", "y"}, "o": JSONObject{"k": "v"}}) {
+            t.Errorf("style %d: round trip gave %v", tt.style, back)
+        }
+    }
+}
+
+func TestUnmarshalValues(t *testing.T) {
+    var v struct {
+        Name string   `json:"name"`
+        Age  int      `json:"age"`
+        Tags []string `json:"tags"`
+    }
+    values, _ := url.ParseQuery("name=ann&age=41&tags=x")
+    if err := UnmarshalValues(values, &v, FormOptions{}); err != nil {
+        t.Fatal(err)
+    }
+    if v.Name != "ann" || v.Age != 41 || len(v.Tags) != 1 || v.Tags[0] != "x" {
+        t.Errorf("got %+v", v)
+    }
+}
@@ -0,0 +1,154 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "reflect"
+    "time"
+)
+
+// JSONObjectMarshaler is implemented by types that convert themselves into
+// a JSONObject without reflection, such as those given methods by the
+// jsonhelper-gen command. Marshal uses the method whenever the call's
+// options and the enclosing field's tag leave everything at its default.
+type JSONObjectMarshaler interface {
+    MarshalJSONObject() (JSONObject, error)
+}
+
+// JSONObjectUnmarshaler is the decoding counterpart of JSONObjectMarshaler,
+// used by Unmarshal under the same conditions.
+type JSONObjectUnmarshaler interface {
+    UnmarshalJSONObject(obj JSONObject) error
+}
+
+// EncodeContext carries the state of a Marshal call into generated
+// MarshalJSONObjectWith methods, so that the fields they pass back to the
+// package are still checked for cycles and depth.
+type EncodeContext struct {
+    e *encodeState
+}
+
+// jsonObjectContextMarshaler is implemented by the methods jsonhelper-gen
+// writes alongside MarshalJSONObject.
+type jsonObjectContextMarshaler interface {
+    MarshalJSONObjectWith(ctx *EncodeContext) (JSONObject, error)
+}
+
+var jsonObjectMarshalerType = reflect.TypeOf((*JSONObjectMarshaler)(nil)).Elem()
+var jsonObjectUnmarshalerType = reflect.TypeOf((*JSONObjectUnmarshaler)(nil)).Elem()
+
+// MarshalField converts v as Marshal would if it were the value of a struct
+// field with the given json tag options, such as "string,unix" or
+// "bytes=hex". Generated code uses it for fields it does not convert
+// itself.
+func MarshalField(v interface{}, tagOpts string) (retval interface{}, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            err = recoveredError(r)
+        }
+    }()
+    opts := tagOptions(tagOpts)
+    var fe fieldEncoder
+    fe.stringify = opts.Contains("string")
+    fe.setOptions(opts)
+    e := &encodeState{opts: &MarshalOptions{}, isDefault: true}
+    retval = e.encode(reflect.ValueOf(v), fe.fieldOpts(encOpts{}))
+    if opts.Contains("sensitive") {
+        retval, _ = redactValue(retval, RedactMask, "")
+    }
+    return
+}
+
+// MarshalField converts the value of the struct field key as the package
+// function MarshalField does, but within the Marshal call ctx belongs to.
+// Errors then unwind to that call rather than being returned. A nil ctx,
+// as generated MarshalJSONObject methods pass, starts a new call.
+func (ctx *EncodeContext) MarshalField(key string, v interface{}, tagOpts string) (interface{}, error) {
+    if ctx == nil {
+        return MarshalField(v, tagOpts)
+    }
+    e := ctx.e
+    opts := tagOptions(tagOpts)
+    var fe fieldEncoder
+    fe.stringify = opts.Contains("string")
+    fe.setOptions(opts)
+    e.pushKey(key)
+    value := e.encode(reflect.ValueOf(v), fe.fieldOpts(encOpts{}))
+    e.pop()
+    if opts.Contains("sensitive") {
+        value, _ = redactValue(value, RedactMask, "")
+    }
+    return value, nil
+}
+
+// UnmarshalField stores value into the value pointed to by v as Unmarshal
+// would if it were a struct field with the given json tag options.
+func UnmarshalField(value interface{}, v interface{}, tagOpts string) error {
+    d := newDecodeState(UnmarshalOptions{})
+    d.applyFieldOptions(tagOptions(tagOpts))
+    return d.unmarshal(value, v)
+}
+
+// IsEmpty reports whether v would be dropped by the omitempty tag option.
+func IsEmpty(v interface{}) bool {
+    if v == nil {
+        return true
+    }
+    return isEmptyValue(reflect.ValueOf(v))
+}
+
+// IsZero reports whether v would be dropped by the omitzero tag option.
+func IsZero(v interface{}) bool {
+    if v == nil {
+        return true
+    }
+    return isZeroValue(reflect.ValueOf(v))
+}
+
+// NewUnmarshalTypeError describes a JSON value that cannot be stored in a
+// value of the same type as v.
+func NewUnmarshalTypeError(value interface{}, v interface{}) error {
+    return &json.UnmarshalTypeError{Value: describeJSONValue(value), Type: reflect.TypeOf(v)}
+}
+
+// JSONValueToBoolStrict is like JSONValueToBool but fails on objects and
+// arrays.
+func JSONValueToBoolStrict(value interface{}) (bool, error) {
+    if isCompositeJSONValue(value) {
+        return false, NewUnmarshalTypeError(value, false)
+    }
+    return JSONValueToBool(value), nil
+}
+
+// JSONValueToStringStrict is like JSONValueToString but fails on objects
+// and arrays.
+func JSONValueToStringStrict(value interface{}) (string, error) {
+    if isCompositeJSONValue(value) {
+        return "", NewUnmarshalTypeError(value, "")
+    }
+    return JSONValueToString(value), nil
+}
+
+// JSONValueToTimeStrict is like JSONValueToTime but fails on strings that
+// do not match format and on objects and arrays.
+func JSONValueToTimeStrict(value interface{}, format string) (time.Time, error) {
+    if s, ok := value.(string); ok {
+        return parseTime(s, format)
+    }
+    if isCompositeJSONValue(value) {
+        return time.Time{}, NewUnmarshalTypeError(value, time.Time{})
+    }
+    return JSONValueToTime(value, format), nil
+}
+
+// JSONValueToDurationStrict is like JSONValueToDuration but fails on
+// malformed strings, on numbers out of range and on objects and arrays.
+func JSONValueToDurationStrict(value interface{}, unit time.Duration) (time.Duration, error) {
+    if isCompositeJSONValue(value) {
+        return 0, NewUnmarshalTypeError(value, time.Duration(0))
+    }
+    return jsonValueToDuration(value, unit)
+}
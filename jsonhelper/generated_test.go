@@ -0,0 +1,68 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "testing"
+)
+
+// genNode has the methods jsonhelper-gen writes for
+//
+//	type genNode struct {
+//	    Name string   `json:"name"`
+//	    Next *genNode `json:"next,omitempty"`
+//	}
+type genNode struct {
+    Name string
+    Next *genNode
+}
+
+func (v genNode) MarshalJSONObject() (JSONObject, error) {
+    return v.MarshalJSONObjectWith(nil)
+}
+
+func (v genNode) MarshalJSONObjectWith(ctx *EncodeContext) (JSONObject, error) {
+    obj := NewJSONObject()
+    obj["name"] = v.Name
+    if v.Next != nil {
+        value, err := ctx.MarshalField("next", v.Next, "omitempty")
+        if err != nil {
+            return nil, err
+        }
+        obj["next"] = value
+    }
+    return obj, nil
+}
+
+func TestGeneratedMarshalerChain(t *testing.T) {
+    n := &genNode{Name: "a", Next: &genNode{Name: "b", Next: &genNode{Name: "c"}}}
+    v, err := Marshal(n)
+    if err != nil {
+        t.Fatal(err)
+    }
+    got := v.(JSONObject).GetAsObject("next").GetAsObject("next").GetAsString("name")
+    if got != "c" {
+        t.Errorf("next.next.name = %q, want %q", got, "c")
+    }
+}
+
+func TestGeneratedMarshalerCycle(t *testing.T) {
+    n := &genNode{Name: "a"}
+    n.Next = &genNode{Name: "b", Next: n}
+    tests := []struct {
+        name string
+        call func() error
+    }{
+        {"Marshal", func() error { _, err := Marshal(n); return err }},
+        {"Marshal value", func() error { _, err := Marshal(*n); return err }},
+        {"MarshalJSONObject", func() error { _, err := n.MarshalJSONObject(); return err }},
+    }
+    for _, tt := range tests {
+        err := tt.call()
+        if _, ok := err.(*CycleError); !ok {
+            t.Errorf("%s: got error %v, want a *CycleError", tt.name, err)
+        }
+    }
+}
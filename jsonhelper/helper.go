@@ -6,11 +6,45 @@ package jsonhelper
 
 import (
     "encoding/json"
+    "math"
     "strconv"
     "strings"
     "time"
 )
 
+// normalizeJSONValue converts the map[string]interface{} and []interface{}
+// values produced by encoding/json into JSONObject and JSONArray values.
+func normalizeJSONValue(value interface{}) interface{} {
+    switch v := value.(type) {
+    case map[string]interface{}:
+        for k, item := range v {
+            v[k] = normalizeJSONValue(item)
+        }
+        return NewJSONObjectFromMap(v)
+    case JSONObject:
+        for k, item := range v {
+            v[k] = normalizeJSONValue(item)
+        }
+        return v
+    case *OrderedJSONObject:
+        for k, item := range v.values {
+            v.values[k] = normalizeJSONValue(item)
+        }
+        return v
+    case []interface{}:
+        for i, item := range v {
+            v[i] = normalizeJSONValue(item)
+        }
+        return NewJSONArrayFromArray(v)
+    case JSONArray:
+        for i, item := range v {
+            v[i] = normalizeJSONValue(item)
+        }
+        return v
+    }
+    return value
+}
+
 func JSONValueToString(value interface{}) string {
     switch v := value.(type) {
     case nil:
@@ -244,6 +278,10 @@ func JSONValueToObject(value interface{}) JSONObject {
         return v
     case map[string]interface{}:
         return NewJSONObjectFromMap(v)
+    case *OrderedJSONObject:
+        if v != nil {
+            return v.ToJSONObject()
+        }
     }
     return NewJSONObject()
 }
@@ -265,14 +303,14 @@ func JSONValueToTime(value interface{}, format string) time.Time {
     case nil, bool, JSONArray, JSONObject, []interface{}, map[string]interface{}:
         return time.Time{}
     case string:
-        t, _ := time.Parse(format, v)
+        t, _ := parseTime(v, format)
         return t
     case int64:
-        return time.Unix(v, 0).UTC()
+        return unixTime(v, format)
     case int:
-        return time.Unix(int64(v), 0).UTC()
+        return unixTime(int64(v), format)
     case float64:
-        return time.Unix(int64(v), 0).UTC()
+        return unixTime(int64(v), format)
     case *time.Time:
         return *v
     case time.Time:
@@ -280,3 +318,53 @@ func JSONValueToTime(value interface{}, format string) time.Time {
     }
     return time.Time{}
 }
+
+func JSONValueToDuration(value interface{}, unit time.Duration) time.Duration {
+    d, _ := jsonValueToDuration(value, unit)
+    return d
+}
+
+// jsonValueToDuration converts value as JSONValueToDuration does, reporting
+// strings that do not parse and numbers outside the range of a Duration.
+func jsonValueToDuration(value interface{}, unit time.Duration) (time.Duration, error) {
+    if unit <= 0 {
+        unit = time.Nanosecond
+    }
+    switch v := value.(type) {
+    case string:
+        return parseDurationString(v, unit)
+    case time.Duration:
+        return v, nil
+    case *time.Duration:
+        if v == nil {
+            return 0, nil
+        }
+        return *v, nil
+    case float64:
+        return durationFromFloat(v, unit)
+    case float32:
+        return durationFromFloat(float64(v), unit)
+    case int:
+        return durationFromInt(int64(v), unit)
+    case int8:
+        return durationFromInt(int64(v), unit)
+    case int16:
+        return durationFromInt(int64(v), unit)
+    case int32:
+        return durationFromInt(int64(v), unit)
+    case int64:
+        return durationFromInt(v, unit)
+    case uint8:
+        return durationFromInt(int64(v), unit)
+    case uint16:
+        return durationFromInt(int64(v), unit)
+    case uint32:
+        return durationFromInt(int64(v), unit)
+    case uint64:
+        if v > math.MaxInt64 {
+            return 0, errDurationRange
+        }
+        return durationFromInt(int64(v), unit)
+    }
+    return 0, nil
+}
@@ -0,0 +1,353 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "math"
+    "net/url"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// SchemaDraft is the $schema of the documents SchemaInferrer produces.
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Defaults for SchemaOptions.
+const (
+    DefaultSchemaMaxEnumValues = 10
+    DefaultSchemaRequiredRatio = 1.0
+)
+
+// SchemaOptions controls SchemaInferrer.
+type SchemaOptions struct {
+    // MaxEnumValues is the most distinct values a string property may have
+    // to be described as an enum. Strings are only made an enum when each
+    // value was seen at least twice on average, and never when they have a
+    // format. It defaults to DefaultSchemaMaxEnumValues; a negative value
+    // disables enums.
+    MaxEnumValues int
+    // RequiredRatio is the share of objects, between 0 and 1, a key must
+    // appear in to be listed as required. It defaults to
+    // DefaultSchemaRequiredRatio, requiring keys present in every object.
+    RequiredRatio float64
+    // NoFormats turns off the detection of string formats.
+    NoFormats bool
+}
+
+// SchemaInferrer builds a JSON Schema describing the documents given to
+// Add. Documents are summarized as they are added rather than kept, so a
+// stream of any length can be described, and Schema may be called at any
+// point to describe the documents seen so far.
+type SchemaInferrer struct {
+    opts  SchemaOptions
+    root  *schemaNode
+    count int
+}
+
+func NewSchemaInferrer(opts SchemaOptions) *SchemaInferrer {
+    if opts.MaxEnumValues == 0 {
+        opts.MaxEnumValues = DefaultSchemaMaxEnumValues
+    }
+    if opts.RequiredRatio <= 0 || opts.RequiredRatio > 1 {
+        opts.RequiredRatio = DefaultSchemaRequiredRatio
+    }
+    return &SchemaInferrer{opts: opts, root: &schemaNode{}}
+}
+
+// InferSchema returns the schema of the given sample documents.
+func InferSchema(samples []interface{}, opts SchemaOptions) JSONObject {
+    s := NewSchemaInferrer(opts)
+    for _, v := range samples {
+        s.Add(v)
+    }
+    return s.Schema()
+}
+
+// Add adds one document to the samples.
+func (s *SchemaInferrer) Add(value interface{}) {
+    s.count++
+    s.root.add(value, &s.opts)
+}
+
+// Count returns the number of documents added.
+func (s *SchemaInferrer) Count() int {
+    return s.count
+}
+
+// Schema returns the schema of the documents added so far.
+func (s *SchemaInferrer) Schema() JSONObject {
+    schema := s.root.schema(&s.opts)
+    schema["$schema"] = SchemaDraft
+    return schema
+}
+
+// schemaNode summarizes the values seen at one position of the documents.
+type schemaNode struct {
+    count    int
+    nulls    int
+    bools    int
+    integers int
+    numbers  int
+    strings  int
+    objects  int
+    arrays   int
+
+    min, max float64
+    // big is the largest integer seen above math.MaxInt64, which max
+    // cannot hold exactly.
+    big uint64
+
+    // values counts the distinct strings, and is dropped once there are
+    // more than MaxEnumValues of them.
+    values   map[string]int
+    tooMany  bool
+    format   string
+    noFormat bool
+
+    properties map[string]*schemaNode
+    items      *schemaNode
+}
+
+func (n *schemaNode) add(value interface{}, opts *SchemaOptions) {
+    n.count++
+    if obj, ok := jsonObjectValue(value); ok {
+        n.objects++
+        if n.properties == nil {
+            n.properties = make(map[string]*schemaNode)
+        }
+        for k, v := range obj {
+            child, ok := n.properties[k]
+            if !ok {
+                child = &schemaNode{}
+                n.properties[k] = child
+            }
+            child.add(v, opts)
+        }
+        return
+    }
+    if arr, ok := jsonArrayValue(value); ok {
+        n.arrays++
+        if n.items == nil {
+            n.items = &schemaNode{}
+        }
+        for _, item := range arr {
+            n.items.add(item, opts)
+        }
+        return
+    }
+    switch v := value.(type) {
+    case nil:
+        n.nulls++
+    case bool:
+        n.bools++
+    case string:
+        n.addString(v, opts)
+    case json.Number:
+        f, err := v.Float64()
+        if err != nil {
+            n.addString(string(v), opts)
+            return
+        }
+        if _, err := v.Int64(); err == nil {
+            n.addNumber(f, true)
+        } else if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+            n.addUint(u)
+        } else {
+            n.addNumber(f, false)
+        }
+    case float64:
+        n.addNumber(v, v == math.Trunc(v) && !math.IsInf(v, 0))
+    case float32:
+        n.addNumber(float64(v), float64(v) == math.Trunc(float64(v)) && !math.IsInf(float64(v), 0))
+    case uint64:
+        n.addUint(v)
+    case uint:
+        n.addUint(uint64(v))
+    case int, int8, int16, int32, int64, uint8, uint16, uint32:
+        f, _ := strconv.ParseFloat(JSONValueToString(v), 64)
+        n.addNumber(f, true)
+    case time.Time:
+        n.addString(v.Format(time.RFC3339Nano), opts)
+    default:
+        n.addString(JSONValueToString(v), opts)
+    }
+}
+
+func (n *schemaNode) addNumber(f float64, integer bool) {
+    if n.integers+n.numbers == 0 || f < n.min {
+        n.min = f
+    }
+    if n.integers+n.numbers == 0 || f > n.max {
+        n.max = f
+    }
+    if integer {
+        n.integers++
+    } else {
+        n.numbers++
+    }
+}
+
+func (n *schemaNode) addUint(u uint64) {
+    n.addNumber(float64(u), true)
+    if u > math.MaxInt64 && u > n.big {
+        n.big = u
+    }
+}
+
+func (n *schemaNode) addString(s string, opts *SchemaOptions) {
+    n.strings++
+    if !n.tooMany && opts.MaxEnumValues > 0 {
+        if n.values == nil {
+            n.values = make(map[string]int)
+        }
+        n.values[s]++
+        if len(n.values) > opts.MaxEnumValues {
+            n.values, n.tooMany = nil, true
+        }
+    }
+    if n.noFormat || opts.NoFormats {
+        return
+    }
+    format := stringFormat(s)
+    switch {
+    case format == "":
+        n.format, n.noFormat = "", true
+    case n.strings == 1:
+        n.format = format
+    case format != n.format:
+        n.format, n.noFormat = "", true
+    }
+}
+
+var (
+    uuidPattern  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+    emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s.]+$`)
+    datePattern  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// stringFormat returns the JSON Schema format s is written in, or "".
+func stringFormat(s string) string {
+    switch {
+    case uuidPattern.MatchString(s):
+        return "uuid"
+    case emailPattern.MatchString(s):
+        return "email"
+    case datePattern.MatchString(s):
+        if _, err := time.Parse("2006-01-02", s); err == nil {
+            return "date"
+        }
+    case len(s) > 10 && s[4] == '-' && (s[10] == 'T' || s[10] == 't'):
+        // RFC 3339 allows a lower case t and z, which time.Parse does not.
+        if _, err := time.Parse(time.RFC3339Nano, strings.ToUpper(s)); err == nil {
+            return "date-time"
+        }
+    }
+    if u, err := url.Parse(s); err == nil && u.Scheme != "" && u.Host != "" {
+        return "uri"
+    }
+    return ""
+}
+
+// onlyStrings reports whether the values seen are strings or null.
+func (n *schemaNode) onlyStrings() bool {
+    return n.objects+n.arrays+n.integers+n.numbers+n.bools == 0
+}
+
+// schemaTypes lists the JSON Schema types of the values seen, in a fixed
+// order.
+func (n *schemaNode) schemaTypes() []string {
+    var types []string
+    if n.objects > 0 {
+        types = append(types, "object")
+    }
+    if n.arrays > 0 {
+        types = append(types, "array")
+    }
+    if n.strings > 0 {
+        types = append(types, "string")
+    }
+    if n.numbers > 0 {
+        types = append(types, "number")
+    } else if n.integers > 0 {
+        types = append(types, "integer")
+    }
+    if n.bools > 0 {
+        types = append(types, "boolean")
+    }
+    if n.nulls > 0 {
+        types = append(types, "null")
+    }
+    return types
+}
+
+func (n *schemaNode) schema(opts *SchemaOptions) JSONObject {
+    schema := NewJSONObject()
+    types := n.schemaTypes()
+    switch len(types) {
+    case 0:
+        return schema
+    case 1:
+        schema["type"] = types[0]
+    default:
+        arr := make(JSONArray, len(types))
+        for i, t := range types {
+            arr[i] = t
+        }
+        schema["type"] = arr
+    }
+    if n.objects > 0 {
+        props := NewJSONObject()
+        var required []string
+        for k, child := range n.properties {
+            props[k] = child.schema(opts)
+            if float64(child.count) >= opts.RequiredRatio*float64(n.objects) {
+                required = append(required, k)
+            }
+        }
+        schema["properties"] = props
+        if len(required) > 0 {
+            sort.Strings(required)
+            arr := make(JSONArray, len(required))
+            for i, k := range required {
+                arr[i] = k
+            }
+            schema["required"] = arr
+        }
+    }
+    if n.arrays > 0 && n.items != nil && n.items.count > 0 {
+        schema["items"] = n.items.schema(opts)
+    }
+    if n.strings > 0 {
+        if n.format != "" {
+            schema["format"] = n.format
+        } else if n.values != nil && len(n.values)*2 <= n.strings && n.onlyStrings() {
+            values := make([]string, 0, len(n.values))
+            for v := range n.values {
+                values = append(values, v)
+            }
+            sort.Strings(values)
+            enum := make(JSONArray, len(values))
+            for i, v := range values {
+                enum[i] = v
+            }
+            schema["enum"] = enum
+            if n.nulls > 0 {
+                schema["enum"] = append(enum, nil)
+            }
+        }
+    }
+    if n.integers+n.numbers > 0 {
+        schema["minimum"] = n.min
+        schema["maximum"] = n.max
+        if n.numbers == 0 && n.big > 0 && float64(n.big) >= n.max {
+            schema["maximum"] = n.big
+        }
+    }
+    return schema
+}
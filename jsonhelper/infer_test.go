@@ -0,0 +1,142 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "testing"
+    "time"
+)
+
+func TestInferSchema(t *testing.T) {
+    tests := []struct {
+        samples []interface{}
+        want    string
+    }{
+        {[]interface{}{json.Number("1"), json.Number("-2")},
+            `{"type":"integer","minimum":-2,"maximum":1}`},
+        {[]interface{}{json.Number("1"), json.Number("2.5")},
+            `{"type":"number","minimum":1,"maximum":2.5}`},
+        {[]interface{}{float64(3), nil},
+            `{"type":["integer","null"],"minimum":3,"maximum":3}`},
+        {[]interface{}{"a", "a", "b", "b"},
+            `{"type":"string","enum":["a","b"]}`},
+        {[]interface{}{"2012-01-02", "2013-04-05"},
+            `{"type":"string","format":"date"}`},
+        {[]interface{}{JSONArray{true, false}, JSONArray{}},
+            `{"type":"array","items":{"type":"boolean"}}`},
+        {[]interface{}{JSONObject{"a": "x", "b": "y"}, JSONObject{"a": "z"}},
+            `{"type":"object","properties":{"a":{"type":"string"},"b":{"type":"string"}},"required":["a"]}`},
+    }
+    for _, tt := range tests {
+        got := InferSchema(tt.samples, SchemaOptions{})
+        delete(got, "$schema")
+        want := mustParse(t, tt.want)
+        if !EqualJSONValues(got, want) {
+            b, _ := json.Marshal(got)
+            t.Errorf("%v: got %s, want %s", tt.samples, b, tt.want)
+        }
+    }
+}
+
+func TestInferSchemaLargeIntegers(t *testing.T) {
+    tests := []struct {
+        samples []interface{}
+        max     interface{}
+    }{
+        {[]interface{}{json.Number("12345678901234567890"), json.Number("1")}, uint64(12345678901234567890)},
+        {[]interface{}{uint64(18446744073709551615)}, uint64(18446744073709551615)},
+        {[]interface{}{json.Number("9223372036854775807")}, float64(9223372036854775807)},
+        {[]interface{}{json.Number("12345678901234567890"), float64(1.5e19)}, float64(1.5e19)},
+    }
+    for _, tt := range tests {
+        got := InferSchema(tt.samples, SchemaOptions{})
+        if got["type"] != "integer" || got["maximum"] != tt.max {
+            t.Errorf("%v: got type %v, maximum %T %v, want integer and %T %v", tt.samples, got["type"], got["maximum"], got["maximum"], tt.max, tt.max)
+        }
+    }
+    got := InferSchema([]interface{}{json.Number("18446744073709551616")}, SchemaOptions{})
+    if got["type"] != "number" {
+        t.Errorf("2^64: got type %v, want number", got["type"])
+    }
+}
+
+func TestInferSchemaOptions(t *testing.T) {
+    tests := []struct {
+        name    string
+        samples []interface{}
+        opts    SchemaOptions
+        want    string
+    }{
+        {"no samples", nil, SchemaOptions{}, `{}`},
+        {"enum with null", []interface{}{"a", "a", nil, "b", "b"}, SchemaOptions{},
+            `{"type":["string","null"],"enum":["a","b",null]}`},
+        {"too few repeats for enum", []interface{}{"a", "b", "c", "a"}, SchemaOptions{},
+            `{"type":"string"}`},
+        {"too many values for enum", []interface{}{"a", "a", "b", "b", "c", "c"}, SchemaOptions{MaxEnumValues: 2},
+            `{"type":"string"}`},
+        {"enums disabled", []interface{}{"a", "a"}, SchemaOptions{MaxEnumValues: -1},
+            `{"type":"string"}`},
+        {"no enum beside other types", []interface{}{"a", "a", json.Number("1")}, SchemaOptions{},
+            `{"type":["string","integer"],"minimum":1,"maximum":1}`},
+        {"uuid", []interface{}{"123e4567-e89b-12d3-a456-426614174000"}, SchemaOptions{},
+            `{"type":"string","format":"uuid"}`},
+        {"email", []interface{}{"a@example.com", "b.c@example.org"}, SchemaOptions{},
+            `{"type":"string","format":"email"}`},
+        {"date-time", []interface{}{"2012-01-02T03:04:05Z", "2012-01-02t03:04:05.5+01:00", "2012-01-02T03:04:05z"}, SchemaOptions{},
+            `{"type":"string","format":"date-time"}`},
+        {"uri", []interface{}{"https://example.com/a?b=c"}, SchemaOptions{},
+            `{"type":"string","format":"uri"}`},
+        {"invalid date", []interface{}{"2012-13-45"}, SchemaOptions{},
+            `{"type":"string"}`},
+        {"mixed formats", []interface{}{"2012-01-02", "2012-01-02", "a@example.com", "a@example.com"}, SchemaOptions{},
+            `{"type":"string","enum":["2012-01-02","a@example.com"]}`},
+        {"format then plain", []interface{}{"2012-01-02", "plain", "2012-01-03"}, SchemaOptions{},
+            `{"type":"string"}`},
+        {"formats disabled", []interface{}{"2012-01-02", "2012-01-02"}, SchemaOptions{NoFormats: true},
+            `{"type":"string","enum":["2012-01-02"]}`},
+        {"time values", []interface{}{time.Date(2012, 1, 2, 3, 4, 5, 0, time.UTC)}, SchemaOptions{},
+            `{"type":"string","format":"date-time"}`},
+        {"required ratio", []interface{}{JSONObject{"a": 1, "b": 1}, JSONObject{"a": 1, "b": 1}, JSONObject{"a": 1}, JSONObject{}}, SchemaOptions{RequiredRatio: 0.5},
+            `{"type":"object","properties":{"a":{"type":"integer","minimum":1,"maximum":1},"b":{"type":"integer","minimum":1,"maximum":1}},"required":["a","b"]}`},
+        {"nested arrays", []interface{}{JSONArray{JSONArray{1.5}, JSONArray{}}, JSONArray{JSONArray{-2}}}, SchemaOptions{},
+            `{"type":"array","items":{"type":"array","items":{"type":"number","minimum":-2,"maximum":1.5}}}`},
+        {"mixed types", []interface{}{JSONObject{}, JSONArray{}, true, nil}, SchemaOptions{},
+            `{"type":["object","array","boolean","null"],"properties":{}}`},
+        {"ordered objects", []interface{}{mustParseOrdered(t, `{"z":true,"a":null}`)}, SchemaOptions{},
+            `{"type":"object","properties":{"z":{"type":"boolean"},"a":{"type":"null"}},"required":["a","z"]}`},
+    }
+    for _, tt := range tests {
+        got := InferSchema(tt.samples, tt.opts)
+        if got["$schema"] != SchemaDraft {
+            t.Errorf("%s: $schema = %v", tt.name, got["$schema"])
+        }
+        delete(got, "$schema")
+        if !EqualJSONValues(got, mustParse(t, tt.want)) {
+            b, _ := json.Marshal(got)
+            t.Errorf("%s: got %s, want %s", tt.name, b, tt.want)
+        }
+    }
+}
+
+func TestSchemaInferrerIncremental(t *testing.T) {
+    s := NewSchemaInferrer(SchemaOptions{})
+    s.Add(JSONObject{"a": 1})
+    first := s.Schema()
+    s.Add(JSONObject{"a": "x", "b": 2})
+    if s.Count() != 2 {
+        t.Errorf("Count = %d, want 2", s.Count())
+    }
+    if !EqualJSONValues(first["required"], JSONArray{"a"}) {
+        t.Errorf("first schema required = %v", first["required"])
+    }
+    got := s.Schema()
+    delete(got, "$schema")
+    want := `{"type":"object","properties":{"a":{"type":["string","integer"],"minimum":1,"maximum":1},"b":{"type":"integer","minimum":2,"maximum":2}},"required":["a"]}`
+    if !EqualJSONValues(got, mustParse(t, want)) {
+        b, _ := json.Marshal(got)
+        t.Errorf("got %s, want %s", b, want)
+    }
+}
@@ -0,0 +1,487 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bytes"
+    "encoding/json"
+    "io"
+    "math"
+    "strconv"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// ParseJSON5 parses a JSON5 document, such as a hand-edited configuration
+// file, with the limits of Parse. On top of JSON it accepts // and /* */
+// comments, trailing commas, single-quoted strings, unquoted keys,
+// hexadecimal numbers, numbers with a leading plus sign or a leading or
+// trailing decimal point, Infinity and NaN, \x, \v and \0 escapes, and
+// strings continued over lines with a backslash. JSON with comments
+// (JSONC) is a subset.
+func ParseJSON5(data []byte, opts ParseOptions) (interface{}, error) {
+    opts.Relaxed = true
+    return Parse(data, opts)
+}
+
+// JSON5Document is a JSON5 document along with its comments, so that a
+// file can be edited and written back without losing them. Comments are
+// kept as written, including their // or /* */ markers, and keyed by the
+// JSON Pointer of the value they belong to, such as "/server/port", or ""
+// for the top-level value.
+type JSON5Document struct {
+    // Value is the document, with objects as *OrderedJSONObject values.
+    Value interface{}
+    // Comments holds the comments written before a value.
+    Comments map[string][]string
+    // LineComments holds a comment written after a value on the same line.
+    LineComments map[string]string
+    // EndComments holds the comments after the last member of an object or
+    // array.
+    EndComments map[string][]string
+    // Trailing holds the comments after the top-level value.
+    Trailing []string
+}
+
+// ParseJSON5Document parses a JSON5 document as ParseJSON5 does, keeping
+// its comments and the order of its keys.
+func ParseJSON5Document(data []byte, opts ParseOptions) (*JSON5Document, error) {
+    opts.Relaxed = true
+    opts.Ordered = true
+    doc := &JSON5Document{
+        Comments:     make(map[string][]string),
+        LineComments: make(map[string]string),
+        EndComments:  make(map[string][]string),
+    }
+    p := newParser(data, &opts)
+    p.doc = doc
+    value, err := p.run()
+    if err != nil {
+        return nil, err
+    }
+    doc.Value = value
+    return doc, nil
+}
+
+// jsonPointerToken escapes s for use as a JSON Pointer segment.
+func jsonPointerToken(s string) string {
+    return strings.Replace(strings.Replace(s, "~", "~0", -1), "/", "~1", -1)
+}
+
+func (p *parser) pointer() string {
+    var b strings.Builder
+    for _, s := range p.path {
+        b.WriteByte('/')
+        b.WriteString(jsonPointerToken(s))
+    }
+    return b.String()
+}
+
+func (p *parser) takeComments() []string {
+    comments := p.pending
+    p.pending = nil
+    return comments
+}
+
+// attachComments gives the pending comments to the value about to be
+// parsed.
+func (p *parser) attachComments() {
+    if len(p.pending) > 0 {
+        ptr := p.pointer()
+        p.doc.Comments[ptr] = append(p.doc.Comments[ptr], p.takeComments()...)
+    }
+}
+
+// endComments gives the pending comments to the object or array being
+// closed.
+func (p *parser) endComments() {
+    if p.doc != nil && len(p.pending) > 0 {
+        p.doc.EndComments[p.pointer()] = p.takeComments()
+    }
+}
+
+// comment records a comment found between tokens. One that starts on the
+// line a value ended on belongs to that value.
+func (p *parser) comment(text string) {
+    if p.doc == nil {
+        return
+    }
+    if p.afterValue {
+        if prev, ok := p.doc.LineComments[p.lastPath]; ok {
+            text = prev + " " + text
+        }
+        p.doc.LineComments[p.lastPath] = text
+        return
+    }
+    p.pending = append(p.pending, text)
+}
+
+// skipRelaxedSpace skips JSON5 white space and comments.
+func (p *parser) skipRelaxedSpace() {
+    for p.pos < len(p.data) {
+        c := p.data[p.pos]
+        switch {
+        case c == '\n':
+            p.afterValue = false
+            p.pos++
+        case c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f':
+            p.pos++
+        case c == '/' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '/':
+            end := bytes.IndexByte(p.data[p.pos:], '\n')
+            if end < 0 {
+                end = len(p.data) - p.pos
+            }
+            p.comment(strings.TrimRight(string(p.data[p.pos:p.pos+end]), "\r"))
+            p.pos += end
+        case c == '/' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '*':
+            end := bytes.Index(p.data[p.pos+2:], []byte("*/"))
+            if end < 0 {
+                p.fail(p.pos, "comment is not terminated")
+            }
+            text := string(p.data[p.pos : p.pos+end+4])
+            p.comment(text)
+            if strings.Contains(text, "\n") {
+                p.afterValue = false
+            }
+            p.pos += end + 4
+        case c >= utf8.RuneSelf:
+            r, size := utf8.DecodeRune(p.data[p.pos:])
+            switch {
+            case r == '\u2028' || r == '\u2029':
+                p.afterValue = false
+            case r == '\ufeff' || unicode.Is(unicode.Zs, r):
+            default:
+                return
+            }
+            p.pos += size
+        default:
+            return
+        }
+    }
+}
+
+// identifier reads an unquoted key, an ECMAScript 5 IdentifierName.
+func (p *parser) identifier() (string, bool) {
+    var b strings.Builder
+    for p.pos < len(p.data) {
+        r, size := utf8.DecodeRune(p.data[p.pos:])
+        if r == '\\' {
+            if p.pos+1 >= len(p.data) || p.data[p.pos+1] != 'u' {
+                p.fail(p.pos, "invalid escape in unquoted key")
+            }
+            p.pos += 2
+            start := p.pos
+            if r, size = p.hex4(), 0; !isIdentifierRune(r, b.Len() == 0) {
+                p.fail(start, "escape \\u%04x is not valid in an unquoted key", r)
+            }
+        } else if !isIdentifierRune(r, b.Len() == 0) {
+            break
+        }
+        b.WriteRune(r)
+        p.pos += size
+        if b.Len() > p.maxStringLength {
+            p.failLimit(p.pos, "MaxStringLength", "string is longer than %d bytes", p.maxStringLength)
+        }
+    }
+    return b.String(), b.Len() > 0
+}
+
+func isIdentifierRune(r rune, first bool) bool {
+    if r == '$' || r == '_' || unicode.IsLetter(r) || unicode.Is(unicode.Nl, r) {
+        return true
+    }
+    return !first && (unicode.In(r, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc) || r == '\u200c' || r == '\u200d')
+}
+
+// relaxedEscape appends the character written by the JSON5 escape sequence
+// at p.pos.
+func (p *parser) relaxedEscape(buf []byte) []byte {
+    if p.pos+1 >= len(p.data) {
+        p.fail(p.pos, "string is not terminated")
+    }
+    switch c := p.data[p.pos+1]; c {
+    case '"', '\\', '/', 'b', 'f', 'n', 'r', 't', 'u':
+        return p.escape(buf)
+    case 'v':
+        p.pos += 2
+        return append(buf, '\v')
+    case '0':
+        if p.pos+2 < len(p.data) && p.data[p.pos+2] >= '0' && p.data[p.pos+2] <= '9' {
+            p.fail(p.pos, "invalid escape sequence \\0 followed by a digit in string")
+        }
+        p.pos += 2
+        return append(buf, 0)
+    case 'x':
+        if len(p.data)-p.pos < 4 {
+            p.fail(p.pos, "invalid \\x escape in string")
+        }
+        n, err := strconv.ParseUint(string(p.data[p.pos+2:p.pos+4]), 16, 8)
+        if err != nil {
+            p.fail(p.pos, "invalid \\x escape in string")
+        }
+        p.pos += 4
+        return append(buf, string(rune(n))...)
+    case '\n':
+        p.pos += 2
+        return buf
+    case '\r':
+        p.pos += 2
+        if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+            p.pos++
+        }
+        return buf
+    }
+    if c := p.data[p.pos+1]; c >= '1' && c <= '9' {
+        p.fail(p.pos, "invalid escape sequence \\%c in string", c)
+    }
+    r, size := utf8.DecodeRune(p.data[p.pos+1:])
+    if r == utf8.RuneError && size == 1 && !p.opts.AllowInvalidUTF8 {
+        p.fail(p.pos+1, "invalid UTF-8 in string")
+    }
+    p.pos += 1 + size
+    if r == '\u2028' || r == '\u2029' {
+        return buf
+    }
+    return append(buf, string(r)...)
+}
+
+// relaxedNumber reads a JSON5 number, returning Infinity and NaN as
+// float64 values even under UseNumber.
+func (p *parser) relaxedNumber() interface{} {
+    start := p.pos
+    negative := false
+    if c := p.data[p.pos]; c == '+' || c == '-' {
+        negative = c == '-'
+        p.pos++
+    }
+    if p.literal("Infinity") {
+        if negative {
+            return math.Inf(-1)
+        }
+        return math.Inf(1)
+    }
+    if p.literal("NaN") {
+        return math.NaN()
+    }
+    if p.pos+1 < len(p.data) && p.data[p.pos] == '0' && (p.data[p.pos+1] == 'x' || p.data[p.pos+1] == 'X') {
+        p.pos += 2
+        digits := p.pos
+        for p.pos < len(p.data) && isHexDigit(p.data[p.pos]) {
+            p.pos++
+        }
+        if p.pos == digits {
+            p.fail(p.pos, "unexpected %s in hexadecimal number", p.describe(p.pos))
+        }
+        n, err := strconv.ParseUint(string(p.data[digits:p.pos]), 16, 64)
+        if err != nil {
+            p.fail(start, "number %s is out of range", p.data[start:p.pos])
+        }
+        if p.opts.UseNumber {
+            text := strconv.FormatUint(n, 10)
+            if negative && n != 0 {
+                text = "-" + text
+            }
+            return json.Number(text)
+        }
+        if negative {
+            return -float64(n)
+        }
+        return float64(n)
+    }
+    var text []byte
+    if negative {
+        text = append(text, '-')
+    }
+    intStart := p.pos
+    if p.pos+1 < len(p.data) && p.data[p.pos] == '0' && p.data[p.pos+1] >= '0' && p.data[p.pos+1] <= '9' {
+        p.fail(p.pos, "number has a leading zero")
+    }
+    hasInt := p.digits()
+    text = append(text, p.data[intStart:p.pos]...)
+    if p.pos < len(p.data) && p.data[p.pos] == '.' {
+        p.pos++
+        fracStart := p.pos
+        if !p.digits() && !hasInt {
+            p.fail(p.pos, "unexpected %s after decimal point", p.describe(p.pos))
+        }
+        if p.pos > fracStart {
+            if !hasInt {
+                text = append(text, '0')
+            }
+            text = append(text, '.')
+            text = append(text, p.data[fracStart:p.pos]...)
+        }
+    } else if !hasInt {
+        p.fail(p.pos, "unexpected %s in number", p.describe(p.pos))
+    }
+    if p.pos < len(p.data) && (p.data[p.pos] == 'e' || p.data[p.pos] == 'E') {
+        expStart := p.pos
+        p.pos++
+        if p.pos < len(p.data) && (p.data[p.pos] == '+' || p.data[p.pos] == '-') {
+            p.pos++
+        }
+        if !p.digits() {
+            p.fail(p.pos, "unexpected %s in exponent", p.describe(p.pos))
+        }
+        text = append(text, p.data[expStart:p.pos]...)
+    }
+    if p.opts.UseNumber {
+        return json.Number(text)
+    }
+    f, err := strconv.ParseFloat(string(text), 64)
+    if err != nil {
+        p.fail(start, "number %s is out of range", p.data[start:p.pos])
+    }
+    return f
+}
+
+func isHexDigit(c byte) bool {
+    return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// EncodeJSON5 writes doc as indented JSON with its comments put back at
+// the values they belong to. Keys are written in order for
+// *OrderedJSONObject values and sorted otherwise, and non-finite numbers as
+// JSON5 Infinity and NaN. Comments for values no longer in the document
+// are dropped. An empty indent defaults to two spaces.
+func EncodeJSON5(doc *JSON5Document, indent string) ([]byte, error) {
+    if indent == "" {
+        indent = "  "
+    }
+    e := &json5Encoder{doc: doc, indent: indent}
+    e.comments(doc.Comments[""], 0)
+    if err := e.value(doc.Value, "", 0); err != nil {
+        return nil, err
+    }
+    e.lineComment("")
+    e.buf.WriteByte('\n')
+    e.comments(doc.Trailing, 0)
+    return e.buf.Bytes(), nil
+}
+
+// WriteJSON5 writes doc to w as EncodeJSON5 does.
+func WriteJSON5(w io.Writer, doc *JSON5Document, indent string) error {
+    b, err := EncodeJSON5(doc, indent)
+    if err != nil {
+        return err
+    }
+    _, err = w.Write(b)
+    return err
+}
+
+type json5Encoder struct {
+    buf    bytes.Buffer
+    doc    *JSON5Document
+    indent string
+}
+
+func (e *json5Encoder) writeIndent(depth int) {
+    for i := 0; i < depth; i++ {
+        e.buf.WriteString(e.indent)
+    }
+}
+
+// comments writes each comment on its own line.
+func (e *json5Encoder) comments(comments []string, depth int) {
+    for _, c := range comments {
+        e.writeIndent(depth)
+        e.buf.WriteString(c)
+        e.buf.WriteByte('\n')
+    }
+}
+
+func (e *json5Encoder) lineComment(ptr string) {
+    if c, ok := e.doc.LineComments[ptr]; ok {
+        e.buf.WriteByte(' ')
+        e.buf.WriteString(c)
+    }
+}
+
+func (e *json5Encoder) value(value interface{}, ptr string, depth int) error {
+    if obj, ok := jsonObjectValue(value); ok {
+        keys := orderedJSONObjectKeys(value, obj)
+        if len(keys) == 0 && len(e.doc.EndComments[ptr]) == 0 {
+            e.buf.WriteString("{}")
+            return nil
+        }
+        e.buf.WriteString("{\n")
+        for i, k := range keys {
+            child := ptr + "/" + jsonPointerToken(k)
+            e.comments(e.doc.Comments[child], depth+1)
+            e.writeIndent(depth + 1)
+            if err := e.scalar(k); err != nil {
+                return err
+            }
+            e.buf.WriteString(": ")
+            if err := e.value(obj[k], child, depth+1); err != nil {
+                return err
+            }
+            if i < len(keys)-1 {
+                e.buf.WriteByte(',')
+            }
+            e.lineComment(child)
+            e.buf.WriteByte('\n')
+        }
+        e.comments(e.doc.EndComments[ptr], depth+1)
+        e.writeIndent(depth)
+        e.buf.WriteByte('}')
+        return nil
+    }
+    if arr, ok := jsonArrayValue(value); ok {
+        if len(arr) == 0 && len(e.doc.EndComments[ptr]) == 0 {
+            e.buf.WriteString("[]")
+            return nil
+        }
+        e.buf.WriteString("[\n")
+        for i, item := range arr {
+            child := ptr + "/" + strconv.Itoa(i)
+            e.comments(e.doc.Comments[child], depth+1)
+            e.writeIndent(depth + 1)
+            if err := e.value(item, child, depth+1); err != nil {
+                return err
+            }
+            if i < len(arr)-1 {
+                e.buf.WriteByte(',')
+            }
+            e.lineComment(child)
+            e.buf.WriteByte('\n')
+        }
+        e.comments(e.doc.EndComments[ptr], depth+1)
+        e.writeIndent(depth)
+        e.buf.WriteByte(']')
+        return nil
+    }
+    return e.scalar(value)
+}
+
+func (e *json5Encoder) scalar(value interface{}) error {
+    var f float64
+    switch v := value.(type) {
+    case float64:
+        f = v
+    case float32:
+        f = float64(v)
+    }
+    switch {
+    case math.IsNaN(f):
+        e.buf.WriteString("NaN")
+        return nil
+    case math.IsInf(f, 1):
+        e.buf.WriteString("Infinity")
+        return nil
+    case math.IsInf(f, -1):
+        e.buf.WriteString("-Infinity")
+        return nil
+    }
+    var b bytes.Buffer
+    enc := json.NewEncoder(&b)
+    enc.SetEscapeHTML(false)
+    if err := enc.Encode(value); err != nil {
+        return err
+    }
+    e.buf.Write(bytes.TrimRight(b.Bytes(), "\n"))
+    return nil
+}
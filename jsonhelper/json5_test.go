@@ -0,0 +1,182 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bytes"
+    "encoding/json"
+    "math"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestParseJSON5(t *testing.T) {
+    tests := []struct {
+        in   string
+        opts ParseOptions
+        want interface{}
+    }{
+        {"{a: 1, $b_: 'x', \\u0063: 2, \u00e9t\u00e9: 3,}", ParseOptions{}, JSONObject{"a": 1.0, "$b_": "x", "c": 2.0, "\u00e9t\u00e9": 3.0}},
+        {"[0x1F, -0x10, +1, .5, 5., +.5e1, 1e2,]", ParseOptions{}, JSONArray{31.0, -16.0, 1.0, 0.5, 5.0, 5.0, 100.0}},
+        {"[0x10, -0x0, 5., .5, 1e2]", ParseOptions{UseNumber: true}, JSONArray{json.Number("16"), json.Number("0"), json.Number("5"), json.Number("0.5"), json.Number("1e2")}},
+        {`'it\'s \x41\v\0 "q"'`, ParseOptions{}, "it's A\v\x00 \"q\""},
+        {"'line \\\ncontinued'", ParseOptions{}, "line continued"},
+        {"'crlf \\\r\ncontinued'", ParseOptions{}, "crlf continued"},
+        {"'raw\ttab'", ParseOptions{}, "raw\ttab"},
+        {"'\\a\\$'", ParseOptions{}, "a$"},
+        {"// head\n{/* c */ a: 1 // tail\n, b: [1, /* two */ 2,], // list\n /* end */}\n// trailer", ParseOptions{}, JSONObject{"a": 1.0, "b": JSONArray{1.0, 2.0}}},
+        {"\ufeff\u00a0[1,\u2028 2]\v\f", ParseOptions{}, JSONArray{1.0, 2.0}},
+        {`{"json": [true, null]}`, ParseOptions{}, JSONObject{"json": JSONArray{true, nil}}},
+        {"{a: 1, a: 2}", ParseOptions{DuplicateKeys: DuplicateKeyLast}, JSONObject{"a": 2.0}},
+    }
+    for _, tt := range tests {
+        got, err := ParseJSON5([]byte(tt.in), tt.opts)
+        if err != nil {
+            t.Errorf("ParseJSON5(%q): %v", tt.in, err)
+        } else if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("ParseJSON5(%q) = %#v, want %#v", tt.in, got, tt.want)
+        }
+    }
+    got, err := ParseJSON5([]byte("[Infinity, -Infinity, NaN]"), ParseOptions{UseNumber: true})
+    arr, _ := got.(JSONArray)
+    if err != nil || len(arr) != 3 || !math.IsInf(arr[0].(float64), 1) || !math.IsInf(arr[1].(float64), -1) || !math.IsNaN(arr[2].(float64)) {
+        t.Errorf("non-finite numbers gave %#v, %v", got, err)
+    }
+}
+
+func TestParseJSON5Errors(t *testing.T) {
+    tests := []struct {
+        in   string
+        opts ParseOptions
+        want string
+    }{
+        {"{\\u0020: 1}", ParseOptions{}, "line 1 column 4: escape \\u0020 is not valid in an unquoted key"},
+        {"{a\\x: 1}", ParseOptions{}, "invalid escape in unquoted key"},
+        {"{1a: 1}", ParseOptions{}, "unexpected character '1' looking for an object key"},
+        {"{abcd: 1}", ParseOptions{MaxStringLength: 3}, "string is longer than 3 bytes"},
+        {"[01]", ParseOptions{}, "number has a leading zero"},
+        {"'\\01'", ParseOptions{}, "invalid escape sequence \\0 followed by a digit in string"},
+        {"'\\1'", ParseOptions{}, "invalid escape sequence \\1 in string"},
+        {"'\\xZZ'", ParseOptions{}, "invalid \\x escape in string"},
+        {"'a\nb'", ParseOptions{}, "line break in string"},
+        {"'abc", ParseOptions{}, "string is not terminated"},
+        {"/* open", ParseOptions{}, "comment is not terminated"},
+        {"[0x]", ParseOptions{}, "unexpected character ']' in hexadecimal number"},
+        {"0x10000000000000000", ParseOptions{}, "number 0x10000000000000000 is out of range"},
+        {"[.]", ParseOptions{}, "unexpected character ']' after decimal point"},
+        {"[1e]", ParseOptions{}, "unexpected character ']' in exponent"},
+        {"[+]", ParseOptions{}, "unexpected character ']' in number"},
+        {"[1,,]", ParseOptions{}, "unexpected character ',' looking for a value"},
+        {"{,}", ParseOptions{}, "unexpected character ',' looking for an object key"},
+        {"{a: 1, a: 2}", ParseOptions{}, "duplicate key \"a\""},
+        {"// only a comment", ParseOptions{}, "unexpected end of input looking for a value"},
+    }
+    for _, tt := range tests {
+        _, err := ParseJSON5([]byte(tt.in), tt.opts)
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("ParseJSON5(%q) error = %v, want %q", tt.in, err, tt.want)
+        }
+    }
+}
+
+func TestParseJSONIsStrict(t *testing.T) {
+    for _, in := range []string{"{a: 1}", "[1,]", "'s'", "// c\n1", "0x10", "+1", ".5", "Infinity", "\"a\tb\""} {
+        if _, err := Parse([]byte(in), ParseOptions{}); err == nil {
+            t.Errorf("Parse(%q) accepted JSON5", in)
+        }
+        if _, err := Parse([]byte(in), ParseOptions{Relaxed: true}); err != nil {
+            t.Errorf("Parse(%q) with Relaxed: %v", in, err)
+        }
+    }
+}
+
+const json5DocumentSample = `// head
+{
+  // about a
+  a: 1, // tail a
+  b: [1, /* two */ 2,], // list
+  'c~/d': {},
+  /* end */
+}
+// trailer
+`
+
+func TestParseJSON5Document(t *testing.T) {
+    doc, err := ParseJSON5Document([]byte(json5DocumentSample), ParseOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if p, ok := doc.Value.(*OrderedJSONObject); !ok || strings.Join(p.Keys(), ",") != "a,b,c~/d" {
+        t.Errorf("Value = %#v", doc.Value)
+    }
+    tests := []struct {
+        name string
+        got  interface{}
+        want interface{}
+    }{
+        {"Comments", doc.Comments, map[string][]string{"": {"// head"}, "/a": {"// about a"}}},
+        {"LineComments", doc.LineComments, map[string]string{"/a": "// tail a", "/b": "// list", "/b/0": "/* two */"}},
+        {"EndComments", doc.EndComments, map[string][]string{"": {"/* end */"}}},
+        {"Trailing", doc.Trailing, []string{"// trailer"}},
+    }
+    for _, tt := range tests {
+        if !reflect.DeepEqual(tt.got, tt.want) {
+            t.Errorf("%s = %#v, want %#v", tt.name, tt.got, tt.want)
+        }
+    }
+}
+
+func TestEncodeJSON5(t *testing.T) {
+    doc, err := ParseJSON5Document([]byte(json5DocumentSample), ParseOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    doc.Value.(*OrderedJSONObject).Set("e", JSONArray{math.Inf(1), math.NaN(), "<&>"})
+    want := `// head
+{
+  // about a
+  "a": 1, // tail a
+  "b": [
+    1, /* two */
+    2
+  ], // list
+  "c~/d": {},
+  "e": [
+    Infinity,
+    NaN,
+    "<&>"
+  ]
+  /* end */
+}
+// trailer
+`
+    var buf bytes.Buffer
+    if err := WriteJSON5(&buf, doc, ""); err != nil {
+        t.Fatal(err)
+    }
+    if buf.String() != want {
+        t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+    }
+    again, err := ParseJSON5Document(buf.Bytes(), ParseOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(again.LineComments, doc.LineComments) || !reflect.DeepEqual(again.EndComments, doc.EndComments) {
+        t.Errorf("comments changed on a round trip: %#v", again)
+    }
+
+    plain := &JSON5Document{Value: JSONObject{"b": JSONArray{}, "a": 1}, LineComments: map[string]string{"/gone": "// x"}}
+    out, err := EncodeJSON5(plain, "\t")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := "{\n\t\"a\": 1,\n\t\"b\": []\n}\n"; string(out) != want {
+        t.Errorf("got %q, want %q", out, want)
+    }
+    if _, err := EncodeJSON5(&JSON5Document{Value: JSONArray{make(chan int)}}, ""); err == nil {
+        t.Error("encoding a channel succeeded")
+    }
+}
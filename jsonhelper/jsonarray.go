@@ -69,6 +69,16 @@ func (p JSONArray) GetAsTime(index int, format string) time.Time {
     return JSONValueToTime(value, format)
 }
 
+func (p JSONArray) GetAsDuration(index int, unit time.Duration) time.Duration {
+    value := p[index]
+    return JSONValueToDuration(value, unit)
+}
+
+func (p JSONArray) GetAsBytes(index int, enc BytesEncoding) []byte {
+    value := p[index]
+    return JSONValueToBytes(value, enc)
+}
+
 func (p JSONArray) Compact(removeFalse bool, removeEmptyStrings bool, removeZero bool, removeEmptyArrays bool, removeEmptyObjects bool) JSONArray {
     if len(p) == 0 {
         if removeEmptyArrays {
@@ -91,6 +101,12 @@ func (p JSONArray) Compact(removeFalse bool, removeEmptyStrings bool, removeZero
             value = t.Compact(removeFalse, removeEmptyStrings, removeZero, removeEmptyArrays, removeEmptyObjects)
         case JSONArray:
             value = t.Compact(removeFalse, removeEmptyStrings, removeZero, removeEmptyArrays, removeEmptyObjects)
+        case *OrderedJSONObject:
+            if c := t.Compact(removeFalse, removeEmptyStrings, removeZero, removeEmptyArrays, removeEmptyObjects); c != nil {
+                value = c
+            } else {
+                continue
+            }
         case map[string]interface{}:
             value = NewJSONObjectFromMap(t).Compact(removeFalse, removeEmptyStrings, removeZero, removeEmptyArrays, removeEmptyObjects)
         case []interface{}:
@@ -127,6 +143,10 @@ func (p JSONArray) Compact(removeFalse bool, removeEmptyStrings bool, removeZero
             if removeZero && t == 0 {
                 continue
             }
+        case json.Number:
+            if f, err := t.Float64(); removeZero && err == nil && f == 0 {
+                continue
+            }
         case bool:
             if removeFalse && t == false {
                 continue
@@ -86,6 +86,16 @@ func (p JSONObject) GetAsTime(key string, format string) time.Time {
     return JSONValueToTime(value, format)
 }
 
+func (p JSONObject) GetAsDuration(key string, unit time.Duration) time.Duration {
+    value, _ := p[key]
+    return JSONValueToDuration(value, unit)
+}
+
+func (p JSONObject) GetAsBytes(key string, enc BytesEncoding) []byte {
+    value, _ := p[key]
+    return JSONValueToBytes(value, enc)
+}
+
 func (p JSONObject) Compact(removeFalse bool, removeEmptyStrings bool, removeZero bool, removeEmptyArrays bool, removeEmptyObjects bool) JSONObject {
     if len(p) == 0 {
         if removeEmptyObjects {
@@ -108,6 +118,12 @@ func (p JSONObject) Compact(removeFalse bool, removeEmptyStrings bool, removeZer
             value = t.Compact(removeFalse, removeEmptyStrings, removeZero, removeEmptyArrays, removeEmptyObjects)
         case JSONArray:
             value = t.Compact(removeFalse, removeEmptyStrings, removeZero, removeEmptyArrays, removeEmptyObjects)
+        case *OrderedJSONObject:
+            if c := t.Compact(removeFalse, removeEmptyStrings, removeZero, removeEmptyArrays, removeEmptyObjects); c != nil {
+                value = c
+            } else {
+                continue
+            }
         case map[string]interface{}:
             value = NewJSONObjectFromMap(t).Compact(removeFalse, removeEmptyStrings, removeZero, removeEmptyArrays, removeEmptyObjects)
         case []interface{}:
@@ -144,6 +160,10 @@ func (p JSONObject) Compact(removeFalse bool, removeEmptyStrings bool, removeZer
             if removeZero && t == 0 {
                 continue
             }
+        case json.Number:
+            if f, err := t.Float64(); removeZero && err == nil && f == 0 {
+                continue
+            }
         case bool:
             if removeFalse && t == false {
                 continue
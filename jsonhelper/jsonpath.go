@@ -0,0 +1,631 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// QueryJSONPath returns the values in value selected by the JSONPath
+// expression path, in document order. Supported are the root $, member
+// names written .name or ['name'], array indices including negative ones,
+// slices [start:end:step], wildcards .* and [*], recursive descent ..,
+// unions such as [0,2] or ['a','b'] and filters such as
+// [?(@.price < 10 && @.tags)], which compare relative paths with literals
+// using == != < <= > >= and may be combined with !, && and ||.
+func QueryJSONPath(value interface{}, path string) (JSONArray, error) {
+    p := &pathParser{s: path}
+    segments, err := p.parse()
+    if err != nil {
+        return nil, err
+    }
+    return segments.query(value, value), nil
+}
+
+// jsonPathSegment selects children of a value, or of it and every value it
+// contains when descendant is set.
+type jsonPathSegment struct {
+    descendant bool
+    selectors  []jsonPathSelector
+}
+
+// jsonPathSelector selects one kind of child: a member name, an array index, a
+// slice, every child, or the children passing a filter.
+type jsonPathSelector struct {
+    kind   byte // 'n'ame, 'i'ndex, 's'lice, '*' or '?'
+    name   string
+    index  int
+    slice  [3]*int
+    filter pathExpr
+}
+
+type jsonPathSegments []jsonPathSegment
+
+func (segments jsonPathSegments) query(value, root interface{}) JSONArray {
+    nodes := JSONArray{value}
+    for _, seg := range segments {
+        var next JSONArray
+        for _, node := range nodes {
+            if seg.descendant {
+                for _, d := range descendants(node, nil) {
+                    next = seg.apply(next, d, root)
+                }
+            } else {
+                next = seg.apply(next, node, root)
+            }
+        }
+        nodes = next
+    }
+    if nodes == nil {
+        nodes = make(JSONArray, 0)
+    }
+    return nodes
+}
+
+// descendants appends value and every value within it to list.
+func descendants(value interface{}, list JSONArray) JSONArray {
+    list = append(list, value)
+    if obj, ok := jsonObjectValue(value); ok {
+        for _, k := range orderedJSONObjectKeys(value, obj) {
+            list = descendants(obj[k], list)
+        }
+    } else if arr, ok := jsonArrayValue(value); ok {
+        for _, item := range arr {
+            list = descendants(item, list)
+        }
+    }
+    return list
+}
+
+func (seg *jsonPathSegment) apply(out JSONArray, value, root interface{}) JSONArray {
+    obj, isObject := jsonObjectValue(value)
+    arr, isArray := jsonArrayValue(value)
+    for _, sel := range seg.selectors {
+        switch sel.kind {
+        case 'n':
+            if child, ok := obj[sel.name]; ok && isObject {
+                out = append(out, child)
+            }
+        case 'i':
+            i := sel.index
+            if i < 0 {
+                i += len(arr)
+            }
+            if isArray && i >= 0 && i < len(arr) {
+                out = append(out, arr[i])
+            }
+        case 's':
+            if isArray {
+                for _, i := range sliceIndices(sel.slice, len(arr)) {
+                    out = append(out, arr[i])
+                }
+            }
+        case '*', '?':
+            var children JSONArray
+            if isObject {
+                for _, k := range orderedJSONObjectKeys(value, obj) {
+                    children = append(children, obj[k])
+                }
+            } else if isArray {
+                children = arr
+            }
+            for _, child := range children {
+                if sel.kind == '*' || truthy(sel.filter.eval(child, root)) {
+                    out = append(out, child)
+                }
+            }
+        }
+    }
+    return out
+}
+
+// sliceIndices returns the indices an array of length n selected by the
+// slice [start:end:step], as in Python.
+func sliceIndices(slice [3]*int, n int) []int {
+    step := 1
+    if slice[2] != nil {
+        step = *slice[2]
+    }
+    if step == 0 {
+        return nil
+    }
+    bound := func(p *int, def int) int {
+        if p == nil {
+            return def
+        }
+        i := *p
+        if i < 0 {
+            i += n
+        }
+        if step > 0 {
+            return clampInt(i, 0, n)
+        }
+        return clampInt(i, -1, n-1)
+    }
+    var indices []int
+    if step > 0 {
+        for i, end := bound(slice[0], 0), bound(slice[1], n); i < end; i += step {
+            indices = append(indices, i)
+        }
+    } else {
+        for i, end := bound(slice[0], n-1), bound(slice[1], -1); i > end; i += step {
+            indices = append(indices, i)
+        }
+    }
+    return indices
+}
+
+func clampInt(i, lo, hi int) int {
+    if i < lo {
+        return lo
+    }
+    if i > hi {
+        return hi
+    }
+    return i
+}
+
+// pathExpr is a node of a filter expression.
+type pathExpr interface {
+    eval(current, root interface{}) interface{}
+}
+
+// pathLiteral is a constant in a filter.
+type pathLiteral struct {
+    value interface{}
+}
+
+func (e *pathLiteral) eval(current, root interface{}) interface{} {
+    return e.value
+}
+
+// pathNothing is the result of a relative path selecting nothing.
+type pathNothing struct{}
+
+// pathQuery is a path relative to @ or $ in a filter.
+type pathQuery struct {
+    relative bool
+    segments jsonPathSegments
+}
+
+func (e *pathQuery) eval(current, root interface{}) interface{} {
+    start := root
+    if e.relative {
+        start = current
+    }
+    nodes := e.segments.query(start, root)
+    if len(nodes) == 0 {
+        return pathNothing{}
+    }
+    return nodes[0]
+}
+
+type pathNot struct {
+    x pathExpr
+}
+
+func (e *pathNot) eval(current, root interface{}) interface{} {
+    return !truthy(e.x.eval(current, root))
+}
+
+type pathBinary struct {
+    op   string
+    x, y pathExpr
+}
+
+func (e *pathBinary) eval(current, root interface{}) interface{} {
+    switch e.op {
+    case "&&":
+        return truthy(e.x.eval(current, root)) && truthy(e.y.eval(current, root))
+    case "||":
+        return truthy(e.x.eval(current, root)) || truthy(e.y.eval(current, root))
+    }
+    x, y := e.x.eval(current, root), e.y.eval(current, root)
+    if _, ok := x.(pathNothing); ok {
+        return false
+    }
+    if _, ok := y.(pathNothing); ok {
+        return false
+    }
+    switch e.op {
+    case "==":
+        return EqualJSONValues(x, y)
+    case "!=":
+        return !EqualJSONValues(x, y)
+    }
+    var cmp int
+    if fx, ok := jsonNumberValue(x); ok {
+        fy, ok := jsonNumberValue(y)
+        if !ok {
+            return false
+        }
+        switch {
+        case fx < fy:
+            cmp = -1
+        case fx > fy:
+            cmp = 1
+        }
+    } else if sx, ok := x.(string); ok {
+        sy, ok := y.(string)
+        if !ok {
+            return false
+        }
+        cmp = strings.Compare(sx, sy)
+    } else {
+        return false
+    }
+    switch e.op {
+    case "<":
+        return cmp < 0
+    case "<=":
+        return cmp <= 0
+    case ">":
+        return cmp > 0
+    case ">=":
+        return cmp >= 0
+    }
+    return false
+}
+
+// truthy reports whether a filter result selects a value: a path that
+// selected something, or true.
+func truthy(value interface{}) bool {
+    switch v := value.(type) {
+    case pathNothing:
+        return false
+    case bool:
+        return v
+    }
+    return true
+}
+
+// pathParser parses a JSONPath expression.
+type pathParser struct {
+    s   string
+    pos int
+}
+
+func (p *pathParser) fail(format string, args ...interface{}) error {
+    return fmt.Errorf("jsonhelper: JSONPath %q at offset %d: %s", p.s, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *pathParser) parse() (jsonPathSegments, error) {
+    p.space()
+    if !p.consume("$") {
+        return nil, p.fail("must start with $")
+    }
+    segments, err := p.segments()
+    if err != nil {
+        return nil, err
+    }
+    p.space()
+    if p.pos < len(p.s) {
+        return nil, p.fail("unexpected %q", p.s[p.pos:])
+    }
+    return segments, nil
+}
+
+// segments parses the segments following $ or @.
+func (p *pathParser) segments() (jsonPathSegments, error) {
+    var segments jsonPathSegments
+    for p.pos < len(p.s) {
+        var seg jsonPathSegment
+        switch {
+        case p.consume(".."):
+            seg.descendant = true
+            if p.peek() != '[' {
+                sel, err := p.dotSelector()
+                if err != nil {
+                    return nil, err
+                }
+                seg.selectors = []jsonPathSelector{sel}
+                break
+            }
+            fallthrough
+        case p.peek() == '[':
+            p.pos++
+            sels, err := p.bracketSelectors()
+            if err != nil {
+                return nil, err
+            }
+            seg.selectors = sels
+        case p.consume("."):
+            sel, err := p.dotSelector()
+            if err != nil {
+                return nil, err
+            }
+            seg.selectors = []jsonPathSelector{sel}
+        default:
+            return segments, nil
+        }
+        segments = append(segments, seg)
+    }
+    return segments, nil
+}
+
+func (p *pathParser) dotSelector() (jsonPathSelector, error) {
+    if p.consume("*") {
+        return jsonPathSelector{kind: '*'}, nil
+    }
+    start := p.pos
+    for p.pos < len(p.s) && isPathNameChar(p.s[p.pos]) {
+        p.pos++
+    }
+    if start == p.pos {
+        return jsonPathSelector{}, p.fail("expected a member name")
+    }
+    return jsonPathSelector{kind: 'n', name: p.s[start:p.pos]}, nil
+}
+
+func isPathNameChar(c byte) bool {
+    return c == '_' || c == '-' || c == '$' || c >= 0x80 ||
+        (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// bracketSelectors parses the comma separated selectors after [.
+func (p *pathParser) bracketSelectors() ([]jsonPathSelector, error) {
+    var sels []jsonPathSelector
+    for {
+        p.space()
+        var sel jsonPathSelector
+        switch c := p.peek(); {
+        case c == '*':
+            p.pos++
+            sel.kind = '*'
+        case c == '\'' || c == '"':
+            name, err := p.quoted()
+            if err != nil {
+                return nil, err
+            }
+            sel = jsonPathSelector{kind: 'n', name: name}
+        case c == '?':
+            p.pos++
+            filter, err := p.filter()
+            if err != nil {
+                return nil, err
+            }
+            sel = jsonPathSelector{kind: '?', filter: filter}
+        default:
+            var err error
+            if sel, err = p.indexOrSlice(); err != nil {
+                return nil, err
+            }
+        }
+        sels = append(sels, sel)
+        p.space()
+        if p.consume("]") {
+            return sels, nil
+        }
+        if !p.consume(",") {
+            return nil, p.fail("expected , or ]")
+        }
+    }
+}
+
+func (p *pathParser) indexOrSlice() (jsonPathSelector, error) {
+    var parts [3]*int
+    n := 0
+    for {
+        p.space()
+        if c := p.peek(); c == '-' || (c >= '0' && c <= '9') {
+            i, err := p.integer()
+            if err != nil {
+                return jsonPathSelector{}, err
+            }
+            parts[n] = &i
+        }
+        p.space()
+        if n == 2 || !p.consume(":") {
+            break
+        }
+        n++
+    }
+    if n == 0 {
+        if parts[0] == nil {
+            return jsonPathSelector{}, p.fail("expected a selector")
+        }
+        return jsonPathSelector{kind: 'i', index: *parts[0]}, nil
+    }
+    return jsonPathSelector{kind: 's', slice: parts}, nil
+}
+
+func (p *pathParser) integer() (int, error) {
+    start := p.pos
+    p.consume("-")
+    for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+        p.pos++
+    }
+    i, err := strconv.Atoi(p.s[start:p.pos])
+    if err != nil {
+        p.pos = start
+        return 0, p.fail("invalid integer")
+    }
+    return i, nil
+}
+
+// quoted parses a string in single or double quotes.
+func (p *pathParser) quoted() (string, error) {
+    quote := p.s[p.pos]
+    start := p.pos
+    p.pos++
+    var b strings.Builder
+    for p.pos < len(p.s) {
+        c := p.s[p.pos]
+        p.pos++
+        switch {
+        case c == quote:
+            return b.String(), nil
+        case c == '\\' && p.pos < len(p.s):
+            c = p.s[p.pos]
+            p.pos++
+            switch c {
+            case 'n':
+                b.WriteByte('\n')
+            case 't':
+                b.WriteByte('\t')
+            case 'r':
+                b.WriteByte('\r')
+            case 'u':
+                if p.pos+4 > len(p.s) {
+                    return "", p.fail("invalid \\u escape")
+                }
+                r, err := strconv.ParseUint(p.s[p.pos:p.pos+4], 16, 32)
+                if err != nil {
+                    return "", p.fail("invalid \\u escape")
+                }
+                b.WriteRune(rune(r))
+                p.pos += 4
+            default:
+                b.WriteByte(c)
+            }
+        default:
+            b.WriteByte(c)
+        }
+    }
+    p.pos = start
+    return "", p.fail("unterminated string")
+}
+
+// filter parses the expression after ?, with or without parentheses.
+func (p *pathParser) filter() (pathExpr, error) {
+    return p.or()
+}
+
+func (p *pathParser) or() (pathExpr, error) {
+    x, err := p.and()
+    if err != nil {
+        return nil, err
+    }
+    for p.space(); p.consume("||"); p.space() {
+        y, err := p.and()
+        if err != nil {
+            return nil, err
+        }
+        x = &pathBinary{op: "||", x: x, y: y}
+    }
+    return x, nil
+}
+
+func (p *pathParser) and() (pathExpr, error) {
+    x, err := p.comparison()
+    if err != nil {
+        return nil, err
+    }
+    for p.space(); p.consume("&&"); p.space() {
+        y, err := p.comparison()
+        if err != nil {
+            return nil, err
+        }
+        x = &pathBinary{op: "&&", x: x, y: y}
+    }
+    return x, nil
+}
+
+var pathComparisons = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func (p *pathParser) comparison() (pathExpr, error) {
+    x, err := p.unary()
+    if err != nil {
+        return nil, err
+    }
+    p.space()
+    for _, op := range pathComparisons {
+        if p.consume(op) {
+            y, err := p.unary()
+            if err != nil {
+                return nil, err
+            }
+            return &pathBinary{op: op, x: x, y: y}, nil
+        }
+    }
+    return x, nil
+}
+
+func (p *pathParser) unary() (pathExpr, error) {
+    p.space()
+    switch c := p.peek(); {
+    case c == '!':
+        p.pos++
+        x, err := p.unary()
+        if err != nil {
+            return nil, err
+        }
+        return &pathNot{x}, nil
+    case c == '(':
+        p.pos++
+        x, err := p.or()
+        if err != nil {
+            return nil, err
+        }
+        p.space()
+        if !p.consume(")") {
+            return nil, p.fail("expected )")
+        }
+        return x, nil
+    case c == '@' || c == '$':
+        p.pos++
+        segments, err := p.segments()
+        if err != nil {
+            return nil, err
+        }
+        return &pathQuery{relative: c == '@', segments: segments}, nil
+    case c == '\'' || c == '"':
+        s, err := p.quoted()
+        if err != nil {
+            return nil, err
+        }
+        return &pathLiteral{s}, nil
+    case c == '-' || (c >= '0' && c <= '9'):
+        start := p.pos
+        for p.pos < len(p.s) && strings.IndexByte("+-.eE0123456789", p.s[p.pos]) >= 0 {
+            p.pos++
+        }
+        text := p.s[start:p.pos]
+        f, err := strconv.ParseFloat(text, 64)
+        if err != nil {
+            p.pos = start
+            return nil, p.fail("invalid number")
+        }
+        // Integers are kept exact, so that == compares them with integers
+        // beyond the precision of a float64.
+        if _, ok := jsonIntegerText(json.Number(text)); ok {
+            return &pathLiteral{json.Number(text)}, nil
+        }
+        return &pathLiteral{f}, nil
+    }
+    for _, lit := range []struct {
+        word  string
+        value interface{}
+    }{{"true", true}, {"false", false}, {"null", nil}} {
+        if p.consume(lit.word) {
+            return &pathLiteral{lit.value}, nil
+        }
+    }
+    return nil, p.fail("expected a filter expression")
+}
+
+func (p *pathParser) peek() byte {
+    if p.pos < len(p.s) {
+        return p.s[p.pos]
+    }
+    return 0
+}
+
+func (p *pathParser) consume(s string) bool {
+    if strings.HasPrefix(p.s[p.pos:], s) {
+        p.pos += len(s)
+        return true
+    }
+    return false
+}
+
+func (p *pathParser) space() {
+    for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+        p.pos++
+    }
+}
@@ -0,0 +1,113 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "strings"
+    "testing"
+)
+
+const bookstoreSample = `{"store": {
+  "book": [
+    {"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+    {"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+    {"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+    {"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+  ],
+  "bicycle": {"color": "red", "price": 19.95}
+}}`
+
+func TestQueryJSONPath(t *testing.T) {
+    doc, err := Parse([]byte(bookstoreSample), ParseOptions{Ordered: true})
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        path string
+        want string
+    }{
+        {"$", "[" + bookstoreSample + "]"},
+        {"$.store.book[*].author", `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`},
+        {"$..author", `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`},
+        {"$.store.*.color", `["red"]`},
+        {"$['store']['bicycle']['color']", `["red"]`},
+        {`$["store"].bicycle.price`, `[19.95]`},
+        {"$..book[2].title", `["Moby Dick"]`},
+        {"$..book[-1].title", `["The Lord of the Rings"]`},
+        {"$..book[0,1].title", `["Sayings of the Century","Sword of Honour"]`},
+        {"$..book[:2].title", `["Sayings of the Century","Sword of Honour"]`},
+        {"$..book[::-1].price", `[22.99,8.99,12.99,8.95]`},
+        {"$..book[1:4:2].price", `[12.99,22.99]`},
+        {"$..book[::0].price", `[]`},
+        {"$..book[9].title", `[]`},
+        {"$..book[?(@.isbn)].title", `["Moby Dick","The Lord of the Rings"]`},
+        {"$..book[?(!@.isbn)].title", `["Sayings of the Century","Sword of Honour"]`},
+        {"$..book[?(@.price < 10)].title", `["Sayings of the Century","Moby Dick"]`},
+        {"$..book[?(@.category == 'fiction' && @.price > 20)].title", `["The Lord of the Rings"]`},
+        {"$..book[?(@.price > 20 || @.author == \"Nigel Rees\")].title", `["Sayings of the Century","The Lord of the Rings"]`},
+        {"$.store.missing", `[]`},
+        {"$['\\u0073tore'].bicycle.color", `["red"]`},
+    }
+    for _, tt := range tests {
+        got, err := QueryJSONPath(doc, tt.path)
+        if err != nil {
+            t.Errorf("QueryJSONPath(%q): %v", tt.path, err)
+        } else if got == nil || !EqualJSONValues(got, mustParse(t, tt.want)) {
+            t.Errorf("QueryJSONPath(%q) = %#v, want %s", tt.path, got, tt.want)
+        }
+    }
+}
+
+func TestQueryJSONPathNumbers(t *testing.T) {
+    doc, err := Parse([]byte(`{"n":[12345678901234567890,12345678901234567891,1.5,-3,0]}`), ParseOptions{UseNumber: true})
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        path string
+        want string
+    }{
+        {"$.n[?(@ == 12345678901234567891)]", `[12345678901234567891]`},
+        {"$.n[?(@ != 12345678901234567890)]", `[12345678901234567891,1.5,-3,0]`},
+        {"$.n[?(@ >= 1e19)]", `[12345678901234567890,12345678901234567891]`},
+        {"$.n[?(@ == 1.5)]", `[1.5]`},
+        {"$.n[?(@ == -3)]", `[-3]`},
+        {"$.n[?(@ < -0)]", `[-3]`},
+        {"$.n[?(@ == 0.0)]", `[0]`},
+    }
+    for _, tt := range tests {
+        got, err := QueryJSONPath(doc, tt.path)
+        if err != nil {
+            t.Errorf("QueryJSONPath(%q): %v", tt.path, err)
+        } else if want, _ := Parse([]byte(tt.want), ParseOptions{UseNumber: true}); got == nil || !EqualJSONValues(got, want) {
+            t.Errorf("QueryJSONPath(%q) = %v, want %s", tt.path, got, tt.want)
+        }
+    }
+}
+
+func TestQueryJSONPathErrors(t *testing.T) {
+    tests := []struct {
+        path string
+        want string
+    }{
+        {"", "must start with $"},
+        {"store", "must start with $"},
+        {"$.", "expected a member name"},
+        {"$[", "expected a selector"},
+        {"$['a'", "expected , or ]"},
+        {"$[1:x]", "expected , or ]"},
+        {"$[?(@.a ==)]", "expected a filter expression"},
+        {"$[?(@.a]", "expected )"},
+        {"$ x", `unexpected "x"`},
+        {"$['\\u12']", "invalid \\u escape"},
+        {"$[-]", "invalid integer"},
+    }
+    for _, tt := range tests {
+        _, err := QueryJSONPath(JSONObject{}, tt.path)
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("QueryJSONPath(%q) error = %v, want %q", tt.path, err, tt.want)
+        }
+    }
+}
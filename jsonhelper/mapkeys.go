@@ -0,0 +1,93 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding"
+    "encoding/json"
+    "reflect"
+    "strconv"
+)
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// isSupportedMapKey reports whether a map with keys of type t can become a
+// JSONObject: string kinds, integers and encoding.TextMarshaler types.
+func isSupportedMapKey(t reflect.Type) bool {
+    switch t.Kind() {
+    case reflect.String,
+        reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return true
+    }
+    return t.Implements(textMarshalerType)
+}
+
+// mapKeyString converts a map key into a JSONObject key, in the same order
+// of preference as encoding/json.
+func mapKeyString(k reflect.Value) (string, error) {
+    if k.Kind() == reflect.String {
+        return k.String(), nil
+    }
+    if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+        if k.Kind() == reflect.Ptr && k.IsNil() {
+            return "", nil
+        }
+        b, err := tm.MarshalText()
+        return string(b), err
+    }
+    switch k.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return strconv.FormatInt(k.Int(), 10), nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return strconv.FormatUint(k.Uint(), 10), nil
+    }
+    return "", &json.UnsupportedTypeError{Type: k.Type()}
+}
+
+// parseMapKey is the inverse of mapKeyString, producing a key of type t.
+func parseMapKey(s string, t reflect.Type) (reflect.Value, error) {
+    if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+        kv := reflect.New(t)
+        if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+            return reflect.Value{}, err
+        }
+        return kv.Elem(), nil
+    }
+    kv := reflect.New(t).Elem()
+    switch t.Kind() {
+    case reflect.String:
+        kv.SetString(s)
+        return kv, nil
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        n, err := strconv.ParseInt(s, 10, 64)
+        if err != nil || kv.OverflowInt(n) {
+            return reflect.Value{}, &json.UnmarshalTypeError{Value: "number " + s, Type: t}
+        }
+        kv.SetInt(n)
+        return kv, nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        n, err := strconv.ParseUint(s, 10, 64)
+        if err != nil || kv.OverflowUint(n) {
+            return reflect.Value{}, &json.UnmarshalTypeError{Value: "number " + s, Type: t}
+        }
+        kv.SetUint(n)
+        return kv, nil
+    }
+    return reflect.Value{}, &json.UnsupportedTypeError{Type: t}
+}
+
+// mapKey is a map key along with the JSONObject key it is written as.
+type mapKey struct {
+    s string
+    v reflect.Value
+}
+
+// mapKeys implements the methods to sort map keys by their string form.
+type mapKeys []mapKey
+
+func (mk mapKeys) Len() int           { return len(mk) }
+func (mk mapKeys) Swap(i, j int)      { mk[i], mk[j] = mk[j], mk[i] }
+func (mk mapKeys) Less(i, j int) bool { return mk[i].s < mk[j].s }
@@ -0,0 +1,84 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "errors"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+type pointKey struct{ X, Y int }
+
+func (p pointKey) MarshalText() ([]byte, error) {
+    if p.X < 0 {
+        return nil, errors.New("negative point")
+    }
+    return []byte(strings.Repeat("x", p.X) + "," + strings.Repeat("y", p.Y)), nil
+}
+
+func (p *pointKey) UnmarshalText(b []byte) error {
+    parts := strings.Split(string(b), ",")
+    if len(parts) != 2 {
+        return errors.New("bad point")
+    }
+    p.X, p.Y = len(parts[0]), len(parts[1])
+    return nil
+}
+
+type namedKey string
+
+func TestMarshalMapKeys(t *testing.T) {
+    tests := []struct {
+        in   interface{}
+        want JSONObject
+    }{
+        {map[int]string{-1: "a", 2: "b"}, JSONObject{"-1": "a", "2": "b"}},
+        {map[uint8]bool{255: true}, JSONObject{"255": true}},
+        {map[namedKey]int{"k": 1}, JSONObject{"k": int64(1)}},
+        {map[pointKey]int{{1, 2}: 3}, JSONObject{"x,yy": int64(3)}},
+    }
+    for _, tt := range tests {
+        got, err := Marshal(tt.in)
+        if err != nil {
+            t.Errorf("%T: %v", tt.in, err)
+            continue
+        }
+        if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%T: got %#v, want %#v", tt.in, got, tt.want)
+            continue
+        }
+        out := reflect.New(reflect.TypeOf(tt.in))
+        if err := Unmarshal(got, out.Interface()); err != nil {
+            t.Errorf("%T: unmarshal: %v", tt.in, err)
+        } else if !reflect.DeepEqual(out.Elem().Interface(), tt.in) {
+            t.Errorf("%T: round trip gave %#v", tt.in, out.Elem().Interface())
+        }
+    }
+}
+
+func TestMapKeyErrors(t *testing.T) {
+    if _, err := Marshal(map[float64]int{1.5: 1}); err == nil {
+        t.Error("float keys succeeded")
+    }
+    if _, err := Marshal(map[pointKey]int{{-1, 0}: 1}); err == nil {
+        t.Error("failing MarshalText succeeded")
+    }
+    tests := []struct {
+        obj JSONObject
+        v   interface{}
+    }{
+        {JSONObject{"300": 1}, new(map[int8]int)},
+        {JSONObject{"-1": 1}, new(map[uint]int)},
+        {JSONObject{"x": 1}, new(map[int]int)},
+        {JSONObject{"nocomma": 1}, new(map[pointKey]int)},
+    }
+    for _, tt := range tests {
+        if err := Unmarshal(tt.obj, tt.v); err == nil {
+            t.Errorf("Unmarshal(%v) into %T succeeded", tt.obj, tt.v)
+        }
+    }
+}
@@ -0,0 +1,395 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math"
+    "time"
+)
+
+// MsgpackExt is a MessagePack extension value of a type this package does
+// not interpret. It is returned by the decoder and written back unchanged
+// by the encoder.
+type MsgpackExt struct {
+    Type int8
+    Data []byte
+}
+
+// msgpackTimestamp is the extension type of MessagePack timestamps.
+const msgpackTimestamp = -1
+
+// maxMsgpackDepth bounds the nesting of arrays and maps the decoder accepts.
+const maxMsgpackDepth = 10000
+
+// EncodeMsgpack encodes value as MessagePack. It accepts the values this
+// package produces: nil, bools, strings, []byte, integers, floats,
+// time.Time, json.Number, MsgpackExt, and JSONObject and JSONArray values
+// holding them, along with their map[string]interface{} and []interface{}
+// forms. Integers use the smallest encoding that holds them, and times use
+// the timestamp extension.
+func EncodeMsgpack(value interface{}) ([]byte, error) {
+    var buf bytes.Buffer
+    if err := WriteMsgpack(&buf, value); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+// WriteMsgpack writes value to w as MessagePack, as EncodeMsgpack does.
+func WriteMsgpack(w io.Writer, value interface{}) error {
+    bw := bufio.NewWriter(w)
+    if err := writeMsgpackValue(bw, value); err != nil {
+        return err
+    }
+    return bw.Flush()
+}
+
+func writeMsgpackValue(w *bufio.Writer, value interface{}) error {
+    switch v := value.(type) {
+    case nil:
+        w.WriteByte(0xc0)
+    case bool:
+        if v {
+            w.WriteByte(0xc3)
+        } else {
+            w.WriteByte(0xc2)
+        }
+    case string:
+        writeMsgpackLength(w, len(v), 0xa0, 31, [3]byte{0xd9, 0xda, 0xdb})
+        w.WriteString(v)
+    case []byte:
+        writeMsgpackLength(w, len(v), 0, -1, [3]byte{0xc4, 0xc5, 0xc6})
+        w.Write(v)
+    case int:
+        writeMsgpackInt(w, int64(v))
+    case int8:
+        writeMsgpackInt(w, int64(v))
+    case int16:
+        writeMsgpackInt(w, int64(v))
+    case int32:
+        writeMsgpackInt(w, int64(v))
+    case int64:
+        writeMsgpackInt(w, v)
+    case uint:
+        writeMsgpackUint(w, uint64(v))
+    case uint8:
+        writeMsgpackUint(w, uint64(v))
+    case uint16:
+        writeMsgpackUint(w, uint64(v))
+    case uint32:
+        writeMsgpackUint(w, uint64(v))
+    case uint64:
+        writeMsgpackUint(w, v)
+    case float32:
+        w.WriteByte(0xca)
+        binary.Write(w, binary.BigEndian, math.Float32bits(v))
+    case float64:
+        w.WriteByte(0xcb)
+        binary.Write(w, binary.BigEndian, math.Float64bits(v))
+    case json.Number:
+        if n, err := v.Int64(); err == nil {
+            writeMsgpackInt(w, n)
+        } else if f, err := v.Float64(); err == nil {
+            return writeMsgpackValue(w, f)
+        } else {
+            return fmt.Errorf("jsonhelper: cannot encode number %q as MessagePack", string(v))
+        }
+    case time.Time:
+        writeMsgpackTime(w, v)
+    case MsgpackExt:
+        writeMsgpackExt(w, v.Type, v.Data)
+    case JSONObject:
+        return writeMsgpackMap(w, v)
+    case *OrderedJSONObject:
+        if v == nil {
+            w.WriteByte(0xc0)
+            break
+        }
+        return writeMsgpackOrderedMap(w, v)
+    case map[string]interface{}:
+        return writeMsgpackMap(w, v)
+    case JSONArray:
+        return writeMsgpackArray(w, v)
+    case []interface{}:
+        return writeMsgpackArray(w, v)
+    default:
+        return fmt.Errorf("jsonhelper: cannot encode %T as MessagePack", value)
+    }
+    return nil
+}
+
+// writeMsgpackLength writes the header of a value of n elements or bytes:
+// fix|n when n <= fixMax, or else one of codes followed by an 8, 16 or 32
+// bit length. A zero code marks a form the format does not have.
+func writeMsgpackLength(w *bufio.Writer, n int, fix byte, fixMax int, codes [3]byte) {
+    switch {
+    case n <= fixMax:
+        w.WriteByte(fix | byte(n))
+    case n <= math.MaxUint8 && codes[0] != 0:
+        w.WriteByte(codes[0])
+        w.WriteByte(byte(n))
+    case n <= math.MaxUint16:
+        w.WriteByte(codes[1])
+        binary.Write(w, binary.BigEndian, uint16(n))
+    default:
+        w.WriteByte(codes[2])
+        binary.Write(w, binary.BigEndian, uint32(n))
+    }
+}
+
+func writeMsgpackInt(w *bufio.Writer, n int64) {
+    switch {
+    case n >= 0:
+        writeMsgpackUint(w, uint64(n))
+    case n >= -32:
+        w.WriteByte(byte(n))
+    case n >= math.MinInt8:
+        w.WriteByte(0xd0)
+        w.WriteByte(byte(n))
+    case n >= math.MinInt16:
+        w.WriteByte(0xd1)
+        binary.Write(w, binary.BigEndian, int16(n))
+    case n >= math.MinInt32:
+        w.WriteByte(0xd2)
+        binary.Write(w, binary.BigEndian, int32(n))
+    default:
+        w.WriteByte(0xd3)
+        binary.Write(w, binary.BigEndian, n)
+    }
+}
+
+func writeMsgpackUint(w *bufio.Writer, n uint64) {
+    switch {
+    case n <= 0x7f:
+        w.WriteByte(byte(n))
+    case n <= math.MaxUint8:
+        w.WriteByte(0xcc)
+        w.WriteByte(byte(n))
+    case n <= math.MaxUint16:
+        w.WriteByte(0xcd)
+        binary.Write(w, binary.BigEndian, uint16(n))
+    case n <= math.MaxUint32:
+        w.WriteByte(0xce)
+        binary.Write(w, binary.BigEndian, uint32(n))
+    default:
+        w.WriteByte(0xcf)
+        binary.Write(w, binary.BigEndian, n)
+    }
+}
+
+func writeMsgpackExt(w *bufio.Writer, typ int8, data []byte) {
+    switch len(data) {
+    case 1:
+        w.WriteByte(0xd4)
+    case 2:
+        w.WriteByte(0xd5)
+    case 4:
+        w.WriteByte(0xd6)
+    case 8:
+        w.WriteByte(0xd7)
+    case 16:
+        w.WriteByte(0xd8)
+    default:
+        writeMsgpackLength(w, len(data), 0, -1, [3]byte{0xc7, 0xc8, 0xc9})
+    }
+    w.WriteByte(byte(typ))
+    w.Write(data)
+}
+
+// writeMsgpackTime writes t with the 32, 64 or 96 bit timestamp format,
+// whichever is the smallest that holds it.
+func writeMsgpackTime(w *bufio.Writer, t time.Time) {
+    sec, nsec := t.Unix(), int64(t.Nanosecond())
+    var data []byte
+    switch {
+    case sec>>34 == 0 && nsec == 0 && sec <= math.MaxUint32:
+        data = make([]byte, 4)
+        binary.BigEndian.PutUint32(data, uint32(sec))
+    case sec>>34 == 0:
+        data = make([]byte, 8)
+        binary.BigEndian.PutUint64(data, uint64(nsec)<<34|uint64(sec))
+    default:
+        data = make([]byte, 12)
+        binary.BigEndian.PutUint32(data, uint32(nsec))
+        binary.BigEndian.PutUint64(data[4:], uint64(sec))
+    }
+    writeMsgpackExt(w, msgpackTimestamp, data)
+}
+
+func writeMsgpackMap(w *bufio.Writer, m map[string]interface{}) error {
+    writeMsgpackLength(w, len(m), 0x80, 15, [3]byte{0, 0xde, 0xdf})
+    for k, v := range m {
+        writeMsgpackValue(w, k)
+        if err := writeMsgpackValue(w, v); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// writeMsgpackOrderedMap writes the members of p in their order.
+func writeMsgpackOrderedMap(w *bufio.Writer, p *OrderedJSONObject) error {
+    writeMsgpackLength(w, len(p.keys), 0x80, 15, [3]byte{0, 0xde, 0xdf})
+    for _, k := range p.keys {
+        writeMsgpackValue(w, k)
+        if err := writeMsgpackValue(w, p.values[k]); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func writeMsgpackArray(w *bufio.Writer, arr []interface{}) error {
+    writeMsgpackLength(w, len(arr), 0x90, 15, [3]byte{0, 0xdc, 0xdd})
+    for _, item := range arr {
+        if err := writeMsgpackValue(w, item); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// DecodeMsgpack decodes the single MessagePack value in data. Maps become
+// JSONObject values and must have string keys, arrays become JSONArray
+// values, integers become int64, or uint64 when too large for int64,
+// floats become float64, binary data becomes []byte and timestamps become
+// UTC time.Time values. Other extension types are returned as MsgpackExt.
+func DecodeMsgpack(data []byte) (interface{}, error) {
+    r := bytes.NewReader(data)
+    value, err := ReadMsgpack(r)
+    if err != nil {
+        return nil, err
+    }
+    if r.Len() > 0 {
+        return nil, fmt.Errorf("jsonhelper: %d bytes of data after MessagePack value", r.Len())
+    }
+    return value, nil
+}
+
+// ReadMsgpack reads one MessagePack value from r, as DecodeMsgpack does.
+// Unless r is an io.ByteReader it is buffered, and may be read past the
+// end of the value.
+func ReadMsgpack(r io.Reader) (value interface{}, err error) {
+    d := &msgpackDecoder{newBinaryReader(r)}
+    defer recoverBinaryError(&err)
+    return d.value(0), nil
+}
+
+type msgpackDecoder struct {
+    binaryReader
+}
+
+func (d *msgpackDecoder) value(depth int) interface{} {
+    c := d.byte()
+    switch {
+    case c <= 0x7f:
+        return int64(c)
+    case c >= 0xe0:
+        return int64(int8(c))
+    case c&0xf0 == 0x80:
+        return d.mapValue(uint64(c&0x0f), depth)
+    case c&0xf0 == 0x90:
+        return d.arrayValue(uint64(c&0x0f), depth)
+    case c&0xe0 == 0xa0:
+        return string(d.bytes(uint64(c & 0x1f)))
+    }
+    switch c {
+    case 0xc0:
+        return nil
+    case 0xc2:
+        return false
+    case 0xc3:
+        return true
+    case 0xc4, 0xc5, 0xc6:
+        return d.bytes(d.uint(1 << (c - 0xc4)))
+    case 0xc7, 0xc8, 0xc9:
+        return d.ext(d.uint(1 << (c - 0xc7)))
+    case 0xca:
+        return float64(math.Float32frombits(uint32(d.uint(4))))
+    case 0xcb:
+        return math.Float64frombits(d.uint(8))
+    case 0xcc, 0xcd, 0xce, 0xcf:
+        n := d.uint(1 << (c - 0xcc))
+        if n > math.MaxInt64 {
+            return n
+        }
+        return int64(n)
+    case 0xd0:
+        return int64(int8(d.uint(1)))
+    case 0xd1:
+        return int64(int16(d.uint(2)))
+    case 0xd2:
+        return int64(int32(d.uint(4)))
+    case 0xd3:
+        return int64(d.uint(8))
+    case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+        return d.ext(1 << (c - 0xd4))
+    case 0xd9, 0xda, 0xdb:
+        return string(d.bytes(d.uint(1 << (c - 0xd9))))
+    case 0xdc, 0xdd:
+        return d.arrayValue(d.uint(2<<(c-0xdc)), depth)
+    case 0xde, 0xdf:
+        return d.mapValue(d.uint(2<<(c-0xde)), depth)
+    }
+    d.error(fmt.Errorf("jsonhelper: invalid MessagePack code 0x%02x", c))
+    return nil
+}
+
+func (d *msgpackDecoder) arrayValue(n uint64, depth int) JSONArray {
+    if depth >= maxMsgpackDepth {
+        d.error(fmt.Errorf("jsonhelper: MessagePack value nested too deeply"))
+    }
+    arr := make([]interface{}, 0, minUint64(n, 1024))
+    for i := uint64(0); i < n; i++ {
+        arr = append(arr, d.value(depth+1))
+    }
+    return NewJSONArrayFromArray(arr)
+}
+
+func (d *msgpackDecoder) mapValue(n uint64, depth int) JSONObject {
+    if depth >= maxMsgpackDepth {
+        d.error(fmt.Errorf("jsonhelper: MessagePack value nested too deeply"))
+    }
+    obj := NewJSONObject()
+    for i := uint64(0); i < n; i++ {
+        k, ok := d.value(depth + 1).(string)
+        if !ok {
+            d.error(fmt.Errorf("jsonhelper: MessagePack map key is not a string"))
+        }
+        obj[k] = d.value(depth + 1)
+    }
+    return obj
+}
+
+func (d *msgpackDecoder) ext(n uint64) interface{} {
+    typ := int8(d.byte())
+    data := d.bytes(n)
+    if typ != msgpackTimestamp {
+        return MsgpackExt{Type: typ, Data: data}
+    }
+    var sec, nsec int64
+    switch len(data) {
+    case 4:
+        sec = int64(binary.BigEndian.Uint32(data))
+    case 8:
+        v := binary.BigEndian.Uint64(data)
+        sec, nsec = int64(v&(1<<34-1)), int64(v>>34)
+    case 12:
+        nsec = int64(binary.BigEndian.Uint32(data))
+        sec = int64(binary.BigEndian.Uint64(data[4:]))
+    default:
+        d.error(fmt.Errorf("jsonhelper: invalid MessagePack timestamp of %d bytes", len(data)))
+    }
+    if nsec >= 1e9 {
+        d.error(fmt.Errorf("jsonhelper: invalid MessagePack timestamp nanoseconds %d", nsec))
+    }
+    return time.Unix(sec, nsec).UTC()
+}
@@ -0,0 +1,194 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bytes"
+    "encoding/hex"
+    "encoding/json"
+    "io"
+    "math"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+    b, err := hex.DecodeString(strings.Replace(s, " ", "", -1))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return b
+}
+
+func TestEncodeMsgpack(t *testing.T) {
+    tests := []struct {
+        value interface{}
+        want  string
+    }{
+        {nil, "c0"},
+        {false, "c2"},
+        {true, "c3"},
+        {0, "00"},
+        {127, "7f"},
+        {128, "cc 80"},
+        {uint16(256), "cd 0100"},
+        {int64(1 << 16), "ce 00010000"},
+        {uint64(math.MaxUint64), "cf ffffffffffffffff"},
+        {-1, "ff"},
+        {-32, "e0"},
+        {-33, "d0 df"},
+        {-129, "d1 ff7f"},
+        {int32(math.MinInt16 - 1), "d2 ffff7fff"},
+        {int64(math.MinInt64), "d3 8000000000000000"},
+        {float32(1.5), "ca 3fc00000"},
+        {1.5, "cb 3ff8000000000000"},
+        {json.Number("-2"), "fe"},
+        {json.Number("2.5"), "cb 4004000000000000"},
+        {"", "a0"},
+        {"abc", "a3 616263"},
+        {strings.Repeat("x", 32), "d9 20" + strings.Repeat("78", 32)},
+        {[]byte{}, "c4 00"},
+        {[]byte{1, 2}, "c4 02 0102"},
+        {JSONArray{}, "90"},
+        {[]interface{}{1, "a"}, "92 01 a1 61"},
+        {JSONObject{"a": nil}, "81 a1 61 c0"},
+        {map[string]interface{}{}, "80"},
+        {MsgpackExt{Type: 5, Data: []byte{9}}, "d4 05 09"},
+        {MsgpackExt{Type: 5, Data: []byte{1, 2, 3}}, "c7 03 05 010203"},
+        {time.Unix(1, 0), "d6 ff 00000001"},
+        {time.Unix(1, 5), "d7 ff 0000001400000001"},
+        {time.Unix(-1, 0), "c7 0c ff 00000000 ffffffffffffffff"},
+    }
+    for _, tt := range tests {
+        got, err := EncodeMsgpack(tt.value)
+        if err != nil {
+            t.Errorf("EncodeMsgpack(%#v): %v", tt.value, err)
+        } else if want := mustHex(t, tt.want); !bytes.Equal(got, want) {
+            t.Errorf("EncodeMsgpack(%#v) = % x, want % x", tt.value, got, want)
+        }
+    }
+}
+
+func TestEncodeMsgpackLengths(t *testing.T) {
+    tests := []struct {
+        value  interface{}
+        prefix string
+    }{
+        {make(JSONArray, 15), "9f"},
+        {make(JSONArray, 16), "dc 0010"},
+        {make(JSONArray, 1<<16), "dd 00010000"},
+        {strings.Repeat("x", 256), "da 0100"},
+        {make([]byte, 1<<16), "c6 00010000"},
+    }
+    for _, tt := range tests {
+        got, err := EncodeMsgpack(tt.value)
+        if err != nil {
+            t.Errorf("%s: %v", tt.prefix, err)
+        } else if want := mustHex(t, tt.prefix); !bytes.HasPrefix(got, want) {
+            t.Errorf("encoding starts % x, want % x", got[:len(want)], want)
+        }
+    }
+}
+
+func TestEncodeMsgpackErrors(t *testing.T) {
+    for _, value := range []interface{}{
+        struct{}{},
+        JSONArray{complex(1, 2)},
+        JSONObject{"a": JSONObject{"b": []string{"x"}}},
+        json.Number("x"),
+    } {
+        if _, err := EncodeMsgpack(value); err == nil {
+            t.Errorf("EncodeMsgpack(%#v) succeeded", value)
+        }
+    }
+}
+
+func TestDecodeMsgpack(t *testing.T) {
+    tests := []struct {
+        in   string
+        want interface{}
+    }{
+        {"c0", nil},
+        {"c3", true},
+        {"05", int64(5)},
+        {"e0", int64(-32)},
+        {"cc ff", int64(255)},
+        {"cf 7fffffffffffffff", int64(math.MaxInt64)},
+        {"cf ffffffffffffffff", uint64(math.MaxUint64)},
+        {"d0 80", int64(-128)},
+        {"d3 ffffffffffffffff", int64(-1)},
+        {"ca 3fc00000", 1.5},
+        {"a3 616263", "abc"},
+        {"db 00000001 78", "x"},
+        {"c5 0001 ff", []byte{0xff}},
+        {"92 01 a1 61", JSONArray{int64(1), "a"}},
+        {"de 0001 a1 61 90", JSONObject{"a": JSONArray{}}},
+        {"d5 07 0102", MsgpackExt{Type: 7, Data: []byte{1, 2}}},
+        {"d6 ff 00000001", time.Unix(1, 0).UTC()},
+        {"d7 ff 0000001400000001", time.Unix(1, 5).UTC()},
+        {"c7 0c ff 00000000 ffffffffffffffff", time.Unix(-1, 0).UTC()},
+    }
+    for _, tt := range tests {
+        got, err := DecodeMsgpack(mustHex(t, tt.in))
+        if err != nil {
+            t.Errorf("DecodeMsgpack(%s): %v", tt.in, err)
+        } else if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("DecodeMsgpack(%s) = %#v, want %#v", tt.in, got, tt.want)
+        }
+    }
+}
+
+func TestDecodeMsgpackErrors(t *testing.T) {
+    tests := []struct {
+        in   string
+        want string
+    }{
+        {"", "unexpected EOF"},
+        {"a3 6162", "unexpected EOF"},
+        {"c6 ffffffff", "unexpected EOF"},
+        {"dd ffffffff", "unexpected EOF"},
+        {"c1", "invalid MessagePack code 0xc1"},
+        {"81 01 02", "map key is not a string"},
+        {"c0 c0", "1 bytes of data after"},
+        {"c7 03 ff 000000", "timestamp of 3 bytes"},
+        {"d6 ff", "unexpected EOF"},
+        {"c7 0c ff 3b9aca00 0000000000000000", "timestamp nanoseconds 1000000000"},
+        {strings.Repeat("91", maxMsgpackDepth+1) + "c0", "nested too deeply"},
+    }
+    for _, tt := range tests {
+        _, err := DecodeMsgpack(mustHex(t, tt.in))
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("DecodeMsgpack(%.20s) error = %v, want %q", tt.in, err, tt.want)
+        }
+    }
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+    in := mustParse(t, `{"s":"x","n":[1,-1,300,-300,70000,1e100,0.5],"o":{"b":true,"z":null},"e":[],"m":{}}`)
+    var buf bytes.Buffer
+    if err := WriteMsgpack(&buf, in); err != nil {
+        t.Fatal(err)
+    }
+    if err := WriteMsgpack(&buf, "next"); err != nil {
+        t.Fatal(err)
+    }
+    // A bytes.Reader is an io.ByteReader, so each read stops at the end of its value.
+    r := bytes.NewReader(buf.Bytes())
+    got, err := ReadMsgpack(r)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !EqualJSONValues(got, in) {
+        t.Errorf("round trip gave %v, want %v", got, in)
+    }
+    if next, err := ReadMsgpack(r); err != nil || next != "next" {
+        t.Errorf("second value = %v, %v", next, err)
+    }
+    if _, err := ReadMsgpack(r); err != io.ErrUnexpectedEOF {
+        t.Errorf("reading past the end gave %v", err)
+    }
+}
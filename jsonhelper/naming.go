@@ -0,0 +1,117 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "strings"
+    "unicode"
+)
+
+// Naming strategies for MarshalOptions.FieldNaming, UnmarshalOptions.FieldNaming
+// and RenameKeys. Words are split on '_', '-', spaces and case changes, so
+// "HTTPServerID" and "http_server_id" both become ["http", "server", "id"].
+var (
+    // SnakeCase produces "http_server_id".
+    SnakeCase NamingStrategy = func(name string) string { return joinWords(splitWords(name), "_", false) }
+    // KebabCase produces "http-server-id".
+    KebabCase NamingStrategy = func(name string) string { return joinWords(splitWords(name), "-", false) }
+    // CamelCase produces "httpServerId".
+    CamelCase NamingStrategy = func(name string) string { return joinWords(splitWords(name), "", true) }
+    // LowerCase produces "httpserverid".
+    LowerCase NamingStrategy = func(name string) string { return strings.ToLower(name) }
+)
+
+// splitWords breaks an identifier into its words.
+func splitWords(name string) []string {
+    var words []string
+    runes := []rune(name)
+    start := 0
+    flush := func(end int) {
+        if end > start {
+            words = append(words, string(runes[start:end]))
+        }
+        start = end
+    }
+    for i := 0; i < len(runes); i++ {
+        r := runes[i]
+        if r == '_' || r == '-' || unicode.IsSpace(r) || r == '.' {
+            flush(i)
+            start = i + 1
+            continue
+        }
+        if i == start {
+            continue
+        }
+        prev := runes[i-1]
+        switch {
+        case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+            // fooBar, foo2Bar
+            flush(i)
+        case unicode.IsUpper(r) && unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+            // HTTPServer: split before the 'S'
+            flush(i)
+        }
+    }
+    flush(len(runes))
+    return words
+}
+
+func joinWords(words []string, sep string, camel bool) string {
+    for i, w := range words {
+        w = strings.ToLower(w)
+        if camel && i > 0 {
+            r := []rune(w)
+            r[0] = unicode.ToUpper(r[0])
+            w = string(r)
+        }
+        words[i] = w
+    }
+    return strings.Join(words, sep)
+}
+
+// RenameKeys returns a copy of value in which every JSONObject key, at any
+// depth, has been passed through strategy.
+func RenameKeys(value interface{}, strategy NamingStrategy) interface{} {
+    switch v := value.(type) {
+    case JSONObject:
+        return v.RenameKeys(strategy)
+    case map[string]interface{}:
+        return NewJSONObjectFromMap(v).RenameKeys(strategy)
+    case JSONArray:
+        return v.RenameKeys(strategy)
+    case []interface{}:
+        return NewJSONArrayFromArray(v).RenameKeys(strategy)
+    case *OrderedJSONObject:
+        if v != nil {
+            return v.RenameKeys(strategy)
+        }
+    }
+    return value
+}
+
+func (p JSONObject) RenameKeys(strategy NamingStrategy) JSONObject {
+    m := NewJSONObject()
+    for k, v := range p {
+        m[strategy(k)] = RenameKeys(v, strategy)
+    }
+    return m
+}
+
+// RenameKeys is like the JSONObject method, keeping the order of the keys.
+func (p *OrderedJSONObject) RenameKeys(strategy NamingStrategy) *OrderedJSONObject {
+    m := NewOrderedJSONObject()
+    for _, k := range p.keys {
+        m.Set(strategy(k), RenameKeys(p.values[k], strategy))
+    }
+    return m
+}
+
+func (p JSONArray) RenameKeys(strategy NamingStrategy) JSONArray {
+    arr := make([]interface{}, len(p))
+    for i, v := range p {
+        arr[i] = RenameKeys(v, strategy)
+    }
+    return NewJSONArrayFromArray(arr)
+}
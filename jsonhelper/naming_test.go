@@ -0,0 +1,95 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestNamingStrategies(t *testing.T) {
+    tests := []struct {
+        in                         string
+        snake, kebab, camel, lower string
+    }{
+        {"HTTPServerID", "http_server_id", "http-server-id", "httpServerId", "httpserverid"},
+        {"http_server_id", "http_server_id", "http-server-id", "httpServerId", "http_server_id"},
+        {"UserName", "user_name", "user-name", "userName", "username"},
+        {"userName2FA", "user_name2_fa", "user-name2-fa", "userName2Fa", "username2fa"},
+        {"already-kebab case", "already_kebab_case", "already-kebab-case", "alreadyKebabCase", "already-kebab case"},
+        {"ID", "id", "id", "id", "id"},
+        {"", "", "", "", ""},
+    }
+    for _, tt := range tests {
+        for _, c := range []struct {
+            name     string
+            strategy NamingStrategy
+            want     string
+        }{
+            {"SnakeCase", SnakeCase, tt.snake},
+            {"KebabCase", KebabCase, tt.kebab},
+            {"CamelCase", CamelCase, tt.camel},
+            {"LowerCase", LowerCase, tt.lower},
+        } {
+            if got := c.strategy(tt.in); got != c.want {
+                t.Errorf("%s(%q) = %q, want %q", c.name, tt.in, got, c.want)
+            }
+        }
+    }
+}
+
+type namingSample struct {
+    UserName  string
+    HomeCity  string `json:"city"`
+    OmitEmpty int    `json:",omitempty"`
+}
+
+func TestFieldNaming(t *testing.T) {
+    in := namingSample{UserName: "ann", HomeCity: "x", OmitEmpty: 3}
+    v, err := MarshalWithOptions(in, MarshalOptions{FieldNaming: SnakeCase})
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := JSONObject{"user_name": "ann", "city": "x", "omit_empty": int64(3)}
+    if !reflect.DeepEqual(v, want) {
+        t.Errorf("got %#v, want %#v", v, want)
+    }
+    var out namingSample
+    if err := UnmarshalWithOptions(v, &out, UnmarshalOptions{FieldNaming: SnakeCase}); err != nil {
+        t.Fatal(err)
+    }
+    if out != in {
+        t.Errorf("round trip gave %#v, want %#v", out, in)
+    }
+}
+
+func TestRenameKeys(t *testing.T) {
+    in := JSONObject{
+        "userName": "a",
+        "homeAddress": JSONObject{
+            "zipCode": "1",
+            "lines":   JSONArray{JSONObject{"lineOne": "x"}},
+        },
+        "tagList": []interface{}{map[string]interface{}{"tagName": "t"}},
+    }
+    got := in.RenameKeys(KebabCase)
+    want := JSONObject{
+        "user-name": "a",
+        "home-address": JSONObject{
+            "zip-code": "1",
+            "lines":    JSONArray{JSONObject{"line-one": "x"}},
+        },
+        "tag-list": JSONArray{JSONObject{"tag-name": "t"}},
+    }
+    if !EqualJSONValues(got, want) {
+        t.Errorf("got %v, want %v", got, want)
+    }
+    if _, ok := in["userName"]; !ok {
+        t.Error("RenameKeys modified its input")
+    }
+    if got := RenameKeys("plain", SnakeCase); got != "plain" {
+        t.Errorf("RenameKeys of a string = %#v", got)
+    }
+}
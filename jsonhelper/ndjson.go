@@ -0,0 +1,52 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "io"
+    "strings"
+)
+
+// ReadNDJSON reads newline-delimited JSON from r, one document per line,
+// parsing each with Parse and opts. Blank lines are skipped, and errors
+// give the line they occurred on.
+func ReadNDJSON(r io.Reader, opts ParseOptions) (JSONArray, error) {
+    values := make(JSONArray, 0)
+    br := bufio.NewReader(r)
+    for line := 1; ; line++ {
+        text, err := br.ReadString('\n')
+        if err != nil && err != io.EOF {
+            return nil, err
+        }
+        if strings.TrimSpace(text) != "" {
+            value, perr := Parse([]byte(strings.TrimRight(text, "\r\n")), opts)
+            if e, ok := perr.(*ParseError); ok {
+                e.Line = line
+                return nil, e
+            } else if perr != nil {
+                return nil, fmt.Errorf("jsonhelper: line %d: %v", line, strings.TrimPrefix(perr.Error(), "jsonhelper: "))
+            }
+            values = append(values, value)
+        }
+        if err == io.EOF {
+            return values, nil
+        }
+    }
+}
+
+// WriteNDJSON writes each of values to w as JSON on a line of its own.
+func WriteNDJSON(w io.Writer, values JSONArray) error {
+    enc := json.NewEncoder(w)
+    enc.SetEscapeHTML(false)
+    for _, value := range values {
+        if err := enc.Encode(value); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+func TestReadNDJSON(t *testing.T) {
+    tests := []struct {
+        in   string
+        want string
+    }{
+        {"", `[]`},
+        {"\n\n", `[]`},
+        {`{"a":1}`, `[{"a":1}]`},
+        {"{\"a\":1}\n[2]\n", `[{"a":1},[2]]`},
+        {"1\r\n\n  \n\"x\"\r\n", `[1,"x"]`},
+        {"null\nnull", `[null,null]`},
+    }
+    for _, tt := range tests {
+        got, err := ReadNDJSON(strings.NewReader(tt.in), ParseOptions{})
+        if err != nil {
+            t.Errorf("ReadNDJSON(%q): %v", tt.in, err)
+        } else if got == nil || !EqualJSONValues(got, mustParse(t, tt.want)) {
+            t.Errorf("ReadNDJSON(%q) = %#v, want %s", tt.in, got, tt.want)
+        }
+    }
+}
+
+func TestReadNDJSONErrors(t *testing.T) {
+    tests := []struct {
+        in     string
+        opts   ParseOptions
+        line   int
+        column int
+        limit  string
+    }{
+        {"1\n\n[1,]\n", ParseOptions{}, 3, 4, ""},
+        {"{\"a\":\n1}\n", ParseOptions{}, 1, 6, ""},
+        {"1\n2 3\n", ParseOptions{}, 2, 3, ""},
+        {"[1]\n[1,2,3]\n", ParseOptions{MaxArrayLength: 2}, 2, 6, "MaxArrayLength"},
+    }
+    for _, tt := range tests {
+        _, err := ReadNDJSON(strings.NewReader(tt.in), tt.opts)
+        pe, ok := err.(*ParseError)
+        if !ok {
+            t.Errorf("ReadNDJSON(%q) error = %v, want a *ParseError", tt.in, err)
+        } else if pe.Line != tt.line || pe.Column != tt.column || pe.Limit != tt.limit {
+            t.Errorf("ReadNDJSON(%q) error = %+v, want line %d column %d limit %q", tt.in, *pe, tt.line, tt.column, tt.limit)
+        }
+    }
+}
+
+func TestWriteNDJSON(t *testing.T) {
+    values := JSONArray{
+        JSONObject{"b": "<&>", "a": JSONArray{1, 2}},
+        mustParseOrdered(t, `{"z":1,"a":"line\nbreak"}`),
+        nil,
+        "s",
+    }
+    var buf bytes.Buffer
+    if err := WriteNDJSON(&buf, values); err != nil {
+        t.Fatal(err)
+    }
+    want := "{\"a\":[1,2],\"b\":\"<&>\"}\n{\"z\":1,\"a\":\"line\\nbreak\"}\nnull\n\"s\"\n"
+    if buf.String() != want {
+        t.Errorf("got %q, want %q", buf.String(), want)
+    }
+    got, err := ReadNDJSON(&buf, ParseOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !EqualJSONValues(got, values) {
+        t.Errorf("round trip gave %v", got)
+    }
+    if err := WriteNDJSON(&buf, JSONArray{make(chan int)}); err == nil {
+        t.Error("writing a channel succeeded")
+    }
+}
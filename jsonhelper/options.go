@@ -0,0 +1,108 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "reflect"
+)
+
+// BytesEncoding selects how []byte values are represented by Marshal.
+type BytesEncoding string
+
+const (
+    // BytesBase64 is standard padded base64, matching encoding/json.
+    BytesBase64 BytesEncoding = ""
+    // BytesBase64URL is padded base64 using the URL-safe alphabet.
+    BytesBase64URL BytesEncoding = "base64url"
+    // BytesBase64Raw is standard base64 without padding.
+    BytesBase64Raw BytesEncoding = "base64raw"
+    // BytesBase64RawURL is URL-safe base64 without padding.
+    BytesBase64RawURL BytesEncoding = "base64rawurl"
+    // BytesHex is lowercase hexadecimal.
+    BytesHex BytesEncoding = "hex"
+    // BytesArray is a JSONArray holding one number per byte.
+    BytesArray BytesEncoding = "array"
+)
+
+// FloatPolicy selects how Marshal handles NaN and infinite floats, which
+// have no JSON representation.
+type FloatPolicy int
+
+const (
+    // FloatAllow stores NaN and Inf in the tree as-is.
+    FloatAllow FloatPolicy = iota
+    // FloatError fails with a *json.UnsupportedValueError.
+    FloatError
+    // FloatNull replaces NaN and Inf with nil.
+    FloatNull
+    // FloatString replaces NaN and Inf with "NaN", "+Inf" or "-Inf".
+    FloatString
+)
+
+// NamingStrategy maps a Go struct field name to a JSON key. It is only
+// applied to fields whose json tag does not specify a name.
+type NamingStrategy func(fieldName string) string
+
+// EncoderFunc converts a value of a registered type into a JSON value.
+type EncoderFunc func(v interface{}) (interface{}, error)
+
+// MarshalOptions controls MarshalWithOptions. The zero value produces the
+// same output as Marshal.
+type MarshalOptions struct {
+    // TimeFormat, if set, is the layout used to format time.Time values,
+    // or one of TimeFormatUnix and TimeFormatUnixMilli.
+    TimeFormat string
+    // DurationFormat is one of the DurationFormat constants or a unit name.
+    DurationFormat string
+    // BytesEncoding selects the representation of []byte values.
+    BytesEncoding BytesEncoding
+    // NilSliceAsNull emits nil slices as nil rather than an empty JSONArray.
+    NilSliceAsNull bool
+    // NilMapAsEmpty emits nil maps as an empty JSONObject rather than nil.
+    NilMapAsEmpty bool
+    // FloatPolicy selects how NaN and infinite values are handled.
+    FloatPolicy FloatPolicy
+    // FieldNaming names struct fields that have no name in their json tag.
+    FieldNaming NamingStrategy
+    // SkipUnsupported drops values of unsupported types (channels,
+    // functions, ...) instead of failing.
+    SkipUnsupported bool
+    // MaxDepth limits the nesting of objects and arrays. Zero means no limit.
+    MaxDepth int
+    // Encoders override the encoding of specific types.
+    Encoders map[reflect.Type]EncoderFunc
+    // View, if set, drops struct fields whose jsonview tag, such as
+    // `jsonview:"public,admin"`, does not list it. Fields without a
+    // jsonview tag are part of every view.
+    View string
+    // Include, if set, limits the output to these dot-separated key paths,
+    // such as "user.address.city", along with their ancestors and
+    // descendants. Ancestors are only kept when they lead to an included
+    // value. Slice elements do not add a segment to the path, and a "*"
+    // segment matches any key.
+    Include []string
+    // Exclude drops these key paths and their descendants.
+    Exclude []string
+    // Redaction selects how fields tagged with the sensitive option, such
+    // as `json:"password,sensitive"`, are written. The zero value masks them.
+    Redaction RedactionMode
+    // RedactionMask replaces sensitive values under RedactMask. It defaults
+    // to DefaultRedactionMask.
+    RedactionMask string
+    // OrderedObjects makes Marshal return *OrderedJSONObject values for
+    // structs, keeping the order of their fields, and for maps, in sorted
+    // key order.
+    OrderedObjects bool
+}
+
+// isDefault reports whether o changes nothing from Marshal, so that a
+// MarshalJSONObject method gives the same result as reflection.
+func (o *MarshalOptions) isDefault() bool {
+    return o.TimeFormat == "" && o.DurationFormat == "" && o.BytesEncoding == BytesBase64 &&
+        !o.NilSliceAsNull && !o.NilMapAsEmpty && o.FloatPolicy == FloatAllow &&
+        o.FieldNaming == nil && !o.SkipUnsupported && o.MaxDepth == 0 &&
+        len(o.Encoders) == 0 && o.View == "" && len(o.Include) == 0 && len(o.Exclude) == 0 &&
+        o.Redaction == RedactMask && o.RedactionMask == "" && !o.OrderedObjects
+}
@@ -0,0 +1,103 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "math"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+type optionsSample struct {
+    Slice []int          `json:"slice"`
+    Map   map[string]int `json:"map"`
+    Float float64        `json:"float"`
+    When  time.Time      `json:"when"`
+}
+
+func TestMarshalOptions(t *testing.T) {
+    when := time.Date(2012, 3, 4, 5, 6, 7, 0, time.UTC)
+    v := optionsSample{Float: 1.5, When: when}
+    tests := []struct {
+        name string
+        opts MarshalOptions
+        want JSONObject
+    }{
+        {"default", MarshalOptions{},
+            JSONObject{"slice": JSONArray{}, "map": nil, "float": 1.5, "when": "2012-03-04T05:06:07Z"}},
+        {"nil slice as null", MarshalOptions{NilSliceAsNull: true},
+            JSONObject{"slice": nil, "map": nil, "float": 1.5, "when": "2012-03-04T05:06:07Z"}},
+        {"nil map as empty", MarshalOptions{NilMapAsEmpty: true},
+            JSONObject{"slice": JSONArray{}, "map": JSONObject{}, "float": 1.5, "when": "2012-03-04T05:06:07Z"}},
+        {"time layout", MarshalOptions{TimeFormat: "2006-01-02"},
+            JSONObject{"slice": JSONArray{}, "map": nil, "float": 1.5, "when": "2012-03-04"}},
+        {"unix time", MarshalOptions{TimeFormat: TimeFormatUnix},
+            JSONObject{"slice": JSONArray{}, "map": nil, "float": 1.5, "when": when.Unix()}},
+        {"encoder", MarshalOptions{Encoders: map[reflect.Type]EncoderFunc{
+            reflect.TypeOf(float64(0)): func(v interface{}) (interface{}, error) { return "f", nil },
+        }}, JSONObject{"slice": JSONArray{}, "map": nil, "float": "f", "when": "2012-03-04T05:06:07Z"}},
+    }
+    for _, tt := range tests {
+        got, err := MarshalWithOptions(v, tt.opts)
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+        } else if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%s: got %#v, want %#v", tt.name, got, tt.want)
+        }
+    }
+    got, err := MarshalWithTimeFormat(when, TimeFormatUnixMilli)
+    if err != nil || got != when.UnixNano()/1e6 {
+        t.Errorf("MarshalWithTimeFormat = %#v, %v", got, err)
+    }
+}
+
+func TestMarshalFloatPolicy(t *testing.T) {
+    tests := []struct {
+        policy FloatPolicy
+        in     float64
+        want   interface{}
+    }{
+        {FloatNull, math.NaN(), nil},
+        {FloatNull, 2, float64(2)},
+        {FloatString, math.Inf(1), "+Inf"},
+        {FloatString, math.Inf(-1), "-Inf"},
+        {FloatString, math.NaN(), "NaN"},
+    }
+    for _, tt := range tests {
+        got, err := MarshalWithOptions(tt.in, MarshalOptions{FloatPolicy: tt.policy})
+        if err != nil || !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("policy %d on %v: got %#v, %v, want %#v", tt.policy, tt.in, got, err, tt.want)
+        }
+    }
+    if got, err := MarshalWithOptions(math.Inf(1), MarshalOptions{}); err != nil || !math.IsInf(got.(float64), 1) {
+        t.Errorf("FloatAllow: got %#v, %v", got, err)
+    }
+    _, err := MarshalWithOptions(math.NaN(), MarshalOptions{FloatPolicy: FloatError})
+    if _, ok := err.(*json.UnsupportedValueError); !ok {
+        t.Errorf("FloatError: got error %v", err)
+    }
+}
+
+func TestMarshalSkipUnsupported(t *testing.T) {
+    v := struct {
+        Name string      `json:"name"`
+        C    chan int    `json:"c"`
+        F    func()      `json:"f"`
+        List []chan bool `json:"list"`
+    }{Name: "n", C: make(chan int), List: []chan bool{nil}}
+    if _, err := Marshal(v); err == nil || !strings.Contains(err.Error(), "chan") {
+        t.Errorf("Marshal: got error %v, want one naming chan", err)
+    }
+    got, err := MarshalWithOptions(v, MarshalOptions{SkipUnsupported: true})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if obj := got.(JSONObject); obj.GetAsString("name") != "n" || obj.Len() != 2 || len(obj.GetAsArray("list")) != 0 {
+        t.Errorf("got %#v, want only name and an empty list", got)
+    }
+}
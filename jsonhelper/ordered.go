@@ -0,0 +1,387 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "sort"
+    "time"
+)
+
+// OrderedJSONObject is a JSON object that keeps its keys in the order they
+// were first set, and is written in that order. Parsing with UnmarshalJSON
+// keeps the order of the document, and MarshalOptions.OrderedObjects makes
+// Marshal keep the order of struct fields. The zero value is an empty
+// object ready to use.
+type OrderedJSONObject struct {
+    keys   []string
+    values JSONObject
+}
+
+func NewOrderedJSONObject() *OrderedJSONObject {
+    return &OrderedJSONObject{values: NewJSONObject()}
+}
+
+// NewOrderedJSONObjectFromMap returns an OrderedJSONObject holding the
+// entries of m in sorted key order.
+func NewOrderedJSONObjectFromMap(m map[string]interface{}) *OrderedJSONObject {
+    p := &OrderedJSONObject{keys: make([]string, 0, len(m)), values: make(JSONObject, len(m))}
+    for k, v := range m {
+        p.keys = append(p.keys, k)
+        p.values[k] = v
+    }
+    sort.Strings(p.keys)
+    return p
+}
+
+// ParseOrderedJSONObject parses a JSON object, keeping the order of its
+// keys. Nested objects are also returned as OrderedJSONObject values.
+func ParseOrderedJSONObject(data []byte) (*OrderedJSONObject, error) {
+    p := NewOrderedJSONObject()
+    if err := p.UnmarshalJSON(data); err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+func (p *OrderedJSONObject) String() string {
+    b, _ := p.MarshalJSON()
+    return string(b)
+}
+
+// Keys returns the keys of p in order.
+func (p *OrderedJSONObject) Keys() []string {
+    keys := make([]string, len(p.keys))
+    copy(keys, p.keys)
+    return keys
+}
+
+func (p *OrderedJSONObject) Del(key string) {
+    if _, ok := p.values[key]; !ok {
+        return
+    }
+    delete(p.values, key)
+    for i, k := range p.keys {
+        if k == key {
+            p.keys = append(p.keys[:i], p.keys[i+1:]...)
+            break
+        }
+    }
+}
+
+// Set stores value under key. A new key is added at the end, while an
+// existing key keeps its position.
+func (p *OrderedJSONObject) Set(key string, value interface{}) {
+    if p.values == nil {
+        p.values = NewJSONObject()
+    }
+    if _, ok := p.values[key]; !ok {
+        p.keys = append(p.keys, key)
+    }
+    p.values[key] = value
+}
+
+func (p *OrderedJSONObject) Get(key string) interface{} {
+    value, _ := p.values[key]
+    return value
+}
+
+func (p *OrderedJSONObject) Len() int {
+    return len(p.keys)
+}
+
+func (p *OrderedJSONObject) GetAsString(key string) string {
+    return JSONValueToString(p.Get(key))
+}
+
+func (p *OrderedJSONObject) GetAsInt(key string) int {
+    return JSONValueToInt(p.Get(key))
+}
+
+func (p *OrderedJSONObject) GetAsInt32(key string) int32 {
+    return JSONValueToInt32(p.Get(key))
+}
+
+func (p *OrderedJSONObject) GetAsInt64(key string) int64 {
+    return JSONValueToInt64(p.Get(key))
+}
+
+func (p *OrderedJSONObject) GetAsFloat64(key string) float64 {
+    return JSONValueToFloat64(p.Get(key))
+}
+
+func (p *OrderedJSONObject) GetAsBool(key string) bool {
+    return JSONValueToBool(p.Get(key))
+}
+
+func (p *OrderedJSONObject) GetAsObject(key string) JSONObject {
+    return JSONValueToObject(p.Get(key))
+}
+
+// GetAsOrderedObject is GetAsObject keeping key order. JSONObject values
+// are returned in sorted key order.
+func (p *OrderedJSONObject) GetAsOrderedObject(key string) *OrderedJSONObject {
+    return JSONValueToOrderedObject(p.Get(key))
+}
+
+func (p *OrderedJSONObject) GetAsArray(key string) JSONArray {
+    return JSONValueToArray(p.Get(key))
+}
+
+func (p *OrderedJSONObject) GetAsTime(key string, format string) time.Time {
+    return JSONValueToTime(p.Get(key), format)
+}
+
+func (p *OrderedJSONObject) GetAsDuration(key string, unit time.Duration) time.Duration {
+    return JSONValueToDuration(p.Get(key), unit)
+}
+
+func (p *OrderedJSONObject) GetAsBytes(key string, enc BytesEncoding) []byte {
+    return JSONValueToBytes(p.Get(key), enc)
+}
+
+// ToJSONObject returns p as a JSONObject, converting nested
+// OrderedJSONObject values as well.
+func (p *OrderedJSONObject) ToJSONObject() JSONObject {
+    obj := make(JSONObject, len(p.keys))
+    for _, k := range p.keys {
+        obj[k] = unorderJSONValue(p.values[k])
+    }
+    return obj
+}
+
+func unorderJSONValue(value interface{}) interface{} {
+    switch v := value.(type) {
+    case *OrderedJSONObject:
+        return v.ToJSONObject()
+    case JSONArray:
+        arr := make(JSONArray, len(v))
+        for i, item := range v {
+            arr[i] = unorderJSONValue(item)
+        }
+        return arr
+    }
+    return value
+}
+
+// JSONValueToOrderedObject is JSONValueToObject returning an
+// OrderedJSONObject. Unordered objects are returned in sorted key order.
+func JSONValueToOrderedObject(value interface{}) *OrderedJSONObject {
+    switch v := value.(type) {
+    case *OrderedJSONObject:
+        if v != nil {
+            return v
+        }
+    case JSONObject:
+        return NewOrderedJSONObjectFromMap(v)
+    case map[string]interface{}:
+        return NewOrderedJSONObjectFromMap(v)
+    }
+    return NewOrderedJSONObject()
+}
+
+// orderedJSONObjectKeys returns the keys of value, an object, in the order
+// it is written: the key order of an OrderedJSONObject and sorted order
+// otherwise.
+func orderedJSONObjectKeys(value interface{}, obj JSONObject) []string {
+    if p, ok := value.(*OrderedJSONObject); ok {
+        return p.Keys()
+    }
+    keys := make([]string, 0, len(obj))
+    for k := range obj {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+func (p *OrderedJSONObject) Compact(removeFalse bool, removeEmptyStrings bool, removeZero bool, removeEmptyArrays bool, removeEmptyObjects bool) *OrderedJSONObject {
+    if len(p.keys) == 0 {
+        if removeEmptyObjects {
+            return nil
+        }
+        return p
+    }
+    m := NewOrderedJSONObject()
+    for _, k := range p.keys {
+        var value interface{}
+        value = p.values[k]
+        switch t := value.(type) {
+        case nil:
+            continue
+        case string:
+            if removeEmptyStrings && len(t) == 0 {
+                continue
+            }
+        case *OrderedJSONObject:
+            if c := t.Compact(removeFalse, removeEmptyStrings, removeZero, removeEmptyArrays, removeEmptyObjects); c != nil {
+                value = c
+            } else {
+                continue
+            }
+        case JSONObject:
+            value = t.Compact(removeFalse, removeEmptyStrings, removeZero, removeEmptyArrays, removeEmptyObjects)
+        case JSONArray:
+            value = t.Compact(removeFalse, removeEmptyStrings, removeZero, removeEmptyArrays, removeEmptyObjects)
+        case map[string]interface{}:
+            value = NewJSONObjectFromMap(t).Compact(removeFalse, removeEmptyStrings, removeZero, removeEmptyArrays, removeEmptyObjects)
+        case []interface{}:
+            value = NewJSONArrayFromArray(t).Compact(removeFalse, removeEmptyStrings, removeZero, removeEmptyArrays, removeEmptyObjects)
+        case float64:
+            if removeZero && t == 0.0 {
+                continue
+            }
+        case float32:
+            if removeZero && t == 0.0 {
+                continue
+            }
+        case int64:
+            if removeZero && t == 0 {
+                continue
+            }
+        case int32:
+            if removeZero && t == 0 {
+                continue
+            }
+        case int:
+            if removeZero && t == 0 {
+                continue
+            }
+        case int16:
+            if removeZero && t == 0 {
+                continue
+            }
+        case int8:
+            if removeZero && t == 0 {
+                continue
+            }
+        case byte:
+            if removeZero && t == 0 {
+                continue
+            }
+        case json.Number:
+            if f, err := t.Float64(); removeZero && err == nil && f == 0 {
+                continue
+            }
+        case bool:
+            if removeFalse && t == false {
+                continue
+            }
+        }
+        if value == nil {
+            continue
+        }
+        m.Set(k, value)
+    }
+    if removeEmptyObjects && m.Len() == 0 {
+        return nil
+    }
+    return m
+}
+
+// MarshalJSON writes p with its keys in order.
+func (p *OrderedJSONObject) MarshalJSON() ([]byte, error) {
+    var buf bytes.Buffer
+    buf.WriteByte('{')
+    for i, k := range p.keys {
+        if i > 0 {
+            buf.WriteByte(',')
+        }
+        key, _ := json.Marshal(k)
+        buf.Write(key)
+        buf.WriteByte(':')
+        b, err := json.Marshal(p.values[k])
+        if err != nil {
+            return nil, err
+        }
+        buf.Write(b)
+    }
+    buf.WriteByte('}')
+    return buf.Bytes(), nil
+}
+
+// UnmarshalJSON replaces the contents of p with the JSON object in data,
+// keeping the order of its keys. Nested objects become OrderedJSONObject
+// values and arrays become JSONArray values. A key given more than once
+// keeps its first position and its last value.
+func (p *OrderedJSONObject) UnmarshalJSON(data []byte) error {
+    dec := json.NewDecoder(bytes.NewReader(data))
+    tok, err := dec.Token()
+    if err != nil {
+        return err
+    }
+    if tok != json.Delim('{') {
+        return fmt.Errorf("jsonhelper: cannot unmarshal %s into an OrderedJSONObject", describeJSONToken(tok))
+    }
+    obj, err := decodeOrderedObject(dec)
+    if err != nil {
+        return err
+    }
+    if _, err := dec.Token(); err != io.EOF {
+        return fmt.Errorf("jsonhelper: unexpected data after JSON object")
+    }
+    *p = *obj
+    return nil
+}
+
+func describeJSONToken(tok json.Token) string {
+    switch tok {
+    case json.Delim('['):
+        return "array"
+    case json.Delim('{'):
+        return "object"
+    }
+    return describeJSONValue(tok)
+}
+
+// decodeOrderedObject reads the members of an object whose opening brace
+// has been read.
+func decodeOrderedObject(dec *json.Decoder) (*OrderedJSONObject, error) {
+    p := NewOrderedJSONObject()
+    for dec.More() {
+        tok, err := dec.Token()
+        if err != nil {
+            return nil, err
+        }
+        key := tok.(string)
+        value, err := decodeOrderedValue(dec)
+        if err != nil {
+            return nil, err
+        }
+        p.Set(key, value)
+    }
+    if _, err := dec.Token(); err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+func decodeOrderedValue(dec *json.Decoder) (interface{}, error) {
+    tok, err := dec.Token()
+    if err != nil {
+        return nil, err
+    }
+    switch tok {
+    case json.Delim('{'):
+        return decodeOrderedObject(dec)
+    case json.Delim('['):
+        arr := make(JSONArray, 0)
+        for dec.More() {
+            value, err := decodeOrderedValue(dec)
+            if err != nil {
+                return nil, err
+            }
+            arr = append(arr, value)
+        }
+        if _, err := dec.Token(); err != nil {
+            return nil, err
+        }
+        return arr, nil
+    }
+    return tok, nil
+}
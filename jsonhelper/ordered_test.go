@@ -0,0 +1,121 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bytes"
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+const orderedSample = `{"zeta":1,"alpha":{"token":"s3cret","beta":[{"password":"x","id":2}]},"mid":"m"}`
+
+func mustParseOrdered(t *testing.T, s string) *OrderedJSONObject {
+    p, err := ParseOrderedJSONObject([]byte(s))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return p
+}
+
+func TestOrderedJSONObjectRoundTrip(t *testing.T) {
+    p := mustParseOrdered(t, orderedSample)
+    if got := strings.Join(p.Keys(), ","); got != "zeta,alpha,mid" {
+        t.Errorf("Keys() = %s", got)
+    }
+    b, err := json.Marshal(p)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if string(b) != orderedSample {
+        t.Errorf("MarshalJSON gave %s, want %s", b, orderedSample)
+    }
+    p.Set("alpha", 3)
+    p.Set("new", true)
+    p.Del("zeta")
+    if got := strings.Join(p.Keys(), ","); got != "alpha,mid,new" {
+        t.Errorf("after Set and Del, Keys() = %s", got)
+    }
+    for _, bad := range []string{`{"a":1}x`, `[1]`, `{"a":}`} {
+        if _, err := ParseOrderedJSONObject([]byte(bad)); err == nil {
+            t.Errorf("ParseOrderedJSONObject(%s) succeeded", bad)
+        }
+    }
+}
+
+func TestOrderedJSONObjectRedact(t *testing.T) {
+    p := mustParseOrdered(t, orderedSample)
+    v := Redact(p, []string{"**.token", "**.password"}, RedactMask, "***")
+    r, ok := v.(*OrderedJSONObject)
+    if !ok {
+        t.Fatalf("Redact returned %T", v)
+    }
+    b, _ := json.Marshal(r)
+    want := `{"zeta":1,"alpha":{"token":"***","beta":[{"password":"***","id":2}]},"mid":"m"}`
+    if string(b) != want {
+        t.Errorf("got %s, want %s", b, want)
+    }
+    if p.GetAsOrderedObject("alpha").GetAsString("token") != "s3cret" {
+        t.Error("Redact modified its input")
+    }
+}
+
+func TestOrderedJSONObjectRenameKeys(t *testing.T) {
+    p := mustParseOrdered(t, `{"userName":"a","homeAddress":{"zipCode":"1","cityName":"c"}}`)
+    v, ok := RenameKeys(p, SnakeCase).(*OrderedJSONObject)
+    if !ok {
+        t.Fatalf("RenameKeys returned %T", v)
+    }
+    b, _ := json.Marshal(v)
+    want := `{"user_name":"a","home_address":{"zip_code":"1","city_name":"c"}}`
+    if string(b) != want {
+        t.Errorf("got %s, want %s", b, want)
+    }
+}
+
+func TestOrderedJSONObjectBinaryFormats(t *testing.T) {
+    p := mustParseOrdered(t, `{"b":1,"a":[true,null],"c":{"y":"s","x":2.5}}`)
+    mp, err := EncodeMsgpack(p)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // fixmap of 3, then fixstr "b".
+    if !bytes.HasPrefix(mp, []byte{0x83, 0xa1, 'b'}) {
+        t.Errorf("MessagePack starts % x, want the key b first", mp[:3])
+    }
+    cb, err := EncodeCBOR(p, CBOROptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    // map of 3, then text "b".
+    if !bytes.HasPrefix(cb, []byte{0xa3, 0x61, 'b'}) {
+        t.Errorf("CBOR starts % x, want the key b first", cb[:3])
+    }
+    det, err := EncodeCBOR(p, CBOROptions{Deterministic: true})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.HasPrefix(det, []byte{0xa3, 0x61, 'a'}) {
+        t.Errorf("deterministic CBOR starts % x, want the key a first", det[:3])
+    }
+    want := p.ToJSONObject()
+    for name, decode := range map[string]func() (interface{}, error){
+        "MessagePack": func() (interface{}, error) { return DecodeMsgpack(mp) },
+        "CBOR":        func() (interface{}, error) { return DecodeCBOR(cb, CBOROptions{}) },
+    } {
+        got, err := decode()
+        if err != nil {
+            t.Errorf("%s: %v", name, err)
+        } else if !EqualJSONValues(got, want) {
+            t.Errorf("%s: decoded %v, want %v", name, got, want)
+        }
+    }
+    var nilObj *OrderedJSONObject
+    if b, err := EncodeMsgpack(nilObj); err != nil || !reflect.DeepEqual(b, []byte{0xc0}) {
+        t.Errorf("nil MessagePack = % x, %v", b, err)
+    }
+}
@@ -0,0 +1,565 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "strconv"
+    "unicode/utf16"
+    "unicode/utf8"
+)
+
+// DuplicateKeyPolicy selects how Parse handles an object that has the same
+// key more than once.
+type DuplicateKeyPolicy int
+
+const (
+    // DuplicateKeyReject fails with a *ParseError.
+    DuplicateKeyReject DuplicateKeyPolicy = iota
+    // DuplicateKeyFirst keeps the first value given for a key.
+    DuplicateKeyFirst
+    // DuplicateKeyLast keeps the last value given for a key, as
+    // encoding/json does.
+    DuplicateKeyLast
+)
+
+// Defaults for the limits in ParseOptions.
+const (
+    DefaultParseMaxBytes        = 8 << 20
+    DefaultParseMaxDepth        = 1000
+    DefaultParseMaxStringLength = 1 << 20
+    DefaultParseMaxObjectKeys   = 10000
+    DefaultParseMaxArrayLength  = 1 << 20
+    DefaultParseMaxNodes        = 1 << 20
+)
+
+// ParseOptions controls Parse. Each limit defaults to the matching
+// DefaultParse constant when zero and is unlimited when negative.
+type ParseOptions struct {
+    // MaxBytes limits the size of the input.
+    MaxBytes int
+    // MaxDepth limits the nesting of objects and arrays.
+    MaxDepth int
+    // MaxStringLength limits the length in bytes of decoded strings,
+    // including object keys.
+    MaxStringLength int
+    // MaxObjectKeys limits the number of members of each object.
+    MaxObjectKeys int
+    // MaxArrayLength limits the number of elements of each array.
+    MaxArrayLength int
+    // MaxNodes limits the total number of values in the document, counting
+    // objects, arrays and scalars.
+    MaxNodes int
+    // DuplicateKeys selects how repeated object keys are handled.
+    DuplicateKeys DuplicateKeyPolicy
+    // AllowInvalidUTF8 replaces invalid UTF-8 in strings with U+FFFD
+    // instead of failing.
+    AllowInvalidUTF8 bool
+    // UseNumber stores numbers as json.Number instead of float64.
+    UseNumber bool
+    // Relaxed accepts JSON5 as well as JSON, as ParseJSON5 describes.
+    Relaxed bool
+    // Ordered returns objects as *OrderedJSONObject values that keep the
+    // order of their keys.
+    Ordered bool
+}
+
+func parseLimit(n, def int) int {
+    if n == 0 {
+        return def
+    }
+    if n < 0 {
+        return int(^uint(0) >> 1)
+    }
+    return n
+}
+
+// ParseError reports invalid input or an exceeded limit, with the position
+// at which it was found. Line and Column count from 1, with Column
+// counting characters rather than bytes.
+type ParseError struct {
+    Line   int
+    Column int
+    // Offset is the byte offset of the error in the input.
+    Offset int
+    // Limit names the ParseOptions field that was exceeded, such as
+    // "MaxDepth", or is empty for syntax errors.
+    Limit string
+    Msg   string
+}
+
+func (e *ParseError) Error() string {
+    return fmt.Sprintf("jsonhelper: line %d column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// parseError carries a *ParseError up through the panics the parser uses
+// to unwind.
+type parseError struct {
+    err *ParseError
+}
+
+// Parse parses the JSON document in data into a JSONObject, JSONArray or
+// scalar, enforcing the limits in opts. It is meant for untrusted input:
+// every limit applies while parsing, so oversized documents are rejected
+// before they are built.
+func Parse(data []byte, opts ParseOptions) (interface{}, error) {
+    return newParser(data, &opts).run()
+}
+
+func (p *parser) run() (value interface{}, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            if e, ok := r.(parseError); ok {
+                value, err = nil, e.err
+                return
+            }
+            panic(r)
+        }
+    }()
+    if len(p.data) > p.maxBytes {
+        p.failLimit(p.maxBytes, "MaxBytes", "document is larger than %d bytes", p.maxBytes)
+    }
+    p.skipSpace()
+    value = p.value()
+    p.skipSpace()
+    if p.pos < len(p.data) {
+        p.fail(p.pos, "unexpected %s after top-level value", p.describe(p.pos))
+    }
+    if p.doc != nil {
+        p.doc.Trailing = p.takeComments()
+    }
+    return value, nil
+}
+
+// ParseReader reads a JSON document from r and parses it as Parse does,
+// reading no more than MaxBytes plus one bytes.
+func ParseReader(r io.Reader, opts ParseOptions) (interface{}, error) {
+    max := parseLimit(opts.MaxBytes, DefaultParseMaxBytes)
+    if max < int(^uint(0)>>1) {
+        r = io.LimitReader(r, int64(max)+1)
+    }
+    data, err := ioutil.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    return Parse(data, opts)
+}
+
+// ParseObject is Parse for documents that must be a JSON object.
+func ParseObject(data []byte, opts ParseOptions) (JSONObject, error) {
+    value, err := Parse(data, opts)
+    if err != nil {
+        return nil, err
+    }
+    if describeJSONValue(value) != "object" {
+        return nil, fmt.Errorf("jsonhelper: cannot parse %s into a JSONObject", describeJSONValue(value))
+    }
+    return JSONValueToObject(value), nil
+}
+
+type parser struct {
+    data []byte
+    pos  int
+    opts *ParseOptions
+
+    maxBytes        int
+    maxDepth        int
+    maxStringLength int
+    maxObjectKeys   int
+    maxArrayLength  int
+    maxNodes        int
+
+    depth int
+    nodes int
+    buf   []byte
+
+    // doc collects comments when parsing a JSON5Document, with path
+    // holding the keys and indices of the value being parsed.
+    doc        *JSON5Document
+    path       []string
+    pending    []string
+    lastPath   string
+    afterValue bool
+}
+
+func newParser(data []byte, opts *ParseOptions) *parser {
+    return &parser{
+        data:            data,
+        opts:            opts,
+        maxBytes:        parseLimit(opts.MaxBytes, DefaultParseMaxBytes),
+        maxDepth:        parseLimit(opts.MaxDepth, DefaultParseMaxDepth),
+        maxStringLength: parseLimit(opts.MaxStringLength, DefaultParseMaxStringLength),
+        maxObjectKeys:   parseLimit(opts.MaxObjectKeys, DefaultParseMaxObjectKeys),
+        maxArrayLength:  parseLimit(opts.MaxArrayLength, DefaultParseMaxArrayLength),
+        maxNodes:        parseLimit(opts.MaxNodes, DefaultParseMaxNodes),
+    }
+}
+
+func (p *parser) fail(offset int, format string, args ...interface{}) {
+    p.failLimit(offset, "", format, args...)
+}
+
+func (p *parser) failLimit(offset int, limit string, format string, args ...interface{}) {
+    if offset > len(p.data) {
+        offset = len(p.data)
+    }
+    line, lineStart := 1, 0
+    for i := 0; i < offset; i++ {
+        if p.data[i] == '\n' {
+            line++
+            lineStart = i + 1
+        }
+    }
+    panic(parseError{&ParseError{
+        Line:   line,
+        Column: utf8.RuneCount(p.data[lineStart:offset]) + 1,
+        Offset: offset,
+        Limit:  limit,
+        Msg:    fmt.Sprintf(format, args...),
+    }})
+}
+
+// describe names the character at offset for error messages.
+func (p *parser) describe(offset int) string {
+    if offset >= len(p.data) {
+        return "end of input"
+    }
+    r, _ := utf8.DecodeRune(p.data[offset:])
+    return "character " + strconv.QuoteRune(r)
+}
+
+func (p *parser) skipSpace() {
+    if p.opts.Relaxed {
+        p.skipRelaxedSpace()
+        return
+    }
+    for p.pos < len(p.data) {
+        switch p.data[p.pos] {
+        case ' ', '\t', '\n', '\r':
+            p.pos++
+        default:
+            return
+        }
+    }
+}
+
+// expect consumes c, which must be the next character.
+func (p *parser) expect(c byte, context string) {
+    if p.pos >= len(p.data) || p.data[p.pos] != c {
+        p.fail(p.pos, "unexpected %s %s", p.describe(p.pos), context)
+    }
+    p.pos++
+}
+
+func (p *parser) node() {
+    p.nodes++
+    if p.nodes > p.maxNodes {
+        p.failLimit(p.pos, "MaxNodes", "document has more than %d values", p.maxNodes)
+    }
+}
+
+func (p *parser) value() interface{} {
+    p.node()
+    if p.doc == nil {
+        return p.parseValue()
+    }
+    p.attachComments()
+    value := p.parseValue()
+    p.lastPath, p.afterValue = p.pointer(), true
+    return value
+}
+
+func (p *parser) parseValue() interface{} {
+    if p.pos >= len(p.data) {
+        p.fail(p.pos, "unexpected end of input looking for a value")
+    }
+    switch c := p.data[p.pos]; {
+    case c == '{':
+        return p.object()
+    case c == '[':
+        return p.array()
+    case c == '"' || (c == '\'' && p.opts.Relaxed):
+        return p.string()
+    case p.opts.Relaxed && (c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9') || c == 'I' || c == 'N'):
+        return p.relaxedNumber()
+    case c == '-' || (c >= '0' && c <= '9'):
+        return p.number()
+    }
+    if p.literal("true") {
+        return true
+    }
+    if p.literal("false") {
+        return false
+    }
+    if p.literal("null") {
+        return nil
+    }
+    p.fail(p.pos, "unexpected %s looking for a value", p.describe(p.pos))
+    return nil
+}
+
+// literal consumes word if the input continues with it.
+func (p *parser) literal(word string) bool {
+    if len(p.data)-p.pos < len(word) || string(p.data[p.pos:p.pos+len(word)]) != word {
+        return false
+    }
+    p.pos += len(word)
+    return true
+}
+
+func (p *parser) enter() {
+    p.depth++
+    if p.depth > p.maxDepth {
+        p.failLimit(p.pos, "MaxDepth", "nesting is deeper than %d", p.maxDepth)
+    }
+}
+
+func (p *parser) object() interface{} {
+    p.enter()
+    p.pos++
+    p.afterValue = false
+    obj := NewJSONObject()
+    var order []string
+    for first := true; ; first = false {
+        p.skipSpace()
+        if p.pos < len(p.data) && p.data[p.pos] == '}' && (first || p.opts.Relaxed) {
+            break
+        }
+        keyStart := p.pos
+        before := p.takeComments()
+        key := p.key()
+        p.skipSpace()
+        p.expect(':', "after object key")
+        p.skipSpace()
+        p.path = append(p.path, key)
+        p.pending = append(before, p.pending...)
+        value := p.value()
+        p.path = p.path[:len(p.path)-1]
+        if _, exists := obj[key]; exists {
+            switch p.opts.DuplicateKeys {
+            case DuplicateKeyReject:
+                p.fail(keyStart, "duplicate key %q", key)
+            case DuplicateKeyLast:
+                obj[key] = value
+            }
+        } else {
+            if len(obj) >= p.maxObjectKeys {
+                p.failLimit(keyStart, "MaxObjectKeys", "object has more than %d keys", p.maxObjectKeys)
+            }
+            obj[key] = value
+            if p.opts.Ordered {
+                order = append(order, key)
+            }
+        }
+        p.skipSpace()
+        if p.pos < len(p.data) && p.data[p.pos] == ',' {
+            p.pos++
+            continue
+        }
+        if p.pos >= len(p.data) || p.data[p.pos] != '}' {
+            p.fail(p.pos, "unexpected %s after object member", p.describe(p.pos))
+        }
+        break
+    }
+    p.endComments()
+    p.pos++
+    p.depth--
+    if p.opts.Ordered {
+        return &OrderedJSONObject{keys: order, values: obj}
+    }
+    return obj
+}
+
+// key reads an object key, which relaxed input may leave unquoted.
+func (p *parser) key() string {
+    if p.pos < len(p.data) {
+        if c := p.data[p.pos]; c == '"' || (c == '\'' && p.opts.Relaxed) {
+            return p.string()
+        }
+        if p.opts.Relaxed {
+            if key, ok := p.identifier(); ok {
+                return key
+            }
+        }
+    }
+    p.fail(p.pos, "unexpected %s looking for an object key", p.describe(p.pos))
+    return ""
+}
+
+func (p *parser) array() interface{} {
+    p.enter()
+    p.pos++
+    p.afterValue = false
+    arr := make(JSONArray, 0)
+    for first := true; ; first = false {
+        p.skipSpace()
+        if p.pos < len(p.data) && p.data[p.pos] == ']' && (first || p.opts.Relaxed) {
+            break
+        }
+        if len(arr) >= p.maxArrayLength {
+            p.failLimit(p.pos, "MaxArrayLength", "array has more than %d elements", p.maxArrayLength)
+        }
+        p.path = append(p.path, strconv.Itoa(len(arr)))
+        arr = append(arr, p.value())
+        p.path = p.path[:len(p.path)-1]
+        p.skipSpace()
+        if p.pos < len(p.data) && p.data[p.pos] == ',' {
+            p.pos++
+            continue
+        }
+        if p.pos >= len(p.data) || p.data[p.pos] != ']' {
+            p.fail(p.pos, "unexpected %s after array element", p.describe(p.pos))
+        }
+        break
+    }
+    p.endComments()
+    p.pos++
+    p.depth--
+    return arr
+}
+
+// string reads a quoted string starting at the opening quote.
+func (p *parser) string() string {
+    start := p.pos
+    quote := p.data[p.pos]
+    p.pos++
+    buf := p.buf[:0]
+    for {
+        if p.pos >= len(p.data) {
+            p.fail(start, "string is not terminated")
+        }
+        c := p.data[p.pos]
+        switch {
+        case c == quote:
+            p.pos++
+            p.buf = buf
+            return string(buf)
+        case c == '\\' && p.opts.Relaxed:
+            buf = p.relaxedEscape(buf)
+        case c == '\\':
+            buf = p.escape(buf)
+        case c == '\n' || c == '\r':
+            p.fail(p.pos, "line break in string")
+        case c < 0x20 && !p.opts.Relaxed:
+            p.fail(p.pos, "control character %q in string", rune(c))
+        case c < utf8.RuneSelf:
+            buf = append(buf, c)
+            p.pos++
+        default:
+            r, size := utf8.DecodeRune(p.data[p.pos:])
+            if r == utf8.RuneError && size == 1 {
+                if !p.opts.AllowInvalidUTF8 {
+                    p.fail(p.pos, "invalid UTF-8 in string")
+                }
+                buf = append(buf, "\ufffd"...)
+            } else {
+                buf = append(buf, p.data[p.pos:p.pos+size]...)
+            }
+            p.pos += size
+        }
+        if len(buf) > p.maxStringLength {
+            p.failLimit(start, "MaxStringLength", "string is longer than %d bytes", p.maxStringLength)
+        }
+    }
+}
+
+// escape appends the character written by the escape sequence at p.pos.
+func (p *parser) escape(buf []byte) []byte {
+    if p.pos+1 >= len(p.data) {
+        p.fail(p.pos, "string is not terminated")
+    }
+    c := p.data[p.pos+1]
+    p.pos += 2
+    switch c {
+    case '"', '\\', '/':
+        return append(buf, c)
+    case 'b':
+        return append(buf, '\b')
+    case 'f':
+        return append(buf, '\f')
+    case 'n':
+        return append(buf, '\n')
+    case 'r':
+        return append(buf, '\r')
+    case 't':
+        return append(buf, '\t')
+    case 'u':
+        r := p.hex4()
+        if utf16.IsSurrogate(r) {
+            r2 := utf8.RuneError
+            if p.pos+1 < len(p.data) && p.data[p.pos] == '\\' && p.data[p.pos+1] == 'u' {
+                save := p.pos
+                p.pos += 2
+                if r2 = utf16.DecodeRune(r, p.hex4()); r2 == utf8.RuneError {
+                    p.pos = save
+                }
+            }
+            r = r2
+        }
+        return append(buf, string(r)...)
+    }
+    p.fail(p.pos-2, "invalid escape sequence \\%c in string", c)
+    return nil
+}
+
+func (p *parser) hex4() rune {
+    if len(p.data)-p.pos < 4 {
+        p.fail(p.pos, "invalid \\u escape in string")
+    }
+    n, err := strconv.ParseUint(string(p.data[p.pos:p.pos+4]), 16, 32)
+    if err != nil {
+        p.fail(p.pos, "invalid \\u escape in string")
+    }
+    p.pos += 4
+    return rune(n)
+}
+
+func (p *parser) number() interface{} {
+    start := p.pos
+    if p.data[p.pos] == '-' {
+        p.pos++
+    }
+    if p.pos < len(p.data) && p.data[p.pos] == '0' {
+        p.pos++
+    } else if !p.digits() {
+        p.fail(p.pos, "unexpected %s in number", p.describe(p.pos))
+    }
+    if p.pos < len(p.data) && p.data[p.pos] == '.' {
+        p.pos++
+        if !p.digits() {
+            p.fail(p.pos, "unexpected %s after decimal point", p.describe(p.pos))
+        }
+    }
+    if p.pos < len(p.data) && (p.data[p.pos] == 'e' || p.data[p.pos] == 'E') {
+        p.pos++
+        if p.pos < len(p.data) && (p.data[p.pos] == '+' || p.data[p.pos] == '-') {
+            p.pos++
+        }
+        if !p.digits() {
+            p.fail(p.pos, "unexpected %s in exponent", p.describe(p.pos))
+        }
+    }
+    text := string(p.data[start:p.pos])
+    if p.opts.UseNumber {
+        return json.Number(text)
+    }
+    f, err := strconv.ParseFloat(text, 64)
+    if err != nil {
+        p.fail(start, "number %s is out of range", text)
+    }
+    return f
+}
+
+// digits consumes a run of decimal digits, reporting whether there was one.
+func (p *parser) digits() bool {
+    start := p.pos
+    for p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+        p.pos++
+    }
+    return p.pos > start
+}
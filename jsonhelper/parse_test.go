@@ -0,0 +1,147 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestParse(t *testing.T) {
+    tests := []struct {
+        in   string
+        opts ParseOptions
+        want interface{}
+    }{
+        {`null`, ParseOptions{}, nil},
+        {` true `, ParseOptions{}, true},
+        {`-1.5e2`, ParseOptions{}, -150.0},
+        {`0`, ParseOptions{}, 0.0},
+        {`12345678901234567890`, ParseOptions{UseNumber: true}, json.Number("12345678901234567890")},
+        {"\"a\\\"\\\\\\/\\b\\f\\n\\r\\t\u00e9\U0001f600\"", ParseOptions{}, "a\"\\/\b\f\n\r\t\u00e9\U0001f600"},
+        {`"\ud800"`, ParseOptions{}, "\ufffd"},
+        {`"\ud83d\ude00"`, ParseOptions{}, "\U0001f600"},
+        {`"\ud83dA"`, ParseOptions{}, "\ufffdA"},
+        {"\"\xff\"", ParseOptions{AllowInvalidUTF8: true}, "\ufffd"},
+        {`{}`, ParseOptions{}, JSONObject{}},
+        {`[]`, ParseOptions{}, JSONArray{}},
+        {`{"a":[1,{"b":null}],"c":"d"}`, ParseOptions{}, JSONObject{"a": JSONArray{1.0, JSONObject{"b": nil}}, "c": "d"}},
+        {`{"a":1,"a":2}`, ParseOptions{DuplicateKeys: DuplicateKeyFirst}, JSONObject{"a": 1.0}},
+        {`{"a":1,"a":2}`, ParseOptions{DuplicateKeys: DuplicateKeyLast}, JSONObject{"a": 2.0}},
+        {`[[[]]]`, ParseOptions{MaxDepth: 3}, JSONArray{JSONArray{JSONArray{}}}},
+        {`[[[]]]`, ParseOptions{MaxDepth: -1}, JSONArray{JSONArray{JSONArray{}}}},
+        {`"abc"`, ParseOptions{MaxStringLength: 3}, "abc"},
+        {`[1,2]`, ParseOptions{MaxArrayLength: 2}, JSONArray{1.0, 2.0}},
+        {`{"a":1,"b":2}`, ParseOptions{MaxObjectKeys: 2}, JSONObject{"a": 1.0, "b": 2.0}},
+        {`{"a":1,"a":2}`, ParseOptions{MaxObjectKeys: 1, DuplicateKeys: DuplicateKeyLast}, JSONObject{"a": 2.0}},
+        {`[1,[2]]`, ParseOptions{MaxNodes: 4}, JSONArray{1.0, JSONArray{2.0}}},
+        {`[1]`, ParseOptions{MaxBytes: 3}, JSONArray{1.0}},
+    }
+    for _, tt := range tests {
+        got, err := Parse([]byte(tt.in), tt.opts)
+        if err != nil {
+            t.Errorf("Parse(%s, %+v): %v", tt.in, tt.opts, err)
+        } else if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("Parse(%s, %+v) = %#v, want %#v", tt.in, tt.opts, got, tt.want)
+        }
+    }
+}
+
+func TestParseErrors(t *testing.T) {
+    tests := []struct {
+        in     string
+        opts   ParseOptions
+        line   int
+        column int
+        limit  string
+        msg    string
+    }{
+        {``, ParseOptions{}, 1, 1, "", "unexpected end of input looking for a value"},
+        {` `, ParseOptions{}, 1, 2, "", "unexpected end of input looking for a value"},
+        {`nul`, ParseOptions{}, 1, 1, "", "unexpected character 'n' looking for a value"},
+        {`{"a":1,}`, ParseOptions{}, 1, 8, "", "unexpected character '}' looking for an object key"},
+        {`[1,]`, ParseOptions{}, 1, 4, "", "unexpected character ']' looking for a value"},
+        {`[1 2]`, ParseOptions{}, 1, 4, "", "unexpected character '2' after array element"},
+        {`{"a" 1}`, ParseOptions{}, 1, 6, "", "unexpected character '1' after object key"},
+        {`{1:2}`, ParseOptions{}, 1, 2, "", "unexpected character '1' looking for an object key"},
+        {`[1] 2`, ParseOptions{}, 1, 5, "", "unexpected character '2' after top-level value"},
+        {`01`, ParseOptions{}, 1, 2, "", "unexpected character '1' after top-level value"},
+        {`1.`, ParseOptions{}, 1, 3, "", "unexpected end of input after decimal point"},
+        {`-`, ParseOptions{}, 1, 2, "", "unexpected end of input in number"},
+        {`1e`, ParseOptions{}, 1, 3, "", "unexpected end of input in exponent"},
+        {`1e999`, ParseOptions{}, 1, 1, "", "number 1e999 is out of range"},
+        {`"abc`, ParseOptions{}, 1, 1, "", "string is not terminated"},
+        {"\"a\tb\"", ParseOptions{}, 1, 3, "", "control character '\\t' in string"},
+        {"\"a\nb\"", ParseOptions{}, 1, 3, "", "line break in string"},
+        {`"\x"`, ParseOptions{}, 1, 2, "", "invalid escape sequence \\x in string"},
+        {`"\u12"`, ParseOptions{}, 1, 4, "", "invalid \\u escape in string"},
+        {"\"\xff\"", ParseOptions{}, 1, 2, "", "invalid UTF-8 in string"},
+        {"{\n  \"a\": tru}", ParseOptions{}, 2, 8, "", "unexpected character 't' looking for a value"},
+        {"{\"\u00e9\":\"x\"\n,\"\u00e9\":1}", ParseOptions{}, 2, 2, "", "duplicate key \"\u00e9\""},
+        {"[\"\u00e9\u00e9\", x]", ParseOptions{}, 1, 8, "", "unexpected character 'x' looking for a value"},
+        {`[1]`, ParseOptions{MaxBytes: 2}, 1, 3, "MaxBytes", "document is larger than 2 bytes"},
+        {`[[[]]]`, ParseOptions{MaxDepth: 2}, 1, 3, "MaxDepth", "nesting is deeper than 2"},
+        {strings.Repeat("[", DefaultParseMaxDepth+1), ParseOptions{}, 1, DefaultParseMaxDepth + 1, "MaxDepth", "nesting is deeper than 1000"},
+        {`["abcd"]`, ParseOptions{MaxStringLength: 3}, 1, 2, "MaxStringLength", "string is longer than 3 bytes"},
+        {`{"abcd":1}`, ParseOptions{MaxStringLength: 3}, 1, 2, "MaxStringLength", "string is longer than 3 bytes"},
+        {`[1,2,3]`, ParseOptions{MaxArrayLength: 2}, 1, 6, "MaxArrayLength", "array has more than 2 elements"},
+        {`{"a":1,"b":2,"c":3}`, ParseOptions{MaxObjectKeys: 2}, 1, 14, "MaxObjectKeys", "object has more than 2 keys"},
+        {`[1,[2]]`, ParseOptions{MaxNodes: 3}, 1, 5, "MaxNodes", "document has more than 3 values"},
+    }
+    for _, tt := range tests {
+        _, err := Parse([]byte(tt.in), tt.opts)
+        pe, ok := err.(*ParseError)
+        if !ok {
+            t.Errorf("Parse(%.20q) error = %v, want a *ParseError", tt.in, err)
+            continue
+        }
+        if pe.Line != tt.line || pe.Column != tt.column || pe.Limit != tt.limit || pe.Msg != tt.msg {
+            t.Errorf("Parse(%.20q) error = %+v, want line %d column %d limit %q: %s", tt.in, *pe, tt.line, tt.column, tt.limit, tt.msg)
+        }
+    }
+}
+
+func TestParseOrdered(t *testing.T) {
+    v, err := Parse([]byte(`{"z":1,"a":{"y":2,"b":3},"m":[{"k":4,"c":5}]}`), ParseOptions{Ordered: true})
+    if err != nil {
+        t.Fatal(err)
+    }
+    b, _ := json.Marshal(v)
+    if want := `{"z":1,"a":{"y":2,"b":3},"m":[{"k":4,"c":5}]}`; string(b) != want {
+        t.Errorf("got %s, want %s", b, want)
+    }
+    v, err = Parse([]byte(`{"b":1,"a":2,"b":3}`), ParseOptions{Ordered: true, DuplicateKeys: DuplicateKeyLast})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if b, _ := json.Marshal(v); string(b) != `{"b":3,"a":2}` {
+        t.Errorf("duplicate keys gave %s", b)
+    }
+}
+
+func TestParseReader(t *testing.T) {
+    v, err := ParseReader(strings.NewReader(`{"a":[1]}`), ParseOptions{})
+    if err != nil || !EqualJSONValues(v, JSONObject{"a": JSONArray{1.0}}) {
+        t.Errorf("ParseReader gave %v, %v", v, err)
+    }
+    _, err = ParseReader(strings.NewReader(strings.Repeat(" ", 100)+"1"), ParseOptions{MaxBytes: 10})
+    if pe, ok := err.(*ParseError); !ok || pe.Limit != "MaxBytes" {
+        t.Errorf("oversized reader gave %v", err)
+    }
+}
+
+func TestParseObject(t *testing.T) {
+    obj, err := ParseObject([]byte(`{"a":"b"}`), ParseOptions{})
+    if err != nil || obj.GetAsString("a") != "b" {
+        t.Errorf("ParseObject gave %v, %v", obj, err)
+    }
+    for _, in := range []string{`[1]`, `"s"`, `null`, `{`} {
+        if _, err := ParseObject([]byte(in), ParseOptions{}); err == nil {
+            t.Errorf("ParseObject(%s) succeeded", in)
+        }
+    }
+}
@@ -0,0 +1,287 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "strconv"
+    "strings"
+)
+
+// ApplyPatch applies the JSON Patch (RFC 6902) operations in patch to a
+// copy of doc and returns the result. doc is not modified, and no change
+// is made if any operation fails.
+func ApplyPatch(doc interface{}, patch JSONArray) (interface{}, error) {
+    doc = CopyJSONValue(doc)
+    for i, item := range patch {
+        op, ok := jsonObjectValue(item)
+        if !ok {
+            return nil, fmt.Errorf("jsonhelper: patch operation %d is %s, not an object", i, describeJSONValue(item))
+        }
+        var err error
+        if doc, err = applyPatchOp(doc, op); err != nil {
+            return nil, fmt.Errorf("jsonhelper: patch operation %d (%s): %v", i, op.GetAsString("op"), strings.TrimPrefix(err.Error(), "jsonhelper: "))
+        }
+    }
+    return doc, nil
+}
+
+func applyPatchOp(doc interface{}, op JSONObject) (interface{}, error) {
+    path, ok := op["path"].(string)
+    if !ok {
+        return nil, fmt.Errorf("missing path")
+    }
+    value, hasValue := op["value"]
+    name := op.GetAsString("op")
+    switch name {
+    case "add", "replace", "test":
+        if !hasValue {
+            return nil, fmt.Errorf("missing value")
+        }
+    case "move", "copy":
+        if _, ok := op["from"].(string); !ok {
+            return nil, fmt.Errorf("missing from")
+        }
+    }
+    switch name {
+    case "add":
+        return SetPointer(doc, path, CopyJSONValue(value))
+    case "remove":
+        return RemovePointer(doc, path)
+    case "replace":
+        if path == "" {
+            return CopyJSONValue(value), nil
+        }
+        return replacePointer(doc, path, CopyJSONValue(value))
+    case "move":
+        from := op.GetAsString("from")
+        if from == path {
+            return doc, nil
+        }
+        if strings.HasPrefix(path, from+"/") {
+            return nil, fmt.Errorf("cannot move %q into itself", from)
+        }
+        moved, err := GetPointer(doc, from)
+        if err != nil {
+            return nil, err
+        }
+        if doc, err = RemovePointer(doc, from); err != nil {
+            return nil, err
+        }
+        return SetPointer(doc, path, moved)
+    case "copy":
+        copied, err := GetPointer(doc, op.GetAsString("from"))
+        if err != nil {
+            return nil, err
+        }
+        return SetPointer(doc, path, CopyJSONValue(copied))
+    case "test":
+        actual, err := GetPointer(doc, path)
+        if err != nil {
+            return nil, err
+        }
+        if !EqualJSONValues(actual, value) {
+            return nil, fmt.Errorf("value at %q is not equal", path)
+        }
+        return doc, nil
+    }
+    return nil, fmt.Errorf("unknown op %q", name)
+}
+
+// Diff returns a JSON Patch that turns a into b. Objects are compared key
+// by key and arrays element by element, with elements added or removed at
+// the end; values that differ otherwise are replaced.
+func Diff(a, b interface{}) JSONArray {
+    patch := make(JSONArray, 0)
+    return diffValues(patch, "", a, b)
+}
+
+func diffValues(patch JSONArray, path string, a, b interface{}) JSONArray {
+    if EqualJSONValues(a, b) {
+        return patch
+    }
+    objA, okA := jsonObjectValue(a)
+    objB, okB := jsonObjectValue(b)
+    if okA && okB {
+        for _, k := range orderedJSONObjectKeys(a, objA) {
+            if _, exists := objB[k]; !exists {
+                patch = append(patch, JSONObject{"op": "remove", "path": path + "/" + jsonPointerToken(k)})
+            }
+        }
+        for _, k := range orderedJSONObjectKeys(b, objB) {
+            child := path + "/" + jsonPointerToken(k)
+            if old, exists := objA[k]; exists {
+                patch = diffValues(patch, child, old, objB[k])
+            } else {
+                patch = append(patch, JSONObject{"op": "add", "path": child, "value": objB[k]})
+            }
+        }
+        return patch
+    }
+    arrA, okA := jsonArrayValue(a)
+    arrB, okB := jsonArrayValue(b)
+    if okA && okB {
+        n := len(arrA)
+        if len(arrB) < n {
+            n = len(arrB)
+        }
+        for i := 0; i < n; i++ {
+            patch = diffValues(patch, path+"/"+strconv.Itoa(i), arrA[i], arrB[i])
+        }
+        for i := len(arrA) - 1; i >= n; i-- {
+            patch = append(patch, JSONObject{"op": "remove", "path": path + "/" + strconv.Itoa(i)})
+        }
+        for i := n; i < len(arrB); i++ {
+            patch = append(patch, JSONObject{"op": "add", "path": path + "/" + strconv.Itoa(i), "value": arrB[i]})
+        }
+        return patch
+    }
+    return append(patch, JSONObject{"op": "replace", "path": path, "value": b})
+}
+
+// MergePatch applies the JSON Merge Patch (RFC 7386) patch to a copy of
+// target and returns the result: members of patch objects replace those of
+// target objects, recursively, and null members remove them.
+func MergePatch(target, patch interface{}) interface{} {
+    return mergePatch(CopyJSONValue(target), patch)
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+    p, ok := jsonObjectValue(patch)
+    if !ok {
+        return CopyJSONValue(patch)
+    }
+    if _, isObject := jsonObjectValue(target); !isObject {
+        target = NewJSONObject()
+        if _, ordered := patch.(*OrderedJSONObject); ordered {
+            target = NewOrderedJSONObject()
+        }
+    }
+    obj, _ := jsonObjectValue(target)
+    for _, k := range orderedJSONObjectKeys(patch, p) {
+        if p[k] == nil {
+            deleteObjectMember(target, k)
+        } else {
+            setObjectMember(target, k, mergePatch(obj[k], p[k]))
+        }
+    }
+    return target
+}
+
+// CopyJSONValue returns a deep copy of the objects and arrays in value.
+// OrderedJSONObject values keep their type and order; other objects and
+// arrays are copied as JSONObject and JSONArray values.
+func CopyJSONValue(value interface{}) interface{} {
+    switch v := value.(type) {
+    case *OrderedJSONObject:
+        if v == nil {
+            return v
+        }
+        c := &OrderedJSONObject{keys: v.Keys(), values: make(JSONObject, len(v.values))}
+        for k, item := range v.values {
+            c.values[k] = CopyJSONValue(item)
+        }
+        return c
+    }
+    if obj, ok := jsonObjectValue(value); ok {
+        c := make(JSONObject, len(obj))
+        for k, item := range obj {
+            c[k] = CopyJSONValue(item)
+        }
+        return c
+    }
+    if arr, ok := jsonArrayValue(value); ok {
+        c := make(JSONArray, len(arr))
+        for i, item := range arr {
+            c[i] = CopyJSONValue(item)
+        }
+        return c
+    }
+    return value
+}
+
+// EqualJSONValues reports whether a and b hold the same JSON value:
+// objects with the same members in any order, arrays with equal elements,
+// and numbers of equal value whatever their Go types. Integers are compared
+// exactly, even beyond the precision of float64.
+func EqualJSONValues(a, b interface{}) bool {
+    if objA, ok := jsonObjectValue(a); ok {
+        objB, ok := jsonObjectValue(b)
+        if !ok || len(objA) != len(objB) {
+            return false
+        }
+        for k, v := range objA {
+            w, exists := objB[k]
+            if !exists || !EqualJSONValues(v, w) {
+                return false
+            }
+        }
+        return true
+    }
+    if arrA, ok := jsonArrayValue(a); ok {
+        arrB, ok := jsonArrayValue(b)
+        if !ok || len(arrA) != len(arrB) {
+            return false
+        }
+        for i := range arrA {
+            if !EqualJSONValues(arrA[i], arrB[i]) {
+                return false
+            }
+        }
+        return true
+    }
+    if fa, ok := jsonNumberValue(a); ok {
+        fb, ok := jsonNumberValue(b)
+        if !ok {
+            return false
+        }
+        if ia, ok := jsonIntegerText(a); ok {
+            if ib, ok := jsonIntegerText(b); ok {
+                return ia == ib
+            }
+        }
+        return fa == fb
+    }
+    if _, ok := jsonNumberValue(b); ok {
+        return false
+    }
+    return reflect.DeepEqual(a, b)
+}
+
+// jsonIntegerText returns the decimal text of an integer of any Go type,
+// including a json.Number holding an integer.
+func jsonIntegerText(value interface{}) (string, bool) {
+    switch v := value.(type) {
+    case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+        return JSONValueToString(v), true
+    case json.Number:
+        if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+            return strconv.FormatInt(n, 10), true
+        }
+        if n, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+            return strconv.FormatUint(n, 10), true
+        }
+    }
+    return "", false
+}
+
+// jsonNumberValue returns the value of a number of any Go type.
+func jsonNumberValue(value interface{}) (float64, bool) {
+    switch v := value.(type) {
+    case float64:
+        return v, true
+    case float32:
+        return float64(v), true
+    case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+        f, err := strconv.ParseFloat(JSONValueToString(v), 64)
+        return f, err == nil
+    case json.Number:
+        f, err := v.Float64()
+        return f, err == nil
+    }
+    return 0, false
+}
@@ -0,0 +1,209 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "math"
+    "strings"
+    "testing"
+)
+
+func mustParsePatch(t *testing.T, s string) JSONArray {
+    patch, ok := mustParse(t, s).(JSONArray)
+    if !ok {
+        t.Fatalf("%s is not an array", s)
+    }
+    return patch
+}
+
+// Most of the cases are the examples of RFC 6902 appendix A.
+func TestApplyPatch(t *testing.T) {
+    tests := []struct {
+        doc   string
+        patch string
+        want  string
+    }{
+        {`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+        {`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+        {`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+        {`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+        {`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+        {`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+        {`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+        {`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+        {`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`, `{"foo":"bar","baz":"qux"}`},
+        {`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+        {`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+        {`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+        {`{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`, `{"a":{"b":1},"c":{"b":2}}`},
+        {`{"a":1}`, `[{"op":"move","from":"/a","path":"/a"}]`, `{"a":1}`},
+        {`{"a":1}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+        {`{"a":1}`, `[{"op":"add","path":"","value":null}]`, `null`},
+        {`{"a":1}`, `[]`, `{"a":1}`},
+    }
+    for _, tt := range tests {
+        doc := mustParse(t, tt.doc)
+        got, err := ApplyPatch(doc, mustParsePatch(t, tt.patch))
+        if err != nil {
+            t.Errorf("ApplyPatch(%s, %s): %v", tt.doc, tt.patch, err)
+        } else if !EqualJSONValues(got, mustParse(t, tt.want)) {
+            t.Errorf("ApplyPatch(%s, %s) = %v, want %s", tt.doc, tt.patch, got, tt.want)
+        }
+        if !EqualJSONValues(doc, mustParse(t, tt.doc)) {
+            t.Errorf("ApplyPatch(%s, %s) changed its document to %v", tt.doc, tt.patch, doc)
+        }
+    }
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+    tests := []struct {
+        doc   string
+        patch string
+        want  string
+    }{
+        {`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, `patch operation 0 (add): `},
+        {`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, `patch operation 0 (test): value at "/baz" is not equal`},
+        {`{"a":1}`, `[{"op":"remove","path":"/a"},{"op":"remove","path":"/a"}]`, `patch operation 1 (remove): `},
+        {`{"a":1}`, `[{"op":"replace","path":"/b","value":2}]`, `patch operation 0 (replace): `},
+        {`{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, `cannot move "/a" into itself`},
+        {`{"a":1}`, `[{"op":"copy","from":"/x","path":"/b"}]`, `patch operation 0 (copy): `},
+        {`{"a":1}`, `[{"op":"add","value":1}]`, "missing path"},
+        {`{"a":1}`, `[{"op":"add","path":"/b"}]`, "missing value"},
+        {`{"a":1}`, `[{"op":"move","path":"/b"}]`, "missing from"},
+        {`{"a":1}`, `[{"op":"jump","path":"/a"}]`, `unknown op "jump"`},
+        {`{"a":1}`, `[1]`, "patch operation 0 is number, not an object"},
+    }
+    for _, tt := range tests {
+        doc := mustParse(t, tt.doc)
+        got, err := ApplyPatch(doc, mustParsePatch(t, tt.patch))
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("ApplyPatch(%s, %s) = %v, %v, want error %q", tt.doc, tt.patch, got, err, tt.want)
+        }
+        if !EqualJSONValues(doc, mustParse(t, tt.doc)) {
+            t.Errorf("failed ApplyPatch(%s, %s) changed its document to %v", tt.doc, tt.patch, doc)
+        }
+    }
+}
+
+func TestDiff(t *testing.T) {
+    tests := []struct {
+        a, b string
+        ops  int
+    }{
+        {`{"a":1}`, `{"a":1}`, 0},
+        {`{"a":1,"b":2}`, `{"a":1,"c":3}`, 2},
+        {`{"a":{"b":[1,2,3]}}`, `{"a":{"b":[1,4]}}`, 2},
+        {`[1]`, `[1,2,3]`, 2},
+        {`[1,{"x":1}]`, `[1,{"x":"1"}]`, 1},
+        {`{"a/b":{"~":1}}`, `{"a/b":{"~":2}}`, 1},
+        {`{"a":1}`, `[1]`, 1},
+        {`1`, `1.0`, 0},
+        {`null`, `{}`, 1},
+    }
+    for _, tt := range tests {
+        a, b := mustParse(t, tt.a), mustParse(t, tt.b)
+        patch := Diff(a, b)
+        if len(patch) != tt.ops {
+            t.Errorf("Diff(%s, %s) = %v, want %d operations", tt.a, tt.b, patch, tt.ops)
+        }
+        got, err := ApplyPatch(a, patch)
+        if err != nil {
+            t.Errorf("applying Diff(%s, %s): %v", tt.a, tt.b, err)
+        } else if !EqualJSONValues(got, b) {
+            t.Errorf("applying Diff(%s, %s) gave %v", tt.a, tt.b, got)
+        }
+    }
+}
+
+// The cases are the examples of RFC 7386 appendix A.
+func TestMergePatch(t *testing.T) {
+    tests := []struct {
+        target, patch, want string
+    }{
+        {`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+        {`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+        {`{"a":"b"}`, `{"a":null}`, `{}`},
+        {`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+        {`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+        {`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+        {`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+        {`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+        {`["a","b"]`, `["c","d"]`, `["c","d"]`},
+        {`{"a":"b"}`, `["c"]`, `["c"]`},
+        {`{"a":"foo"}`, `null`, `null`},
+        {`{"a":"foo"}`, `"bar"`, `"bar"`},
+        {`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+        {`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+        {`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+    }
+    for _, tt := range tests {
+        target := mustParse(t, tt.target)
+        got := MergePatch(target, mustParse(t, tt.patch))
+        if !EqualJSONValues(got, mustParse(t, tt.want)) {
+            t.Errorf("MergePatch(%s, %s) = %v, want %s", tt.target, tt.patch, got, tt.want)
+        }
+        if !EqualJSONValues(target, mustParse(t, tt.target)) {
+            t.Errorf("MergePatch(%s, %s) changed its target to %v", tt.target, tt.patch, target)
+        }
+    }
+    got := MergePatch(mustParseOrdered(t, `{"z":1,"a":2}`), mustParseOrdered(t, `{"m":3,"z":null}`))
+    if b, _ := json.Marshal(got); string(b) != `{"a":2,"m":3}` {
+        t.Errorf("ordered MergePatch gave %s", b)
+    }
+}
+
+func TestCopyJSONValue(t *testing.T) {
+    orig := mustParse(t, `{"a":[1,{"b":2}]}`)
+    c := CopyJSONValue(orig)
+    c.(JSONObject)["a"].(JSONArray)[1].(JSONObject)["b"] = 3.0
+    if !EqualJSONValues(orig, mustParse(t, `{"a":[1,{"b":2}]}`)) {
+        t.Errorf("changing the copy changed the original to %v", orig)
+    }
+    ordered := mustParseOrdered(t, `{"z":{"y":1},"a":2}`)
+    oc, ok := CopyJSONValue(ordered).(*OrderedJSONObject)
+    if !ok {
+        t.Fatalf("an ordered object copied as %T", CopyJSONValue(ordered))
+    }
+    oc.Set("b", 3)
+    if b, _ := json.Marshal(ordered); string(b) != `{"z":{"y":1},"a":2}` {
+        t.Errorf("changing the ordered copy changed the original to %s", b)
+    }
+    if v := CopyJSONValue(map[string]interface{}{"a": []interface{}{1}}); !EqualJSONValues(v, JSONObject{"a": JSONArray{1}}) {
+        t.Errorf("plain maps copied as %#v", v)
+    }
+}
+
+func TestEqualJSONValues(t *testing.T) {
+    tests := []struct {
+        a, b interface{}
+        want bool
+    }{
+        {nil, nil, true},
+        {1, 1.0, true},
+        {int8(1), uint64(1), true},
+        {json.Number("1e2"), 100, true},
+        {float32(0.5), 0.5, true},
+        {uint64(math.MaxUint64), uint64(math.MaxUint64 - 1), false},
+        {json.Number("9007199254740993"), int64(9007199254740992), false},
+        {json.Number("18446744073709551615"), uint64(math.MaxUint64), true},
+        {int64(math.MinInt64), json.Number("-9223372036854775808"), true},
+        {1, "1", false},
+        {"1", 1, false},
+        {0, false, false},
+        {nil, JSONObject{}, false},
+        {JSONArray{}, JSONObject{}, false},
+        {JSONObject{"a": 1, "b": JSONArray{2}}, map[string]interface{}{"b": []interface{}{2.0}, "a": 1.0}, true},
+        {JSONObject{"a": 1}, JSONObject{"a": 1, "b": 2}, false},
+        {JSONObject{"a": nil}, JSONObject{"b": nil}, false},
+        {JSONArray{1, 2}, JSONArray{2, 1}, false},
+        {mustParseOrdered(t, `{"a":1,"b":2}`), JSONObject{"b": 2, "a": 1}, true},
+    }
+    for _, tt := range tests {
+        if got := EqualJSONValues(tt.a, tt.b); got != tt.want {
+            t.Errorf("EqualJSONValues(%#v, %#v) = %v, want %v", tt.a, tt.b, got, tt.want)
+        }
+    }
+}
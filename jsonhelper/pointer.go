@@ -0,0 +1,213 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// ParsePointer splits a JSON Pointer (RFC 6901), such as "/users/0/name",
+// into its unescaped reference tokens. The empty pointer refers to the
+// whole document and has no tokens.
+func ParsePointer(ptr string) ([]string, error) {
+    if ptr == "" {
+        return nil, nil
+    }
+    if ptr[0] != '/' {
+        return nil, fmt.Errorf("jsonhelper: JSON Pointer %q does not start with /", ptr)
+    }
+    tokens := strings.Split(ptr[1:], "/")
+    for i, t := range tokens {
+        for j := 0; j < len(t); j++ {
+            if t[j] == '~' && (j+1 == len(t) || (t[j+1] != '0' && t[j+1] != '1')) {
+                return nil, fmt.Errorf("jsonhelper: JSON Pointer %q has an invalid ~ escape", ptr)
+            }
+        }
+        tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+    }
+    return tokens, nil
+}
+
+// GetPointer returns the value the JSON Pointer ptr refers to in doc.
+func GetPointer(doc interface{}, ptr string) (interface{}, error) {
+    tokens, err := ParsePointer(ptr)
+    if err != nil {
+        return nil, err
+    }
+    value := doc
+    for _, t := range tokens {
+        if value, err = pointerChild(value, t); err != nil {
+            return nil, fmt.Errorf("jsonhelper: JSON Pointer %q: %v", ptr, err)
+        }
+    }
+    return value, nil
+}
+
+// SetPointer stores value at the JSON Pointer ptr in doc as the JSON Patch
+// add operation does: object members are added or replaced, an array index
+// inserts before the element at that index and "-" appends. Containers are
+// changed in place where possible, and the resulting document, which
+// differs from doc when ptr is "" or an array grows, is returned.
+func SetPointer(doc interface{}, ptr string, value interface{}) (interface{}, error) {
+    return updatePointer(doc, ptr, func(container interface{}, t string) (interface{}, error) {
+        if _, ok := jsonObjectValue(container); ok {
+            setObjectMember(container, t, value)
+            return container, nil
+        }
+        if arr, ok := jsonArrayValue(container); ok {
+            i, err := pointerIndex(t, len(arr), true)
+            if err != nil {
+                return nil, err
+            }
+            arr = append(arr, nil)
+            copy(arr[i+1:], arr[i:])
+            arr[i] = value
+            return arr, nil
+        }
+        return nil, fmt.Errorf("cannot add to %s", describeJSONValue(container))
+    }, value)
+}
+
+// RemovePointer removes the value at the JSON Pointer ptr from doc,
+// returning the resulting document. Objects are changed in place, while an
+// array losing an element is copied.
+func RemovePointer(doc interface{}, ptr string) (interface{}, error) {
+    if ptr == "" {
+        return nil, fmt.Errorf("jsonhelper: cannot remove the whole document")
+    }
+    return updatePointer(doc, ptr, func(container interface{}, t string) (interface{}, error) {
+        if obj, ok := jsonObjectValue(container); ok {
+            if _, exists := obj[t]; !exists {
+                return nil, fmt.Errorf("no member %q", t)
+            }
+            deleteObjectMember(container, t)
+            return container, nil
+        }
+        if arr, ok := jsonArrayValue(container); ok {
+            i, err := pointerIndex(t, len(arr), false)
+            if err != nil {
+                return nil, err
+            }
+            return append(arr[:i:i], arr[i+1:]...), nil
+        }
+        return nil, fmt.Errorf("cannot remove from %s", describeJSONValue(container))
+    }, nil)
+}
+
+// replacePointer replaces the existing value at ptr.
+func replacePointer(doc interface{}, ptr string, value interface{}) (interface{}, error) {
+    return updatePointer(doc, ptr, func(container interface{}, t string) (interface{}, error) {
+        if obj, ok := jsonObjectValue(container); ok {
+            if _, exists := obj[t]; !exists {
+                return nil, fmt.Errorf("no member %q", t)
+            }
+            setObjectMember(container, t, value)
+            return container, nil
+        }
+        if arr, ok := jsonArrayValue(container); ok {
+            i, err := pointerIndex(t, len(arr), false)
+            if err != nil {
+                return nil, err
+            }
+            arr[i] = value
+            return arr, nil
+        }
+        return nil, fmt.Errorf("cannot replace in %s", describeJSONValue(container))
+    }, value)
+}
+
+// updatePointer calls fn with the container holding the value at ptr and
+// the last token of ptr, storing the container fn returns in its parent.
+// When ptr is "" the document is replaced by root.
+func updatePointer(doc interface{}, ptr string, fn func(container interface{}, t string) (interface{}, error), root interface{}) (interface{}, error) {
+    tokens, err := ParsePointer(ptr)
+    if err != nil {
+        return nil, err
+    }
+    if len(tokens) == 0 {
+        return root, nil
+    }
+    value, err := updateTokens(doc, tokens, fn)
+    if err != nil {
+        return nil, fmt.Errorf("jsonhelper: JSON Pointer %q: %v", ptr, err)
+    }
+    return value, nil
+}
+
+func updateTokens(value interface{}, tokens []string, fn func(container interface{}, t string) (interface{}, error)) (interface{}, error) {
+    if len(tokens) == 1 {
+        return fn(value, tokens[0])
+    }
+    child, err := pointerChild(value, tokens[0])
+    if err != nil {
+        return nil, err
+    }
+    if child, err = updateTokens(child, tokens[1:], fn); err != nil {
+        return nil, err
+    }
+    if _, ok := jsonObjectValue(value); ok {
+        setObjectMember(value, tokens[0], child)
+        return value, nil
+    }
+    arr, _ := jsonArrayValue(value)
+    i, _ := pointerIndex(tokens[0], len(arr), false)
+    arr[i] = child
+    return arr, nil
+}
+
+// pointerChild returns the member or element of value named by token t.
+func pointerChild(value interface{}, t string) (interface{}, error) {
+    if obj, ok := jsonObjectValue(value); ok {
+        child, exists := obj[t]
+        if !exists {
+            return nil, fmt.Errorf("no member %q", t)
+        }
+        return child, nil
+    }
+    if arr, ok := jsonArrayValue(value); ok {
+        i, err := pointerIndex(t, len(arr), false)
+        if err != nil {
+            return nil, err
+        }
+        return arr[i], nil
+    }
+    return nil, fmt.Errorf("cannot index into %s", describeJSONValue(value))
+}
+
+// pointerIndex converts t into an index of an array of length n. With
+// allowEnd set, n itself and "-" are allowed, to append.
+func pointerIndex(t string, n int, allowEnd bool) (int, error) {
+    if t == "-" && allowEnd {
+        return n, nil
+    }
+    if t == "" || (len(t) > 1 && t[0] == '0') || strings.TrimLeft(t, "0123456789") != "" {
+        return 0, fmt.Errorf("%q is not an array index", t)
+    }
+    i, err := strconv.Atoi(t)
+    if err != nil || i > n || (i == n && !allowEnd) {
+        return 0, errors.New("array index " + t + " is out of range")
+    }
+    return i, nil
+}
+
+// setObjectMember sets key in obj, which may be an *OrderedJSONObject.
+func setObjectMember(obj interface{}, key string, value interface{}) {
+    if p, ok := obj.(*OrderedJSONObject); ok {
+        p.Set(key, value)
+    } else if m, ok := jsonObjectValue(obj); ok {
+        m[key] = value
+    }
+}
+
+func deleteObjectMember(obj interface{}, key string) {
+    if p, ok := obj.(*OrderedJSONObject); ok {
+        p.Del(key)
+    } else if m, ok := jsonObjectValue(obj); ok {
+        delete(m, key)
+    }
+}
@@ -0,0 +1,186 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+const pointerSample = `{"foo":["bar","baz"],"":0,"a/b":1,"c%d":2,"e^f":3,"g|h":4,"i\\j":5,"k\"l":6," ":7,"m~n":8}`
+
+func TestParsePointer(t *testing.T) {
+    tests := []struct {
+        ptr  string
+        want []string
+    }{
+        {"", nil},
+        {"/", []string{""}},
+        {"/foo/0", []string{"foo", "0"}},
+        {"/a~1b", []string{"a/b"}},
+        {"/m~0n", []string{"m~n"}},
+        {"/~01", []string{"~1"}},
+        {"//x/", []string{"", "x", ""}},
+    }
+    for _, tt := range tests {
+        got, err := ParsePointer(tt.ptr)
+        if err != nil {
+            t.Errorf("ParsePointer(%q): %v", tt.ptr, err)
+        } else if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("ParsePointer(%q) = %q, want %q", tt.ptr, got, tt.want)
+        }
+    }
+    for _, bad := range []string{"foo", "/a~", "/a~2", "#/a"} {
+        if _, err := ParsePointer(bad); err == nil {
+            t.Errorf("ParsePointer(%q) succeeded", bad)
+        }
+    }
+}
+
+// The pointers are the examples of RFC 6901 section 5.
+func TestGetPointer(t *testing.T) {
+    doc := mustParse(t, pointerSample)
+    tests := []struct {
+        ptr  string
+        want interface{}
+    }{
+        {"", doc},
+        {"/foo", JSONArray{"bar", "baz"}},
+        {"/foo/0", "bar"},
+        {"/", 0.0},
+        {"/a~1b", 1.0},
+        {"/c%d", 2.0},
+        {"/e^f", 3.0},
+        {"/g|h", 4.0},
+        {"/i\\j", 5.0},
+        {"/k\"l", 6.0},
+        {"/ ", 7.0},
+        {"/m~0n", 8.0},
+    }
+    for _, tt := range tests {
+        got, err := GetPointer(doc, tt.ptr)
+        if err != nil {
+            t.Errorf("GetPointer(%q): %v", tt.ptr, err)
+        } else if !EqualJSONValues(got, tt.want) {
+            t.Errorf("GetPointer(%q) = %v, want %v", tt.ptr, got, tt.want)
+        }
+    }
+    ordered := mustParseOrdered(t, `{"a":[{"b":true}]}`)
+    if got, err := GetPointer(ordered, "/a/0/b"); err != nil || got != true {
+        t.Errorf("ordered GetPointer = %v, %v", got, err)
+    }
+}
+
+func TestGetPointerErrors(t *testing.T) {
+    doc := mustParse(t, pointerSample)
+    tests := []struct {
+        ptr  string
+        want string
+    }{
+        {"/missing", `no member "missing"`},
+        {"/foo/2", "array index 2 is out of range"},
+        {"/foo/-", `"-" is not an array index`},
+        {"/foo/01", `"01" is not an array index`},
+        {"/foo/+1", `"+1" is not an array index`},
+        {"/foo/", `"" is not an array index`},
+        {"/foo/99999999999999999999", "out of range"},
+        {"/foo/0/x", "cannot index into string"},
+        {"/a~1b/x", "cannot index into number"},
+        {"x", "does not start with /"},
+    }
+    for _, tt := range tests {
+        _, err := GetPointer(doc, tt.ptr)
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("GetPointer(%q) error = %v, want %q", tt.ptr, err, tt.want)
+        }
+    }
+}
+
+func TestSetPointer(t *testing.T) {
+    tests := []struct {
+        doc   string
+        ptr   string
+        value interface{}
+        want  string
+    }{
+        {`{"a":1}`, "/b", 2, `{"a":1,"b":2}`},
+        {`{"a":1}`, "/a", 3, `{"a":3}`},
+        {`{"a":[1,2]}`, "/a/0", 0, `{"a":[0,1,2]}`},
+        {`{"a":[1,2]}`, "/a/2", 3, `{"a":[1,2,3]}`},
+        {`{"a":[1,2]}`, "/a/-", 3, `{"a":[1,2,3]}`},
+        {`{"a":{"b":[]}}`, "/a/b/-", "x", `{"a":{"b":["x"]}}`},
+        {`[[1]]`, "/0/-", 2, `[[1,2]]`},
+        {`{"a":1}`, "", "whole", `"whole"`},
+    }
+    for _, tt := range tests {
+        got, err := SetPointer(mustParse(t, tt.doc), tt.ptr, tt.value)
+        if err != nil {
+            t.Errorf("SetPointer(%s, %q): %v", tt.doc, tt.ptr, err)
+        } else if !EqualJSONValues(got, mustParse(t, tt.want)) {
+            t.Errorf("SetPointer(%s, %q) = %v, want %s", tt.doc, tt.ptr, got, tt.want)
+        }
+    }
+    ordered := mustParseOrdered(t, `{"z":1,"a":{"y":2}}`)
+    if _, err := SetPointer(ordered, "/a/b", 3); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := SetPointer(ordered, "/m", 4); err != nil {
+        t.Fatal(err)
+    }
+    if b, _ := json.Marshal(ordered); string(b) != `{"z":1,"a":{"y":2,"b":3},"m":4}` {
+        t.Errorf("ordered SetPointer gave %s", b)
+    }
+    for _, tt := range []struct{ doc, ptr string }{
+        {`{"a":[1]}`, "/a/2"},
+        {`{"a":[1]}`, "/a/x"},
+        {`{"a":1}`, "/a/b"},
+        {`{"a":1}`, "/x/y"},
+    } {
+        if _, err := SetPointer(mustParse(t, tt.doc), tt.ptr, 0); err == nil {
+            t.Errorf("SetPointer(%s, %q) succeeded", tt.doc, tt.ptr)
+        }
+    }
+}
+
+func TestRemovePointer(t *testing.T) {
+    tests := []struct {
+        doc  string
+        ptr  string
+        want string
+    }{
+        {`{"a":1,"b":2}`, "/a", `{"b":2}`},
+        {`{"a":[1,2,3]}`, "/a/1", `{"a":[1,3]}`},
+        {`{"a":[1,2,3]}`, "/a/2", `{"a":[1,2]}`},
+        {`[{"a":{"b":1,"c":2}}]`, "/0/a/b", `[{"a":{"c":2}}]`},
+    }
+    for _, tt := range tests {
+        got, err := RemovePointer(mustParse(t, tt.doc), tt.ptr)
+        if err != nil {
+            t.Errorf("RemovePointer(%s, %q): %v", tt.doc, tt.ptr, err)
+        } else if !EqualJSONValues(got, mustParse(t, tt.want)) {
+            t.Errorf("RemovePointer(%s, %q) = %v, want %s", tt.doc, tt.ptr, got, tt.want)
+        }
+    }
+    arr := JSONArray{1, 2, 3}
+    if _, err := RemovePointer(arr, "/0"); err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(arr, JSONArray{1, 2, 3}) {
+        t.Errorf("RemovePointer changed the array it was given to %v", arr)
+    }
+    for _, tt := range []struct{ doc, ptr string }{
+        {`{"a":1}`, ""},
+        {`{"a":1}`, "/b"},
+        {`{"a":[1]}`, "/a/1"},
+        {`{"a":[1]}`, "/a/-"},
+        {`{"a":"s"}`, "/a/0"},
+    } {
+        if _, err := RemovePointer(mustParse(t, tt.doc), tt.ptr); err == nil {
+            t.Errorf("RemovePointer(%s, %q) succeeded", tt.doc, tt.ptr)
+        }
+    }
+}
@@ -0,0 +1,163 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "strconv"
+    "strings"
+)
+
+// RedactionMode selects what happens to sensitive values, both for struct
+// fields tagged with the sensitive option during Marshal and for paths
+// given to Redact.
+type RedactionMode int
+
+const (
+    // RedactMask replaces the value with a mask string.
+    RedactMask RedactionMode = iota
+    // RedactHash replaces the value with "sha256:" and the hex SHA-256 of
+    // its string form, so equal values can still be correlated.
+    RedactHash
+    // RedactDrop removes the value altogether.
+    RedactDrop
+    // RedactNone leaves sensitive values untouched.
+    RedactNone
+)
+
+// DefaultRedactionMask is used by RedactMask when no mask is given.
+const DefaultRedactionMask = "********"
+
+// redactValue applies mode to value, returning false if it must be dropped.
+func redactValue(value interface{}, mode RedactionMode, mask string) (interface{}, bool) {
+    switch mode {
+    case RedactNone:
+        return value, true
+    case RedactDrop:
+        return nil, false
+    }
+    if value == nil {
+        return nil, true
+    }
+    if mode == RedactHash {
+        sum := sha256.Sum256([]byte(JSONValueToString(value)))
+        return "sha256:" + hex.EncodeToString(sum[:]), true
+    }
+    if mask == "" {
+        mask = DefaultRedactionMask
+    }
+    return mask, true
+}
+
+// Redact returns a copy of value in which every member matching one of the
+// path patterns has been redacted with mode. Patterns are either
+// dot-separated ("**.token", "users.*.ssn") or JSON Pointer style
+// ("/users/*/ssn"); a "*" segment matches any single key or array index
+// and a "**" segment matches any number of them. An empty mask uses
+// DefaultRedactionMask.
+func Redact(value interface{}, patterns []string, mode RedactionMode, mask string) interface{} {
+    compiled := make([][]string, len(patterns))
+    for i, p := range patterns {
+        compiled[i] = splitPathPattern(p)
+    }
+    return redactTree(value, nil, compiled, mode, mask)
+}
+
+// splitPathPattern splits a dotted or JSON Pointer style pattern into its
+// segments.
+func splitPathPattern(p string) []string {
+    if strings.HasPrefix(p, "/") {
+        segs := strings.Split(p[1:], "/")
+        for i, s := range segs {
+            segs[i] = strings.Replace(strings.Replace(s, "~1", "/", -1), "~0", "~", -1)
+        }
+        return segs
+    }
+    return strings.Split(p, ".")
+}
+
+// globMatch reports whether path matches pattern, where "*" matches one
+// segment and "**" any number of segments.
+func globMatch(pattern, path []string) bool {
+    for len(pattern) > 0 {
+        if pattern[0] == "**" {
+            for i := 0; i <= len(path); i++ {
+                if globMatch(pattern[1:], path[i:]) {
+                    return true
+                }
+            }
+            return false
+        }
+        if len(path) == 0 || (pattern[0] != "*" && pattern[0] != path[0]) {
+            return false
+        }
+        pattern, path = pattern[1:], path[1:]
+    }
+    return len(path) == 0
+}
+
+func redactTree(value interface{}, path []string, patterns [][]string, mode RedactionMode, mask string) interface{} {
+    switch v := value.(type) {
+    case map[string]interface{}:
+        return redactTree(NewJSONObjectFromMap(v), path, patterns, mode, mask)
+    case []interface{}:
+        return redactTree(NewJSONArrayFromArray(v), path, patterns, mode, mask)
+    case JSONObject:
+        m := NewJSONObject()
+        for k, item := range v {
+            itemPath := append(path[:len(path):len(path)], k)
+            if redacted, keep, matched := redactMember(item, itemPath, patterns, mode, mask); matched {
+                if keep {
+                    m[k] = redacted
+                }
+                continue
+            }
+            m[k] = redactTree(item, itemPath, patterns, mode, mask)
+        }
+        return m
+    case JSONArray:
+        arr := make([]interface{}, 0, len(v))
+        for i, item := range v {
+            itemPath := append(path[:len(path):len(path)], strconv.Itoa(i))
+            if redacted, keep, matched := redactMember(item, itemPath, patterns, mode, mask); matched {
+                if keep {
+                    arr = append(arr, redacted)
+                }
+                continue
+            }
+            arr = append(arr, redactTree(item, itemPath, patterns, mode, mask))
+        }
+        return NewJSONArrayFromArray(arr)
+    case *OrderedJSONObject:
+        if v == nil {
+            return v
+        }
+        m := NewOrderedJSONObject()
+        for _, k := range v.keys {
+            item := v.values[k]
+            itemPath := append(path[:len(path):len(path)], k)
+            if redacted, keep, matched := redactMember(item, itemPath, patterns, mode, mask); matched {
+                if keep {
+                    m.Set(k, redacted)
+                }
+                continue
+            }
+            m.Set(k, redactTree(item, itemPath, patterns, mode, mask))
+        }
+        return m
+    }
+    return value
+}
+
+func redactMember(value interface{}, path []string, patterns [][]string, mode RedactionMode, mask string) (interface{}, bool, bool) {
+    for _, p := range patterns {
+        if globMatch(p, path) {
+            redacted, keep := redactValue(value, mode, mask)
+            return redacted, keep, true
+        }
+    }
+    return nil, false, false
+}
@@ -0,0 +1,84 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "reflect"
+    "testing"
+)
+
+type credentials struct {
+    User     string  `json:"user"`
+    Password string  `json:"password,sensitive"`
+    PIN      *int    `json:"pin,sensitive"`
+    Backup   *string `json:"backup,omitempty,sensitive"`
+}
+
+func TestMarshalSensitive(t *testing.T) {
+    pin := 1234
+    in := credentials{User: "u", Password: "p", PIN: &pin}
+    tests := []struct {
+        name string
+        opts MarshalOptions
+        want JSONObject
+    }{
+        {"default mask", MarshalOptions{},
+            JSONObject{"user": "u", "password": DefaultRedactionMask, "pin": DefaultRedactionMask}},
+        {"custom mask", MarshalOptions{RedactionMask: "x"},
+            JSONObject{"user": "u", "password": "x", "pin": "x"}},
+        {"hash", MarshalOptions{Redaction: RedactHash},
+            JSONObject{"user": "u", "password": "sha256:" + hashOf("p"), "pin": "sha256:" + hashOf("1234")}},
+        {"drop", MarshalOptions{Redaction: RedactDrop},
+            JSONObject{"user": "u"}},
+        {"none", MarshalOptions{Redaction: RedactNone},
+            JSONObject{"user": "u", "password": "p", "pin": int64(1234)}},
+    }
+    for _, tt := range tests {
+        got, err := MarshalWithOptions(in, tt.opts)
+        if err != nil || !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%s: got %#v, %v, want %#v", tt.name, got, err, tt.want)
+        }
+    }
+    got, _ := Marshal(credentials{User: "u"})
+    if want := (JSONObject{"user": "u", "password": DefaultRedactionMask, "pin": nil}); !reflect.DeepEqual(got, want) {
+        t.Errorf("nil values: got %#v, want %#v", got, want)
+    }
+}
+
+func hashOf(s string) string {
+    sum := sha256.Sum256([]byte(s))
+    return hex.EncodeToString(sum[:])
+}
+
+func TestRedact(t *testing.T) {
+    const doc = `{"token":"t","user":{"token":"u","ssn":"1","list":[{"ssn":"2"},{"ssn":"3","token":null}]},"keep":"k"}`
+    tests := []struct {
+        patterns []string
+        mode     RedactionMode
+        want     string
+    }{
+        {[]string{"token"}, RedactMask, `{"token":"#","user":{"token":"u","ssn":"1","list":[{"ssn":"2"},{"ssn":"3","token":null}]},"keep":"k"}`},
+        {[]string{"**.token"}, RedactMask, `{"token":"#","user":{"token":"#","ssn":"1","list":[{"ssn":"2"},{"ssn":"3","token":null}]},"keep":"k"}`},
+        {[]string{"user.list.*.ssn"}, RedactDrop, `{"token":"t","user":{"token":"u","ssn":"1","list":[{},{"token":null}]},"keep":"k"}`},
+        {[]string{"/user/list/1"}, RedactMask, `{"token":"t","user":{"token":"u","ssn":"1","list":[{"ssn":"2"},"#"]},"keep":"k"}`},
+        {[]string{"/user/*"}, RedactDrop, `{"token":"t","user":{},"keep":"k"}`},
+        {[]string{"nothing.here"}, RedactMask, doc},
+    }
+    for _, tt := range tests {
+        in, _ := Parse([]byte(doc), ParseOptions{})
+        got := Redact(in, tt.patterns, tt.mode, "#")
+        want, _ := Parse([]byte(tt.want), ParseOptions{})
+        if !EqualJSONValues(got, want) {
+            b, _ := json.Marshal(got)
+            t.Errorf("%v: got %s, want %s", tt.patterns, b, tt.want)
+        }
+        if orig, _ := Parse([]byte(doc), ParseOptions{}); !EqualJSONValues(in, orig) {
+            t.Errorf("%v: Redact modified its input", tt.patterns)
+        }
+    }
+}
@@ -44,3 +44,24 @@ func (o tagOptions) Contains(optionName string) bool {
     }
     return false
 }
+
+// Get returns the value of a "name=value" option, and whether the option
+// was present at all.
+func (o tagOptions) Get(optionName string) (string, bool) {
+    s := string(o)
+    for s != "" {
+        var next string
+        i := strings.Index(s, ",")
+        if i >= 0 {
+            s, next = s[:i], s[i+1:]
+        }
+        if s == optionName {
+            return "", true
+        }
+        if strings.HasPrefix(s, optionName) && s[len(optionName)] == '=' {
+            return s[len(optionName)+1:], true
+        }
+        s = next
+    }
+    return "", false
+}
@@ -0,0 +1,55 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Special time formats, usable as MarshalOptions.TimeFormat, as the
+// format argument of JSONValueToTime and GetAsTime, or as the unix and
+// unixmilli tag options, that represent a time.Time as an epoch number.
+const (
+    TimeFormatUnix      = "unix"
+    TimeFormatUnixMilli = "unixmilli"
+)
+
+func isUnixTimeFormat(format string) bool {
+    return format == TimeFormatUnix || format == TimeFormatUnixMilli
+}
+
+// FormatTime converts t into the JSON value described by format.
+func FormatTime(t time.Time, format string) interface{} {
+    switch format {
+    case TimeFormatUnix:
+        return t.Unix()
+    case TimeFormatUnixMilli:
+        return t.Unix()*1000 + int64(t.Nanosecond())/int64(time.Millisecond)
+    }
+    return t.Format(format)
+}
+
+// unixTime is the inverse of FormatTime for the epoch formats.
+func unixTime(n int64, format string) time.Time {
+    if format == TimeFormatUnixMilli {
+        return time.Unix(n/1000, n%1000*int64(time.Millisecond)).UTC()
+    }
+    return time.Unix(n, 0).UTC()
+}
+
+// parseTime parses s with format, accepting decimal epoch values for the
+// epoch formats.
+func parseTime(s string, format string) (time.Time, error) {
+    if isUnixTimeFormat(format) {
+        n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+        if err != nil {
+            return time.Time{}, err
+        }
+        return unixTime(n, format), nil
+    }
+    return time.Parse(format, s)
+}
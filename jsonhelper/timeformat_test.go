@@ -0,0 +1,160 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "reflect"
+    "testing"
+    "time"
+)
+
+// money reports itself zero when it has no currency, whatever its amount.
+type money struct {
+    Amount   int64  `json:"amount"`
+    Currency string `json:"currency"`
+}
+
+func (m money) IsZero() bool { return m.Currency == "" }
+
+type fieldOptionsSample struct {
+    Day      time.Time     `json:"day,format=2006-01-02"`
+    Unix     time.Time     `json:"unix,unix"`
+    Milli    time.Time     `json:"milli,unixmilli"`
+    Default  time.Time     `json:"default"`
+    Timeout  time.Duration `json:"timeout,duration=ms"`
+    Interval time.Duration `json:"interval,duration=iso8601"`
+    Skipped  time.Time     `json:"skipped,omitzero"`
+    Price    money         `json:"price,omitzero"`
+    Count    int           `json:"count,omitzero"`
+}
+
+func TestFieldTimeOptions(t *testing.T) {
+    when := time.Date(2012, 3, 4, 5, 6, 7, 8000000, time.UTC)
+    in := fieldOptionsSample{
+        Day:      time.Date(2012, 3, 4, 0, 0, 0, 0, time.UTC),
+        Unix:     when.Truncate(time.Second),
+        Milli:    when,
+        Default:  when,
+        Timeout:  1500 * time.Millisecond,
+        Interval: 90 * time.Minute,
+        Price:    money{Amount: 5},
+    }
+    v, err := MarshalWithOptions(in, MarshalOptions{TimeFormat: time.RFC1123})
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := JSONObject{
+        "day":      "2012-03-04",
+        "unix":     when.Unix(),
+        "milli":    when.Unix()*1000 + 8,
+        "default":  when.Format(time.RFC1123),
+        "timeout":  int64(1500),
+        "interval": "PT1H30M",
+    }
+    if !reflect.DeepEqual(v, want) {
+        t.Fatalf("got %#v, want %#v", v, want)
+    }
+    var out fieldOptionsSample
+    if err := UnmarshalWithOptions(v, &out, UnmarshalOptions{TimeFormat: time.RFC1123}); err != nil {
+        t.Fatal(err)
+    }
+    in.Default = in.Default.Truncate(time.Second)
+    in.Price = money{}
+    if !reflect.DeepEqual(out, in) {
+        t.Errorf("round trip gave %+v, want %+v", out, in)
+    }
+}
+
+type pointerTimeSample struct {
+    Unix    *time.Time `json:"unix,unix"`
+    Milli   *time.Time `json:"milli,unixmilli"`
+    Day     *time.Time `json:"day,format=2006-01-02"`
+    Default *time.Time `json:"default"`
+    Missing *time.Time `json:"missing"`
+}
+
+func TestPointerTimeOptions(t *testing.T) {
+    when := time.Date(2020, 5, 6, 7, 8, 9, 123456789, time.UTC)
+    unix, milli := when.Truncate(time.Second), when.Truncate(time.Millisecond)
+    day := time.Date(2020, 5, 6, 0, 0, 0, 0, time.UTC)
+    def := when.Truncate(time.Second)
+    in := pointerTimeSample{Unix: &unix, Milli: &milli, Day: &day, Default: &def}
+    v, err := MarshalWithOptions(in, MarshalOptions{TimeFormat: time.RFC1123})
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := JSONObject{
+        "unix":    when.Unix(),
+        "milli":   when.Unix()*1000 + 123,
+        "day":     "2020-05-06",
+        "default": when.Format(time.RFC1123),
+        "missing": nil,
+    }
+    if !reflect.DeepEqual(v, want) {
+        t.Fatalf("got %#v, want %#v", v, want)
+    }
+    var out pointerTimeSample
+    if err := UnmarshalWithOptions(v, &out, UnmarshalOptions{TimeFormat: time.RFC1123}); err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(out, in) {
+        t.Errorf("round trip gave %+v, want %+v", out, in)
+    }
+
+    // Without options a *time.Time is written as RFC 3339, like time.Time.
+    v, err = Marshal(struct {
+        When *time.Time `json:"when"`
+    }{&when})
+    if err != nil || v.(JSONObject)["when"] != when.Format(time.RFC3339Nano) {
+        t.Errorf("default format gave %#v, %v", v, err)
+    }
+}
+
+func TestOmitZero(t *testing.T) {
+    tests := []struct {
+        in   fieldOptionsSample
+        keys []string
+    }{
+        {fieldOptionsSample{Count: 1}, []string{"count"}},
+        {fieldOptionsSample{Price: money{Currency: "EUR"}}, []string{"price"}},
+        {fieldOptionsSample{Skipped: time.Unix(0, 0)}, []string{"skipped"}},
+    }
+    for _, tt := range tests {
+        v, err := Marshal(tt.in)
+        if err != nil {
+            t.Fatal(err)
+        }
+        obj := v.(JSONObject)
+        for _, k := range tt.keys {
+            if _, ok := obj[k]; !ok {
+                t.Errorf("%+v: missing %s in %v", tt.in, k, obj)
+            }
+        }
+        for _, k := range []string{"skipped", "price", "count"} {
+            if _, ok := obj[k]; ok && k != tt.keys[0] {
+                t.Errorf("%+v: unexpected %s in %v", tt.in, k, obj)
+            }
+        }
+    }
+}
+
+func TestJSONValueToTimeEpoch(t *testing.T) {
+    tests := []struct {
+        value  interface{}
+        format string
+        want   time.Time
+    }{
+        {int64(1331000000), TimeFormatUnix, time.Unix(1331000000, 0)},
+        {"1331000000", TimeFormatUnix, time.Unix(1331000000, 0)},
+        {float64(1331000000123), TimeFormatUnixMilli, time.Unix(1331000000, 123000000)},
+        {int64(-1500), TimeFormatUnixMilli, time.Unix(-2, 500000000)},
+        {"2012-03-04", "2006-01-02", time.Date(2012, 3, 4, 0, 0, 0, 0, time.UTC)},
+    }
+    for _, tt := range tests {
+        if got := JSONValueToTime(tt.value, tt.format); !got.Equal(tt.want) {
+            t.Errorf("JSONValueToTime(%#v, %q) = %v, want %v", tt.value, tt.format, got, tt.want)
+        }
+    }
+}
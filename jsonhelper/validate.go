@@ -0,0 +1,473 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "fmt"
+    "math"
+    "regexp"
+    "strings"
+    "unicode/utf8"
+)
+
+// ValidationError describes a value that does not match a schema, or a
+// schema that is not valid.
+type ValidationError struct {
+    // Path is the JSON Pointer of the value, "" for the whole document.
+    // When Schema is set it points into the schema instead.
+    Path   string
+    Msg    string
+    Schema bool
+}
+
+func (e *ValidationError) Error() string {
+    prefix := "jsonhelper: "
+    if e.Schema {
+        prefix += "invalid schema: "
+    }
+    if e.Path == "" {
+        return prefix + e.Msg
+    }
+    return prefix + e.Path + ": " + e.Msg
+}
+
+// ValidateSchema checks value against the JSON Schema schema and returns
+// every mismatch found, or nil when value is valid. The keywords checked
+// are type, enum, const, properties, required, additionalProperties,
+// minProperties, maxProperties, items, minItems, maxItems, uniqueItems,
+// minLength, maxLength, pattern, format, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, multipleOf, allOf, anyOf, oneOf, not
+// and $ref to a JSON Pointer within schema, such as "#/$defs/item". Other
+// keywords are ignored.
+//
+// A schema that misuses one of these keywords, such as a pattern that does
+// not compile or a minItems that is not a non-negative integer, is not
+// checked against value. The errors returned then have Schema set and
+// describe the schema.
+func ValidateSchema(value, schema interface{}) []*ValidationError {
+    v := &validator{root: schema, patterns: make(map[string]*regexp.Regexp), checked: make(map[string]bool)}
+    v.checkSchema(schema, "")
+    if len(v.errors) > 0 {
+        return v.errors
+    }
+    v.validate(value, schema, "")
+    return v.errors
+}
+
+type validator struct {
+    root     interface{}
+    errors   []*ValidationError
+    depth    int
+    patterns map[string]*regexp.Regexp
+    checked  map[string]bool
+}
+
+func (v *validator) invalid(path, format string, args ...interface{}) {
+    v.errors = append(v.errors, &ValidationError{Path: path, Msg: fmt.Sprintf(format, args...), Schema: true})
+}
+
+// schemaKeywords lists the kinds of value the keywords of a schema take.
+var schemaKeywords = map[string]string{
+    "type":                 "type",
+    "enum":                 "array",
+    "required":             "strings",
+    "properties":           "schemas",
+    "additionalProperties": "schema",
+    "minProperties":        "count",
+    "maxProperties":        "count",
+    "items":                "schema",
+    "minItems":             "count",
+    "maxItems":             "count",
+    "uniqueItems":          "boolean",
+    "minLength":            "count",
+    "maxLength":            "count",
+    "pattern":              "pattern",
+    "format":               "string",
+    "minimum":              "number",
+    "maximum":              "number",
+    "exclusiveMinimum":     "number",
+    "exclusiveMaximum":     "number",
+    "multipleOf":           "positive",
+    "allOf":                "schema list",
+    "anyOf":                "schema list",
+    "oneOf":                "schema list",
+    "not":                  "schema",
+    "$ref":                 "ref",
+    "$defs":                "schemas",
+    "definitions":          "schemas",
+}
+
+// checkSchema records an error for each keyword of schema, and of the
+// schemas within it, whose value is not of the kind the keyword takes.
+func (v *validator) checkSchema(schema interface{}, path string) {
+    if v.checked[path] {
+        return
+    }
+    v.checked[path] = true
+    if _, ok := schema.(bool); ok {
+        return
+    }
+    s, ok := jsonObjectValue(schema)
+    if !ok {
+        v.invalid(path, "a schema must be an object or a boolean, not %s", describeJSONValue(schema))
+        return
+    }
+    for _, k := range orderedJSONObjectKeys(schema, s) {
+        kind, ok := schemaKeywords[k]
+        if !ok {
+            continue
+        }
+        value := s[k]
+        at := path + "/" + jsonPointerToken(k)
+        switch kind {
+        case "type":
+            names, ok := jsonArrayValue(value)
+            if !ok {
+                names = JSONArray{value}
+            }
+            for _, name := range names {
+                if n, ok := name.(string); !ok || !isSchemaTypeName(n) {
+                    v.invalid(at, "%s is not a type name", describeJSONValue(name))
+                }
+            }
+        case "array":
+            if _, ok := jsonArrayValue(value); !ok {
+                v.invalid(at, "%s takes an array", k)
+            }
+        case "strings":
+            arr, ok := jsonArrayValue(value)
+            for _, item := range arr {
+                if _, isString := item.(string); !isString {
+                    ok = false
+                }
+            }
+            if !ok {
+                v.invalid(at, "%s takes an array of strings", k)
+            }
+        case "schemas":
+            obj, ok := jsonObjectValue(value)
+            if !ok {
+                v.invalid(at, "%s takes an object of schemas", k)
+                break
+            }
+            for _, name := range orderedJSONObjectKeys(value, obj) {
+                v.checkSchema(obj[name], at+"/"+jsonPointerToken(name))
+            }
+        case "schema":
+            v.checkSchema(value, at)
+        case "schema list":
+            arr, ok := jsonArrayValue(value)
+            if !ok || len(arr) == 0 {
+                v.invalid(at, "%s takes a non-empty array of schemas", k)
+                break
+            }
+            for i, sub := range arr {
+                v.checkSchema(sub, fmt.Sprintf("%s/%d", at, i))
+            }
+        case "count":
+            if f, ok := jsonNumberValue(value); !ok || f < 0 || f != math.Trunc(f) || math.IsInf(f, 0) {
+                v.invalid(at, "%s takes a non-negative integer", k)
+            }
+        case "boolean":
+            if _, ok := value.(bool); !ok {
+                v.invalid(at, "%s takes a boolean", k)
+            }
+        case "string":
+            if _, ok := value.(string); !ok {
+                v.invalid(at, "%s takes a string", k)
+            }
+        case "pattern":
+            pattern, ok := value.(string)
+            if !ok {
+                v.invalid(at, "%s takes a string", k)
+            } else if re, err := regexp.Compile(pattern); err != nil {
+                v.invalid(at, "invalid pattern %q: %v", pattern, err)
+            } else {
+                v.patterns[pattern] = re
+            }
+        case "number":
+            if _, ok := jsonNumberValue(value); !ok {
+                v.invalid(at, "%s takes a number", k)
+            }
+        case "positive":
+            if f, ok := jsonNumberValue(value); !ok || f <= 0 {
+                v.invalid(at, "%s takes a number greater than 0", k)
+            }
+        case "ref":
+            ref, ok := value.(string)
+            if !ok {
+                v.invalid(at, "%s takes a string", k)
+            } else if target, err := GetPointer(v.root, strings.TrimPrefix(ref, "#")); err != nil || !strings.HasPrefix(ref, "#") {
+                v.invalid(at, "cannot resolve $ref %q", ref)
+            } else {
+                // The target may lie outside the keywords walked here.
+                v.checkSchema(target, ref[1:])
+            }
+        }
+    }
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+    v.errors = append(v.errors, &ValidationError{Path: path, Msg: fmt.Sprintf(format, args...)})
+}
+
+// valid reports whether value matches schema without recording errors.
+func (v *validator) valid(value, schema interface{}, path string) bool {
+    n := len(v.errors)
+    v.validate(value, schema, path)
+    ok := len(v.errors) == n
+    v.errors = v.errors[:n]
+    return ok
+}
+
+func (v *validator) validate(value, schema interface{}, path string) {
+    if b, ok := schema.(bool); ok {
+        if !b {
+            v.fail(path, "no value is allowed")
+        }
+        return
+    }
+    s, ok := jsonObjectValue(schema)
+    if !ok {
+        return
+    }
+    if ref, ok := s["$ref"].(string); ok {
+        v.depth++
+        defer func() { v.depth-- }()
+        if v.depth > 100 {
+            v.fail(path, "$ref %q recurses too deeply", ref)
+            return
+        }
+        target, _ := GetPointer(v.root, ref[1:])
+        v.validate(value, target, path)
+    }
+    if t, ok := s["type"]; ok {
+        v.checkType(value, t, path)
+    }
+    if enum, ok := jsonArrayValue(s["enum"]); ok {
+        found := false
+        for _, e := range enum {
+            if EqualJSONValues(value, e) {
+                found = true
+                break
+            }
+        }
+        if !found {
+            v.fail(path, "value is not one of the enum values")
+        }
+    }
+    if c, ok := s["const"]; ok && !EqualJSONValues(value, c) {
+        v.fail(path, "value is not the const value")
+    }
+    if obj, ok := jsonObjectValue(value); ok {
+        v.validateObject(value, obj, s, path)
+    }
+    if arr, ok := jsonArrayValue(value); ok {
+        v.validateArray(arr, s, path)
+    }
+    if str, ok := value.(string); ok {
+        v.validateString(str, s, path)
+    }
+    if f, ok := jsonNumberValue(value); ok {
+        v.validateNumber(f, s, path)
+    }
+    if all, ok := jsonArrayValue(s["allOf"]); ok {
+        for _, sub := range all {
+            v.validate(value, sub, path)
+        }
+    }
+    if any, ok := jsonArrayValue(s["anyOf"]); ok {
+        matched := false
+        for _, sub := range any {
+            if v.valid(value, sub, path) {
+                matched = true
+                break
+            }
+        }
+        if !matched {
+            v.fail(path, "value matches none of anyOf")
+        }
+    }
+    if one, ok := jsonArrayValue(s["oneOf"]); ok {
+        matches := 0
+        for _, sub := range one {
+            if v.valid(value, sub, path) {
+                matches++
+            }
+        }
+        if matches != 1 {
+            v.fail(path, "value matches %d of oneOf, not 1", matches)
+        }
+    }
+    if not, ok := s["not"]; ok && v.valid(value, not, path) {
+        v.fail(path, "value matches not")
+    }
+}
+
+func (v *validator) checkType(value, t interface{}, path string) {
+    var types []string
+    if name, ok := t.(string); ok {
+        types = []string{name}
+    } else if arr, ok := jsonArrayValue(t); ok {
+        for _, name := range arr {
+            types = append(types, JSONValueToString(name))
+        }
+    }
+    for _, name := range types {
+        if isSchemaType(value, name) {
+            return
+        }
+    }
+    v.fail(path, "%s is not of type %s", describeJSONValue(value), strings.Join(types, " or "))
+}
+
+// isSchemaTypeName reports whether name is one of the JSON Schema types.
+func isSchemaTypeName(name string) bool {
+    switch name {
+    case "object", "array", "string", "number", "integer", "boolean", "null":
+        return true
+    }
+    return false
+}
+
+// isSchemaType reports whether value is of the JSON Schema type name.
+func isSchemaType(value interface{}, name string) bool {
+    switch name {
+    case "object":
+        _, ok := jsonObjectValue(value)
+        return ok
+    case "array":
+        _, ok := jsonArrayValue(value)
+        return ok
+    case "string":
+        _, ok := value.(string)
+        return ok
+    case "number":
+        _, ok := jsonNumberValue(value)
+        return ok
+    case "integer":
+        f, ok := jsonNumberValue(value)
+        return ok && f == math.Trunc(f) && !math.IsInf(f, 0)
+    case "boolean":
+        _, ok := value.(bool)
+        return ok
+    case "null":
+        return value == nil
+    }
+    return false
+}
+
+func (v *validator) validateObject(value interface{}, obj JSONObject, s JSONObject, path string) {
+    for _, k := range s.GetAsArray("required") {
+        key := JSONValueToString(k)
+        if _, ok := obj[key]; !ok {
+            v.fail(path, "missing required property %q", key)
+        }
+    }
+    if n, ok := schemaInt(s, "minProperties"); ok && len(obj) < n {
+        v.fail(path, "object has %d properties, fewer than %d", len(obj), n)
+    }
+    if n, ok := schemaInt(s, "maxProperties"); ok && len(obj) > n {
+        v.fail(path, "object has %d properties, more than %d", len(obj), n)
+    }
+    props, _ := jsonObjectValue(s["properties"])
+    additional, hasAdditional := s["additionalProperties"]
+    for _, k := range orderedJSONObjectKeys(value, obj) {
+        child := path + "/" + jsonPointerToken(k)
+        if sub, ok := props[k]; ok {
+            v.validate(obj[k], sub, child)
+        } else if hasAdditional {
+            if b, ok := additional.(bool); ok && !b {
+                v.fail(child, "additional property %q is not allowed", k)
+            } else {
+                v.validate(obj[k], additional, child)
+            }
+        }
+    }
+}
+
+func (v *validator) validateArray(arr JSONArray, s JSONObject, path string) {
+    if n, ok := schemaInt(s, "minItems"); ok && len(arr) < n {
+        v.fail(path, "array has %d items, fewer than %d", len(arr), n)
+    }
+    if n, ok := schemaInt(s, "maxItems"); ok && len(arr) > n {
+        v.fail(path, "array has %d items, more than %d", len(arr), n)
+    }
+    if unique, _ := s["uniqueItems"].(bool); unique {
+    outer:
+        for i := range arr {
+            for j := 0; j < i; j++ {
+                if EqualJSONValues(arr[i], arr[j]) {
+                    v.fail(path, "items %d and %d are equal", j, i)
+                    break outer
+                }
+            }
+        }
+    }
+    if items, ok := s["items"]; ok {
+        for i, item := range arr {
+            v.validate(item, items, fmt.Sprintf("%s/%d", path, i))
+        }
+    }
+}
+
+func (v *validator) validateString(str string, s JSONObject, path string) {
+    length := utf8.RuneCountInString(str)
+    if n, ok := schemaInt(s, "minLength"); ok && length < n {
+        v.fail(path, "string is %d characters long, shorter than %d", length, n)
+    }
+    if n, ok := schemaInt(s, "maxLength"); ok && length > n {
+        v.fail(path, "string is %d characters long, longer than %d", length, n)
+    }
+    if pattern, ok := s["pattern"].(string); ok && !v.patterns[pattern].MatchString(str) {
+        v.fail(path, "string does not match pattern %q", pattern)
+    }
+    if format, ok := s["format"].(string); ok && !matchesFormat(str, format) {
+        v.fail(path, "string is not in %s format", format)
+    }
+}
+
+// matchesFormat reports whether s is written in format. Formats
+// stringFormat does not recognize are not checked.
+func matchesFormat(s, format string) bool {
+    switch format {
+    case "uuid", "email", "date", "date-time", "uri":
+        return stringFormat(s) == format
+    }
+    return true
+}
+
+func (v *validator) validateNumber(f float64, s JSONObject, path string) {
+    if min, ok := jsonNumberValue(s["minimum"]); ok && f < min {
+        v.fail(path, "%v is less than the minimum %v", f, min)
+    }
+    if max, ok := jsonNumberValue(s["maximum"]); ok && f > max {
+        v.fail(path, "%v is greater than the maximum %v", f, max)
+    }
+    if min, ok := jsonNumberValue(s["exclusiveMinimum"]); ok && f <= min {
+        v.fail(path, "%v is not greater than %v", f, min)
+    }
+    if max, ok := jsonNumberValue(s["exclusiveMaximum"]); ok && f >= max {
+        v.fail(path, "%v is not less than %v", f, max)
+    }
+    if m, ok := jsonNumberValue(s["multipleOf"]); ok {
+        if q := f / m; math.Abs(q-math.Round(q)) > 1e-9 {
+            v.fail(path, "%v is not a multiple of %v", f, m)
+        }
+    }
+}
+
+// schemaInt returns the integer keyword name of s, which checkSchema has
+// found to be non-negative.
+func schemaInt(s JSONObject, name string) (int, bool) {
+    f, ok := jsonNumberValue(s[name])
+    if !ok {
+        return 0, false
+    }
+    if f > math.MaxInt32 {
+        return math.MaxInt32, true
+    }
+    return int(f), true
+}
@@ -0,0 +1,93 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "testing"
+)
+
+func mustParse(t *testing.T, s string) interface{} {
+    v, err := Parse([]byte(s), ParseOptions{})
+    if err != nil {
+        t.Fatalf("%s: %v", s, err)
+    }
+    return v
+}
+
+func TestValidateSchema(t *testing.T) {
+    tests := []struct {
+        schema, value string
+        paths         []string
+    }{
+        {`true`, `1`, nil},
+        {`false`, `1`, []string{""}},
+        {`{"type":"integer"}`, `3`, nil},
+        {`{"type":"integer"}`, `3.5`, []string{""}},
+        {`{"type":["string","null"]}`, `null`, nil},
+        {`{"enum":[1,"a"]}`, `"b"`, []string{""}},
+        {`{"const":{"a":1}}`, `{"a":1.0}`, nil},
+        {`{"required":["a","b"],"properties":{"a":{"type":"string"}}}`, `{"a":1}`, []string{"", "/a"}},
+        {`{"additionalProperties":false,"properties":{"a":{}}}`, `{"a":1,"b/c":2}`, []string{"/b~1c"}},
+        {`{"items":{"minimum":0},"minItems":1,"uniqueItems":true}`, `[1,-1,1]`, []string{"", "/1"}},
+        {`{"minLength":2,"maxLength":3,"pattern":"^a"}`, `"b"`, []string{"", ""}},
+        {`{"format":"date"}`, `"2012-02-30x"`, []string{""}},
+        {`{"multipleOf":0.5,"exclusiveMaximum":2}`, `2`, []string{""}},
+        {`{"multipleOf":0.5}`, `1.25`, []string{""}},
+        {`{"anyOf":[{"type":"string"},{"type":"number"}]}`, `true`, []string{""}},
+        {`{"oneOf":[{"minimum":0},{"maximum":10}]}`, `5`, []string{""}},
+        {`{"not":{"type":"null"}}`, `null`, []string{""}},
+        {`{"$defs":{"pos":{"minimum":1}},"items":{"$ref":"#/$defs/pos"}}`, `[1,0]`, []string{"/1"}},
+        {`{"$defs":{"list":{"type":"array","items":{"$ref":"#/$defs/list"}}},"$ref":"#/$defs/list"}`, `[[],[[1]]]`, []string{"/1/0/0"}},
+    }
+    for _, tt := range tests {
+        errs := ValidateSchema(mustParse(t, tt.value), mustParse(t, tt.schema))
+        var paths []string
+        for _, e := range errs {
+            if e.Schema {
+                t.Errorf("%s: schema reported invalid: %v", tt.schema, e)
+            }
+            paths = append(paths, e.Path)
+        }
+        if len(paths) != len(tt.paths) {
+            t.Errorf("%s on %s: got errors %v, want at %q", tt.schema, tt.value, errs, tt.paths)
+            continue
+        }
+        for i := range paths {
+            if paths[i] != tt.paths[i] {
+                t.Errorf("%s on %s: got errors %v, want at %q", tt.schema, tt.value, errs, tt.paths)
+                break
+            }
+        }
+    }
+}
+
+func TestValidateSchemaInvalid(t *testing.T) {
+    tests := []struct {
+        schema string
+        path   string
+    }{
+        {`{"pattern":"["}`, "/pattern"},
+        {`{"minItems":"x"}`, "/minItems"},
+        {`{"minItems":-1}`, "/minItems"},
+        {`{"maxLength":1.5}`, "/maxLength"},
+        {`{"multipleOf":0}`, "/multipleOf"},
+        {`{"type":"text"}`, "/type"},
+        {`{"required":"a"}`, "/required"},
+        {`{"uniqueItems":1}`, "/uniqueItems"},
+        {`{"anyOf":[]}`, "/anyOf"},
+        {`{"properties":{"a":{"minimum":"0"}}}`, "/properties/a/minimum"},
+        {`{"items":[{}]}`, "/items"},
+        {`{"$ref":"#/missing"}`, "/$ref"},
+        {`{"$ref":"other.json"}`, "/$ref"},
+        {`{"$ref":"#/x","x":{"pattern":"("}}`, "/x/pattern"},
+        {`1`, ""},
+    }
+    for _, tt := range tests {
+        errs := ValidateSchema("anything", mustParse(t, tt.schema))
+        if len(errs) != 1 || !errs[0].Schema || errs[0].Path != tt.path {
+            t.Errorf("%s: got %v, want one schema error at %q", tt.schema, errs, tt.path)
+        }
+    }
+}
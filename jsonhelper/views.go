@@ -0,0 +1,105 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "strings"
+)
+
+// inView reports whether a field with the given jsonview tag belongs to
+// view. Fields without a jsonview tag belong to every view, and every
+// field is shown when no view is selected.
+func inView(tag string, view string) bool {
+    if view == "" || tag == "" {
+        return true
+    }
+    for _, name := range strings.Split(tag, ",") {
+        if strings.TrimSpace(name) == view {
+            return true
+        }
+    }
+    return false
+}
+
+// fieldSelector holds the Include and Exclude paths of MarshalOptions,
+// split into their dot-separated segments.
+type fieldSelector struct {
+    include [][]string
+    exclude [][]string
+}
+
+func newFieldSelector(include, exclude []string) *fieldSelector {
+    if len(include) == 0 && len(exclude) == 0 {
+        return nil
+    }
+    s := &fieldSelector{}
+    for _, p := range include {
+        s.include = append(s.include, strings.Split(p, "."))
+    }
+    for _, p := range exclude {
+        s.exclude = append(s.exclude, strings.Split(p, "."))
+    }
+    return s
+}
+
+// segmentsMatch compares pattern and path segment by segment over their
+// common length, where a "*" segment matches any key.
+func segmentsMatch(pattern, path []string) bool {
+    n := len(pattern)
+    if len(path) < n {
+        n = len(path)
+    }
+    for i := 0; i < n; i++ {
+        if pattern[i] != "*" && pattern[i] != path[i] {
+            return false
+        }
+    }
+    return true
+}
+
+// allows reports whether the value at path is emitted: it must be an
+// included path, an ancestor of one or a descendant of one, and must not
+// be an excluded path or a descendant of one. partial is set when path is
+// only an ancestor, whose value is kept for the included values below it.
+func (s *fieldSelector) allows(path []string) (ok, partial bool) {
+    if s == nil {
+        return true, false
+    }
+    for _, x := range s.exclude {
+        if len(path) >= len(x) && segmentsMatch(x, path) {
+            return false, false
+        }
+    }
+    if len(s.include) == 0 {
+        return true, false
+    }
+    for _, in := range s.include {
+        if segmentsMatch(in, path) {
+            if len(path) >= len(in) {
+                return true, false
+            }
+            ok = true
+        }
+    }
+    return ok, ok
+}
+
+// pruneAncestor keeps of value, found at a partial path, only the objects
+// holding included values, dropping scalars and empty objects and arrays.
+func pruneAncestor(value interface{}) (interface{}, bool) {
+    if obj, ok := jsonObjectValue(value); ok {
+        return value, len(obj) > 0
+    }
+    if arr, ok := jsonArrayValue(value); ok {
+        kept := make(JSONArray, 0, len(arr))
+        for _, item := range arr {
+            if item, ok := pruneAncestor(item); ok {
+                kept = append(kept, item)
+            }
+        }
+        return kept, len(kept) > 0
+    }
+    return nil, false
+}
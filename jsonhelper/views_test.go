@@ -0,0 +1,71 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+type viewAddress struct {
+    City   string `json:"city"`
+    Street string `json:"street" jsonview:"admin"`
+}
+
+type viewUser struct {
+    ID      int           `json:"id"`
+    Name    string        `json:"name" jsonview:"public, admin"`
+    Email   string        `json:"email" jsonview:"admin"`
+    Address viewAddress   `json:"address"`
+    Friends []viewAddress `json:"friends"`
+    Meta    JSONObject    `json:"meta"`
+}
+
+func TestMarshalViewsAndPaths(t *testing.T) {
+    u := viewUser{
+        ID:      1,
+        Name:    "ann",
+        Email:   "a@example.com",
+        Address: viewAddress{"paris", "rue"},
+        Friends: []viewAddress{{"rome", "via"}},
+        Meta:    JSONObject{"k": "v", "n": JSONObject{"x": 1}},
+    }
+    tests := []struct {
+        name string
+        opts MarshalOptions
+        want string
+    }{
+        {"public view", MarshalOptions{View: "public"},
+            `{"id":1,"name":"ann","address":{"city":"paris"},"friends":[{"city":"rome"}],"meta":{"k":"v","n":{"x":1}}}`},
+        {"admin view", MarshalOptions{View: "admin"},
+            `{"id":1,"name":"ann","email":"a@example.com","address":{"city":"paris","street":"rue"},"friends":[{"city":"rome","street":"via"}],"meta":{"k":"v","n":{"x":1}}}`},
+        {"include", MarshalOptions{Include: []string{"name", "address.city"}},
+            `{"name":"ann","address":{"city":"paris"}}`},
+        {"include through slices", MarshalOptions{Include: []string{"friends.street"}},
+            `{"friends":[{"street":"via"}]}`},
+        {"include into a tree", MarshalOptions{Include: []string{"meta.n"}},
+            `{"meta":{"n":{"x":1}}}`},
+        {"wildcard", MarshalOptions{Include: []string{"*.city"}},
+            `{"address":{"city":"paris"},"friends":[{"city":"rome"}]}`},
+        {"missing path", MarshalOptions{Include: []string{"id", "address.zip", "name.first"}},
+            `{"id":1}`},
+        {"exclude", MarshalOptions{Exclude: []string{"email", "address", "meta.n", "friends.*"}},
+            `{"id":1,"name":"ann","friends":[{}],"meta":{"k":"v"}}`},
+        {"view with include and exclude", MarshalOptions{View: "public", Include: []string{"address", "name"}, Exclude: []string{"address.city"}},
+            `{"name":"ann","address":{}}`},
+    }
+    for _, tt := range tests {
+        got, err := MarshalWithOptions(u, tt.opts)
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        want, _ := Parse([]byte(tt.want), ParseOptions{})
+        if !EqualJSONValues(got, want) {
+            b, _ := json.Marshal(got)
+            t.Errorf("%s: got %s, want %s", tt.name, b, tt.want)
+        }
+    }
+}
@@ -0,0 +1,389 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "encoding/xml"
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+    "unicode"
+)
+
+// XMLConvention selects how XML elements, attributes and text map onto
+// JSON values.
+type XMLConvention int
+
+const (
+    // XMLAttributePrefix stores attributes under AttributePrefix plus their
+    // name and, for elements that also have attributes or children, text
+    // under TextKey. Elements holding only text become that text.
+    XMLAttributePrefix XMLConvention = iota
+    // XMLBadgerFish makes every element an object, with text under "$",
+    // attributes under "@" plus their name and namespace declarations under
+    // "@xmlns", keyed by prefix or "$" for the default namespace.
+    XMLBadgerFish
+    // XMLParker drops attributes, and turns elements holding only text
+    // into that text and other elements into objects of their children,
+    // dropping the text mixed in with them.
+    XMLParker
+)
+
+// Defaults for XMLOptions.
+const (
+    DefaultXMLAttributePrefix = "@"
+    DefaultXMLTextKey         = "#text"
+    DefaultXMLRootName        = "root"
+)
+
+// XMLOptions controls the conversions between XML and JSON values.
+type XMLOptions struct {
+    // Convention selects the mapping between XML and JSON.
+    Convention XMLConvention
+    // AttributePrefix marks attribute keys under XMLAttributePrefix. It
+    // defaults to DefaultXMLAttributePrefix.
+    AttributePrefix string
+    // TextKey holds the text of elements under XMLAttributePrefix. It
+    // defaults to DefaultXMLTextKey.
+    TextKey string
+    // InferTypes converts text and attribute values that spell booleans,
+    // null or JSON numbers into values of those types.
+    InferTypes bool
+    // ArrayElements names elements that are always read as a JSONArray,
+    // even when they appear once.
+    ArrayElements []string
+    // RootName names the root element written for values that do not
+    // consist of a single element. It defaults to DefaultXMLRootName.
+    RootName string
+    // Indent, if set, indents the XML written by WriteXML.
+    Indent string
+}
+
+func (o *XMLOptions) attributePrefix() string {
+    if o.Convention == XMLBadgerFish {
+        return "@"
+    }
+    if o.AttributePrefix == "" {
+        return DefaultXMLAttributePrefix
+    }
+    return o.AttributePrefix
+}
+
+func (o *XMLOptions) textKey() string {
+    if o.Convention == XMLBadgerFish {
+        return "$"
+    }
+    if o.TextKey == "" {
+        return DefaultXMLTextKey
+    }
+    return o.TextKey
+}
+
+func (o *XMLOptions) isArrayElement(name string) bool {
+    for _, n := range o.ArrayElements {
+        if n == name {
+            return true
+        }
+    }
+    return false
+}
+
+func (o *XMLOptions) scalar(s string) interface{} {
+    if o.InferTypes {
+        return inferScalar(s)
+    }
+    return s
+}
+
+// xmlName writes a raw name as "prefix:local", the form used for keys.
+func xmlName(name xml.Name) string {
+    if name.Space == "" {
+        return name.Local
+    }
+    return name.Space + ":" + name.Local
+}
+
+// maxXMLDepth bounds the nesting of elements the reader accepts.
+const maxXMLDepth = 10000
+
+type xmlReader struct {
+    d     *xml.Decoder
+    opts  *XMLOptions
+    depth int
+}
+
+// ReadXML converts the XML document in r into a JSONObject holding its
+// root element under the element's name. Element and attribute names keep
+// their namespace prefixes, as in "soap:Body", and namespace declarations
+// are read as the xmlns attributes they are written as.
+func ReadXML(r io.Reader, opts XMLOptions) (JSONObject, error) {
+    x := &xmlReader{d: xml.NewDecoder(r), opts: &opts}
+    for {
+        tok, err := x.d.RawToken()
+        if err == io.EOF {
+            return nil, fmt.Errorf("jsonhelper: XML document has no root element")
+        }
+        if err != nil {
+            return nil, err
+        }
+        if start, ok := tok.(xml.StartElement); ok {
+            value, err := x.element(start)
+            if err != nil {
+                return nil, err
+            }
+            return JSONObject{xmlName(start.Name): value}, nil
+        }
+    }
+}
+
+// ReadXMLElements streams the XML in r, calling fn with the converted value
+// of each element named name, such as "item" or "ns:item", wherever it
+// appears. Only one such element is held in memory at a time, so large
+// documents of repeated records can be processed. Returning an error from
+// fn stops the stream with that error.
+func ReadXMLElements(r io.Reader, name string, opts XMLOptions, fn func(value interface{}) error) error {
+    x := &xmlReader{d: xml.NewDecoder(r), opts: &opts}
+    for {
+        tok, err := x.d.RawToken()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        start, ok := tok.(xml.StartElement)
+        if !ok || xmlName(start.Name) != name {
+            continue
+        }
+        value, err := x.element(start)
+        if err != nil {
+            return err
+        }
+        if err := fn(value); err != nil {
+            return err
+        }
+    }
+}
+
+// element reads the rest of the element begun by start.
+func (x *xmlReader) element(start xml.StartElement) (interface{}, error) {
+    if x.depth >= maxXMLDepth {
+        return nil, fmt.Errorf("jsonhelper: XML elements nested deeper than %d", maxXMLDepth)
+    }
+    x.depth++
+    defer func() { x.depth-- }()
+    var names []string
+    children := make(map[string][]interface{})
+    var text strings.Builder
+    for {
+        tok, err := x.d.RawToken()
+        if err == io.EOF {
+            return nil, fmt.Errorf("jsonhelper: XML element <%s> is not closed", xmlName(start.Name))
+        }
+        if err != nil {
+            return nil, err
+        }
+        switch t := tok.(type) {
+        case xml.StartElement:
+            value, err := x.element(t)
+            if err != nil {
+                return nil, err
+            }
+            name := xmlName(t.Name)
+            if _, ok := children[name]; !ok {
+                names = append(names, name)
+            }
+            children[name] = append(children[name], value)
+        case xml.CharData:
+            text.Write(t)
+        case xml.EndElement:
+            if t.Name != start.Name {
+                return nil, fmt.Errorf("jsonhelper: XML element <%s> closed by </%s>", xmlName(start.Name), xmlName(t.Name))
+            }
+            return x.build(start.Attr, names, children, strings.TrimSpace(text.String())), nil
+        }
+    }
+}
+
+func (x *xmlReader) build(attrs []xml.Attr, names []string, children map[string][]interface{}, text string) interface{} {
+    opts := x.opts
+    if opts.Convention == XMLParker {
+        if len(names) == 0 {
+            if text == "" {
+                return nil
+            }
+            return opts.scalar(text)
+        }
+        attrs, text = nil, ""
+    } else if opts.Convention == XMLAttributePrefix && len(attrs) == 0 && len(names) == 0 {
+        if text == "" {
+            return nil
+        }
+        return opts.scalar(text)
+    }
+    obj := NewJSONObject()
+    prefix := opts.attributePrefix()
+    for _, attr := range attrs {
+        if opts.Convention == XMLBadgerFish && isXMLNSAttr(attr.Name) {
+            ns, _ := obj["@xmlns"].(JSONObject)
+            if ns == nil {
+                ns = NewJSONObject()
+                obj["@xmlns"] = ns
+            }
+            if attr.Name.Space == "" {
+                ns["$"] = attr.Value
+            } else {
+                ns[attr.Name.Local] = attr.Value
+            }
+            continue
+        }
+        obj[prefix+xmlName(attr.Name)] = opts.scalar(attr.Value)
+    }
+    for _, name := range names {
+        values := children[name]
+        if len(values) == 1 && !opts.isArrayElement(name) {
+            obj[name] = values[0]
+        } else {
+            obj[name] = NewJSONArrayFromArray(values)
+        }
+    }
+    if text != "" {
+        obj[opts.textKey()] = opts.scalar(text)
+    }
+    return obj
+}
+
+func isXMLNSAttr(name xml.Name) bool {
+    return (name.Space == "" && name.Local == "xmlns") || name.Space == "xmlns"
+}
+
+// WriteXML writes value as an XML document to w, reversing ReadXML. An
+// object with a single key holding an object or scalar is written as that
+// root element; anything else is wrapped in an element named RootName.
+// Arrays, which would make several root elements, are an error. Keys are
+// written in sorted order, or in order for an OrderedJSONObject.
+func WriteXML(w io.Writer, value interface{}, opts XMLOptions) error {
+    if _, ok := jsonArrayValue(value); ok {
+        return fmt.Errorf("jsonhelper: cannot write an array as an XML document")
+    }
+    e := xml.NewEncoder(w)
+    if opts.Indent != "" {
+        e.Indent("", opts.Indent)
+    }
+    name, root := "", value
+    if obj, ok := jsonObjectValue(value); ok && len(obj) == 1 {
+        for k, v := range obj {
+            if _, isArray := jsonArrayValue(v); !isArray {
+                name, root = k, v
+            }
+        }
+    }
+    if name == "" {
+        name = opts.RootName
+        if name == "" {
+            name = DefaultXMLRootName
+        }
+    }
+    if err := writeXMLElement(e, name, root, &opts); err != nil {
+        return err
+    }
+    return e.Flush()
+}
+
+// isXMLName reports whether s can be written as an element or attribute
+// name, optionally with a namespace prefix.
+func isXMLName(s string) bool {
+    if s == "" {
+        return false
+    }
+    for i, r := range s {
+        if unicode.IsLetter(r) || r == '_' || r == ':' {
+            continue
+        }
+        if i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.') {
+            continue
+        }
+        return false
+    }
+    return true
+}
+
+func writeXMLElement(e *xml.Encoder, name string, value interface{}, opts *XMLOptions) error {
+    if !isXMLName(name) {
+        return fmt.Errorf("jsonhelper: %q is not a valid XML element name", name)
+    }
+    if arr, ok := jsonArrayValue(value); ok {
+        for _, item := range arr {
+            if _, nested := jsonArrayValue(item); nested {
+                return fmt.Errorf("jsonhelper: cannot write nested arrays as XML element <%s>", name)
+            }
+            if err := writeXMLElement(e, name, item, opts); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    start := xml.StartElement{Name: xml.Name{Local: name}}
+    obj, isObject := jsonObjectValue(value)
+    if !isObject {
+        if err := e.EncodeToken(start); err != nil {
+            return err
+        }
+        if value != nil {
+            if err := e.EncodeToken(xml.CharData(csvCell(value))); err != nil {
+                return err
+            }
+        }
+        return e.EncodeToken(start.End())
+    }
+    keys := orderedJSONObjectKeys(value, obj)
+    prefix := opts.attributePrefix()
+    textKey := opts.textKey()
+    var elements []string
+    var text interface{}
+    for _, k := range keys {
+        switch {
+        case opts.Convention == XMLBadgerFish && k == "@xmlns":
+            ns, _ := jsonObjectValue(obj[k])
+            nsKeys := make([]string, 0, len(ns))
+            for p := range ns {
+                nsKeys = append(nsKeys, p)
+            }
+            sort.Strings(nsKeys)
+            for _, p := range nsKeys {
+                attr := xml.Attr{Name: xml.Name{Local: "xmlns:" + p}, Value: JSONValueToString(ns[p])}
+                if p == "$" {
+                    attr.Name.Local = "xmlns"
+                }
+                start.Attr = append(start.Attr, attr)
+            }
+        case k == textKey && opts.Convention != XMLParker:
+            text = obj[k]
+        case opts.Convention != XMLParker && strings.HasPrefix(k, prefix):
+            attrName := k[len(prefix):]
+            if !isXMLName(attrName) {
+                return fmt.Errorf("jsonhelper: %q is not a valid XML attribute name", attrName)
+            }
+            start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attrName}, Value: csvCell(obj[k])})
+        default:
+            elements = append(elements, k)
+        }
+    }
+    if err := e.EncodeToken(start); err != nil {
+        return err
+    }
+    if text != nil {
+        if err := e.EncodeToken(xml.CharData(csvCell(text))); err != nil {
+            return err
+        }
+    }
+    for _, k := range elements {
+        if err := writeXMLElement(e, k, obj[k], opts); err != nil {
+            return err
+        }
+    }
+    return e.EncodeToken(start.End())
+}
@@ -0,0 +1,208 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "strings"
+    "testing"
+)
+
+const xmlSample = `<?xml version="1.0"?><a:root xmlns:a="urn:a" id="7"><item>1</item><item k="v">two</item><one>true</one><empty/><mixed>t<b>x</b></mixed></a:root>`
+
+func TestReadXML(t *testing.T) {
+    tests := []struct {
+        name string
+        opts XMLOptions
+        want string
+    }{
+        {
+            "attribute prefix",
+            XMLOptions{ArrayElements: []string{"one"}},
+            `{"a:root":{"@id":"7","@xmlns:a":"urn:a","empty":null,"item":["1",{"#text":"two","@k":"v"}],"mixed":{"#text":"t","b":"x"},"one":["true"]}}`,
+        },
+        {
+            "attribute prefix inferred",
+            XMLOptions{InferTypes: true, ArrayElements: []string{"one"}},
+            `{"a:root":{"@id":7,"@xmlns:a":"urn:a","empty":null,"item":[1,{"#text":"two","@k":"v"}],"mixed":{"#text":"t","b":"x"},"one":[true]}}`,
+        },
+        {
+            "custom prefix and text key",
+            XMLOptions{AttributePrefix: "-", TextKey: "_"},
+            `{"a:root":{"-id":"7","-xmlns:a":"urn:a","empty":null,"item":["1",{"_":"two","-k":"v"}],"mixed":{"_":"t","b":"x"},"one":"true"}}`,
+        },
+        {
+            "badgerfish",
+            XMLOptions{Convention: XMLBadgerFish, ArrayElements: []string{"one"}},
+            `{"a:root":{"@id":"7","@xmlns":{"a":"urn:a"},"empty":{},"item":[{"$":"1"},{"$":"two","@k":"v"}],"mixed":{"$":"t","b":{"$":"x"}},"one":[{"$":"true"}]}}`,
+        },
+        {
+            "parker",
+            XMLOptions{Convention: XMLParker, InferTypes: true},
+            `{"a:root":{"empty":null,"item":[1,"two"],"mixed":{"b":"x"},"one":true}}`,
+        },
+    }
+    for _, tt := range tests {
+        v, err := ReadXML(strings.NewReader(xmlSample), tt.opts)
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if !EqualJSONValues(v, mustParse(t, tt.want)) {
+            b, _ := json.Marshal(v)
+            t.Errorf("%s: got %s, want %s", tt.name, b, tt.want)
+        }
+    }
+}
+
+func TestReadXMLErrors(t *testing.T) {
+    tests := []struct {
+        in   string
+        want string
+    }{
+        {"", "XML document has no root element"},
+        {"<!-- only a comment -->", "XML document has no root element"},
+        {"<a><b></a>", "XML element <b> closed by </a>"},
+        {"<a>", "XML element <a> is not closed"},
+        {strings.Repeat("<a>", maxXMLDepth+1), "XML elements nested deeper than 10000"},
+    }
+    for _, tt := range tests {
+        _, err := ReadXML(strings.NewReader(tt.in), XMLOptions{})
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("ReadXML(%q) error = %v, want %q", tt.in, err, tt.want)
+        }
+    }
+}
+
+func TestReadXMLElements(t *testing.T) {
+    var got []string
+    err := ReadXMLElements(strings.NewReader(xmlSample), "item", XMLOptions{InferTypes: true}, func(v interface{}) error {
+        b, _ := json.Marshal(v)
+        got = append(got, string(b))
+        return nil
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if s := strings.Join(got, " "); s != `1 {"#text":"two","@k":"v"}` {
+        t.Errorf("got %s", s)
+    }
+    stop := errors.New("stop")
+    n := 0
+    err = ReadXMLElements(strings.NewReader(xmlSample), "item", XMLOptions{}, func(v interface{}) error {
+        n++
+        return stop
+    })
+    if err != stop || n != 1 {
+        t.Errorf("stopping gave %v after %d elements", err, n)
+    }
+}
+
+func TestWriteXML(t *testing.T) {
+    tests := []struct {
+        name  string
+        value interface{}
+        opts  XMLOptions
+        want  string
+    }{
+        {
+            "single root key",
+            mustParse(t, `{"a:root":{"@id":"7","@xmlns:a":"urn:a","item":["1",{"#text":"two","@k":"v"}],"empty":null}}`),
+            XMLOptions{},
+            `<a:root id="7" xmlns:a="urn:a"><empty></empty><item>1</item><item k="v">two</item></a:root>`,
+        },
+        {
+            "several keys wrapped",
+            JSONObject{"b": 1, "a": "x<y"},
+            XMLOptions{RootName: "doc"},
+            `<doc><a>x&lt;y</a><b>1</b></doc>`,
+        },
+        {
+            "root key holding an array",
+            JSONObject{"n": JSONArray{1, 2}},
+            XMLOptions{},
+            `<root><n>1</n><n>2</n></root>`,
+        },
+        {
+            "scalar",
+            true,
+            XMLOptions{},
+            `<root>true</root>`,
+        },
+        {
+            "ordered",
+            mustParseOrdered(t, `{"z":1,"a":2}`),
+            XMLOptions{},
+            `<root><z>1</z><a>2</a></root>`,
+        },
+        {
+            "badgerfish",
+            mustParse(t, `{"r":{"@xmlns":{"a":"urn:a"},"$":"t","b":{"$":"x"}}}`),
+            XMLOptions{Convention: XMLBadgerFish},
+            `<r xmlns:a="urn:a">t<b>x</b></r>`,
+        },
+        {
+            "parker",
+            mustParse(t, `{"r":{"b":["x","y"],"c":null}}`),
+            XMLOptions{Convention: XMLParker},
+            `<r><b>x</b><b>y</b><c></c></r>`,
+        },
+        {
+            "indent",
+            JSONObject{"r": JSONObject{"a": 1}},
+            XMLOptions{Indent: "  "},
+            "<r>\n  <a>1</a>\n</r>",
+        },
+    }
+    for _, tt := range tests {
+        var buf bytes.Buffer
+        if err := WriteXML(&buf, tt.value, tt.opts); err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+        } else if buf.String() != tt.want {
+            t.Errorf("%s: got %s, want %s", tt.name, buf.String(), tt.want)
+        }
+    }
+}
+
+func TestWriteXMLErrors(t *testing.T) {
+    tests := []struct {
+        value interface{}
+        want  string
+    }{
+        {JSONArray{1, "a"}, "cannot write an array"},
+        {JSONObject{"bad name": 1, "ok": 2}, `"bad name" is not a valid XML element name`},
+        {JSONObject{"r": JSONObject{"@bad name": 1}}, `"bad name"`},
+        {JSONObject{"1a": nil, "b": nil}, `"1a"`},
+    }
+    for _, tt := range tests {
+        err := WriteXML(&bytes.Buffer{}, tt.value, XMLOptions{})
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("WriteXML(%v) error = %v, want %q", tt.value, err, tt.want)
+        }
+    }
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+    for _, c := range []XMLConvention{XMLAttributePrefix, XMLBadgerFish, XMLParker} {
+        opts := XMLOptions{Convention: c, InferTypes: true, ArrayElements: []string{"one"}}
+        first, err := ReadXML(strings.NewReader(xmlSample), opts)
+        if err != nil {
+            t.Fatal(err)
+        }
+        var buf bytes.Buffer
+        if err := WriteXML(&buf, first, opts); err != nil {
+            t.Errorf("convention %d: %v", c, err)
+            continue
+        }
+        second, err := ReadXML(&buf, opts)
+        if err != nil {
+            t.Errorf("convention %d: reading %s: %v", c, buf.String(), err)
+        } else if !EqualJSONValues(first, second) {
+            t.Errorf("convention %d: round trip gave %v, want %v", c, second, first)
+        }
+    }
+}
@@ -0,0 +1,1289 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bytes"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "math"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+    "unicode"
+    "unicode/utf8"
+)
+
+// Limits on the YAML the parser accepts.
+const (
+    maxYAMLDepth      = 10000
+    maxYAMLAliasNodes = 1 << 20
+)
+
+// DecodeYAML parses the YAML document in data. An empty stream yields nil
+// and a stream of several documents is an error.
+//
+// The parser handles the subset of YAML used for configuration: block and
+// flow mappings and sequences, plain, quoted, literal and folded scalars,
+// comments, anchors and aliases, "<<" merge keys and the !!str, !!int,
+// !!float, !!bool, !!null and !!binary tags. Plain scalars are typed with
+// the YAML 1.2 core schema: null, booleans, int64, uint64 and float64
+// values are recognized and everything else is a string. Mappings become
+// JSONObject values, with keys kept as written, and sequences become
+// JSONArray values. Explicit "?" keys and complex keys are not supported.
+func DecodeYAML(data []byte) (interface{}, error) {
+    docs, err := DecodeYAMLStream(data)
+    if err != nil {
+        return nil, err
+    }
+    switch len(docs) {
+    case 0:
+        return nil, nil
+    case 1:
+        return docs[0], nil
+    }
+    return nil, fmt.Errorf("jsonhelper: YAML stream holds %d documents, not one", len(docs))
+}
+
+// DecodeYAMLStream parses every document of the YAML stream in data, as
+// DecodeYAML does. Anchors are local to the document they appear in.
+func DecodeYAMLStream(data []byte) (docs []interface{}, err error) {
+    p := &yamlParser{src: strings.TrimPrefix(string(data), "\ufeff"), line: 1}
+    defer func() {
+        if r := recover(); r != nil {
+            if e, ok := r.(yamlError); ok {
+                docs, err = nil, e.err
+                return
+            }
+            panic(r)
+        }
+    }()
+    return p.documents(), nil
+}
+
+// ReadYAML parses the single YAML document read from r, as DecodeYAML
+// does.
+func ReadYAML(r io.Reader) (interface{}, error) {
+    data, err := ioutil.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    return DecodeYAML(data)
+}
+
+// ReadYAMLStream parses every document of the YAML stream read from r, as
+// DecodeYAMLStream does.
+func ReadYAMLStream(r io.Reader) ([]interface{}, error) {
+    data, err := ioutil.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    return DecodeYAMLStream(data)
+}
+
+// yamlError carries parse errors up through the panics the parser uses to
+// unwind.
+type yamlError struct {
+    err error
+}
+
+type yamlParser struct {
+    src        string
+    pos        int
+    line       int
+    lineStart  int
+    depth      int
+    anchors    map[string]interface{}
+    aliasNodes int
+}
+
+type yamlState struct {
+    pos, line, lineStart int
+}
+
+func (p *yamlParser) save() yamlState {
+    return yamlState{p.pos, p.line, p.lineStart}
+}
+
+func (p *yamlParser) restore(s yamlState) {
+    p.pos, p.line, p.lineStart = s.pos, s.line, s.lineStart
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) {
+    panic(yamlError{fmt.Errorf("jsonhelper: YAML line %d column %d: %s", p.line, p.col()+1, fmt.Sprintf(format, args...))})
+}
+
+func (p *yamlParser) col() int {
+    return p.pos - p.lineStart
+}
+
+func (p *yamlParser) eof() bool {
+    return p.pos >= len(p.src)
+}
+
+// at returns the byte i bytes past pos, or 0 past the end of the input.
+func (p *yamlParser) at(i int) byte {
+    if p.pos+i < len(p.src) {
+        return p.src[p.pos+i]
+    }
+    return 0
+}
+
+func isYAMLBlank(c byte) bool {
+    return c == ' ' || c == '\t'
+}
+
+func isYAMLBreak(c byte) bool {
+    return c == '\n' || c == '\r'
+}
+
+// isYAMLSpace reports whether c is a blank, a line break or the end of the
+// input.
+func isYAMLSpace(c byte) bool {
+    return c == 0 || isYAMLBlank(c) || isYAMLBreak(c)
+}
+
+func isYAMLFlowIndicator(c byte) bool {
+    return c != 0 && strings.IndexByte(",[]{}", c) >= 0
+}
+
+// atBreak reports whether pos is at the end of a line.
+func (p *yamlParser) atBreak() bool {
+    return p.eof() || isYAMLBreak(p.src[p.pos])
+}
+
+func (p *yamlParser) newline() {
+    if p.at(0) == '\r' && p.at(1) == '\n' {
+        p.pos++
+    }
+    p.pos++
+    p.line++
+    p.lineStart = p.pos
+}
+
+// skipInline skips blanks and a comment on the current line.
+func (p *yamlParser) skipInline() {
+    for isYAMLBlank(p.at(0)) {
+        p.pos++
+    }
+    if p.at(0) == '#' && (p.pos == p.lineStart || isYAMLBlank(p.src[p.pos-1])) {
+        for !p.atBreak() {
+            p.pos++
+        }
+    }
+}
+
+// skipToContent skips blanks, comments and line breaks up to the next
+// token.
+func (p *yamlParser) skipToContent() {
+    for {
+        if p.pos == p.lineStart {
+            for p.at(0) == ' ' {
+                p.pos++
+            }
+            if p.at(0) == '\t' {
+                s := p.save()
+                p.skipInline()
+                if !p.atBreak() {
+                    p.restore(s)
+                    p.errorf("tabs cannot be used for indentation")
+                }
+            }
+        }
+        p.skipInline()
+        if !isYAMLBreak(p.at(0)) {
+            return
+        }
+        p.newline()
+    }
+}
+
+func (p *yamlParser) atDocumentMarker() bool {
+    if p.col() != 0 || p.pos+3 > len(p.src) {
+        return false
+    }
+    m := p.src[p.pos : p.pos+3]
+    return (m == "---" || m == "...") && isYAMLSpace(p.at(3))
+}
+
+func (p *yamlParser) atDocumentEnd() bool {
+    return p.atDocumentMarker() && p.src[p.pos] == '.'
+}
+
+func (p *yamlParser) atSequenceIndicator() bool {
+    return p.at(0) == '-' && isYAMLSpace(p.at(1))
+}
+
+func (p *yamlParser) documents() []interface{} {
+    var docs []interface{}
+    for {
+        p.skipToContent()
+        for p.col() == 0 && p.at(0) == '%' {
+            for !p.atBreak() {
+                p.pos++
+            }
+            p.skipToContent()
+        }
+        if p.eof() {
+            return docs
+        }
+        if p.atDocumentEnd() {
+            p.pos += 3
+            continue
+        }
+        if p.atDocumentMarker() {
+            p.pos += 3
+        }
+        p.anchors = make(map[string]interface{})
+        p.skipToContent()
+        var doc interface{}
+        if !p.eof() && !p.atDocumentMarker() {
+            doc = p.node(-1, true)
+            p.skipToContent()
+        }
+        docs = append(docs, doc)
+        if p.atDocumentEnd() {
+            p.pos += 3
+        } else if !p.eof() && !p.atDocumentMarker() {
+            p.errorf("unexpected %q", p.at(0))
+        }
+    }
+}
+
+// node parses the block node at pos. Its continuation lines must be
+// indented more than parent. Block collections may only start on the
+// current line when compact is set, as for the items of sequences.
+func (p *yamlParser) node(parent int, compact bool) interface{} {
+    p.depth++
+    if p.depth > maxYAMLDepth {
+        p.errorf("nesting deeper than %d", maxYAMLDepth)
+    }
+    defer func() { p.depth-- }()
+    anchor, tag := p.properties()
+    if (anchor != "" || tag != "") && p.atBreak() {
+        p.skipToContent()
+        if p.eof() || p.atDocumentMarker() || p.col() <= parent {
+            return p.anchor(anchor, p.scalarValue(tag, "", true))
+        }
+        compact = true
+    }
+    col := p.col()
+    var value interface{}
+    switch c := p.at(0); {
+    case p.atSequenceIndicator():
+        if !compact {
+            p.errorf("block sequence cannot start on the line of a mapping key")
+        }
+        value = p.sequence(col)
+    case c == '[' || c == '{':
+        value = p.flow()
+        p.endOfValue()
+    case c == '|' || c == '>':
+        value = p.scalarValue(tag, p.blockScalar(parent), false)
+    case c == '*':
+        value = p.alias()
+        p.endOfValue()
+    case c == '?' && isYAMLSpace(p.at(1)):
+        p.errorf("explicit mapping keys are not supported")
+    default:
+        if key, merge, ok := p.mappingKey(); ok {
+            if !compact {
+                p.errorf("mapping cannot start on the line of another mapping key")
+            }
+            value = p.mapping(col, key, merge)
+        } else {
+            text, plain := p.scalar(parent, false)
+            value = p.scalarValue(tag, text, plain)
+            p.endOfValue()
+        }
+    }
+    return p.anchor(anchor, value)
+}
+
+// anchor records value under the name of an anchor, if there is one.
+func (p *yamlParser) anchor(name string, value interface{}) interface{} {
+    if name != "" {
+        p.anchors[name] = value
+    }
+    return value
+}
+
+// endOfValue checks that nothing but a comment follows a value on its line.
+func (p *yamlParser) endOfValue() {
+    p.skipInline()
+    if !p.atBreak() {
+        p.errorf("unexpected %q after value", p.at(0))
+    }
+}
+
+// properties reads the anchor and tag that may precede a node.
+func (p *yamlParser) properties() (anchor, tag string) {
+    for {
+        switch p.at(0) {
+        case '&':
+            if anchor != "" {
+                p.errorf("node has two anchors")
+            }
+            p.pos++
+            if anchor = p.name(); anchor == "" {
+                p.errorf("anchor has no name")
+            }
+        case '!':
+            if tag != "" {
+                p.errorf("node has two tags")
+            }
+            tag = p.tag()
+        default:
+            return
+        }
+        p.skipInline()
+    }
+}
+
+// name reads an anchor or alias name.
+func (p *yamlParser) name() string {
+    start := p.pos
+    for !isYAMLSpace(p.at(0)) && !isYAMLFlowIndicator(p.at(0)) {
+        p.pos++
+    }
+    return p.src[start:p.pos]
+}
+
+// tag reads a tag, writing the standard tags in their "!!" short form.
+func (p *yamlParser) tag() string {
+    if p.at(1) != '<' {
+        start := p.pos
+        p.pos++
+        p.name()
+        return p.src[start:p.pos]
+    }
+    end := strings.IndexByte(p.src[p.pos:], '>')
+    if end < 0 {
+        p.errorf("unterminated tag")
+    }
+    uri := p.src[p.pos+2 : p.pos+end]
+    p.pos += end + 1
+    if strings.HasPrefix(uri, "tag:yaml.org,2002:") {
+        return "!!" + uri[len("tag:yaml.org,2002:"):]
+    }
+    return uri
+}
+
+func (p *yamlParser) alias() interface{} {
+    p.pos++
+    name := p.name()
+    value, ok := p.anchors[name]
+    if !ok {
+        p.errorf("unknown anchor %q", name)
+    }
+    return p.copyValue(value)
+}
+
+// copyValue copies the value of an anchor for an alias, bounding the total
+// number of values aliases may expand to.
+func (p *yamlParser) copyValue(value interface{}) interface{} {
+    p.aliasNodes++
+    if p.aliasNodes > maxYAMLAliasNodes {
+        p.errorf("aliases expand to more than %d values", maxYAMLAliasNodes)
+    }
+    switch v := value.(type) {
+    case JSONObject:
+        obj := NewJSONObject()
+        for k, item := range v {
+            obj[k] = p.copyValue(item)
+        }
+        return obj
+    case JSONArray:
+        arr := make(JSONArray, len(v))
+        for i, item := range v {
+            arr[i] = p.copyValue(item)
+        }
+        return arr
+    case []byte:
+        return append([]byte(nil), v...)
+    }
+    return value
+}
+
+// mappingKey parses the key of a block mapping entry at pos, leaving pos
+// after its colon, or reports false and leaves pos unchanged. A plain "<<"
+// key is reported as a merge key.
+func (p *yamlParser) mappingKey() (key string, merge, ok bool) {
+    s := p.save()
+    if c := p.at(0); c == '"' || c == '\'' {
+        if key = p.quoted(); p.line != s.line {
+            p.restore(s)
+            return "", false, false
+        }
+    } else {
+        key = p.plainLine(false)
+        merge = key == "<<"
+    }
+    for isYAMLBlank(p.at(0)) {
+        p.pos++
+    }
+    if p.pos == s.pos || p.at(0) != ':' || !isYAMLSpace(p.at(1)) {
+        p.restore(s)
+        return "", false, false
+    }
+    p.pos++
+    return key, merge, true
+}
+
+// mapping parses a block mapping whose keys start at column col, the
+// first of which has been read.
+func (p *yamlParser) mapping(col int, key string, merge bool) JSONObject {
+    obj := NewJSONObject()
+    var merges []interface{}
+    for {
+        value := p.mapValue(col)
+        if merge {
+            merges = append(merges, value)
+        } else {
+            if _, ok := obj[key]; ok {
+                p.errorf("duplicate mapping key %q", key)
+            }
+            obj[key] = value
+        }
+        p.skipToContent()
+        if p.eof() || p.atDocumentMarker() || p.col() < col {
+            break
+        }
+        if p.col() > col {
+            p.errorf("unexpected indentation")
+        }
+        var ok bool
+        if key, merge, ok = p.mappingKey(); !ok {
+            p.errorf("expected a mapping key")
+        }
+    }
+    p.merge(obj, merges)
+    return obj
+}
+
+// mapValue parses the value following the colon of a mapping entry at
+// column col. A sequence may hold the value at the column of its key.
+func (p *yamlParser) mapValue(col int) interface{} {
+    p.skipInline()
+    if !p.atBreak() {
+        return p.node(col, false)
+    }
+    p.skipToContent()
+    if p.eof() || p.atDocumentMarker() {
+        return nil
+    }
+    if p.col() > col || p.col() == col && p.atSequenceIndicator() {
+        return p.node(col, true)
+    }
+    return nil
+}
+
+// merge copies the entries of the mappings given to "<<" keys into obj,
+// keeping the keys obj already has. Earlier mappings in a sequence win.
+func (p *yamlParser) merge(obj JSONObject, merges []interface{}) {
+    for _, m := range merges {
+        sources, ok := m.(JSONArray)
+        if !ok {
+            sources = JSONArray{m}
+        }
+        for _, source := range sources {
+            src, ok := source.(JSONObject)
+            if !ok {
+                p.errorf("merge key holds %s, not a mapping", describeJSONValue(source))
+            }
+            for k, v := range src {
+                if _, ok := obj[k]; !ok {
+                    obj[k] = v
+                }
+            }
+        }
+    }
+}
+
+// sequence parses a block sequence whose indicators are at column col.
+func (p *yamlParser) sequence(col int) JSONArray {
+    var items []interface{}
+    for {
+        p.pos++
+        p.skipInline()
+        var item interface{}
+        if !p.atBreak() {
+            item = p.node(col, true)
+        } else {
+            p.skipToContent()
+            if !p.eof() && !p.atDocumentMarker() && p.col() > col {
+                item = p.node(col, true)
+            }
+        }
+        items = append(items, item)
+        p.skipToContent()
+        if p.eof() || p.atDocumentMarker() || p.col() < col || p.col() == col && !p.atSequenceIndicator() {
+            break
+        }
+        if p.col() > col {
+            p.errorf("unexpected indentation")
+        }
+    }
+    return NewJSONArrayFromArray(items)
+}
+
+// flow parses a flow sequence or mapping.
+func (p *yamlParser) flow() interface{} {
+    p.depth++
+    if p.depth > maxYAMLDepth {
+        p.errorf("nesting deeper than %d", maxYAMLDepth)
+    }
+    defer func() { p.depth-- }()
+    open := p.src[p.pos]
+    p.pos++
+    if open == '[' {
+        items := make([]interface{}, 0)
+        for {
+            p.skipToContent()
+            if p.at(0) == ']' {
+                p.pos++
+                break
+            }
+            value, key, scalar := p.flowNode()
+            p.skipToContent()
+            if p.at(0) == ':' {
+                if !scalar {
+                    p.errorf("flow mapping keys must be scalars")
+                }
+                p.pos++
+                value = JSONObject{key: p.flowValue()}
+                p.skipToContent()
+            }
+            items = append(items, value)
+            if !p.flowSeparator(']') {
+                break
+            }
+        }
+        return NewJSONArrayFromArray(items)
+    }
+    obj := NewJSONObject()
+    for {
+        p.skipToContent()
+        if p.at(0) == '}' {
+            p.pos++
+            break
+        }
+        _, key, scalar := p.flowNode()
+        if !scalar {
+            p.errorf("flow mapping keys must be scalars")
+        }
+        p.skipToContent()
+        var value interface{}
+        if p.at(0) == ':' {
+            p.pos++
+            value = p.flowValue()
+            p.skipToContent()
+        }
+        if _, ok := obj[key]; ok {
+            p.errorf("duplicate mapping key %q", key)
+        }
+        obj[key] = value
+        if !p.flowSeparator('}') {
+            break
+        }
+    }
+    return obj
+}
+
+// flowSeparator consumes the comma between flow entries, reporting false
+// once it has consumed the closing bracket instead.
+func (p *yamlParser) flowSeparator(close byte) bool {
+    switch p.at(0) {
+    case ',':
+        p.pos++
+        return true
+    case close:
+        p.pos++
+        return false
+    case 0:
+        p.errorf("unterminated flow collection")
+    }
+    p.errorf("expected ',' or %q, not %q", close, p.at(0))
+    return false
+}
+
+// flowValue parses the value after a colon in a flow collection, which is
+// nil when left out.
+func (p *yamlParser) flowValue() interface{} {
+    p.skipToContent()
+    if c := p.at(0); c == ',' || c == ']' || c == '}' {
+        return nil
+    }
+    value, _, _ := p.flowNode()
+    return value
+}
+
+// flowNode parses a node inside a flow collection, also returning its text
+// when it is a scalar that may serve as a key.
+func (p *yamlParser) flowNode() (value interface{}, text string, scalar bool) {
+    anchor, tag := p.properties()
+    p.skipToContent()
+    switch c := p.at(0); c {
+    case '[', '{':
+        value = p.flow()
+    case '*':
+        value = p.alias()
+    case ',', ']', '}':
+        value, scalar = p.scalarValue(tag, "", true), true
+    default:
+        var plain bool
+        text, plain = p.scalar(-1, true)
+        value, scalar = p.scalarValue(tag, text, plain), true
+    }
+    p.anchor(anchor, value)
+    return value, text, scalar
+}
+
+// plainLine reads the part of a plain scalar on the current line, leaving
+// pos after its last non-blank byte.
+func (p *yamlParser) plainLine(flow bool) string {
+    start, end := p.pos, p.pos
+    for i := p.pos; i < len(p.src); i++ {
+        c := p.src[i]
+        next := byte(0)
+        if i+1 < len(p.src) {
+            next = p.src[i+1]
+        }
+        if isYAMLBreak(c) ||
+            c == ':' && (isYAMLSpace(next) || flow && isYAMLFlowIndicator(next)) ||
+            c == '#' && i > start && isYAMLBlank(p.src[i-1]) ||
+            flow && isYAMLFlowIndicator(c) {
+            break
+        }
+        if !isYAMLBlank(c) {
+            end = i + 1
+        }
+    }
+    p.pos = end
+    return p.src[start:end]
+}
+
+// scalar reads a quoted or plain scalar, reporting whether it was plain.
+// Plain scalars continue on following lines indented more than parent,
+// or in flow collections up to the next indicator.
+func (p *yamlParser) scalar(parent int, flow bool) (string, bool) {
+    switch c := p.at(0); {
+    case c == '"' || c == '\'':
+        return p.quoted(), false
+    case c == '@' || c == '`' || isYAMLFlowIndicator(c):
+        p.errorf("unexpected %q", c)
+    }
+    text := p.plainLine(flow)
+    if text == "" {
+        p.errorf("unexpected %q", p.at(0))
+    }
+    for {
+        s := p.save()
+        breaks := 0
+        for {
+            for isYAMLBlank(p.at(0)) {
+                p.pos++
+            }
+            if !isYAMLBreak(p.at(0)) {
+                break
+            }
+            p.newline()
+            breaks++
+        }
+        c := p.at(0)
+        if breaks == 0 || c == 0 || c == '#' || p.atDocumentMarker() ||
+            !flow && p.col() <= parent ||
+            flow && (isYAMLFlowIndicator(c) || c == ':') {
+            p.restore(s)
+            return text, true
+        }
+        more := p.plainLine(flow)
+        if more == "" {
+            p.restore(s)
+            return text, true
+        }
+        if breaks == 1 {
+            text += " " + more
+        } else {
+            text += strings.Repeat("\n", breaks-1) + more
+        }
+    }
+}
+
+// quoted reads a single or double quoted scalar, folding its line breaks.
+func (p *yamlParser) quoted() string {
+    q := p.src[p.pos]
+    p.pos++
+    var b []byte
+    blanks := 0
+    for {
+        if p.eof() {
+            p.errorf("unterminated quoted scalar")
+        }
+        c := p.src[p.pos]
+        switch {
+        case c == '\'' && q == '\'' && p.at(1) == '\'':
+            b = append(b, '\'')
+            p.pos += 2
+            blanks = 0
+        case c == q:
+            p.pos++
+            return string(b)
+        case isYAMLBreak(c):
+            b = b[:len(b)-blanks]
+            blanks = 0
+            p.newline()
+            breaks := 0
+            for {
+                for isYAMLBlank(p.at(0)) {
+                    p.pos++
+                }
+                if !isYAMLBreak(p.at(0)) {
+                    break
+                }
+                p.newline()
+                breaks++
+            }
+            if breaks == 0 {
+                b = append(b, ' ')
+            }
+            for ; breaks > 0; breaks-- {
+                b = append(b, '\n')
+            }
+        case c == '\\' && q == '"':
+            blanks = 0
+            if isYAMLBreak(p.at(1)) {
+                p.pos++
+                p.newline()
+                for isYAMLBlank(p.at(0)) {
+                    p.pos++
+                }
+                continue
+            }
+            b = p.escape(b)
+        default:
+            if isYAMLBlank(c) {
+                blanks++
+            } else {
+                blanks = 0
+            }
+            b = append(b, c)
+            p.pos++
+        }
+    }
+}
+
+var yamlEscapes = map[byte]string{
+    '0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+    'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+    '/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+    'P': "\u2029",
+}
+
+// escape appends the character of the escape sequence at pos to b.
+func (p *yamlParser) escape(b []byte) []byte {
+    c := p.at(1)
+    if s, ok := yamlEscapes[c]; ok {
+        p.pos += 2
+        return append(b, s...)
+    }
+    n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+    if n == 0 || p.pos+2+n > len(p.src) {
+        p.errorf("invalid escape sequence")
+    }
+    r, err := strconv.ParseUint(p.src[p.pos+2:p.pos+2+n], 16, 32)
+    if err != nil || !utf8.ValidRune(rune(r)) {
+        p.errorf("invalid escape sequence")
+    }
+    p.pos += 2 + n
+    return append(b, string(rune(r))...)
+}
+
+// blockScalar reads a literal or folded block scalar whose lines are
+// indented more than parent.
+func (p *yamlParser) blockScalar(parent int) string {
+    literal := p.src[p.pos] == '|'
+    p.pos++
+    chomp, indent := byte(0), -1
+    for i := 0; i < 2; i++ {
+        switch c := p.at(0); {
+        case c == '-' || c == '+':
+            chomp = c
+            p.pos++
+        case c >= '1' && c <= '9':
+            indent = int(c - '0')
+            if parent >= 0 {
+                indent += parent
+            }
+            p.pos++
+        }
+    }
+    p.skipInline()
+    if !p.atBreak() {
+        p.errorf("unexpected %q after block scalar header", p.at(0))
+    }
+    if !p.eof() {
+        p.newline()
+    }
+    var lines []string
+    for !p.eof() {
+        i := p.pos
+        for i < len(p.src) && p.src[i] == ' ' {
+            i++
+        }
+        n := i - p.pos
+        if i == len(p.src) || isYAMLBreak(p.src[i]) {
+            line := ""
+            if indent >= 0 && n > indent {
+                line = p.src[p.pos+indent : i]
+            }
+            lines = append(lines, line)
+            p.pos = i
+            if !p.eof() {
+                p.newline()
+            }
+            continue
+        }
+        if indent < 0 {
+            if n <= parent {
+                break
+            }
+            indent = n
+        }
+        if n < indent || p.atDocumentMarker() {
+            break
+        }
+        end := i
+        for end < len(p.src) && !isYAMLBreak(p.src[end]) {
+            end++
+        }
+        lines = append(lines, p.src[p.pos+indent:end])
+        p.pos = end
+        if !p.eof() {
+            p.newline()
+        }
+    }
+    last := len(lines) - 1
+    for last >= 0 && lines[last] == "" {
+        last--
+    }
+    trailing := len(lines) - 1 - last
+    var text string
+    if literal {
+        text = strings.Join(lines[:last+1], "\n")
+    } else {
+        text = foldYAMLLines(lines[:last+1])
+    }
+    switch {
+    case chomp == '-':
+    case chomp == '+':
+        if last >= 0 {
+            trailing++
+        }
+        text += strings.Repeat("\n", trailing)
+    case last >= 0:
+        text += "\n"
+    }
+    return text
+}
+
+// foldYAMLLines joins the lines of a folded block scalar. Line breaks
+// between lines of text become spaces, except around more indented lines.
+func foldYAMLLines(lines []string) string {
+    var b strings.Builder
+    empty := 0
+    first, prevMore := true, false
+    for _, line := range lines {
+        if line == "" {
+            empty++
+            continue
+        }
+        more := isYAMLBlank(line[0])
+        switch {
+        case first:
+            b.WriteString(strings.Repeat("\n", empty))
+        case more || prevMore:
+            b.WriteString(strings.Repeat("\n", empty+1))
+        case empty == 0:
+            b.WriteByte(' ')
+        default:
+            b.WriteString(strings.Repeat("\n", empty))
+        }
+        b.WriteString(line)
+        empty, first, prevMore = 0, false, more
+    }
+    return b.String()
+}
+
+// scalarValue converts the text of a scalar into a value as its tag
+// directs, typing untagged plain scalars with the core schema.
+func (p *yamlParser) scalarValue(tag, text string, plain bool) interface{} {
+    switch tag {
+    case "":
+        if plain {
+            return resolveYAMLScalar(text)
+        }
+        return text
+    case "!", "!!str":
+        return text
+    case "!!null":
+        return nil
+    case "!!bool":
+        if v, ok := resolveYAMLScalar(text).(bool); ok {
+            return v
+        }
+    case "!!int":
+        switch v := resolveYAMLScalar(text).(type) {
+        case int64, uint64:
+            return v
+        }
+    case "!!float":
+        switch v := resolveYAMLScalar(text).(type) {
+        case float64:
+            return v
+        case int64:
+            return float64(v)
+        case uint64:
+            return float64(v)
+        }
+    case "!!binary":
+        b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+        if err == nil {
+            return b
+        }
+    default:
+        return p.scalarValue("", text, plain)
+    }
+    p.errorf("%q is not a valid %s value", text, tag)
+    return nil
+}
+
+var (
+    yamlIntPattern   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+    yamlFloatPattern = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// resolveYAMLScalar types a plain scalar with the YAML 1.2 core schema.
+func resolveYAMLScalar(s string) interface{} {
+    switch s {
+    case "", "~", "null", "Null", "NULL":
+        return nil
+    case "true", "True", "TRUE":
+        return true
+    case "false", "False", "FALSE":
+        return false
+    case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+        return math.Inf(1)
+    case "-.inf", "-.Inf", "-.INF":
+        return math.Inf(-1)
+    case ".nan", ".NaN", ".NAN":
+        return math.NaN()
+    }
+    switch {
+    case yamlIntPattern.MatchString(s):
+        return parseYAMLInt(s, s, 10)
+    case strings.HasPrefix(s, "0o") && len(s) > 2:
+        return parseYAMLInt(s, s[2:], 8)
+    case strings.HasPrefix(s, "0x") && len(s) > 2:
+        return parseYAMLInt(s, s[2:], 16)
+    case yamlFloatPattern.MatchString(s):
+        if f, err := strconv.ParseFloat(s, 64); err == nil {
+            return f
+        }
+    }
+    return s
+}
+
+// parseYAMLInt parses digits as an int64, or a uint64 when too large,
+// returning s itself when they are not valid in the base.
+func parseYAMLInt(s, digits string, base int) interface{} {
+    if n, err := strconv.ParseInt(digits, base, 64); err == nil {
+        return n
+    }
+    if n, err := strconv.ParseUint(strings.TrimPrefix(digits, "+"), base, 64); err == nil {
+        return n
+    }
+    if base == 10 {
+        if f, err := strconv.ParseFloat(s, 64); err == nil {
+            return f
+        }
+    }
+    return s
+}
+
+// YAMLOptions controls the YAML emitter.
+type YAMLOptions struct {
+    // Indent is the number of spaces each level of mappings is indented
+    // by. It defaults to 2.
+    Indent int
+}
+
+// EncodeYAML writes value as a YAML document in block style. The keys of
+// mappings are sorted unless they are held in an OrderedJSONObject.
+// Strings that the core schema would read as another type, or that need
+// escapes, are quoted, and multi-line strings are written as literal
+// block scalars. []byte values are written as !!binary. Strings and keys
+// must be valid UTF-8.
+func EncodeYAML(value interface{}, opts YAMLOptions) ([]byte, error) {
+    e := &yamlEmitter{indent: opts.Indent}
+    if e.indent <= 0 {
+        e.indent = 2
+    }
+    if err := e.document(value); err != nil {
+        return nil, err
+    }
+    return e.buf.Bytes(), nil
+}
+
+// WriteYAML writes value to w as a YAML document, as EncodeYAML does.
+func WriteYAML(w io.Writer, value interface{}, opts YAMLOptions) error {
+    b, err := EncodeYAML(value, opts)
+    if err != nil {
+        return err
+    }
+    _, err = w.Write(b)
+    return err
+}
+
+// WriteYAMLStream writes docs to w as a YAML stream, starting each
+// document with "---".
+func WriteYAMLStream(w io.Writer, docs []interface{}, opts YAMLOptions) error {
+    for _, doc := range docs {
+        b, err := EncodeYAML(doc, opts)
+        if err != nil {
+            return err
+        }
+        if _, err := io.WriteString(w, "---\n"); err != nil {
+            return err
+        }
+        if _, err := w.Write(b); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+type yamlEmitter struct {
+    buf    bytes.Buffer
+    indent int
+}
+
+func (e *yamlEmitter) writeIndent(n int) {
+    for i := 0; i < n; i++ {
+        e.buf.WriteByte(' ')
+    }
+}
+
+func nonEmptyJSONObject(value interface{}) (JSONObject, bool) {
+    obj, ok := jsonObjectValue(value)
+    return obj, ok && len(obj) > 0
+}
+
+func nonEmptyJSONArray(value interface{}) (JSONArray, bool) {
+    arr, ok := jsonArrayValue(value)
+    return arr, ok && len(arr) > 0
+}
+
+func (e *yamlEmitter) document(value interface{}) error {
+    if _, ok := nonEmptyJSONObject(value); ok {
+        return e.mapping(value, 0, false)
+    }
+    if arr, ok := nonEmptyJSONArray(value); ok {
+        return e.sequence(arr, 0, false)
+    }
+    return e.scalar(value, e.indent)
+}
+
+// mapping writes the entries of the object value indented by indent
+// spaces, in sorted order unless it is an OrderedJSONObject. When compact
+// is set the indentation of the first line is already written.
+func (e *yamlEmitter) mapping(value interface{}, indent int, compact bool) error {
+    obj, _ := jsonObjectValue(value)
+    for i, k := range orderedJSONObjectKeys(value, obj) {
+        if !utf8.ValidString(k) {
+            return fmt.Errorf("jsonhelper: cannot encode invalid UTF-8 key %q as YAML", k)
+        }
+        if i > 0 || !compact {
+            e.writeIndent(indent)
+        }
+        if yamlPlainSafe(k) && k != "<<" {
+            e.buf.WriteString(k)
+        } else {
+            e.buf.WriteString(strconv.Quote(k))
+        }
+        e.buf.WriteByte(':')
+        var err error
+        if _, ok := nonEmptyJSONObject(obj[k]); ok {
+            e.buf.WriteByte('\n')
+            err = e.mapping(obj[k], indent+e.indent, false)
+        } else if arr, ok := nonEmptyJSONArray(obj[k]); ok {
+            e.buf.WriteByte('\n')
+            err = e.sequence(arr, indent+e.indent, false)
+        } else {
+            e.buf.WriteByte(' ')
+            err = e.scalar(obj[k], indent+e.indent)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// sequence writes the items of arr indented by indent spaces, writing
+// objects and arrays after the "- " of their item.
+func (e *yamlEmitter) sequence(arr JSONArray, indent int, compact bool) error {
+    for i, item := range arr {
+        if i > 0 || !compact {
+            e.writeIndent(indent)
+        }
+        e.buf.WriteString("- ")
+        var err error
+        if _, ok := nonEmptyJSONObject(item); ok {
+            err = e.mapping(item, indent+2, true)
+        } else if items, ok := nonEmptyJSONArray(item); ok {
+            err = e.sequence(items, indent+2, true)
+        } else {
+            err = e.scalar(item, indent+2)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// scalar writes value and a line break. The lines of block scalars are
+// indented by nested spaces.
+func (e *yamlEmitter) scalar(value interface{}, nested int) error {
+    switch v := value.(type) {
+    case nil:
+        e.buf.WriteString("null")
+    case bool:
+        e.buf.WriteString(strconv.FormatBool(v))
+    case string:
+        if !utf8.ValidString(v) {
+            return fmt.Errorf("jsonhelper: cannot encode invalid UTF-8 string %q as YAML", v)
+        }
+        if isYAMLLiteral(v) {
+            e.literal(v, nested)
+        } else if yamlPlainSafe(v) {
+            e.buf.WriteString(v)
+        } else {
+            e.buf.WriteString(strconv.Quote(v))
+        }
+    case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+        fmt.Fprint(&e.buf, v)
+    case float32:
+        e.buf.WriteString(yamlFloat(float64(v), 32))
+    case float64:
+        e.buf.WriteString(yamlFloat(v, 64))
+    case json.Number:
+        e.buf.WriteString(string(v))
+    case []byte:
+        e.buf.WriteString("!!binary ")
+        e.buf.WriteString(base64.StdEncoding.EncodeToString(v))
+    case time.Time:
+        e.buf.WriteString(v.Format(time.RFC3339Nano))
+    case JSONObject, map[string]interface{}:
+        e.buf.WriteString("{}")
+    case *OrderedJSONObject:
+        if v == nil {
+            e.buf.WriteString("null")
+        } else {
+            e.buf.WriteString("{}")
+        }
+    case JSONArray, []interface{}:
+        e.buf.WriteString("[]")
+    default:
+        return fmt.Errorf("jsonhelper: cannot encode %T as YAML", value)
+    }
+    e.buf.WriteByte('\n')
+    return nil
+}
+
+// literal writes s as a literal block scalar, choosing the chomping
+// indicator that keeps its trailing line breaks.
+func (e *yamlEmitter) literal(s string, nested int) {
+    chomp := "-"
+    if strings.HasSuffix(s, "\n") {
+        s, chomp = s[:len(s)-1], ""
+        if strings.HasSuffix(s, "\n") {
+            chomp = "+"
+        }
+    }
+    e.buf.WriteString("|" + chomp)
+    for _, line := range strings.Split(s, "\n") {
+        e.buf.WriteByte('\n')
+        if line != "" {
+            e.writeIndent(nested)
+            e.buf.WriteString(line)
+        }
+    }
+}
+
+func yamlFloat(f float64, bitSize int) string {
+    switch {
+    case math.IsInf(f, 1):
+        return ".inf"
+    case math.IsInf(f, -1):
+        return "-.inf"
+    case math.IsNaN(f):
+        return ".nan"
+    }
+    s := strconv.FormatFloat(f, 'g', -1, bitSize)
+    if !strings.ContainsAny(s, ".e") {
+        s += ".0"
+    }
+    return s
+}
+
+// isYAMLLiteral reports whether s is a multi-line string that can be
+// written as a literal block scalar without an indentation indicator.
+func isYAMLLiteral(s string) bool {
+    if !strings.Contains(s, "\n") || !utf8.ValidString(s) {
+        return false
+    }
+    if text := strings.TrimLeft(s, "\n"); text == "" || isYAMLBlank(text[0]) {
+        return false
+    }
+    for _, r := range s {
+        if r != '\n' && r != '\t' && !unicode.IsPrint(r) {
+            return false
+        }
+    }
+    return true
+}
+
+// yamlPlainSafe reports whether s can be written as a plain scalar that
+// reads back as the same string, also under YAML 1.1 parsers.
+func yamlPlainSafe(s string) bool {
+    if s == "" || strings.TrimSpace(s) != s || !utf8.ValidString(s) {
+        return false
+    }
+    if _, ok := resolveYAMLScalar(s).(string); !ok {
+        return false
+    }
+    switch strings.ToLower(s) {
+    case "y", "n", "yes", "no", "on", "off":
+        return false
+    }
+    if strings.IndexByte("-?:,[]{}#&*!|>'\"%@`", s[0]) >= 0 || strings.HasPrefix(s, "...") {
+        return false
+    }
+    if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+        return false
+    }
+    for _, r := range s {
+        if !unicode.IsPrint(r) {
+            return false
+        }
+    }
+    return true
+}
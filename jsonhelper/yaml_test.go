@@ -0,0 +1,230 @@
+// Copyright 2012 Aalok Shah. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhelper
+
+import (
+    "bytes"
+    "fmt"
+    "math"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestDecodeYAML(t *testing.T) {
+    tests := []struct {
+        name string
+        in   string
+        want interface{}
+    }{
+        {"empty", "", nil},
+        {"comment only", "# nothing\n", nil},
+        {"core schema", "a: 1\nb: -2\nc: 0x1f\nd: 0o17\ne: 1.5e3\nf: true\ng: False\nh: ~\ni: null\nj:\nk: 18446744073709551615\nl: yes\nm: 012\n", JSONObject{
+            "a": int64(1), "b": int64(-2), "c": int64(31), "d": int64(15), "e": 1500.0,
+            "f": true, "g": false, "h": nil, "i": nil, "j": nil,
+            "k": uint64(math.MaxUint64), "l": "yes", "m": int64(12),
+        }},
+        {"infinities", "- .inf\n- -.Inf\n", JSONArray{math.Inf(1), math.Inf(-1)}},
+        {"quoted", "s: 'it''s'\nt: \"tab\\there\\u00e9\"\nu: plain text here\nv: 'multi\n  line'\n", JSONObject{
+            "s": "it's", "t": "tab\there\u00e9", "u": "plain text here", "v": "multi line",
+        }},
+        {"block scalars", "lit: |\n  a\n   b\n\n  c\nfold: >\n  one\n  two\n\n  three\nkeep: |+\n  x\n\nstrip: >-\n  y\n", JSONObject{
+            "lit": "a\n b\n\nc\n", "fold": "one two\nthree\n", "keep": "x\n\n", "strip": "y",
+        }},
+        {"less indented block", "x: |\n a\n  b\n c\n", JSONObject{"x": "a\n b\nc\n"}},
+        {"sequences", "- a\n- - b\n  - c\n- k: v\n  l: w\n-\n- [1, 'two', {x: 3}]\n", JSONArray{
+            "a", JSONArray{"b", "c"}, JSONObject{"k": "v", "l": "w"}, nil,
+            JSONArray{int64(1), "two", JSONObject{"x": int64(3)}},
+        }},
+        {"sequence at key column", "key:\n- x\n- y\n", JSONObject{"key": JSONArray{"x", "y"}}},
+        {"flow", "{a: [1, 2], b: {c: d}}", JSONObject{"a": JSONArray{int64(1), int64(2)}, "b": JSONObject{"c": "d"}}},
+        {"quoted keys", "\"quoted key\": 1\n'single': 2\n", JSONObject{"quoted key": int64(1), "single": int64(2)}},
+        {"anchors", "base: &b {x: 1, y: 2}\nother:\n  <<: *b\n  y: 3\nlist: &l [1, 2]\ncopy: *l\n", JSONObject{
+            "base":  JSONObject{"x": int64(1), "y": int64(2)},
+            "other": JSONObject{"x": int64(1), "y": int64(3)},
+            "list":  JSONArray{int64(1), int64(2)},
+            "copy":  JSONArray{int64(1), int64(2)},
+        }},
+        {"merge sequence", "m:\n  <<: [{a: 1}, {a: 2, b: 2}]\n  c: 3\n", JSONObject{
+            "m": JSONObject{"a": int64(1), "b": int64(2), "c": int64(3)},
+        }},
+        {"tags", "a: !!str 123\nb: !!int '42'\nc: !!float 1\nd: !!bool 'true'\ne: !!null ''\nf: !!binary aGk=\n", JSONObject{
+            "a": "123", "b": int64(42), "c": 1.0, "d": true, "e": nil, "f": []byte("hi"),
+        }},
+        {"timestamps stay strings", "t: 2001-12-14t21:59:43.10-05:00\n", JSONObject{"t": "2001-12-14t21:59:43.10-05:00"}},
+        {"byte order mark", "\ufeffa: 1\n", JSONObject{"a": int64(1)}},
+    }
+    for _, tt := range tests {
+        got, err := DecodeYAML([]byte(tt.in))
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+        } else if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%s: got %#v, want %#v", tt.name, got, tt.want)
+        }
+    }
+    got, err := DecodeYAML([]byte("n: .nan\n"))
+    if f, ok := got.(JSONObject)["n"].(float64); err != nil || !ok || !math.IsNaN(f) {
+        t.Errorf(".nan gave %#v, %v", got, err)
+    }
+}
+
+func TestDecodeYAMLErrors(t *testing.T) {
+    tests := []struct {
+        in   string
+        want string
+    }{
+        {"a: 1\na: 2\n", `line 2 column 5: duplicate mapping key "a"`},
+        {"a: [1, 2\n", "unterminated flow collection"},
+        {"a: 'x\n", "unterminated quoted scalar"},
+        {"a: *nope\n", `unknown anchor "nope"`},
+        {"a: &x [*x]\n", `unknown anchor "x"`},
+        {"- a\nb: c\n", "line 2 column 1: unexpected 'b'"},
+        {"a:\n  b: 1\n c: 2\n", "line 3 column 2: unexpected indentation"},
+        {"a: !!int x\n", `"x" is not a valid !!int value`},
+        {"a: !!bool maybe\n", `"maybe" is not a valid !!bool value`},
+        {"a: !!binary %%\n", `"%%" is not a valid !!binary value`},
+        {"? a\n: b\n", "explicit mapping keys are not supported"},
+        {"a: \"\\q\"\n", "invalid escape sequence"},
+        {"\ta: 1\n", "tabs cannot be used for indentation"},
+        {"a: b: c\n", "mapping cannot start on the line of another mapping key"},
+        {"[a, b]]\n", "unexpected ']' after value"},
+        {"---\na\n---\nb\n", "YAML stream holds 2 documents, not one"},
+        {strings.Repeat("[", maxYAMLDepth+1), "nesting deeper than 10000"},
+    }
+    for _, tt := range tests {
+        _, err := DecodeYAML([]byte(tt.in))
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("DecodeYAML(%.20q) error = %v, want %q", tt.in, err, tt.want)
+        }
+    }
+}
+
+func TestDecodeYAMLAliasLimit(t *testing.T) {
+    // Each anchor holds two aliases of the one before, doubling the nodes
+    // the aliases expand to.
+    var buf bytes.Buffer
+    buf.WriteString("a0: &a0 [x, x]\n")
+    for i := 1; i < 30; i++ {
+        fmt.Fprintf(&buf, "a%d: &a%d [*a%d, *a%d]\n", i, i, i-1, i-1)
+    }
+    _, err := DecodeYAML(buf.Bytes())
+    if err == nil || !strings.Contains(err.Error(), "alias") {
+        t.Errorf("an alias expansion of 2^30 nodes gave error %v", err)
+    }
+}
+
+func TestDecodeYAMLStream(t *testing.T) {
+    docs, err := ReadYAMLStream(strings.NewReader("---\na: &x 1\n...\n---\nb: 2\n---\n- c\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := []interface{}{JSONObject{"a": int64(1)}, JSONObject{"b": int64(2)}, JSONArray{"c"}}
+    if !reflect.DeepEqual(docs, want) {
+        t.Errorf("got %#v, want %#v", docs, want)
+    }
+    if _, err := DecodeYAMLStream([]byte("a: &x 1\n---\nb: *x\n")); err == nil {
+        t.Error("an alias to an anchor of an earlier document succeeded")
+    }
+}
+
+func TestEncodeYAML(t *testing.T) {
+    tests := []struct {
+        value interface{}
+        want  string
+    }{
+        {nil, "null\n"},
+        {true, "true\n"},
+        {"plain", "plain\n"},
+        {"\u00e9t\u00e9", "\u00e9t\u00e9\n"},
+        {"", "\"\"\n"},
+        {"yes", "\"yes\"\n"},
+        {"123", "\"123\"\n"},
+        {"null", "\"null\"\n"},
+        {"- x", "\"- x\"\n"},
+        {"a: b", "\"a: b\"\n"},
+        {"a #b", "\"a #b\"\n"},
+        {"trail:", "\"trail:\"\n"},
+        {" lead", "\" lead\"\n"},
+        {"x\ty", "\"x\\ty\"\n"},
+        {"ctl\x01", "\"ctl\\x01\"\n"},
+        {"line1\nline2", "|-\n  line1\n  line2\n"},
+        {"line1\nline2\n", "|\n  line1\n  line2\n"},
+        {"a\n\n", "|+\n  a\n\n"},
+        {" x\ny", "\" x\\ny\"\n"},
+        {42, "42\n"},
+        {uint64(math.MaxUint64), "18446744073709551615\n"},
+        {1.0, "1.0\n"},
+        {2.5, "2.5\n"},
+        {math.Inf(-1), "-.inf\n"},
+        {math.NaN(), ".nan\n"},
+        {[]byte("hi"), "!!binary aGk=\n"},
+        {JSONObject{}, "{}\n"},
+        {JSONArray{}, "[]\n"},
+        {JSONArray{JSONArray{}, JSONObject{}}, "- []\n- {}\n"},
+        {NewOrderedJSONObject(), "{}\n"},
+        {(*OrderedJSONObject)(nil), "null\n"},
+        {mustParseOrdered(t, `{"a":{},"b":[{}]}`), "a: {}\nb:\n  - {}\n"},
+        {
+            JSONObject{
+                "b":    JSONObject{"c": JSONArray{1, JSONArray{2, 3}, JSONObject{"d": "e", "f": nil}}},
+                "a":    "x",
+                "<<":   1,
+                "y":    2,
+                "text": "l1\nl2",
+            },
+            "\"<<\": 1\na: x\nb:\n  c:\n    - 1\n    - - 2\n      - 3\n    - d: e\n      f: null\ntext: |-\n  l1\n  l2\n\"y\": 2\n",
+        },
+        {mustParseOrdered(t, `{"z":"1","a":["x",{"b":true}]}`), "z: \"1\"\na:\n  - x\n  - b: true\n"},
+    }
+    for _, tt := range tests {
+        got, err := EncodeYAML(tt.value, YAMLOptions{})
+        if err != nil {
+            t.Errorf("EncodeYAML(%#v): %v", tt.value, err)
+        } else if string(got) != tt.want {
+            t.Errorf("EncodeYAML(%#v) = %q, want %q", tt.value, got, tt.want)
+        }
+    }
+    got, _ := EncodeYAML(JSONObject{"a": JSONObject{"b": 1}}, YAMLOptions{Indent: 4})
+    if string(got) != "a:\n    b: 1\n" {
+        t.Errorf("Indent 4 gave %q", got)
+    }
+}
+
+func TestEncodeYAMLErrors(t *testing.T) {
+    for _, value := range []interface{}{
+        struct{}{},
+        JSONArray{make(chan int)},
+        "bad\xff",
+        JSONObject{"bad\xff": 1},
+        JSONObject{"a": JSONArray{"bad\xff"}},
+    } {
+        if _, err := EncodeYAML(value, YAMLOptions{}); err == nil {
+            t.Errorf("EncodeYAML(%#v) succeeded", value)
+        }
+    }
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+    docs := []interface{}{
+        mustParse(t, `{"s":["yes","no","~","1e3","0x1",": x","#c","a\nb\n","\ttab"," sp","\u00e9"],"n":[1,-1.5,0],"o":{"":null,"k y":{},"z":[]}}`),
+        JSONArray{int64(1), "two", JSONObject{"x": false}},
+    }
+    var buf bytes.Buffer
+    if err := WriteYAMLStream(&buf, docs, YAMLOptions{}); err != nil {
+        t.Fatal(err)
+    }
+    got, err := DecodeYAMLStream(buf.Bytes())
+    if err != nil {
+        t.Fatalf("%v in\n%s", err, buf.String())
+    }
+    if len(got) != len(docs) {
+        t.Fatalf("got %d documents, want %d", len(got), len(docs))
+    }
+    for i := range docs {
+        if !EqualJSONValues(got[i], docs[i]) {
+            t.Errorf("document %d: round trip gave %v, want %v", i, got[i], docs[i])
+        }
+    }
+}